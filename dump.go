@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/executor"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+	"github.com/Mohammad-y-abbass/moDB/internal/parser"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// dumpCommand implements "modb dump [-data dir] [-batch n] [-o file] db":
+// it writes a SQL script that recreates the database. It returns the exit
+// status.
+func dumpCommand(args []string) int {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	dataDir := flags.String("data", "./data", "the server's data directory")
+	batch := flags.Int("batch", 500, "the most rows in one INSERT")
+	output := flags.String("o", "", "write the script to this file instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: modb dump [-data dir] [-batch n] [-o file] db")
+		fmt.Fprintln(flags.Output(), "Writes a SQL script that recreates the database; replay it with modb restore.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := dump(*dataDir, flags.Arg(0), *batch, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func dump(dataDir, db string, batch int, output string) error {
+	if _, err := os.Stat(dataDir); err != nil {
+		return err
+	}
+	exec := executor.New(storage.NewEngine(dataDir))
+	if err := exec.Engine.UseDatabase(db); err != nil {
+		return err
+	}
+	if err := exec.ReloadTables(); err != nil {
+		return err
+	}
+
+	if output == "" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := exec.Dump(w, batch); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = exec.Dump(w, batch)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// restoreCommand implements "modb restore [-data dir] [file ...]": it runs
+// the SQL scripts, or standard input, against the data directory, as a
+// dump made by modb dump. It stops at the first failing statement. The
+// server should not be running on the same directory meanwhile.
+func restoreCommand(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dataDir := flags.String("data", "./data", "the server's data directory")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: modb restore [-data dir] [file ...]")
+		fmt.Fprintln(flags.Output(), "Runs SQL scripts, such as those written by modb dump, with the server stopped.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	exec := executor.New(storage.NewEngine(*dataDir))
+	if flags.NArg() == 0 {
+		if err := restore(exec, os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err == nil {
+			err = restore(exec, f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// restore parses a whole script before running any of it, so a script with
+// syntax errors changes nothing.
+func restore(exec *executor.Executor, r io.Reader) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return errors.New(p.Diagnostics())
+	}
+	plan := planner.New(exec)
+	for i, stmt := range program.Statements {
+		node, err := plan.GeneratePlan(stmt)
+		if err == nil {
+			_, err = exec.Execute(node)
+		}
+		if err != nil {
+			return fmt.Errorf("statement %d (%.60s): %v", i+1, stmt.String(), err)
+		}
+	}
+	return nil
+}
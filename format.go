@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+	"github.com/Mohammad-y-abbass/moDB/internal/parser"
+)
+
+// formatCommand implements "modb fmt [-w] [file ...]": it prints each SQL
+// file, or standard input, in canonical form. It returns the exit status.
+func formatCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result back to each file instead of printing it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: modb fmt [-w] [file ...]")
+		fmt.Fprintln(flags.Output(), "Reformats SQL one statement at a time. Comments are not kept, so -w refuses files that have them.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			var out string
+			if out, _, err = formatSQL(string(src)); err == nil {
+				_, err = os.Stdout.WriteString(out)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		if err := formatFile(path, *write); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func formatFile(path string, write bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, comments, err := formatSQL(string(src))
+	if err != nil {
+		return err
+	}
+	if write {
+		if comments > 0 {
+			return errors.New("not rewritten: it has comments, which formatting would delete")
+		}
+		return os.WriteFile(path, []byte(out), 0644)
+	}
+	_, err = os.Stdout.WriteString(out)
+	return err
+}
+
+// formatSQL renders every statement of src with ast.Format, separated by
+// blank lines, and counts the comments of src, which the result drops. A
+// script with syntax errors is left alone and its diagnostics returned
+// instead.
+func formatSQL(src string) (out string, comments int, err error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return "", 0, errors.New(p.Diagnostics())
+	}
+	var sb strings.Builder
+	for i, stmt := range program.Statements {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(ast.Format(stmt) + ";\n")
+	}
+	return sb.String(), l.Comments, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatSQL(t *testing.T) {
+	src := "select a,b from t where x=1 and y='it''s';   insert into t values (1,'a'),(2,'b');"
+	want := "SELECT a, b\nFROM t\nWHERE x = 1 AND y = 'it''s';\n\nINSERT INTO t\nVALUES (1, 'a'), (2, 'b');\n"
+	out, comments, err := formatSQL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out)
+	}
+	if comments != 0 {
+		t.Errorf("expected no comments, got %d", comments)
+	}
+	// Formatting is idempotent.
+	if again, _, err := formatSQL(out); err != nil || again != out {
+		t.Errorf("formatting the output again changed it:\n%s", again)
+	}
+
+	if _, comments, _ := formatSQL("-- first\nSELECT a /* the key */ FROM t;"); comments != 2 {
+		t.Errorf("expected 2 comments, got %d", comments)
+	}
+	if _, _, err := formatSQL("SELECT FROM;"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
+
+func TestFormatFileKeepsComments(t *testing.T) {
+	dir := t.TempDir()
+	commented := filepath.Join(dir, "commented.sql")
+	src := "-- keep me\nselect a from t;\n"
+	if err := os.WriteFile(commented, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := formatFile(commented, true)
+	if err == nil || !strings.Contains(err.Error(), "comments") {
+		t.Errorf("expected -w to refuse a file with comments, got %v", err)
+	}
+	if got, _ := os.ReadFile(commented); string(got) != src {
+		t.Errorf("file with comments was rewritten:\n%s", got)
+	}
+
+	plain := filepath.Join(dir, "plain.sql")
+	if err := os.WriteFile(plain, []byte("select a from t;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := formatFile(plain, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(plain); string(got) != "SELECT a\nFROM t;\n" {
+		t.Errorf("unexpected rewrite:\n%s", got)
+	}
+}
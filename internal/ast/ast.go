@@ -29,7 +29,7 @@ func (p *Program) TokenLiteral() string {
 func (p *Program) String() string {
 	out := ""
 	for _, s := range p.Statements {
-		out += s.String() + "\n"
+		out += s.String() + ";\n"
 	}
 	return out
 }
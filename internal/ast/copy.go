@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// CopyStatement is COPY table [(cols)] FROM 'file', which loads the rows
+// of a CSV or JSON Lines file into Table, or, with To set, COPY table
+// [(cols)] TO 'file' or COPY (query) TO 'file', which writes the table or
+// the result of Query to a file. Options is the optional WITH list.
+type CopyStatement struct {
+	Token   lexer.Token // the 'COPY' token
+	Table   string
+	Columns []string
+	Query   Query // COPY (query) TO; Table is empty
+	To      bool
+	File    string
+	Options []CopyOption
+}
+
+// CopyOption is one entry of the WITH list of a COPY. Name is upper-cased.
+// Value is the FORMAT name (csv, jsonl or columnar) in lower case, "true"
+// or "false" for HEADER, and the text of the string given to DELIMITER or
+// NULL.
+type CopyOption struct {
+	Name  string
+	Value string
+}
+
+func (cs *CopyStatement) StatementNode() {}
+
+func (cs *CopyStatement) TokenLiteral() string {
+	return cs.Token.Value
+}
+
+func (cs *CopyStatement) String() string {
+	return strings.Join(cs.clauses(), " ")
+}
+
+func (cs *CopyStatement) clauses() []string {
+	var source string
+	if cs.Query != nil {
+		source = "COPY (" + cs.Query.String() + ")"
+	} else {
+		source = "COPY " + QuoteIdent(cs.Table)
+		if len(cs.Columns) > 0 {
+			source += " (" + quoteIdents(cs.Columns) + ")"
+		}
+	}
+	direction := "FROM "
+	if cs.To {
+		direction = "TO "
+	}
+	clauses := []string{source, direction + (&StringLiteral{Value: cs.File}).String()}
+	if len(cs.Options) > 0 {
+		options := make([]string, len(cs.Options))
+		for i, opt := range cs.Options {
+			options[i] = opt.String()
+		}
+		clauses = append(clauses, "WITH ("+strings.Join(options, ", ")+")")
+	}
+	return clauses
+}
+
+func (co CopyOption) String() string {
+	switch co.Name {
+	case "FORMAT", "HEADER":
+		return co.Name + " " + co.Value
+	}
+	return co.Name + " " + (&StringLiteral{Value: co.Value}).String()
+}
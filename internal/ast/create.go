@@ -1,6 +1,11 @@
 package ast
 
-import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
 
 // ForeignKeyRef describes a REFERENCES parent_table(parent_col) constraint.
 type ForeignKeyRef struct {
@@ -10,8 +15,8 @@ type ForeignKeyRef struct {
 
 type ColumnDefinition struct {
 	Name         string
-	DataType     string
-	Size         int // e.g., 255 for TEXT(255)
+	DataType     string // upper-cased, as written: INT, INTEGER, TEXT or VARCHAR
+	Size         int    // e.g., 255 for TEXT(255)
 	IsNullable   bool
 	IsUnique     bool
 	IsPrimaryKey bool
@@ -31,5 +36,32 @@ func (cs *CreateTableStatement) TokenLiteral() string {
 }
 
 func (cs *CreateTableStatement) String() string {
-	return "CREATE TABLE statement"
+	cols := make([]string, len(cs.Columns))
+	for i, col := range cs.Columns {
+		cols[i] = col.String()
+	}
+	return "CREATE TABLE " + QuoteIdent(cs.Table) + " (" + strings.Join(cols, ", ") + ")"
+}
+
+// String renders the column as written in CREATE TABLE. PRIMARY KEY
+// already implies NOT NULL and UNIQUE, so those are only written without it.
+func (cd ColumnDefinition) String() string {
+	s := QuoteIdent(cd.Name) + " " + cd.DataType
+	if cd.Size > 0 {
+		s += "(" + strconv.Itoa(cd.Size) + ")"
+	}
+	if cd.IsPrimaryKey {
+		s += " PRIMARY KEY"
+	} else {
+		if !cd.IsNullable {
+			s += " NOT NULL"
+		}
+		if cd.IsUnique {
+			s += " UNIQUE"
+		}
+	}
+	if cd.References != nil {
+		s += " REFERENCES " + QuoteIdent(cd.References.Table) + "(" + QuoteIdent(cd.References.Column) + ")"
+	}
+	return s
 }
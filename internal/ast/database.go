@@ -14,7 +14,7 @@ func (cs *CreateDatabaseStatement) TokenLiteral() string {
 }
 
 func (cs *CreateDatabaseStatement) String() string {
-	return "CREATE DATABASE " + cs.DatabaseName
+	return "CREATE DATABASE " + QuoteIdent(cs.DatabaseName)
 }
 
 type UseDatabaseStatement struct {
@@ -29,5 +29,5 @@ func (us *UseDatabaseStatement) TokenLiteral() string {
 }
 
 func (us *UseDatabaseStatement) String() string {
-	return "USE " + us.DatabaseName
+	return "USE " + QuoteIdent(us.DatabaseName)
 }
@@ -1,11 +1,18 @@
 package ast
 
-import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
 
 type DeleteStatement struct {
 	Token lexer.Token
 	Table string
+	Using *FromClause // nil when only the table is read
 	Where *WhereClause
+	// Returning is the RETURNING list, evaluated against the affected rows.
+	Returning []Expression
 }
 
 func (ds *DeleteStatement) StatementNode() {}
@@ -15,5 +22,18 @@ func (ds *DeleteStatement) TokenLiteral() string {
 }
 
 func (ds *DeleteStatement) String() string {
-	return "DELETE statement"
+	return strings.Join(ds.clauses(), " ")
+}
+
+func (ds *DeleteStatement) clauses() []string {
+	clauses := []string{"DELETE FROM " + QuoteIdent(ds.Table)}
+	if ds.Using != nil {
+		using := fromClauses(ds.Using.Table, ds.Using.Alias, ds.Using.Joins)
+		using[0] = "USING " + using[0]
+		clauses = append(clauses, using...)
+	}
+	if ds.Where != nil {
+		clauses = append(clauses, ds.Where.String())
+	}
+	return append(clauses, returningClause(ds.Returning)...)
 }
@@ -0,0 +1,29 @@
+package ast
+
+import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+
+// ExplainStatement is EXPLAIN [ANALYZE] statement. It shows the plan of a
+// query, INSERT, UPDATE or DELETE; with Analyze the statement is also run,
+// changes included, and the plan reports what each step actually did.
+type ExplainStatement struct {
+	Token     lexer.Token // the 'EXPLAIN' token
+	Analyze   bool
+	Statement Statement
+}
+
+func (es *ExplainStatement) StatementNode() {}
+
+func (es *ExplainStatement) TokenLiteral() string {
+	return es.Token.Value
+}
+
+func (es *ExplainStatement) String() string {
+	return es.keyword() + " " + es.Statement.String()
+}
+
+func (es *ExplainStatement) keyword() string {
+	if es.Analyze {
+		return "EXPLAIN ANALYZE"
+	}
+	return "EXPLAIN"
+}
@@ -0,0 +1,286 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// Operator precedences, lowest to highest. They drive both the parser and
+// the parenthesisation done by String().
+const (
+	LOWEST int = iota
+	OR_PREC
+	AND_PREC
+	NOT_PREC
+	COMPARE_PREC
+	CONCAT_PREC
+	SUM_PREC
+	PRODUCT_PREC
+	PREFIX_PREC
+)
+
+// Precedence returns the binding power of a binary operator.
+func Precedence(op string) int {
+	switch op {
+	case "OR":
+		return OR_PREC
+	case "AND":
+		return AND_PREC
+	case "=", "!=", "<", ">", "<=", ">=", "IN", "IS":
+		return COMPARE_PREC
+	case "||":
+		return CONCAT_PREC
+	case "+", "-":
+		return SUM_PREC
+	case "*", "/", "%":
+		return PRODUCT_PREC
+	}
+	return LOWEST
+}
+
+type IntegerLiteral struct {
+	Token lexer.Token
+	Value int64
+}
+
+func (il *IntegerLiteral) ExpressionNode()      {}
+func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Value }
+func (il *IntegerLiteral) String() string       { return strconv.FormatInt(il.Value, 10) }
+
+// FloatLiteral is a number written with a fraction, such as 2.5.
+type FloatLiteral struct {
+	Token lexer.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) ExpressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Value }
+func (fl *FloatLiteral) String() string {
+	s := strconv.FormatFloat(fl.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0" // still a FLOAT when parsed back
+	}
+	return s
+}
+
+type StringLiteral struct {
+	Token lexer.Token
+	Value string
+}
+
+func (sl *StringLiteral) ExpressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Value }
+func (sl *StringLiteral) String() string {
+	return "'" + strings.ReplaceAll(sl.Value, "'", "''") + "'"
+}
+
+type NullLiteral struct {
+	Token lexer.Token
+}
+
+func (nl *NullLiteral) ExpressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Value }
+func (nl *NullLiteral) String() string       { return "NULL" }
+
+type BooleanLiteral struct {
+	Token lexer.Token
+	Value bool
+}
+
+func (bl *BooleanLiteral) ExpressionNode()      {}
+func (bl *BooleanLiteral) TokenLiteral() string { return bl.Token.Value }
+func (bl *BooleanLiteral) String() string {
+	if bl.Value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Star is the '*' in SELECT * and COUNT(*).
+type Star struct {
+	Token lexer.Token
+}
+
+func (s *Star) ExpressionNode()      {}
+func (s *Star) TokenLiteral() string { return s.Token.Value }
+func (s *Star) String() string       { return "*" }
+
+// Parameter is a bind parameter of a prepared statement. A ? is numbered
+// by its position in the statement, so it prints as $n like a numbered one.
+type Parameter struct {
+	Token lexer.Token
+	Index int // 1-based
+}
+
+func (pe *Parameter) ExpressionNode()      {}
+func (pe *Parameter) TokenLiteral() string { return pe.Token.Value }
+func (pe *Parameter) String() string       { return "$" + strconv.Itoa(pe.Index) }
+
+// PrefixExpression is a unary operator applied to one operand, e.g. NOT x
+// or -x.
+type PrefixExpression struct {
+	Token    lexer.Token
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) ExpressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Value }
+func (pe *PrefixExpression) String() string {
+	if pe.Operator == "NOT" {
+		return "NOT " + wrap(pe.Right, NOT_PREC)
+	}
+	right := wrap(pe.Right, PREFIX_PREC)
+	if strings.HasPrefix(right, pe.Operator) {
+		// Keep "- -1" from reading as "--1".
+		return pe.Operator + " " + right
+	}
+	return pe.Operator + right
+}
+
+// InfixExpression is a binary operator such as =, AND or OR.
+type InfixExpression struct {
+	Token    lexer.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) ExpressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Value }
+func (ie *InfixExpression) String() string {
+	prec := Precedence(ie.Operator)
+	// Operators are left-associative, so an equal-precedence right operand
+	// needs parentheses to keep its grouping.
+	return wrap(ie.Left, prec) + " " + ie.Operator + " " + wrap(ie.Right, prec+1)
+}
+
+// IsNullExpression is x IS NULL or x IS NOT NULL. Unlike a comparison with
+// NULL it is never NULL itself.
+type IsNullExpression struct {
+	Token lexer.Token // the IS token
+	Expr  Expression
+	Not   bool
+}
+
+func (in *IsNullExpression) ExpressionNode()      {}
+func (in *IsNullExpression) TokenLiteral() string { return in.Token.Value }
+func (in *IsNullExpression) String() string {
+	if in.Not {
+		return wrap(in.Expr, COMPARE_PREC+1) + " IS NOT NULL"
+	}
+	return wrap(in.Expr, COMPARE_PREC+1) + " IS NULL"
+}
+
+// FunctionCall is a call such as COUNT(*), SUM(DISTINCT price) or
+// STRING_AGG(name, ','). Name is stored upper-cased. A call with an OVER
+// clause is a window function.
+type FunctionCall struct {
+	Token    lexer.Token
+	Name     string
+	Distinct bool
+	Args     []Expression
+	Over     *WindowSpec // nil unless a window function
+}
+
+func (fc *FunctionCall) ExpressionNode()      {}
+func (fc *FunctionCall) TokenLiteral() string { return fc.Token.Value }
+func (fc *FunctionCall) String() string {
+	args := make([]string, len(fc.Args))
+	for i, a := range fc.Args {
+		args[i] = a.String()
+	}
+	prefix := ""
+	if fc.Distinct {
+		prefix = "DISTINCT "
+	}
+	s := fc.Name + "(" + prefix + strings.Join(args, ", ") + ")"
+	if fc.Over != nil {
+		s += " OVER " + fc.Over.String()
+	}
+	return s
+}
+
+// CastExpression converts a value to another type: CAST(expr AS type).
+// Type holds the upper-cased type name as written.
+type CastExpression struct {
+	Token lexer.Token
+	Expr  Expression
+	Type  string
+}
+
+func (ce *CastExpression) ExpressionNode()      {}
+func (ce *CastExpression) TokenLiteral() string { return ce.Token.Value }
+func (ce *CastExpression) String() string {
+	return "CAST(" + ce.Expr.String() + " AS " + ce.Type + ")"
+}
+
+// CaseExpression is CASE [operand] WHEN ... THEN ... [ELSE ...] END. With
+// an Operand (a simple CASE) each WHEN holds a value compared with it;
+// without one (a searched CASE) each WHEN holds a condition. Else is nil
+// when omitted, which yields NULL.
+type CaseExpression struct {
+	Token   lexer.Token // the CASE token
+	Operand Expression
+	Whens   []WhenClause
+	Else    Expression
+}
+
+// WhenClause is one WHEN ... THEN ... branch of a CASE expression.
+type WhenClause struct {
+	Condition Expression
+	Result    Expression
+}
+
+func (ce *CaseExpression) ExpressionNode()      {}
+func (ce *CaseExpression) TokenLiteral() string { return ce.Token.Value }
+func (ce *CaseExpression) String() string {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	if ce.Operand != nil {
+		sb.WriteString(" " + ce.Operand.String())
+	}
+	for _, w := range ce.Whens {
+		sb.WriteString(" WHEN " + w.Condition.String() + " THEN " + w.Result.String())
+	}
+	if ce.Else != nil {
+		sb.WriteString(" ELSE " + ce.Else.String())
+	}
+	sb.WriteString(" END")
+	return sb.String()
+}
+
+// AliasExpression names a select list item: expr AS alias. The alias
+// becomes the result column header.
+type AliasExpression struct {
+	Token lexer.Token // the AS token, or the alias itself when AS is omitted
+	Expr  Expression
+	Alias string
+}
+
+func (ae *AliasExpression) ExpressionNode()      {}
+func (ae *AliasExpression) TokenLiteral() string { return ae.Token.Value }
+func (ae *AliasExpression) String() string {
+	return ae.Expr.String() + " AS " + QuoteIdent(ae.Alias)
+}
+
+// Unalias returns the expression an alias names, or expr itself.
+func Unalias(expr Expression) Expression {
+	if ae, ok := expr.(*AliasExpression); ok {
+		return ae.Expr
+	}
+	return expr
+}
+
+// wrap renders expr, parenthesised if it binds looser than prec.
+func wrap(expr Expression, prec int) string {
+	if ie, ok := expr.(*InfixExpression); ok && Precedence(ie.Operator) < prec {
+		return "(" + ie.String() + ")"
+	}
+	if _, ok := expr.(*IsNullExpression); ok && COMPARE_PREC < prec {
+		return "(" + expr.String() + ")"
+	}
+	return expr.String()
+}
@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// clauser is a statement that renders as a sequence of clauses. String()
+// joins them with spaces; Format puts each on its own line.
+type clauser interface {
+	clauses() []string
+}
+
+// Format renders stmt as canonical SQL laid out for reading: each clause of
+// a query or DML statement starts a new line, and each column of a CREATE
+// TABLE gets its own line. It parses back to the same tree as String().
+func Format(stmt Statement) string {
+	switch s := stmt.(type) {
+	case *CreateTableStatement:
+		cols := make([]string, len(s.Columns))
+		for i, col := range s.Columns {
+			cols[i] = "  " + col.String()
+		}
+		return "CREATE TABLE " + QuoteIdent(s.Table) + " (\n" + strings.Join(cols, ",\n") + "\n)"
+	case *PrepareStatement:
+		return "PREPARE " + QuoteIdent(s.Name) + " AS\n" + Format(s.Statement)
+	case *ExplainStatement:
+		return s.keyword() + "\n" + Format(s.Statement)
+	case clauser:
+		return strings.Join(s.clauses(), "\n")
+	}
+	return stmt.String()
+}
+
+// QuoteIdent renders a possibly qualified name so that it reads back as the
+// same identifier: parts that are keywords, or that hold characters a bare
+// identifier cannot, are double-quoted.
+func QuoteIdent(name string) string {
+	if isBareIdent(name) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if !isBareIdent(part) {
+			parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// isBareIdent reports whether name lexes, unquoted, as exactly one
+// identifier with the same text.
+func isBareIdent(name string) bool {
+	l := lexer.New(name)
+	tok := l.NextToken()
+	return tok.Type == lexer.IDENTIFIER && tok.Value == name && l.NextToken().Type == lexer.EOF_TOKEN
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
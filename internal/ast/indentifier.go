@@ -7,6 +7,6 @@ type Identifier struct {
 	Value string
 }
 
-func (i *Identifier) expressionNode()      {}
+func (i *Identifier) ExpressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Value }
-func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) String() string       { return QuoteIdent(i.Value) }
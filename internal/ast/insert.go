@@ -1,12 +1,42 @@
 package ast
 
-import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+import (
+	"strconv"
+	"strings"
 
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// InsertStatement adds the rows of a VALUES list, or the result of a query,
+// to Table. Exactly one of Rows and Query is set.
 type InsertStatement struct {
 	Token   lexer.Token
 	Table   string
 	Columns []string
-	Values  []string
+	Rows    [][]string // VALUES (...), (...), ...
+	Query   Query      // INSERT ... SELECT
+	// Params marks the VALUES entries that are bind parameters: Params[i][j]
+	// is the parameter number of Rows[i][j], or 0 for a literal. It is nil
+	// when no row has a parameter, and Params[i] when row i has none.
+	Params [][]int
+	// Nulls marks the VALUES entries that are the NULL keyword, as opposed
+	// to text such as 'NULL', the same way.
+	Nulls [][]bool
+	// OnConflict, when set, says what to do with rows that would violate a
+	// UNIQUE or PRIMARY KEY column.
+	OnConflict *OnConflictClause
+	// Returning is the RETURNING list, evaluated against the written rows.
+	Returning []Expression
+}
+
+// OnConflictClause is ON CONFLICT [(cols)] DO NOTHING, or DO UPDATE SET
+// with Sets, whose expressions read the existing row's columns and the
+// proposed row as EXCLUDED.col.
+type OnConflictClause struct {
+	Token    lexer.Token // the ON token
+	Columns  []string    // the conflict target; empty means any unique column
+	DoUpdate bool
+	Sets     []SetClause
 }
 
 func (is *InsertStatement) StatementNode() {}
@@ -16,5 +46,81 @@ func (is *InsertStatement) TokenLiteral() string {
 }
 
 func (is *InsertStatement) String() string {
-	return "INSERT statement"
+	return strings.Join(is.clauses(), " ")
+}
+
+func (is *InsertStatement) clauses() []string {
+	insert := "INSERT INTO " + QuoteIdent(is.Table)
+	if len(is.Columns) > 0 {
+		insert += " (" + quoteIdents(is.Columns) + ")"
+	}
+	clauses := []string{insert}
+	if is.Query != nil {
+		clauses = append(clauses, is.Query.clauses()...)
+	} else {
+		rows := make([]string, len(is.Rows))
+		for i, row := range is.Rows {
+			values := make([]string, len(row))
+			for j, v := range row {
+				if is.Params != nil && is.Params[i] != nil && is.Params[i][j] != 0 {
+					values[j] = "$" + strconv.Itoa(is.Params[i][j])
+				} else if is.Nulls != nil && is.Nulls[i] != nil && is.Nulls[i][j] {
+					values[j] = "NULL"
+				} else {
+					values[j] = valueLiteral(v)
+				}
+			}
+			rows[i] = "(" + strings.Join(values, ", ") + ")"
+		}
+		clauses = append(clauses, "VALUES "+strings.Join(rows, ", "))
+	}
+	if is.OnConflict != nil {
+		clauses = append(clauses, is.OnConflict.String())
+	}
+	return append(clauses, returningClause(is.Returning)...)
+}
+
+// valueLiteral renders a VALUES entry. Entries keep only their text, so an
+// number is written bare and anything else as a string literal; both read
+// back as the same text.
+func valueLiteral(v string) string {
+	digits := strings.TrimPrefix(v, "-")
+	if whole, frac, ok := strings.Cut(digits, "."); ok {
+		// A number with a fraction must have digits on both sides.
+		if frac == "" || strings.Trim(frac, "0123456789") != "" {
+			digits = ""
+		} else {
+			digits = whole
+		}
+	}
+	if digits != "" && strings.Trim(digits, "0123456789") == "" {
+		return v
+	}
+	return (&StringLiteral{Value: v}).String()
+}
+
+// returningClause renders a RETURNING list, if there is one.
+func returningClause(returning []Expression) []string {
+	if len(returning) == 0 {
+		return nil
+	}
+	return []string{"RETURNING " + joinExpressions(returning)}
+}
+
+func (c *OnConflictClause) String() string {
+	var b strings.Builder
+	b.WriteString("ON CONFLICT")
+	if len(c.Columns) > 0 {
+		b.WriteString(" (" + quoteIdents(c.Columns) + ")")
+	}
+	if !c.DoUpdate {
+		b.WriteString(" DO NOTHING")
+		return b.String()
+	}
+	sets := make([]string, len(c.Sets))
+	for i, set := range c.Sets {
+		sets[i] = set.String()
+	}
+	b.WriteString(" DO UPDATE SET " + strings.Join(sets, ", "))
+	return b.String()
 }
@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// PrepareStatement is PREPARE name AS statement. Params is the number of
+// bind parameters the statement takes: the highest $n it uses.
+type PrepareStatement struct {
+	Token     lexer.Token // the 'PREPARE' token
+	Name      string
+	Statement Statement
+	Params    int
+}
+
+func (ps *PrepareStatement) StatementNode() {}
+
+func (ps *PrepareStatement) TokenLiteral() string {
+	return ps.Token.Value
+}
+
+func (ps *PrepareStatement) String() string {
+	return "PREPARE " + QuoteIdent(ps.Name) + " AS " + ps.Statement.String()
+}
+
+// ExecuteStatement is EXECUTE name(args), which runs a prepared statement
+// with Args bound to its parameters in order.
+type ExecuteStatement struct {
+	Token lexer.Token // the 'EXECUTE' token
+	Name  string
+	Args  []Expression
+}
+
+func (es *ExecuteStatement) StatementNode() {}
+
+func (es *ExecuteStatement) TokenLiteral() string {
+	return es.Token.Value
+}
+
+func (es *ExecuteStatement) String() string {
+	if len(es.Args) == 0 {
+		return "EXECUTE " + QuoteIdent(es.Name)
+	}
+	args := make([]string, len(es.Args))
+	for i, a := range es.Args {
+		args[i] = a.String()
+	}
+	return "EXECUTE " + QuoteIdent(es.Name) + "(" + strings.Join(args, ", ") + ")"
+}
+
+// DeallocateStatement is DEALLOCATE name, which drops a prepared statement.
+type DeallocateStatement struct {
+	Token lexer.Token // the 'DEALLOCATE' token
+	Name  string
+}
+
+func (ds *DeallocateStatement) StatementNode() {}
+
+func (ds *DeallocateStatement) TokenLiteral() string {
+	return ds.Token.Value
+}
+
+func (ds *DeallocateStatement) String() string {
+	return "DEALLOCATE " + QuoteIdent(ds.Name)
+}
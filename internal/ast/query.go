@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// Query is a statement that returns rows: a SELECT, or several combined by
+// set operations.
+type Query interface {
+	Statement
+	queryNode()
+	clauses() []string
+}
+
+func (ss *SelectStatement) queryNode() {}
+func (so *SetOperation) queryNode()    {}
+
+// SetOperator is the way a SetOperation combines its two queries.
+type SetOperator int
+
+const (
+	Union SetOperator = iota
+	Intersect
+	Except
+)
+
+func (o SetOperator) String() string {
+	switch o {
+	case Intersect:
+		return "INTERSECT"
+	case Except:
+		return "EXCEPT"
+	}
+	return "UNION"
+}
+
+// precedence ranks o for grouping; INTERSECT binds tighter than UNION and
+// EXCEPT.
+func (o SetOperator) precedence() int {
+	if o == Intersect {
+		return 2
+	}
+	return 1
+}
+
+// SetOperation combines the rows of two queries. Without All, the result
+// has no duplicate rows. ORDER BY, LIMIT and OFFSET written after the last
+// SELECT belong to the SetOperation and apply to the combined rows.
+// INTERSECT binds tighter than UNION and EXCEPT, which group left to right.
+type SetOperation struct {
+	Token   lexer.Token // the UNION, INTERSECT or EXCEPT token
+	With    *WithClause // nil without a WITH clause
+	Op      SetOperator
+	All     bool
+	Left    Query
+	Right   Query
+	OrderBy []OrderByItem
+	Limit   Expression // nil without LIMIT
+	Offset  Expression // nil without OFFSET
+}
+
+func (so *SetOperation) StatementNode() {}
+
+func (so *SetOperation) TokenLiteral() string {
+	return so.Token.Value
+}
+
+func (so *SetOperation) String() string {
+	return strings.Join(so.clauses(), " ")
+}
+
+func (so *SetOperation) clauses() []string {
+	var clauses []string
+	if so.With != nil {
+		clauses = append(clauses, so.With.String())
+	}
+	op := so.Op.String()
+	if so.All {
+		op += " ALL"
+	}
+	clauses = append(clauses, so.operand(so.Left, false)...)
+	clauses = append(clauses, op)
+	clauses = append(clauses, so.operand(so.Right, true)...)
+	return append(clauses, orderAndLimit(so.OrderBy, so.Limit, so.Offset)...)
+}
+
+// operand renders q as the left or right operand of so, in parentheses
+// when it has clauses of its own or would otherwise group differently.
+func (so *SetOperation) operand(q Query, right bool) []string {
+	parens := false
+	switch n := q.(type) {
+	case *SelectStatement:
+		parens = n.With != nil || len(n.OrderBy) > 0 || n.Limit != nil || n.Offset != nil
+	case *SetOperation:
+		parens = n.With != nil || len(n.OrderBy) > 0 || n.Limit != nil || n.Offset != nil ||
+			n.Op.precedence() < so.Op.precedence() || (right && n.Op.precedence() == so.Op.precedence())
+	}
+	if !parens {
+		return q.clauses()
+	}
+	return []string{"(" + strings.Join(q.clauses(), " ") + ")"}
+}
+
+// orderAndLimit renders the ORDER BY, LIMIT and OFFSET clauses of a query,
+// omitting those it does not have.
+func orderAndLimit(orderBy []OrderByItem, limit, offset Expression) []string {
+	var clauses []string
+	if len(orderBy) > 0 {
+		items := make([]string, len(orderBy))
+		for i, item := range orderBy {
+			items[i] = item.String()
+		}
+		clauses = append(clauses, "ORDER BY "+strings.Join(items, ", "))
+	}
+	if limit != nil {
+		clauses = append(clauses, "LIMIT "+limit.String())
+	}
+	if offset != nil {
+		clauses = append(clauses, "OFFSET "+offset.String())
+	}
+	return clauses
+}
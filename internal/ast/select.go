@@ -1,20 +1,110 @@
 package ast
 
-import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+import (
+	"strings"
 
-// JoinClause holds the right-side table and the ON equality columns for an INNER JOIN.
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// JoinKind is the type of a join.
+type JoinKind int
+
+const (
+	InnerJoin JoinKind = iota
+	LeftJoin
+	RightJoin
+	FullJoin
+	CrossJoin
+	// Semi and anti joins are never written in SQL; the planner turns
+	// EXISTS and IN subqueries into them. They return left rows only.
+	SemiJoin
+	AntiJoin
+)
+
+func (k JoinKind) String() string {
+	switch k {
+	case LeftJoin:
+		return "LEFT JOIN"
+	case RightJoin:
+		return "RIGHT JOIN"
+	case FullJoin:
+		return "FULL JOIN"
+	case CrossJoin:
+		return "CROSS JOIN"
+	case SemiJoin:
+		return "SEMI JOIN"
+	case AntiJoin:
+		return "ANTI JOIN"
+	}
+	return "JOIN"
+}
+
+// JoinClause joins one more table onto everything to its left in the FROM
+// clause. Joins chain left to right: a JOIN b ... JOIN c is (a JOIN b) JOIN c.
 type JoinClause struct {
-	Table    string // right-side table name
-	LeftKey  string // qualified column reference on the left, e.g. "orders.user_id"
-	RightKey string // qualified column reference on the right, e.g. "users.id"
+	Token lexer.Token // the first token of the join, e.g. LEFT or JOIN
+	Kind  JoinKind
+	Table string     // right-side table name
+	Alias string     // optional alias for Table
+	On    Expression // join condition; nil for CROSS JOIN
+}
+
+// FromClause is the FROM list of an UPDATE or the USING list of a DELETE:
+// the tables, joined like a SELECT's, whose rows the WHERE condition can
+// match against the target table's.
+type FromClause struct {
+	Token lexer.Token // FROM or USING
+	Table string
+	Alias string
+	Joins []*JoinClause
+}
+
+func (fc *FromClause) String() string {
+	return strings.Join(fromClauses(fc.Table, fc.Alias, fc.Joins), " ")
+}
+
+// fromClauses renders a table, its alias and the joins onto it, one join
+// per clause.
+func fromClauses(table, alias string, joins []*JoinClause) []string {
+	first := QuoteIdent(table)
+	if alias != "" {
+		first += " " + QuoteIdent(alias)
+	}
+	clauses := []string{first}
+	for _, j := range joins {
+		clauses = append(clauses, j.String())
+	}
+	return clauses
+}
+
+// OrderByItem is one ORDER BY key.
+type OrderByItem struct {
+	Expr Expression
+	Desc bool
+}
+
+func (o OrderByItem) String() string {
+	if o.Desc {
+		return o.Expr.String() + " DESC"
+	}
+	return o.Expr.String()
 }
 
 type SelectStatement struct {
-	Token   lexer.Token
-	Columns []string
-	Table   string
-	Join    *JoinClause // nil for plain SELECT
-	Where   *WhereClause
+	Token      lexer.Token
+	With       *WithClause // nil without a WITH clause
+	Distinct   bool
+	DistinctOn []Expression // DISTINCT ON (...) keys; implies Distinct
+	Columns    []Expression // select list; a lone *ast.Star for SELECT *
+	Table      string
+	Alias      string        // optional alias for Table
+	Joins      []*JoinClause // in FROM order; empty for a single table
+	Where      *WhereClause
+	GroupBy    []Expression
+	Having     Expression // nil when there is no HAVING clause
+	OrderBy    []OrderByItem
+	Limit      Expression // nil without LIMIT
+	Offset     Expression // nil without OFFSET
 }
 
 func (ss *SelectStatement) StatementNode() {}
@@ -24,5 +114,51 @@ func (ss *SelectStatement) TokenLiteral() string {
 }
 
 func (ss *SelectStatement) String() string {
-	return "SELECT statement"
+	return strings.Join(ss.clauses(), " ")
+}
+
+func (ss *SelectStatement) clauses() []string {
+	var clauses []string
+	if ss.With != nil {
+		clauses = append(clauses, ss.With.String())
+	}
+	sel := "SELECT "
+	if len(ss.DistinctOn) > 0 {
+		sel += "DISTINCT ON (" + joinExpressions(ss.DistinctOn) + ") "
+	} else if ss.Distinct {
+		sel += "DISTINCT "
+	}
+	clauses = append(clauses, sel+joinExpressions(ss.Columns))
+	from := fromClauses(ss.Table, ss.Alias, ss.Joins)
+	from[0] = "FROM " + from[0]
+	clauses = append(clauses, from...)
+	if ss.Where != nil {
+		clauses = append(clauses, ss.Where.String())
+	}
+	if len(ss.GroupBy) > 0 {
+		clauses = append(clauses, "GROUP BY "+joinExpressions(ss.GroupBy))
+	}
+	if ss.Having != nil {
+		clauses = append(clauses, "HAVING "+ss.Having.String())
+	}
+	return append(clauses, orderAndLimit(ss.OrderBy, ss.Limit, ss.Offset)...)
+}
+
+func (jc *JoinClause) String() string {
+	s := jc.Kind.String() + " " + QuoteIdent(jc.Table)
+	if jc.Alias != "" {
+		s += " " + QuoteIdent(jc.Alias)
+	}
+	if jc.On != nil {
+		s += " ON " + jc.On.String()
+	}
+	return s
+}
+
+func joinExpressions(exprs []Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, ", ")
 }
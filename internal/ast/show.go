@@ -0,0 +1,60 @@
+package ast
+
+import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+
+// ShowKind says what a ShowStatement lists.
+type ShowKind int
+
+const (
+	ShowDatabases   ShowKind = iota // SHOW DATABASES
+	ShowTables                      // SHOW TABLES
+	ShowColumns                     // DESCRIBE table
+	ShowCreateTable                 // SHOW CREATE TABLE table
+	ShowIndexes                     // SHOW INDEXES [FROM table]
+)
+
+func (k ShowKind) String() string {
+	switch k {
+	case ShowDatabases:
+		return "DATABASES"
+	case ShowTables:
+		return "TABLES"
+	case ShowColumns:
+		return "COLUMNS"
+	case ShowCreateTable:
+		return "CREATE TABLE"
+	}
+	return "INDEXES"
+}
+
+// ShowStatement asks what the server holds: the databases, the tables of
+// the current database, or the definition of one table. Table is empty
+// for SHOW DATABASES, SHOW TABLES and a SHOW INDEXES over every table.
+type ShowStatement struct {
+	Token lexer.Token // the 'SHOW' or 'DESCRIBE' token
+	Kind  ShowKind
+	Table string
+}
+
+func (ss *ShowStatement) StatementNode() {}
+
+func (ss *ShowStatement) TokenLiteral() string {
+	return ss.Token.Value
+}
+
+func (ss *ShowStatement) String() string {
+	switch ss.Kind {
+	case ShowDatabases:
+		return "SHOW DATABASES"
+	case ShowTables:
+		return "SHOW TABLES"
+	case ShowColumns:
+		return "DESCRIBE " + QuoteIdent(ss.Table)
+	case ShowCreateTable:
+		return "SHOW CREATE TABLE " + QuoteIdent(ss.Table)
+	}
+	if ss.Table != "" {
+		return "SHOW INDEXES FROM " + QuoteIdent(ss.Table)
+	}
+	return "SHOW INDEXES"
+}
@@ -0,0 +1,51 @@
+package ast
+
+import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+
+// SubqueryExpression is a parenthesised SELECT used as a value. It must
+// return one column and at most one row.
+type SubqueryExpression struct {
+	Token  lexer.Token // the ( token
+	Select *SelectStatement
+}
+
+func (se *SubqueryExpression) ExpressionNode()      {}
+func (se *SubqueryExpression) TokenLiteral() string { return se.Token.Value }
+func (se *SubqueryExpression) String() string       { return "(" + se.Select.String() + ")" }
+
+// ExistsExpression is EXISTS (SELECT ...). NOT EXISTS is a NOT prefix
+// expression around it.
+type ExistsExpression struct {
+	Token  lexer.Token // the EXISTS token
+	Select *SelectStatement
+}
+
+func (ee *ExistsExpression) ExpressionNode()      {}
+func (ee *ExistsExpression) TokenLiteral() string { return ee.Token.Value }
+func (ee *ExistsExpression) String() string       { return "EXISTS (" + ee.Select.String() + ")" }
+
+// InExpression tests Left against a subquery, or against a list of values
+// when Select is nil.
+type InExpression struct {
+	Token  lexer.Token // the IN token
+	Left   Expression
+	Not    bool
+	Select *SelectStatement
+	List   []Expression
+}
+
+func (ie *InExpression) ExpressionNode()      {}
+func (ie *InExpression) TokenLiteral() string { return ie.Token.Value }
+func (ie *InExpression) String() string {
+	op := " IN ("
+	if ie.Not {
+		op = " NOT IN ("
+	}
+	inner := ""
+	if ie.Select != nil {
+		inner = ie.Select.String()
+	} else {
+		inner = joinExpressions(ie.List)
+	}
+	return wrap(ie.Left, COMPARE_PREC+1) + op + inner + ")"
+}
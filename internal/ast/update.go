@@ -1,12 +1,19 @@
 package ast
 
-import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
 
 type UpdateStatement struct {
 	Token lexer.Token
 	Table string
-	Sets  map[string]string
+	Sets  []SetClause
+	From  *FromClause // nil when only the table is read
 	Where *WhereClause
+	// Returning is the RETURNING list, evaluated against the affected rows.
+	Returning []Expression
 }
 
 func (us *UpdateStatement) StatementNode() {}
@@ -16,5 +23,33 @@ func (us *UpdateStatement) TokenLiteral() string {
 }
 
 func (us *UpdateStatement) String() string {
-	return "UPDATE statement"
+	return strings.Join(us.clauses(), " ")
+}
+
+func (us *UpdateStatement) clauses() []string {
+	sets := make([]string, len(us.Sets))
+	for i, set := range us.Sets {
+		sets[i] = set.String()
+	}
+	clauses := []string{"UPDATE " + QuoteIdent(us.Table), "SET " + strings.Join(sets, ", ")}
+	if us.From != nil {
+		from := fromClauses(us.From.Table, us.From.Alias, us.From.Joins)
+		from[0] = "FROM " + from[0]
+		clauses = append(clauses, from...)
+	}
+	if us.Where != nil {
+		clauses = append(clauses, us.Where.String())
+	}
+	return append(clauses, returningClause(us.Returning)...)
+}
+
+// SetClause is one col = value assignment of an UPDATE or ON CONFLICT DO
+// UPDATE. Assignments keep their written order.
+type SetClause struct {
+	Column string
+	Value  Expression
+}
+
+func (sc SetClause) String() string {
+	return QuoteIdent(sc.Column) + " = " + sc.Value.String()
 }
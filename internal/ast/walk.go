@@ -0,0 +1,111 @@
+package ast
+
+// Inspect traverses an expression tree depth-first, calling f for every
+// node. When f returns false the children of that node are skipped.
+// Subqueries are not entered: they have their own scope.
+func Inspect(expr Expression, f func(Expression) bool) {
+	if expr == nil || !f(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *PrefixExpression:
+		Inspect(e.Right, f)
+	case *InfixExpression:
+		Inspect(e.Left, f)
+		Inspect(e.Right, f)
+	case *FunctionCall:
+		for _, a := range e.Args {
+			Inspect(a, f)
+		}
+		if e.Over != nil {
+			for _, x := range e.Over.expressions() {
+				Inspect(x, f)
+			}
+		}
+	case *CastExpression:
+		Inspect(e.Expr, f)
+	case *IsNullExpression:
+		Inspect(e.Expr, f)
+	case *CaseExpression:
+		Inspect(e.Operand, f)
+		for _, w := range e.Whens {
+			Inspect(w.Condition, f)
+			Inspect(w.Result, f)
+		}
+		Inspect(e.Else, f)
+	case *AliasExpression:
+		Inspect(e.Expr, f)
+	case *InExpression:
+		Inspect(e.Left, f)
+		for _, item := range e.List {
+			Inspect(item, f)
+		}
+	}
+}
+
+// Rewrite returns a copy of expr in which every node for which f returns a
+// non-nil replacement is replaced. Nodes f leaves alone are copied with
+// their children rewritten in turn.
+func Rewrite(expr Expression, f func(Expression) Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+	if r := f(expr); r != nil {
+		return r
+	}
+	switch e := expr.(type) {
+	case *PrefixExpression:
+		c := *e
+		c.Right = Rewrite(e.Right, f)
+		return &c
+	case *InfixExpression:
+		c := *e
+		c.Left, c.Right = Rewrite(e.Left, f), Rewrite(e.Right, f)
+		return &c
+	case *FunctionCall:
+		c := *e
+		c.Args = rewriteList(e.Args, f)
+		if e.Over != nil {
+			c.Over = e.Over.rewrite(f)
+		}
+		return &c
+	case *CastExpression:
+		c := *e
+		c.Expr = Rewrite(e.Expr, f)
+		return &c
+	case *IsNullExpression:
+		c := *e
+		c.Expr = Rewrite(e.Expr, f)
+		return &c
+	case *CaseExpression:
+		c := *e
+		c.Operand = Rewrite(e.Operand, f)
+		c.Whens = make([]WhenClause, len(e.Whens))
+		for i, w := range e.Whens {
+			c.Whens[i] = WhenClause{Condition: Rewrite(w.Condition, f), Result: Rewrite(w.Result, f)}
+		}
+		c.Else = Rewrite(e.Else, f)
+		return &c
+	case *AliasExpression:
+		c := *e
+		c.Expr = Rewrite(e.Expr, f)
+		return &c
+	case *InExpression:
+		c := *e
+		c.Left = Rewrite(e.Left, f)
+		c.List = rewriteList(e.List, f)
+		return &c
+	}
+	return expr
+}
+
+func rewriteList(exprs []Expression, f func(Expression) Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	out := make([]Expression, len(exprs))
+	for i, e := range exprs {
+		out[i] = Rewrite(e, f)
+	}
+	return out
+}
@@ -3,12 +3,10 @@ package ast
 import "github.com/Mohammad-y-abbass/moDB/internal/lexer"
 
 type WhereClause struct {
-	Token lexer.Token // the 'WHERE' token
-	Left  string      // column name
-	Op    string      // '='
-	Right string      // value
+	Token     lexer.Token // the 'WHERE' token
+	Condition Expression
 }
 
 func (wc *WhereClause) Node()                {}
 func (wc *WhereClause) TokenLiteral() string { return wc.Token.Value }
-func (wc *WhereClause) String() string       { return "WHERE " + wc.Left + " " + wc.Op + " " + wc.Right }
+func (wc *WhereClause) String() string       { return "WHERE " + wc.Condition.String() }
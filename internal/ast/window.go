@@ -0,0 +1,122 @@
+package ast
+
+import "strings"
+
+// WindowSpec is the OVER (...) clause of a window function call. Rows are
+// split into partitions by PartitionBy and ordered within each by OrderBy;
+// Frame, when set, picks the rows an aggregate or FIRST_VALUE reads.
+type WindowSpec struct {
+	PartitionBy []Expression
+	OrderBy     []OrderByItem
+	Frame       *WindowFrame // nil for the default frame
+}
+
+func (w *WindowSpec) String() string {
+	var parts []string
+	if len(w.PartitionBy) > 0 {
+		keys := make([]string, len(w.PartitionBy))
+		for i, k := range w.PartitionBy {
+			keys[i] = k.String()
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(keys, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		items := make([]string, len(w.OrderBy))
+		for i, item := range w.OrderBy {
+			items[i] = item.String()
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(items, ", "))
+	}
+	if w.Frame != nil {
+		parts = append(parts, w.Frame.String())
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// FrameUnit says whether frame offsets count rows or ORDER BY key values.
+type FrameUnit int
+
+const (
+	RowsFrame FrameUnit = iota
+	RangeFrame
+)
+
+// BoundKind is the kind of one end of a window frame. The kinds are in
+// order, so a frame's start kind may not come after its end kind.
+type BoundKind int
+
+const (
+	UnboundedPreceding BoundKind = iota
+	OffsetPreceding
+	CurrentRow
+	OffsetFollowing
+	UnboundedFollowing
+)
+
+// FrameBound is one end of a window frame. Offset is set for the
+// "n PRECEDING" and "n FOLLOWING" kinds.
+type FrameBound struct {
+	Kind   BoundKind
+	Offset Expression
+}
+
+func (b FrameBound) String() string {
+	switch b.Kind {
+	case UnboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case OffsetPreceding:
+		return b.Offset.String() + " PRECEDING"
+	case OffsetFollowing:
+		return b.Offset.String() + " FOLLOWING"
+	case UnboundedFollowing:
+		return "UNBOUNDED FOLLOWING"
+	}
+	return "CURRENT ROW"
+}
+
+// WindowFrame is a ROWS or RANGE frame clause. A frame written with a
+// single bound ends at the current row.
+type WindowFrame struct {
+	Unit  FrameUnit
+	Start FrameBound
+	End   FrameBound
+}
+
+func (f *WindowFrame) String() string {
+	unit := "ROWS"
+	if f.Unit == RangeFrame {
+		unit = "RANGE"
+	}
+	return unit + " BETWEEN " + f.Start.String() + " AND " + f.End.String()
+}
+
+// expressions lists the expressions of a window spec, for walking.
+func (w *WindowSpec) expressions() []Expression {
+	exprs := append([]Expression(nil), w.PartitionBy...)
+	for _, item := range w.OrderBy {
+		exprs = append(exprs, item.Expr)
+	}
+	if w.Frame != nil {
+		for _, b := range []FrameBound{w.Frame.Start, w.Frame.End} {
+			if b.Offset != nil {
+				exprs = append(exprs, b.Offset)
+			}
+		}
+	}
+	return exprs
+}
+
+// rewrite returns a copy of the spec with its expressions rewritten.
+func (w *WindowSpec) rewrite(f func(Expression) Expression) *WindowSpec {
+	c := &WindowSpec{PartitionBy: rewriteList(w.PartitionBy, f)}
+	for _, item := range w.OrderBy {
+		c.OrderBy = append(c.OrderBy, OrderByItem{Expr: Rewrite(item.Expr, f), Desc: item.Desc})
+	}
+	if w.Frame != nil {
+		frame := *w.Frame
+		frame.Start.Offset = Rewrite(frame.Start.Offset, f)
+		frame.End.Offset = Rewrite(frame.End.Offset, f)
+		c.Frame = &frame
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// WithClause is the WITH list in front of a query. Each common table
+// expression may be read by the ones after it and by the main query; with
+// RECURSIVE, also by itself.
+type WithClause struct {
+	Token     lexer.Token // the WITH token
+	Recursive bool
+	CTEs      []*CommonTableExpression
+}
+
+func (w *WithClause) String() string {
+	parts := make([]string, len(w.CTEs))
+	for i, cte := range w.CTEs {
+		parts[i] = cte.String()
+	}
+	s := "WITH "
+	if w.Recursive {
+		s += "RECURSIVE "
+	}
+	return s + strings.Join(parts, ", ")
+}
+
+// CommonTableExpression is one name [(columns)] AS (query) entry of a WITH
+// clause. A recursive CTE's query is a UNION [ALL] whose left side seeds
+// the result and whose last SELECT reads the CTE itself.
+type CommonTableExpression struct {
+	Token   lexer.Token // the CTE's name
+	Name    string
+	Columns []string // optional column names; empty to use the query's
+	Query   Query
+}
+
+func (c *CommonTableExpression) String() string {
+	s := QuoteIdent(c.Name)
+	if len(c.Columns) > 0 {
+		s += " (" + quoteIdents(c.Columns) + ")"
+	}
+	return s + " AS (" + c.Query.String() + ")"
+}
@@ -0,0 +1,364 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// maxHashGroups caps the number of groups hash aggregation keeps in memory.
+// Past it the executor falls back to sorting the input by group key and
+// aggregating one group at a time.
+var maxHashGroups = 1 << 16
+
+var errTooManyGroups = errors.New("too many groups for hash aggregation")
+
+// aggregator accumulates the values of one aggregate call for one group.
+type aggregator interface {
+	add(v interface{}) error
+	result() interface{}
+}
+
+// aggregateSpec is an aggregate call with its argument compiled against the
+// AggregateNode's input.
+type aggregateSpec struct {
+	call *ast.FunctionCall
+	arg  evalFunc // nil for COUNT(*)
+}
+
+func (s aggregateSpec) newAggregator() aggregator {
+	var agg aggregator
+	switch s.call.Name {
+	case "COUNT":
+		agg = &countAggregator{}
+	case "SUM":
+		agg = &sumAggregator{}
+	case "AVG":
+		agg = &avgAggregator{}
+	case "MIN":
+		agg = &extremeAggregator{name: "MIN", want: -1}
+	case "MAX":
+		agg = &extremeAggregator{name: "MAX", want: 1}
+	case "STRING_AGG":
+		agg = &stringAggregator{sep: s.call.Args[1].(*ast.StringLiteral).Value}
+	}
+	if s.call.Distinct {
+		agg = &distinctAggregator{inner: agg, seen: make(map[string]bool)}
+	}
+	return agg
+}
+
+type countAggregator struct {
+	n int64
+}
+
+func (a *countAggregator) add(v interface{}) error {
+	if v != nil {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAggregator) result() interface{} { return a.n }
+
+// sumAggregator keeps an exact integer sum until it sees a float.
+type sumAggregator struct {
+	isum    int64
+	fsum    float64
+	isFloat bool
+	seen    bool
+}
+
+func (a *sumAggregator) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	a.seen = true
+	if n, ok := toInt64(v); ok && !a.isFloat {
+		a.isum += n
+		return nil
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		return fmt.Errorf("SUM requires numeric values, got %v", v)
+	}
+	if !a.isFloat {
+		a.isFloat = true
+		a.fsum = float64(a.isum)
+	}
+	a.fsum += f
+	return nil
+}
+
+func (a *sumAggregator) result() interface{} {
+	switch {
+	case !a.seen:
+		return nil
+	case a.isFloat:
+		return a.fsum
+	}
+	return a.isum
+}
+
+type avgAggregator struct {
+	sum float64
+	n   int64
+}
+
+func (a *avgAggregator) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		return fmt.Errorf("AVG requires numeric values, got %v", v)
+	}
+	a.sum += f
+	a.n++
+	return nil
+}
+
+func (a *avgAggregator) result() interface{} {
+	if a.n == 0 {
+		return nil
+	}
+	return a.sum / float64(a.n)
+}
+
+// extremeAggregator implements MIN (want -1) and MAX (want 1).
+type extremeAggregator struct {
+	name string
+	want int
+	val  interface{}
+}
+
+func (a *extremeAggregator) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if a.val == nil {
+		a.val = v
+		return nil
+	}
+	c, ok := compareValues(v, a.val)
+	if !ok {
+		return fmt.Errorf("%s cannot compare %v with %v", a.name, v, a.val)
+	}
+	if c == a.want {
+		a.val = v
+	}
+	return nil
+}
+
+func (a *extremeAggregator) result() interface{} { return a.val }
+
+type stringAggregator struct {
+	sep   string
+	parts []string
+}
+
+func (a *stringAggregator) add(v interface{}) error {
+	if v != nil {
+		a.parts = append(a.parts, fmt.Sprint(v))
+	}
+	return nil
+}
+
+func (a *stringAggregator) result() interface{} {
+	if len(a.parts) == 0 {
+		return nil
+	}
+	return strings.Join(a.parts, a.sep)
+}
+
+// distinctAggregator feeds each distinct non-NULL value to inner once.
+type distinctAggregator struct {
+	inner aggregator
+	seen  map[string]bool
+}
+
+func (a *distinctAggregator) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	k := valueKey(v)
+	if a.seen[k] {
+		return nil
+	}
+	a.seen[k] = true
+	return a.inner.add(v)
+}
+
+func (a *distinctAggregator) result() interface{} { return a.inner.result() }
+
+// aggregateGroup is the running state of one group.
+type aggregateGroup struct {
+	keys []interface{}
+	aggs []aggregator
+}
+
+func newGroup(keys []interface{}, specs []aggregateSpec) *aggregateGroup {
+	g := &aggregateGroup{keys: keys, aggs: make([]aggregator, len(specs))}
+	for i, s := range specs {
+		g.aggs[i] = s.newAggregator()
+	}
+	return g
+}
+
+func (g *aggregateGroup) add(row storage.Row, specs []aggregateSpec) error {
+	for i, s := range specs {
+		var v interface{} = true // COUNT(*) counts every row
+		if s.arg != nil {
+			var err error
+			if v, err = s.arg(row); err != nil {
+				return err
+			}
+		}
+		if err := g.aggs[i].add(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyedRow is an input row together with its evaluated group key.
+type keyedRow struct {
+	keys []interface{}
+	row  storage.Row
+}
+
+func (e *Executor) executeAggregate(n *planner.AggregateNode) (ResultSet, error) {
+	res, err := e.Execute(n.Child)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	keyFns := make([]evalFunc, len(n.GroupBy))
+	for i, g := range n.GroupBy {
+		if keyFns[i], err = e.compileExpr(g, res.Columns); err != nil {
+			return ResultSet{}, err
+		}
+	}
+	specs := make([]aggregateSpec, len(n.Aggregates))
+	for i, call := range n.Aggregates {
+		specs[i].call = call
+		if _, star := call.Args[0].(*ast.Star); star {
+			continue
+		}
+		if specs[i].arg, err = e.compileExpr(call.Args[0], res.Columns); err != nil {
+			return ResultSet{}, err
+		}
+	}
+
+	input := make([]keyedRow, len(res.Rows))
+	for i, row := range res.Rows {
+		keys := make([]interface{}, len(keyFns))
+		for j, fn := range keyFns {
+			if keys[j], err = fn(row); err != nil {
+				return ResultSet{}, err
+			}
+		}
+		input[i] = keyedRow{keys: keys, row: row}
+	}
+
+	groups, err := hashAggregate(input, specs)
+	if errors.Is(err, errTooManyGroups) {
+		groups, err = sortAggregate(input, specs)
+	}
+	if err != nil {
+		return ResultSet{}, err
+	}
+	// An ungrouped aggregate over no rows still yields one row (COUNT = 0).
+	if len(n.GroupBy) == 0 && len(groups) == 0 {
+		groups = append(groups, newGroup(nil, specs))
+	}
+
+	var columns []string
+	for _, g := range n.GroupBy {
+		columns = append(columns, columnName(g))
+	}
+	for _, call := range n.Aggregates {
+		columns = append(columns, call.String())
+	}
+
+	var having evalFunc
+	if n.Having != nil {
+		if having, err = e.compileExpr(n.Having, columns); err != nil {
+			return ResultSet{}, err
+		}
+	}
+
+	var rows []storage.Row
+	for _, g := range groups {
+		values := append([]interface{}{}, g.keys...)
+		for _, agg := range g.aggs {
+			values = append(values, agg.result())
+		}
+		row := storage.Row{Values: values}
+		if having != nil {
+			v, err := having(row)
+			if err != nil {
+				return ResultSet{}, err
+			}
+			if !isTrue(v) {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	return ResultSet{Columns: columns, Rows: rows}, nil
+}
+
+// hashAggregate groups rows in a hash table, keeping groups in order of
+// first appearance. It gives up with errTooManyGroups once maxHashGroups is
+// exceeded.
+func hashAggregate(input []keyedRow, specs []aggregateSpec) ([]*aggregateGroup, error) {
+	index := make(map[string]*aggregateGroup)
+	var groups []*aggregateGroup
+	for _, in := range input {
+		k := rowKey(in.keys)
+		g, ok := index[k]
+		if !ok {
+			if len(groups) >= maxHashGroups {
+				return nil, errTooManyGroups
+			}
+			g = newGroup(in.keys, specs)
+			index[k] = g
+			groups = append(groups, g)
+		}
+		if err := g.add(in.row, specs); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// sortAggregate sorts rows by group key and aggregates each run of equal
+// keys, so it needs no hash table however many groups there are.
+func sortAggregate(input []keyedRow, specs []aggregateSpec) ([]*aggregateGroup, error) {
+	sorted := append([]keyedRow{}, input...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareRows(sorted[i].keys, sorted[j].keys, nil) < 0
+	})
+
+	var groups []*aggregateGroup
+	var current *aggregateGroup
+	currentKey := ""
+	for _, in := range sorted {
+		k := rowKey(in.keys)
+		if current == nil || k != currentKey {
+			current = newGroup(in.keys, specs)
+			currentKey = k
+			groups = append(groups, current)
+		}
+		if err := current.add(in.row, specs); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+)
+
+// columnarMagic starts every file written by COPY ... WITH (FORMAT columnar).
+const columnarMagic = "MODBCOL1"
+
+// Column type codes of the columnar format.
+const (
+	columnarInt byte = iota + 1
+	columnarFloat
+	columnarText
+	columnarBool
+)
+
+// writeColumnar writes a result set in moDB's columnar format. The file
+// describes itself, and stores each column in one chunk so that a reader
+// can skip the columns it does not need. All numbers are little-endian.
+//
+//	magic    "MODBCOL1"
+//	schema   uint32 column count, then for each column a uint16 name
+//	         length, the name, and a uint8 type: 1 INT, 2 FLOAT, 3 TEXT,
+//	         4 BOOLEAN
+//	rows     uint64 row count
+//	chunks   for each column in order, a uint64 chunk length followed by
+//	         the chunk: a NULL bitmap of (rows+7)/8 bytes, bit j of byte
+//	         j/8 set when row j is NULL, then the non-NULL values, INT as
+//	         int64, FLOAT as float64, BOOLEAN as one byte and TEXT as a
+//	         uint32 length and the UTF-8 bytes
+//
+// Columns whose type is unknown at plan time, such as a bare NULL, are
+// written as TEXT.
+func writeColumnar(w io.Writer, res ResultSet, types []planner.Type) error {
+	var header bytes.Buffer
+	header.WriteString(columnarMagic)
+	binary.Write(&header, binary.LittleEndian, uint32(len(res.Columns)))
+	codes := make([]byte, len(res.Columns))
+	for i, name := range res.Columns {
+		if len(name) > math.MaxUint16 {
+			return fmt.Errorf("column name too long for columnar format: %.20s...", name)
+		}
+		codes[i] = columnarText
+		switch types[i] {
+		case planner.TypeInt:
+			codes[i] = columnarInt
+		case planner.TypeFloat:
+			codes[i] = columnarFloat
+		case planner.TypeBool:
+			codes[i] = columnarBool
+		}
+		binary.Write(&header, binary.LittleEndian, uint16(len(name)))
+		header.WriteString(name)
+		header.WriteByte(codes[i])
+	}
+	binary.Write(&header, binary.LittleEndian, uint64(len(res.Rows)))
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	var chunk bytes.Buffer
+	for i, code := range codes {
+		chunk.Reset()
+		nulls := make([]byte, (len(res.Rows)+7)/8)
+		for j, row := range res.Rows {
+			if row.Values[i] == nil {
+				nulls[j/8] |= 1 << (j % 8)
+			}
+		}
+		chunk.Write(nulls)
+		for _, row := range res.Rows {
+			v := row.Values[i]
+			if v == nil {
+				continue
+			}
+			if err := appendColumnarValue(&chunk, code, v); err != nil {
+				return fmt.Errorf("column %s: %w", res.Columns[i], err)
+			}
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint64(chunk.Len())); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendColumnarValue encodes one non-NULL value of a column of type code.
+func appendColumnarValue(buf *bytes.Buffer, code byte, v interface{}) error {
+	switch code {
+	case columnarInt:
+		c, err := castValue(v, planner.TypeInt)
+		if err != nil {
+			return err
+		}
+		return binary.Write(buf, binary.LittleEndian, c.(int64))
+	case columnarFloat:
+		c, err := castValue(v, planner.TypeFloat)
+		if err != nil {
+			return err
+		}
+		return binary.Write(buf, binary.LittleEndian, c.(float64))
+	case columnarBool:
+		c, err := castValue(v, planner.TypeBool)
+		if err != nil {
+			return err
+		}
+		if c.(bool) {
+			return buf.WriteByte(1)
+		}
+		return buf.WriteByte(0)
+	}
+	text := formatValue(v)
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(text))); err != nil {
+		return err
+	}
+	buf.WriteString(text)
+	return nil
+}
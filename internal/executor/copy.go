@@ -0,0 +1,191 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// executeCopy loads a CSV or JSON Lines file into a table as one batch,
+// like a multi-row INSERT: every row is converted and checked against the
+// table's constraints before any is written, and InsertMany then fills
+// each page once. Errors name the line of the file they were found on.
+func (e *Executor) executeCopy(n *planner.CopyNode) (ResultSet, error) {
+	table, ok := e.Tables[n.TableName]
+	if !ok {
+		return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
+	}
+	f, err := e.openImport(n.File)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	defer f.Close()
+
+	// targets[i] is the table column the i-th value of a line goes to.
+	targets := make([]int, 0, len(table.Schema.Columns))
+	if len(n.Columns) == 0 {
+		for i := range table.Schema.Columns {
+			targets = append(targets, i)
+		}
+	}
+	for _, name := range n.Columns {
+		for i, col := range table.Schema.Columns {
+			if col.Name == name {
+				targets = append(targets, i)
+				break
+			}
+		}
+	}
+
+	var rows [][]interface{}
+	var lines []int // lines[i] is the line of the file rows[i] was read from
+	if n.Format == "jsonl" {
+		rows, lines, err = readJSONLines(f, targets, table.Schema)
+	} else {
+		rows, lines, err = readCSV(f, n, targets, table.Schema)
+	}
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	if err := e.checkInsertConstraints(n.TableName, table, rows); err != nil {
+		var re *rowError
+		if errors.As(err, &re) {
+			return ResultSet{}, fmt.Errorf("line %d: %w", lines[re.row], re.err)
+		}
+		return ResultSet{}, err
+	}
+	if err := table.InsertMany(rows); err != nil {
+		return ResultSet{}, err
+	}
+	return ResultSet{Message: fmt.Sprintf("Copied %d rows", len(rows))}, nil
+}
+
+// openImport opens the file named by a COPY FROM, which must be a relative
+// path that stays inside the import directory. Symbolic links that lead
+// out of the directory are refused too.
+func (e *Executor) openImport(file string) (*os.File, error) {
+	if e.ImportDir == "" {
+		return nil, fmt.Errorf("COPY FROM is disabled: no import directory is configured")
+	}
+	if !filepath.IsLocal(file) {
+		return nil, fmt.Errorf("COPY FROM file must be a relative path inside the import directory: %s", file)
+	}
+	return os.OpenInRoot(e.ImportDir, file)
+}
+
+// readCSV reads the records of a CSV file. A field equal to the NULL
+// option, by default the empty string, is NULL.
+func readCSV(r io.Reader, n *planner.CopyNode, targets []int, schema *storage.Schema) ([][]interface{}, []int, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = n.Delimiter
+	cr.FieldsPerRecord = -1
+
+	var rows [][]interface{}
+	var lines []int
+	header := n.Header
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if header {
+			header = false
+			continue
+		}
+		if len(record) != len(targets) {
+			return nil, nil, fmt.Errorf("line %d: expected %d values, got %d", line, len(targets), len(record))
+		}
+		values := make([]interface{}, len(record))
+		for i, field := range record {
+			if field != n.Null {
+				values[i] = field
+			}
+		}
+		row, err := copyRow(values, targets, schema)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+	return rows, lines, nil
+}
+
+// readJSONLines reads a file holding one JSON object per line, keyed by
+// column name. Blank lines are skipped, and columns an object leaves out
+// are NULL.
+func readJSONLines(r io.Reader, targets []int, schema *storage.Schema) ([][]interface{}, []int, error) {
+	// position maps a column name to its place among the targets.
+	position := make(map[string]int, len(targets))
+	for i, idx := range targets {
+		position[schema.Columns[idx].Name] = i
+	}
+
+	var rows [][]interface{}
+	var lines []int
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if text = bytes.TrimSpace(text); len(text) > 0 {
+			var object map[string]interface{}
+			if jsonErr := json.Unmarshal(text, &object); jsonErr != nil || object == nil {
+				return nil, nil, fmt.Errorf("line %d: expected a JSON object", line)
+			}
+			values := make([]interface{}, len(targets))
+			for name, v := range object {
+				i, ok := position[name]
+				if !ok {
+					return nil, nil, fmt.Errorf("line %d: column not found: %s", line, name)
+				}
+				switch v.(type) {
+				case map[string]interface{}, []interface{}:
+					return nil, nil, fmt.Errorf("line %d: value for column %s is not a scalar", line, name)
+				}
+				values[i] = v
+			}
+			row, rowErr := copyRow(values, targets, schema)
+			if rowErr != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, rowErr)
+			}
+			rows = append(rows, row)
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return rows, lines, nil
+}
+
+// copyRow builds a full table row from the values read for the target
+// columns, converting each to its column's type. Other columns are NULL.
+func copyRow(values []interface{}, targets []int, schema *storage.Schema) ([]interface{}, error) {
+	row := make([]interface{}, len(schema.Columns))
+	for i, idx := range targets {
+		row[idx] = values[i]
+	}
+	for i, col := range schema.Columns {
+		var err error
+		if row[i], err = coerceToColumn(row[i], col); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
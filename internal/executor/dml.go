@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// targetRows finds the rows an UPDATE or DELETE acts on. Without a join
+// they are the table rows matching where; with one they are the rows of
+// the join, which start with the target row's columns and keep its
+// location. A target row joined to several rows is returned once, with
+// its first match, so it is written only once. The columns name the
+// values of the returned rows.
+func (e *Executor) targetRows(name string, table *storage.Table, where func(storage.Row) (bool, error),
+	join *planner.JoinNode) ([]storage.Row, []string, error) {
+	if join == nil {
+		rows, err := table.SelectAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		var matched []storage.Row
+		for _, row := range rows {
+			ok, err := where(row)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				matched = append(matched, row)
+			}
+		}
+		return matched, columnNames(name, table.Schema), nil
+	}
+
+	res, err := e.Execute(join)
+	if err != nil {
+		return nil, nil, err
+	}
+	type location struct {
+		page uint32
+		slot uint16
+	}
+	seen := make(map[location]bool, len(res.Rows))
+	var matched []storage.Row
+	for _, row := range res.Rows {
+		loc := location{row.PageID, row.SlotID}
+		if !seen[loc] {
+			seen[loc] = true
+			matched = append(matched, row)
+		}
+	}
+	return matched, res.Columns, nil
+}
+
+// targetValues returns the target table's values of a row from targetRows.
+func targetValues(row storage.Row, schema *storage.Schema) storage.Row {
+	return storage.Row{Values: row.Values[:len(schema.Columns)], PageID: row.PageID, SlotID: row.SlotID}
+}
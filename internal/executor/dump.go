@@ -0,0 +1,198 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// Dump writes the active database as a SQL script that rebuilds it: CREATE
+// DATABASE and USE, a CREATE TABLE for every table, each after the tables
+// its foreign keys reference, and the rows as INSERTs of at most batch rows.
+// The script only relies on SQL, not on the storage format, so it can be
+// replayed by any moDB build.
+func (e *Executor) Dump(w io.Writer, batch int) error {
+	if e.Engine.ActiveDB == "" {
+		return fmt.Errorf("no database selected")
+	}
+	if batch < 1 {
+		return fmt.Errorf("batch size must be positive, got %d", batch)
+	}
+	names, err := e.dependencyOrder()
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	db := e.Engine.ActiveDB
+	sb.WriteString("-- moDB dump of database " + db + "\n")
+	sb.WriteString((&ast.CreateDatabaseStatement{DatabaseName: db}).String() + ";\n")
+	sb.WriteString((&ast.UseDatabaseStatement{DatabaseName: db}).String() + ";\n")
+	for _, name := range names {
+		sb.WriteString("\n" + ast.Format(tableDefinition(name, e.Tables[name].Schema)) + ";\n")
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		table := e.Tables[name]
+		rows, err := table.SelectAll()
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		rows, cyclic := parentsFirst(name, table.Schema, rows)
+
+		sb.Reset()
+		sb.WriteString("\n")
+		// Rows on a reference cycle can only be inserted together.
+		acyclic := len(rows) - cyclic
+		for start := 0; start < len(rows); {
+			end := min(start+batch, len(rows))
+			if end > acyclic {
+				end = len(rows)
+			}
+			insert := &ast.InsertStatement{Table: name}
+			for _, row := range rows[start:end] {
+				values := make([]string, len(row.Values))
+				nulls := make([]bool, len(row.Values))
+				for i, v := range row.Values {
+					if v == nil {
+						nulls[i] = true
+					} else {
+						values[i] = formatValue(v)
+					}
+				}
+				insert.Rows = append(insert.Rows, values)
+				insert.Nulls = append(insert.Nulls, nulls)
+			}
+			sb.WriteString(insert.String() + ";\n")
+			start = end
+		}
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencyOrder lists the tables so that each comes after the tables its
+// foreign keys reference, in name order otherwise. A cycle of foreign keys
+// between tables cannot be dumped.
+func (e *Executor) dependencyOrder() ([]string, error) {
+	var order []string
+	placed := make(map[string]bool)
+	pending := e.tableNames()
+	for len(pending) > 0 {
+		var rest []string
+		for _, name := range pending {
+			ready := true
+			for _, col := range e.Tables[name].Schema.Columns {
+				ref := col.References
+				if ref != nil && ref.Table != name && !placed[ref.Table] {
+					_, exists := e.Tables[ref.Table]
+					ready = ready && !exists
+				}
+			}
+			if ready {
+				order = append(order, name)
+				placed[name] = true
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		if len(rest) == len(pending) {
+			return nil, fmt.Errorf("foreign keys between tables form a cycle: %s", strings.Join(rest, ", "))
+		}
+		pending = rest
+	}
+	return order, nil
+}
+
+// parentsFirst orders the rows of a table whose foreign keys may reference
+// the table itself so that every row comes after the rows it references.
+// Rows that cannot be ordered, being on a cycle of references or below
+// one, are moved to the end in stored order, and cyclic is their count.
+// Rows of other tables are returned as they are.
+func parentsFirst(name string, schema *storage.Schema, rows []storage.Row) (ordered []storage.Row, cyclic int) {
+	type fk struct{ child, parent int }
+	var fks []fk
+	for i, col := range schema.Columns {
+		if col.References == nil || col.References.Table != name {
+			continue
+		}
+		for j, parent := range schema.Columns {
+			if parent.Name == col.References.Column {
+				fks = append(fks, fk{child: i, parent: j})
+			}
+		}
+	}
+	if len(fks) == 0 {
+		return rows, 0
+	}
+
+	// A row waits for each value it references that some row holds.
+	type ref struct {
+		col int
+		key string
+	}
+	held := make(map[ref]bool)
+	for _, row := range rows {
+		for _, f := range fks {
+			held[ref{f.parent, valueKey(row.Values[f.parent])}] = true
+		}
+	}
+	deps := make([]int, len(rows))
+	waiting := make(map[ref][]int)
+	for i, row := range rows {
+		for _, f := range fks {
+			v := row.Values[f.child]
+			k := ref{f.parent, valueKey(v)}
+			if v == nil || !held[k] || valueKey(row.Values[f.parent]) == k.key {
+				continue
+			}
+			deps[i]++
+			waiting[k] = append(waiting[k], i)
+		}
+	}
+
+	var queue []int
+	for i := range rows {
+		if deps[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	done := make(map[ref]bool)
+	emitted := make([]bool, len(rows))
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, rows[i])
+		emitted[i] = true
+		for _, f := range fks {
+			k := ref{f.parent, valueKey(rows[i].Values[f.parent])}
+			if done[k] {
+				continue
+			}
+			done[k] = true
+			for _, j := range waiting[k] {
+				if deps[j]--; deps[j] == 0 {
+					queue = append(queue, j)
+				}
+			}
+		}
+	}
+	for i, row := range rows {
+		if !emitted[i] {
+			ordered = append(ordered, row)
+			cyclic++
+		}
+	}
+	return ordered, cyclic
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
 	"github.com/Mohammad-y-abbass/moDB/internal/planner"
 	"github.com/Mohammad-y-abbass/moDB/internal/storage"
 )
@@ -16,24 +17,85 @@ type ResultSet struct {
 	Columns []string
 	Rows    []storage.Row
 	Message string
+	// Affected is the number of rows an INSERT, UPDATE or DELETE wrote or
+	// deleted, whether or not it returns them.
+	Affected int
 }
 
+// Executor runs plans for one session. The Database is shared with the
+// executors of the other sessions, made by NewSession; everything else,
+// prepared statements included, belongs to this session alone.
 type Executor struct {
+	*Database
+	// frames hold the outer column values of the correlated subqueries
+	// being executed, innermost last.
+	frames []outerFrame
+	// ctes holds the rows of the WITH queries in scope.
+	ctes map[*planner.CTE][]storage.Row
+	// prepared holds the statements this session saved by PREPARE, by
+	// name.
+	prepared map[string]*planner.PrepareNode
+	// params are the values bound to the parameters of the prepared
+	// statement being executed.
+	params []interface{}
+	// stats collects what each plan node did on the executor EXPLAIN
+	// ANALYZE runs its statement with; nil on any other executor.
+	stats map[planner.PlanNode]*nodeStats
+}
+
+// Database is the state every session of a server shares.
+type Database struct {
 	Engine *storage.Engine
 	Tables map[string]*storage.Table
+	// ImportDir is the directory COPY FROM reads its files from, and
+	// ExportDir the one COPY TO writes them under. Each COPY is refused
+	// while its directory is empty.
+	ImportDir string
+	ExportDir string
 }
 
 func New(engine *storage.Engine) *Executor {
 	return &Executor{
-		Engine: engine,
-		Tables: make(map[string]*storage.Table),
+		Database: &Database{
+			Engine: engine,
+			Tables: make(map[string]*storage.Table),
+		},
 	}
 }
 
+// NewSession returns an executor for another session over the same
+// database as e, with no prepared statements.
+func (e *Executor) NewSession() *Executor {
+	return &Executor{Database: e.Database}
+}
+
 func (e *Executor) RegisterTable(name string, table *storage.Table) {
 	e.Tables[name] = table
 }
 
+// TableSchema implements planner.Catalog.
+func (e *Executor) TableSchema(name string) (*storage.Schema, bool) {
+	table, ok := e.Tables[name]
+	if !ok {
+		return nil, false
+	}
+	return table.Schema, true
+}
+
+// TableRows implements planner.Catalog. A table that cannot be read
+// counts as empty; scanning it reports the error.
+func (e *Executor) TableRows(name string) int {
+	table, ok := e.Tables[name]
+	if !ok {
+		return 0
+	}
+	n, err := table.RowCount()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
 func (e *Executor) SaveTableSchema(name string, schema *storage.Schema) error {
 	if e.Engine.ActiveDB == "" {
 		return fmt.Errorf("no active database")
@@ -99,8 +161,18 @@ func (e *Executor) ReloadTables() error {
 }
 
 func (e *Executor) Execute(plan planner.PlanNode) (ResultSet, error) {
+	if e.stats != nil {
+		return e.executeAnalyzed(plan)
+	}
+	return e.execute(plan)
+}
+
+func (e *Executor) execute(plan planner.PlanNode) (ResultSet, error) {
 	switch n := plan.(type) {
 	case *planner.ScanNode:
+		if n.CTE != nil {
+			return e.scanCTE(n)
+		}
 		table, ok := e.Tables[n.TableName]
 		if !ok {
 			return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
@@ -110,30 +182,41 @@ func (e *Executor) Execute(plan planner.PlanNode) (ResultSet, error) {
 			return ResultSet{}, err
 		}
 
+		// Qualify columns ("u.name") so joined scans stay distinguishable;
+		// bare references still resolve by suffix.
 		cols := []string{}
 		for _, c := range table.Schema.Columns {
-			cols = append(cols, c.Name)
+			cols = append(cols, n.Ref()+"."+c.Name)
 		}
 
 		return ResultSet{Columns: cols, Rows: rows}, nil
 
+	case *planner.WithNode:
+		return e.executeWith(n)
+
+	case *planner.SetOpNode:
+		return e.executeSetOp(n)
+
+	case *planner.LimitNode:
+		return e.executeLimit(n)
+
 	case *planner.FilterNode:
 		res, err := e.Execute(n.Child)
 		if err != nil {
 			return ResultSet{}, err
 		}
-		table := e.getTableFromPlan(n.Child)
-		if table == nil {
-			return ResultSet{}, fmt.Errorf("could not determine table for filter")
+		cond, err := e.compileExpr(n.Condition, res.Columns)
+		if err != nil {
+			return ResultSet{}, err
 		}
 
 		var filtered []storage.Row
 		for _, row := range res.Rows {
-			match, err := e.evaluateFilter(row, table.Schema, n)
+			match, err := cond(row)
 			if err != nil {
 				return ResultSet{}, err
 			}
-			if match {
+			if isTrue(match) {
 				filtered = append(filtered, row)
 			}
 		}
@@ -144,275 +227,109 @@ func (e *Executor) Execute(plan planner.PlanNode) (ResultSet, error) {
 		if err != nil {
 			return ResultSet{}, err
 		}
-		table := e.getTableFromPlan(n.Child)
-		if table == nil {
-			return ResultSet{}, fmt.Errorf("could not determine table for projection")
-		}
+		return e.applyProjection(res, n.Columns)
 
-		projectedRows, err := e.applyProjection(res.Rows, table.Schema, n.Columns)
-		if err != nil {
-			return ResultSet{}, err
-		}
+	case *planner.AggregateNode:
+		return e.executeAggregate(n)
+
+	case *planner.WindowNode:
+		return e.executeWindow(n)
 
-		return ResultSet{Columns: n.Columns, Rows: projectedRows}, nil
+	case *planner.SortNode:
+		return e.executeSort(n)
+
+	case *planner.DistinctNode:
+		return e.executeDistinct(n)
 
 	case *planner.InsertNode:
+		return e.executeInsert(n)
+
+	case *planner.DeleteNode:
 		table, ok := e.Tables[n.TableName]
 		if !ok {
 			return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
 		}
 
-		var convertedValues []interface{}
-		var err error
-
-		if len(n.Columns) > 0 {
-			// Handle explicitly named columns: INSERT INTO table (c1, c2) VALUES (v1, v2)
-			if len(n.Columns) != len(n.Values) {
-				return ResultSet{}, fmt.Errorf("column count (%d) does not match value count (%d)", len(n.Columns), len(n.Values))
-			}
-
-			// Map values to columns
-			valMap := make(map[string]string)
-			for i, colName := range n.Columns {
-				valMap[colName] = n.Values[i]
-			}
-
-			// Build the full row based on schema
-			fullValues := make([]string, len(table.Schema.Columns))
-			for i, col := range table.Schema.Columns {
-				if val, exists := valMap[col.Name]; exists {
-					fullValues[i] = val
-				} else {
-					fullValues[i] = "NULL"
-				}
-			}
-			convertedValues, err = e.convertValues(fullValues, table.Schema)
-		} else {
-			// Handle positional insert: INSERT INTO table VALUES (v1, v2, v3)
-			convertedValues, err = e.convertValues(n.Values, table.Schema)
+		where, err := e.compileWhere(n.Where, n.TableName, table.Schema)
+		if err != nil {
+			return ResultSet{}, err
 		}
 
+		rows, columns, err := e.targetRows(n.TableName, table, where, n.Using)
 		if err != nil {
 			return ResultSet{}, err
 		}
 
-		// Basic UNIQUE/PK constraint check (expensive scan)
-		existingRows, _ := table.SelectAll()
-		for _, col := range table.Schema.Columns {
-			if col.IsUnique || col.IsPrimaryKey {
-				colIdx := -1
-				for i, c := range table.Schema.Columns {
-					if c.Name == col.Name {
-						colIdx = i
-						break
-					}
-				}
-				newVal := convertedValues[colIdx]
-				for _, row := range existingRows {
-					if row.Values[colIdx] == newVal {
-						return ResultSet{}, fmt.Errorf("UNIQUE constraint violation on column %s: value %v already exists", col.Name, newVal)
-					}
-				}
+		// Every row is checked before any is deleted, so a rejected delete
+		// leaves the table unchanged.
+		for _, row := range rows {
+			// Referential integrity: reject delete if any child table references this row
+			if err := e.checkReferencingChildren(table, targetValues(row, table.Schema)); err != nil {
+				return ResultSet{}, err
 			}
 		}
-
-		// Foreign Key enforcement: verify referenced parent rows exist
-		for colIdx, col := range table.Schema.Columns {
-			if col.References == nil {
-				continue
-			}
-			parentTable, ok := e.Tables[col.References.Table]
-			if !ok {
-				return ResultSet{}, fmt.Errorf("FK error: referenced table '%s' not found", col.References.Table)
-			}
-			// find the referenced column index in parent
-			parentColIdx := -1
-			for i, pc := range parentTable.Schema.Columns {
-				if pc.Name == col.References.Column {
-					parentColIdx = i
-					break
-				}
-			}
-			if parentColIdx == -1 {
-				return ResultSet{}, fmt.Errorf("FK error: referenced column '%s' not found in table '%s'", col.References.Column, col.References.Table)
-			}
-			childVal := convertedValues[colIdx]
-			// NULL FK values are allowed (optional relationship)
-			if childVal == nil {
-				continue
-			}
-			parentRows, err2 := parentTable.SelectAll()
-			if err2 != nil {
-				return ResultSet{}, err2
-			}
-			found := false
-			for _, pr := range parentRows {
-				if fmt.Sprintf("%v", pr.Values[parentColIdx]) == fmt.Sprintf("%v", childVal) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return ResultSet{}, fmt.Errorf("FK constraint violation: value %v for column '%s' does not exist in '%s.%s'",
-					childVal, col.Name, col.References.Table, col.References.Column)
+		// RETURNING reads the joined rows of a DELETE ... USING.
+		var deleted [][]interface{}
+		for _, row := range rows {
+			if err := table.Delete(row.PageID, row.SlotID); err != nil {
+				return ResultSet{}, err
 			}
+			deleted = append(deleted, row.Values)
 		}
+		return e.returningResult(columns, deleted, n.Returning,
+			fmt.Sprintf("Deleted %d rows", len(deleted)))
 
-		err = table.Insert(convertedValues)
-		if err != nil {
-			return ResultSet{}, err
-		}
-		return ResultSet{}, nil
-
-	case *planner.DeleteNode:
+	case *planner.UpdateNode:
 		table, ok := e.Tables[n.TableName]
 		if !ok {
 			return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
 		}
 
-		rows, err := table.SelectAll()
+		where, err := e.compileWhere(n.Where, n.TableName, table.Schema)
 		if err != nil {
 			return ResultSet{}, err
 		}
 
-		deletedCount := 0
-		for _, row := range rows {
-			match := true
-			if n.Where != nil {
-				match, err = e.evaluateCondition(row, table.Schema, n.Where.Left, n.Where.Op, n.Where.Right)
-				if err != nil {
-					return ResultSet{}, err
-				}
-			}
-
-			if match {
-				// Referential integrity: reject delete if any child table references this row
-				if err2 := e.checkReferencingChildren(table, row); err2 != nil {
-					return ResultSet{}, err2
-				}
-				err = table.Delete(row.PageID, row.SlotID)
-				if err != nil {
-					return ResultSet{}, err
-				}
-				deletedCount++
-			}
-		}
-		return ResultSet{Message: fmt.Sprintf("Deleted %d rows", deletedCount)}, nil
-
-	case *planner.UpdateNode:
-		table, ok := e.Tables[n.TableName]
-		if !ok {
-			return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
+		rows, columns, err := e.targetRows(n.TableName, table, where, n.From)
+		if err != nil {
+			return ResultSet{}, err
 		}
 
-		rows, err := table.SelectAll()
+		sets, err := e.compileSets(n.Sets, table.Schema, columns)
 		if err != nil {
 			return ResultSet{}, err
 		}
 
-		updatedCount := 0
-		for _, row := range rows {
-			match := true
-			if n.Where != nil {
-				match, err = e.evaluateCondition(row, table.Schema, n.Where.Left, n.Where.Op, n.Where.Right)
-				if err != nil {
-					return ResultSet{}, err
-				}
+		// As with DELETE, nothing is written until every row passed its checks.
+		updated := make([][]interface{}, len(rows))
+		for i, input := range rows {
+			target := targetValues(input, table.Schema)
+			newValues, err := applySets(sets, table.Schema, input, target.Values)
+			if err != nil {
+				return ResultSet{}, err
 			}
 
-			if match {
-				newValues := make([]interface{}, len(row.Values))
-				copy(newValues, row.Values)
-
-				for colName, newValStr := range n.Sets {
-					colIdx := -1
-					for i, col := range table.Schema.Columns {
-						if col.Name == colName {
-							colIdx = i
-							break
-						}
-					}
-					if colIdx == -1 {
-						return ResultSet{}, fmt.Errorf("column not found in SET: %s", colName)
-					}
-
-					// Convert the string value from the query to the correct Go type for the column
-					converted, err := e.convertSingleValue(newValStr, table.Schema.Columns[colIdx])
-					if err != nil {
-						return ResultSet{}, err
-					}
-					newValues[colIdx] = converted
-				}
-
-				// --- FK ENFORCEMENT ON UPDATE ---
-
-				// 1. If we are updating a CHILD column (FK), verify the new value exists in the parent
-				for colIdx, col := range table.Schema.Columns {
-					if col.References == nil {
-						continue
-					}
-					// Did this column change?
-					if fmt.Sprintf("%v", row.Values[colIdx]) == fmt.Sprintf("%v", newValues[colIdx]) {
-						continue
-					}
-
-					parentTable, ok := e.Tables[col.References.Table]
-					if !ok {
-						return ResultSet{}, fmt.Errorf("FK error: parent table '%s' not found", col.References.Table)
-					}
-					parentColIdx := -1
-					for i, pc := range parentTable.Schema.Columns {
-						if pc.Name == col.References.Column {
-							parentColIdx = i
-							break
-						}
-					}
-					if parentColIdx == -1 {
-						return ResultSet{}, fmt.Errorf("FK error: ref column '%s' not found in '%s'", col.References.Column, col.References.Table)
-					}
-
-					newFKVal := newValues[colIdx]
-					if newFKVal == nil {
-						continue // NULL allowed
-					}
-
-					parentRows, _ := parentTable.SelectAll()
-					found := false
-					for _, pr := range parentRows {
-						if fmt.Sprintf("%v", pr.Values[parentColIdx]) == fmt.Sprintf("%v", newFKVal) {
-							found = true
-							break
-						}
-					}
-					if !found {
-						return ResultSet{}, fmt.Errorf("FK constraint violation on update: value %v does not exist in '%s.%s'",
-							newFKVal, col.References.Table, col.References.Column)
-					}
-				}
-
-				// 2. If we are updating a PARENT row's PK, block if children reference the OLD value
-				for colIdx, col := range table.Schema.Columns {
-					if !col.IsPrimaryKey {
-						continue
-					}
-					// Did PK change?
-					if fmt.Sprintf("%v", row.Values[colIdx]) == fmt.Sprintf("%v", newValues[colIdx]) {
-						continue
-					}
-					// PK changed – check children
-					if err2 := e.checkReferencingChildren(table, row); err2 != nil {
-						return ResultSet{}, fmt.Errorf("FK constraint violation on update: cannot change PK because %v", err2)
-					}
-				}
-
-				err = table.Update(row.PageID, row.SlotID, newValues)
-				if err != nil {
-					return ResultSet{}, err
-				}
-				updatedCount++
+			if err := e.checkUpdateForeignKeys(table, target, newValues); err != nil {
+				return ResultSet{}, err
+			}
+			updated[i] = newValues
+		}
+		for i, row := range rows {
+			if err := table.Update(row.PageID, row.SlotID, updated[i]); err != nil {
+				return ResultSet{}, err
+			}
+		}
+		// RETURNING reads the new values, joined with the other tables'
+		// values for an UPDATE ... FROM.
+		returned := updated
+		if n.From != nil {
+			returned = make([][]interface{}, len(updated))
+			for i, values := range updated {
+				returned[i] = append(append([]interface{}{}, values...), rows[i].Values[len(values):]...)
 			}
 		}
-		return ResultSet{Message: fmt.Sprintf("Updated %d rows", updatedCount)}, nil
+		return e.returningResult(columns, returned, n.Returning,
+			fmt.Sprintf("Updated %d rows", len(updated)))
 
 	case *planner.CreateDatabaseNode:
 		err := e.Engine.CreateDatabase(n.DatabaseName)
@@ -421,6 +338,15 @@ func (e *Executor) Execute(plan planner.PlanNode) (ResultSet, error) {
 		}
 		return ResultSet{}, nil
 
+	case *planner.ShowNode:
+		return e.executeShow(n)
+
+	case *planner.CopyNode:
+		return e.executeCopy(n)
+
+	case *planner.CopyToNode:
+		return e.executeCopyTo(n)
+
 	case *planner.UseDatabaseNode:
 		err := e.Engine.UseDatabase(n.DatabaseName)
 		if err != nil {
@@ -495,168 +421,63 @@ func (e *Executor) Execute(plan planner.PlanNode) (ResultSet, error) {
 
 	case *planner.JoinNode:
 		return e.executeJoin(n)
-	}
 
-	return ResultSet{}, fmt.Errorf("unknown plan node type")
-}
+	case *planner.PrepareNode:
+		return e.executePrepare(n)
 
-func (e *Executor) getTableFromPlan(plan planner.PlanNode) *storage.Table {
-	switch n := plan.(type) {
-	case *planner.ScanNode:
-		return e.Tables[n.TableName]
-	case *planner.FilterNode:
-		return e.getTableFromPlan(n.Child)
-	case *planner.ProjectNode:
-		return e.getTableFromPlan(n.Child)
-	}
-	return nil
-}
+	case *planner.ExecuteNode:
+		return e.executePrepared(n)
 
-func (e *Executor) evaluateFilter(row storage.Row, schema *storage.Schema, filter *planner.FilterNode) (bool, error) {
-	return e.evaluateCondition(row, schema, filter.Left, filter.Op, filter.Right)
-}
+	case *planner.DeallocateNode:
+		return e.executeDeallocate(n)
 
-func (e *Executor) evaluateCondition(row storage.Row, schema *storage.Schema, left, op, right string) (bool, error) {
-	colIdx := -1
-	for i, col := range schema.Columns {
-		if col.Name == left {
-			colIdx = i
-			break
-		}
+	case *planner.ExplainNode:
+		return e.executeExplain(n)
 	}
 
-	if colIdx == -1 {
-		return false, fmt.Errorf("column not found: %s", left)
-	}
-
-	val := row.Values[colIdx]
-
-	if val == nil {
-		if strings.ToUpper(right) == "NULL" {
-			if op == "=" {
-				return true, nil
-			}
-			if op == "!=" {
-				return false, nil
-			}
-		}
-		return false, nil
-	}
-
-	switch v := val.(type) {
-	case int32:
-		target, err := strconv.Atoi(right)
-		if err != nil {
-			return false, fmt.Errorf("invalid value for int32: %s", right)
-		}
-		rhs := int32(target)
-		switch op {
-		case "=":
-			return v == rhs, nil
-		case "!=":
-			return v != rhs, nil
-		case ">":
-			return v > rhs, nil
-		case "<":
-			return v < rhs, nil
-		case ">=":
-			return v >= rhs, nil
-		case "<=":
-			return v <= rhs, nil
-		}
-	case uint32:
-		target, err := strconv.ParseUint(right, 10, 32)
-		if err != nil {
-			return false, fmt.Errorf("invalid value for uint32: %s", right)
-		}
-		rhs := uint32(target)
-		switch op {
-		case "=":
-			return v == rhs, nil
-		case "!=":
-			return v != rhs, nil
-		case ">":
-			return v > rhs, nil
-		case "<":
-			return v < rhs, nil
-		case ">=":
-			return v >= rhs, nil
-		case "<=":
-			return v <= rhs, nil
-		}
-	case string:
-		rhs := right
-		switch op {
-		case "=":
-			return v == rhs, nil
-		case "!=":
-			return v != rhs, nil
-		case ">":
-			return v > rhs, nil
-		case "<":
-			return v < rhs, nil
-		case ">=":
-			return v >= rhs, nil
-		case "<=":
-			return v <= rhs, nil
-		}
-	}
-
-	return false, nil
+	return ResultSet{}, fmt.Errorf("unknown plan node type")
 }
 
-func (e *Executor) applyProjection(rows []storage.Row, schema *storage.Schema, columns []string) ([]storage.Row, error) {
-	colIndices := []int{}
-	for _, colName := range columns {
-		found := false
-		for i, col := range schema.Columns {
-			if col.Name == colName {
-				colIndices = append(colIndices, i)
-				found = true
-				break
+// applyProjection evaluates the select list against every row of res.
+func (e *Executor) applyProjection(res ResultSet, columns []ast.Expression) (ResultSet, error) {
+	var names []string
+	var fns []evalFunc
+	for _, col := range columns {
+		if _, ok := col.(*ast.Star); ok {
+			for i, name := range res.Columns {
+				names = append(names, name)
+				fns = append(fns, columnRef(i))
 			}
+			continue
+		}
+		name := columnName(col)
+		if ae, ok := col.(*ast.AliasExpression); ok {
+			name, col = ae.Alias, ae.Expr
 		}
-		if !found {
-			return nil, fmt.Errorf("column not found: %s", colName)
+		fn, err := e.compileExpr(col, res.Columns)
+		if err != nil {
+			return ResultSet{}, err
 		}
+		names = append(names, name)
+		fns = append(fns, fn)
 	}
 
 	projectedRows := []storage.Row{}
-	for _, row := range rows {
-		newValues := []interface{}{}
-		for _, idx := range colIndices {
-			newValues = append(newValues, row.Values[idx])
+	for _, row := range res.Rows {
+		newValues := make([]interface{}, len(fns))
+		for i, fn := range fns {
+			v, err := fn(row)
+			if err != nil {
+				return ResultSet{}, err
+			}
+			newValues[i] = v
 		}
 		projectedRows = append(projectedRows, storage.Row{Values: newValues})
 	}
-	return projectedRows, nil
-}
-
-func (e *Executor) convertValues(values []string, schema *storage.Schema) ([]interface{}, error) {
-	if len(values) != len(schema.Columns) {
-		return nil, fmt.Errorf("value count mismatch: expected %d, got %d", len(schema.Columns), len(values))
-	}
-
-	converted := make([]interface{}, len(values))
-	for i, col := range schema.Columns {
-		val, err := e.convertSingleValue(values[i], col)
-		if err != nil {
-			return nil, err
-		}
-		converted[i] = val
-	}
-	return converted, nil
+	return ResultSet{Columns: names, Rows: projectedRows}, nil
 }
 
 func (e *Executor) convertSingleValue(val string, col storage.Column) (interface{}, error) {
-	// Handle NULL (if we had a NULL token in values, but for now we check string "NULL")
-	if strings.ToUpper(val) == "NULL" {
-		if !col.IsNullable {
-			return nil, fmt.Errorf("column %s cannot be NULL", col.Name)
-		}
-		return nil, nil
-	}
-
 	switch col.Type {
 	case storage.TypeInt32:
 		v, err := strconv.Atoi(val)
@@ -0,0 +1,1825 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+	"github.com/Mohammad-y-abbass/moDB/internal/parser"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// newTestExecutor returns an executor on a fresh database in a temp dir.
+func newTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+	e := New(storage.NewEngine(t.TempDir()))
+	mustExec(t, e, "CREATE DATABASE test")
+	mustExec(t, e, "USE test")
+	return e
+}
+
+// run parses, plans and executes every statement in sql, returning the
+// result of the last one.
+func run(e *Executor, sql string) (ResultSet, error) {
+	p := parser.New(lexer.New(sql))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return ResultSet{}, fmt.Errorf("%s", p.GetErrorMessage())
+	}
+	var res ResultSet
+	for _, stmt := range program.Statements {
+		node, err := planner.New(e).GeneratePlan(stmt)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		if res, err = e.Execute(node); err != nil {
+			return ResultSet{}, err
+		}
+	}
+	return res, nil
+}
+
+func mustExec(t *testing.T, e *Executor, sql string) ResultSet {
+	t.Helper()
+	res, err := run(e, sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return res
+}
+
+// rowsToStrings renders result rows for easy comparison.
+func rowsToStrings(res ResultSet) []string {
+	out := make([]string, len(res.Rows))
+	for i, row := range res.Rows {
+		out[i] = fmt.Sprintf("%v", row.Values)
+	}
+	return out
+}
+
+func expectRows(t *testing.T, res ResultSet, expected ...string) {
+	t.Helper()
+	got := rowsToStrings(res)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d rows %v, got %d rows %v", len(expected), expected, len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("row %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+func seedEmployees(t *testing.T, e *Executor) {
+	t.Helper()
+	mustExec(t, e, "CREATE TABLE employees (id INT PRIMARY KEY, name TEXT, dept TEXT, salary INT)")
+	for _, v := range []string{
+		"(1, 'ann', 'eng', 100)",
+		"(2, 'bob', 'eng', 80)",
+		"(3, 'cat', 'ops', 50)",
+		"(4, 'dan', 'ops', 50)",
+	} {
+		mustExec(t, e, "INSERT INTO employees VALUES "+v)
+	}
+	mustExec(t, e, "INSERT INTO employees (id, name, dept) VALUES (5, 'eve', 'hr')")
+}
+
+func TestAggregateGroupBy(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	res := mustExec(t, e, "SELECT dept, COUNT(*), COUNT(salary), SUM(salary), MIN(name), MAX(salary) FROM employees GROUP BY dept")
+	expectRows(t, res,
+		"[eng 2 2 180 ann 100]",
+		"[ops 2 2 100 cat 50]",
+		"[hr 1 0 <nil> eve <nil>]",
+	)
+	if res.Columns[1] != "COUNT(*)" {
+		t.Errorf("expected column header COUNT(*), got %s", res.Columns[1])
+	}
+
+	res = mustExec(t, e, "SELECT dept, AVG(salary), COUNT(DISTINCT salary), STRING_AGG(name, '|') FROM employees WHERE salary >= 50 GROUP BY dept HAVING COUNT(*) > 1")
+	expectRows(t, res,
+		"[eng 90 2 ann|bob]",
+		"[ops 50 1 cat|dan]",
+	)
+}
+
+func TestAggregateWithoutGroupBy(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*), SUM(salary) FROM employees"), "[5 280]")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*), SUM(salary) FROM employees WHERE id > 10"), "[0 <nil>]")
+}
+
+func TestAggregateSortFallback(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	saved := maxHashGroups
+	maxHashGroups = 1
+	defer func() { maxHashGroups = saved }()
+
+	res := mustExec(t, e, "SELECT dept, COUNT(*) FROM employees GROUP BY dept")
+	expectRows(t, res, "[eng 2]", "[hr 1]", "[ops 2]")
+}
+
+func TestAggregateErrors(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	for _, sql := range []string{
+		"SELECT name, COUNT(*) FROM employees GROUP BY dept",
+		"SELECT SUM(*) FROM employees",
+		"SELECT COUNT(SUM(salary)) FROM employees",
+		"SELECT STRING_AGG(name, dept) FROM employees",
+		"SELECT * FROM employees GROUP BY dept",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY salary DESC, name"),
+		"[eve]", "[ann]", "[bob]", "[cat]", "[dan]")
+	expectRows(t, mustExec(t, e, "SELECT name, salary FROM employees ORDER BY 2, 1 DESC"),
+		"[dan 50]", "[cat 50]", "[bob 80]", "[ann 100]", "[eve <nil>]")
+	expectRows(t, mustExec(t, e, "SELECT dept, COUNT(*) FROM employees GROUP BY dept ORDER BY COUNT(*), dept"),
+		"[hr 1]", "[eng 2]", "[ops 2]")
+}
+
+func TestDistinct(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "INSERT INTO employees (id, name, dept) VALUES (6, 'fay', 'hr')")
+
+	expectRows(t, mustExec(t, e, "SELECT DISTINCT dept FROM employees ORDER BY dept"),
+		"[eng]", "[hr]", "[ops]")
+	// NULLs are not distinct from each other.
+	expectRows(t, mustExec(t, e, "SELECT DISTINCT dept, salary FROM employees ORDER BY dept, salary"),
+		"[eng 80]", "[eng 100]", "[hr <nil>]", "[ops 50]")
+	expectRows(t, mustExec(t, e, "SELECT DISTINCT ON (dept) dept, name FROM employees ORDER BY dept, salary DESC, name"),
+		"[eng ann]", "[hr eve]", "[ops cat]")
+	expectRows(t, mustExec(t, e, "SELECT DISTINCT ON (dept) name FROM employees ORDER BY dept, name DESC"),
+		"[bob]", "[fay]", "[dan]")
+
+	for _, sql := range []string{
+		"SELECT DISTINCT dept FROM employees ORDER BY name",
+		"SELECT DISTINCT ON (dept) name FROM employees ORDER BY name",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestScalarFunctions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT UPPER(name), LOWER('AbC'), LENGTH(name) FROM employees WHERE id = 1", "[ANN abc 3]"},
+		{"SELECT SUBSTR('database', 5), SUBSTR('database', 1, 4), SUBSTR('abc', 0, 2) FROM employees WHERE id = 1", "[base data a]"},
+		{"SELECT TRIM('  x  '), TRIM('--x--', '-'), REPLACE(name, 'n', 'N') FROM employees WHERE id = 1", "[x x aNN]"},
+		{"SELECT CONCAT(name, '-', id, NULL), name || '@' || dept, name || NULL FROM employees WHERE id = 1", "[ann-1 ann@eng <nil>]"},
+		{"SELECT ABS(-5), ROUND(AVG(salary)), ROUND(CAST('2.5' AS FLOAT)), ROUND(1234, -2) FROM employees", "[5 70 3 1200]"},
+		{"SELECT ROUND(2.5), ROUND(-2.5), ROUND(3.14159, 2), ABS(-0.5) FROM employees WHERE id = 1", "[3 -3 3.14 0.5]"},
+		{"SELECT COALESCE(salary, 0), NULLIF(dept, 'hr'), NULLIF(salary, 0) FROM employees WHERE id = 5", "[0 <nil> <nil>]"},
+		{"SELECT CAST(id AS TEXT) || 'x', CAST('42' AS INT), CAST(salary AS FLOAT) FROM employees WHERE id = 2", "[2x 42 80]"},
+	}
+	for _, tt := range tests {
+		res := mustExec(t, e, tt.sql)
+		if len(res.Rows) != 1 {
+			t.Fatalf("%s: expected 1 row, got %d", tt.sql, len(res.Rows))
+		}
+		if got := fmt.Sprintf("%v", res.Rows[0].Values); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE UPPER(dept) = 'OPS' AND LENGTH(name) = 3 ORDER BY name"),
+		"[cat]", "[dan]")
+
+	mustExec(t, e, "UPDATE employees SET name = UPPER(name), dept = CONCAT(dept, '-', id) WHERE salary < 60")
+	expectRows(t, mustExec(t, e, "SELECT name, dept FROM employees WHERE salary < 60 ORDER BY id"),
+		"[CAT ops-3]", "[DAN ops-4]")
+}
+
+func TestScalarFunctionPlanErrors(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	for _, sql := range []string{
+		"SELECT UPPER(id) FROM employees",
+		"SELECT UPPER(name, dept) FROM employees",
+		"SELECT SUBSTR(name) FROM employees",
+		"SELECT ABS(name) FROM employees",
+		"SELECT COALESCE(name, 1) FROM employees",
+		"SELECT NOPE(name) FROM employees",
+		"SELECT CAST(name AS BLOB) FROM employees",
+		"SELECT name FROM employees WHERE LENGTH(name)",
+		"UPDATE employees SET name = LOWER(salary)",
+		"DELETE FROM employees WHERE missing = 1",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func expectColumns(t *testing.T, res ResultSet, expected ...string) {
+	t.Helper()
+	if fmt.Sprint(res.Columns) != fmt.Sprint(expected) {
+		t.Errorf("expected columns %v, got %v", expected, res.Columns)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE teams (dept TEXT, lead INT)")
+	mustExec(t, e, "INSERT INTO teams VALUES ('eng', 1)")
+	mustExec(t, e, "INSERT INTO teams VALUES ('ops', 3)")
+
+	res := mustExec(t, e, "SELECT * FROM employees WHERE id = 1")
+	expectColumns(t, res, "id", "name", "dept", "salary")
+
+	res = mustExec(t, e, "SELECT e.name AS who, t.dept team FROM employees AS e JOIN teams t ON t.lead = e.id ORDER BY who")
+	expectColumns(t, res, "who", "team")
+	expectRows(t, res, "[ann eng]", "[cat ops]")
+
+	// Self-join: every employee next to their team lead.
+	res = mustExec(t, e, "SELECT w.name, l.name AS lead FROM employees w JOIN employees l ON w.dept = l.dept "+
+		"WHERE l.id = 1 OR l.id = 3 ORDER BY w.id")
+	expectColumns(t, res, "w.name", "lead")
+	expectRows(t, res, "[ann ann]", "[bob ann]", "[cat cat]", "[dan cat]")
+
+	res = mustExec(t, e, "SELECT * FROM employees a JOIN teams b ON a.id = b.lead")
+	expectColumns(t, res, "a.id", "a.name", "a.dept", "a.salary", "b.dept", "b.lead")
+
+	res = mustExec(t, e, "SELECT UPPER(dept) AS d, COUNT(*) AS n FROM employees GROUP BY d ORDER BY n DESC, d")
+	expectColumns(t, res, "d", "n")
+	expectRows(t, res, "[ENG 2]", "[OPS 2]", "[HR 1]")
+
+	// An output alias wins over the input column of the same name in ORDER BY.
+	expectRows(t, mustExec(t, e, "SELECT name, salary AS id FROM employees WHERE salary > 0 ORDER BY id, name"),
+		"[cat 50]", "[dan 50]", "[bob 80]", "[ann 100]")
+
+	for _, sql := range []string{
+		"SELECT name FROM employees JOIN employees ON employees.id = employees.id",
+		"SELECT employees.name FROM employees e",
+		"SELECT e.name FROM employees e JOIN teams t ON t.lead = e.missing",
+		"SELECT name AS a, dept AS a FROM employees ORDER BY a",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestJoins(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE depts (name TEXT, floor INT)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('eng', 1)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('ops', 2)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('legal', 3)")
+	mustExec(t, e, "CREATE TABLE floors (num INT, label TEXT)")
+	mustExec(t, e, "INSERT INTO floors VALUES (1, 'ground')")
+	mustExec(t, e, "INSERT INTO floors VALUES (3, 'top')")
+
+	expectRows(t, mustExec(t, e, "SELECT e.name, d.name FROM employees e JOIN depts d ON d.name = e.dept AND e.salary > 60 ORDER BY e.id"),
+		"[ann eng]", "[bob eng]")
+
+	expectRows(t, mustExec(t, e, "SELECT e.name, d.floor FROM employees e LEFT JOIN depts d ON d.name = e.dept WHERE e.id > 2 ORDER BY e.id"),
+		"[cat 2]", "[dan 2]", "[eve <nil>]")
+
+	expectRows(t, mustExec(t, e, "SELECT d.name, COUNT(e.id) FROM employees e RIGHT OUTER JOIN depts d ON d.name = e.dept GROUP BY d.name ORDER BY d.name"),
+		"[eng 2]", "[legal 0]", "[ops 2]")
+
+	expectRows(t, mustExec(t, e, "SELECT e.name, d.name FROM employees e FULL JOIN depts d ON d.name = e.dept WHERE COALESCE(e.id, 0) = 0 OR e.id = 5"),
+		"[eve <nil>]", "[<nil> legal]")
+
+	// Three-way join: the ON condition may refer to any table joined before it.
+	expectRows(t, mustExec(t, e, "SELECT e.name, f.label FROM employees e JOIN depts d ON d.name = e.dept "+
+		"LEFT JOIN floors f ON f.num = d.floor WHERE e.salary >= 50 ORDER BY e.id"),
+		"[ann ground]", "[bob ground]", "[cat <nil>]", "[dan <nil>]")
+
+	res := mustExec(t, e, "SELECT COUNT(*) FROM employees CROSS JOIN depts")
+	expectRows(t, res, "[15]")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM depts, floors, employees"), "[30]")
+
+	for _, sql := range []string{
+		"SELECT * FROM employees e JOIN depts d ON f.num = 1 JOIN floors f ON TRUE",
+		"SELECT * FROM employees e JOIN depts d ON e.name",
+		"SELECT * FROM employees e JOIN depts d ON COUNT(*) > 1",
+		"SELECT name FROM employees e JOIN depts d ON d.name = e.dept",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+// forceJoinMethod switches every join in a plan to method.
+func forceJoinMethod(node planner.PlanNode, method planner.JoinMethod) {
+	switch n := node.(type) {
+	case *planner.JoinNode:
+		n.Method = method
+		forceJoinMethod(n.Left, method)
+		forceJoinMethod(n.Right, method)
+	case *planner.FilterNode:
+		forceJoinMethod(n.Child, method)
+	case *planner.ProjectNode:
+		forceJoinMethod(n.Child, method)
+	case *planner.SortNode:
+		forceJoinMethod(n.Child, method)
+	case *planner.AggregateNode:
+		forceJoinMethod(n.Child, method)
+	case *planner.DistinctNode:
+		forceJoinMethod(n.Child, method)
+	case *planner.UpdateNode:
+		forceJoinMethod(n.From, method)
+	case *planner.DeleteNode:
+		forceJoinMethod(n.Using, method)
+	}
+}
+
+func TestJoinMethods(t *testing.T) {
+	e := newTestExecutor(t)
+	mustExec(t, e, "CREATE TABLE l (id INT, k INT, tag TEXT)")
+	mustExec(t, e, "CREATE TABLE r (id INT, k INT, tag TEXT)")
+	for i := 1; i <= 40; i++ {
+		mustExec(t, e, fmt.Sprintf("INSERT INTO l VALUES (%d, %d, 't%d')", i, i%7, i%3))
+		mustExec(t, e, fmt.Sprintf("INSERT INTO r VALUES (%d, %d, 't%d')", i, i%11, i%2))
+	}
+	mustExec(t, e, "INSERT INTO l (id, tag) VALUES (41, 't0')")
+	mustExec(t, e, "INSERT INTO r (id, tag) VALUES (41, 't0')")
+
+	var queries []string
+	for _, join := range []string{"JOIN", "LEFT JOIN", "RIGHT JOIN", "FULL JOIN"} {
+		queries = append(queries, "SELECT l.id, r.id FROM l "+join+" r ON l.k = r.k AND l.tag = r.tag AND l.id < r.id ORDER BY l.id, r.id")
+	}
+	// Decorrelated subqueries plan as semi and anti joins.
+	for _, test := range []string{"EXISTS", "NOT EXISTS"} {
+		queries = append(queries, "SELECT l.id FROM l WHERE "+test+" (SELECT r.id FROM r WHERE r.k = l.k AND r.tag = l.tag AND r.id > 30) ORDER BY l.id")
+	}
+	for _, sql := range queries {
+		stmt := parser.New(lexer.New(sql)).ParseProgram().Statements[0]
+
+		var expected []string
+		for _, tt := range []struct {
+			method   planner.JoinMethod
+			sortRows int
+		}{
+			{planner.NestedLoopJoin, maxSortRows},
+			{planner.HashJoin, maxSortRows},
+			{planner.MergeJoin, maxSortRows},
+			{planner.MergeJoin, 4}, // sorted in spilled runs
+		} {
+			plan, err := planner.New(e).GeneratePlan(stmt)
+			if err != nil {
+				t.Fatalf("%s: %v", sql, err)
+			}
+			forceJoinMethod(plan, tt.method)
+			saved := maxSortRows
+			maxSortRows = tt.sortRows
+			res, err := e.Execute(plan)
+			maxSortRows = saved
+			if err != nil {
+				t.Fatalf("%s (%s, runs of %d): %v", sql, tt.method, tt.sortRows, err)
+			}
+			got := rowsToStrings(res)
+			if expected == nil {
+				expected = got
+				continue
+			}
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Errorf("%s: %s with runs of %d returned %d rows, nested loop %d", sql, tt.method, tt.sortRows, len(got), len(expected))
+			}
+		}
+		if len(expected) == 0 {
+			t.Errorf("%s: expected some rows", sql)
+		}
+	}
+}
+
+func TestMergeJoinSpill(t *testing.T) {
+	e := newTestExecutor(t)
+	mustExec(t, e, "CREATE TABLE l (id INT, k INT)")
+	mustExec(t, e, "CREATE TABLE r (id INT, k INT)")
+	for i := 1; i <= 30; i++ {
+		mustExec(t, e, fmt.Sprintf("INSERT INTO l VALUES (%d, %d)", i, i%5))
+		mustExec(t, e, fmt.Sprintf("INSERT INTO r VALUES (%d, %d)", i, i%6))
+	}
+	sql := "SELECT COUNT(*) FROM l JOIN r ON l.k = r.k"
+	plan, err := planner.New(e).GeneratePlan(parser.New(lexer.New(sql)).ParseProgram().Statements[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	forceJoinMethod(plan, planner.MergeJoin)
+	defer func(saved int) { maxSortRows = saved }(maxSortRows)
+
+	// Runs go to the temporary directory and are removed afterwards.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	maxSortRows = 4
+	res, err := e.Execute(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRows(t, res, "[150]")
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("expected the spilled runs to be removed, found %d files", len(entries))
+	}
+
+	// Without a usable temporary directory only an input that fits in
+	// memory can be sorted.
+	t.Setenv("TMPDIR", filepath.Join(tmp, "missing"))
+	if _, err := e.Execute(plan); err == nil || !strings.Contains(err.Error(), "spill") {
+		t.Errorf("expected the join to spill and fail, got %v", err)
+	}
+	maxSortRows = 30
+	res, err = e.Execute(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRows(t, res, "[150]")
+}
+
+func TestSubqueries(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE depts (name TEXT NOT NULL, budget INT)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('eng', 500)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('ops', 100)")
+	mustExec(t, e, "INSERT INTO depts (name) VALUES ('legal')")
+
+	// Decorrelated into semi and anti joins.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE dept IN (SELECT name FROM depts WHERE budget > 200) ORDER BY id"),
+		"[ann]", "[bob]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE dept NOT IN (SELECT name FROM depts) ORDER BY id"),
+		"[eve]")
+	expectRows(t, mustExec(t, e, "SELECT d.name FROM depts d WHERE EXISTS (SELECT id FROM employees e WHERE e.dept = d.name AND e.salary > 60)"),
+		"[eng]")
+	expectRows(t, mustExec(t, e, "SELECT d.name FROM depts d WHERE NOT EXISTS (SELECT id FROM employees e WHERE e.dept = d.name)"),
+		"[legal]")
+
+	// An unqualified name means the column of its own query, even when the
+	// other query has a column of that name too.
+	mustExec(t, e, "CREATE TABLE orders (id INT, user_id INT)")
+	mustExec(t, e, "INSERT INTO orders VALUES (10, 2), (11, 4), (12, 2)")
+	sql := "SELECT name FROM employees WHERE id IN (SELECT user_id FROM orders) ORDER BY id"
+	expectRows(t, mustExec(t, e, sql), "[bob]", "[dan]")
+	var plan []string
+	for _, row := range mustExec(t, e, "EXPLAIN "+sql).Rows {
+		plan = append(plan, row.Values[0].(string))
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), ": SEMI ON employees.id = orders.user_id") {
+		t.Errorf("expected a semi join, got plan %q", plan)
+	}
+
+	// Correlated subqueries that cannot be decorrelated run per outer row.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees e WHERE salary > (SELECT AVG(salary) FROM employees i WHERE i.dept = e.dept) ORDER BY id"),
+		"[ann]")
+	expectRows(t, mustExec(t, e, "SELECT name, (SELECT budget FROM depts WHERE depts.name = e.dept) FROM employees e ORDER BY id"),
+		"[ann 500]", "[bob 500]", "[cat 100]", "[dan 100]", "[eve <nil>]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM depts d WHERE EXISTS (SELECT e.dept FROM employees e GROUP BY e.dept HAVING e.dept = d.name AND COUNT(*) > 1) OR budget = 0"),
+		"[eng]", "[ops]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE salary = (SELECT MAX(salary) FROM employees)"),
+		"[ann]")
+
+	// NOT IN is NULL, not TRUE, once the subquery returns a NULL.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE salary NOT IN (SELECT budget FROM depts)"))
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE salary IN (SELECT budget FROM depts) OR id = 5"),
+		"[ann]", "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE id IN (1, 3, 42) ORDER BY id"), "[ann]", "[cat]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE id NOT IN (1, 3) AND COALESCE(salary, 0) = 0"), "[eve]")
+
+	mustExec(t, e, "UPDATE employees SET salary = 0 WHERE dept IN (SELECT name FROM depts WHERE budget < 200)")
+	mustExec(t, e, "DELETE FROM employees WHERE NOT EXISTS (SELECT name FROM depts WHERE name = dept)")
+	expectRows(t, mustExec(t, e, "SELECT name, salary FROM employees ORDER BY id"),
+		"[ann 100]", "[bob 80]", "[cat 0]", "[dan 0]")
+
+	for _, sql := range []string{
+		"SELECT name FROM employees WHERE id = (SELECT id, name FROM employees)",
+		"SELECT name FROM employees WHERE id IN (SELECT name FROM depts)",
+		"SELECT name FROM employees WHERE id = (SELECT name FROM depts)",
+		"SELECT name FROM employees WHERE id = (SELECT id FROM employees)",
+		"SELECT name FROM employees WHERE EXISTS (SELECT nope FROM depts)",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestCommonTableExpressions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE staff (id INT PRIMARY KEY, name TEXT, manager_id INT)")
+	mustExec(t, e, "INSERT INTO staff (id, name) VALUES (1, 'ceo')")
+	for _, v := range []string{"(2, 'cto', 1)", "(3, 'cfo', 1)", "(4, 'dev', 2)", "(5, 'intern', 4)", "(6, 'clerk', 3)"} {
+		mustExec(t, e, "INSERT INTO staff VALUES "+v)
+	}
+
+	expectRows(t, mustExec(t, e, "WITH eng AS (SELECT id, name FROM employees WHERE dept = 'eng') SELECT name FROM eng ORDER BY id"),
+		"[ann]", "[bob]")
+
+	// Later CTEs read earlier ones; column lists rename the columns.
+	res := mustExec(t, e, "WITH totals (dept, total) AS (SELECT dept, SUM(salary) FROM employees GROUP BY dept), "+
+		"big AS (SELECT dept FROM totals WHERE total > 100) "+
+		"SELECT t.dept, t.total FROM totals t JOIN big b ON b.dept = t.dept ORDER BY t.dept")
+	expectColumns(t, res, "t.dept", "t.total")
+	expectRows(t, res, "[eng 180]")
+
+	// A CTE shadows a table of the same name.
+	expectRows(t, mustExec(t, e, "WITH employees AS (SELECT name FROM staff WHERE id = 1) SELECT name FROM employees"), "[ceo]")
+
+	// Everyone under the CEO, with their path in the hierarchy.
+	expectRows(t, mustExec(t, e, "WITH RECURSIVE reports (id, path) AS ("+
+		"SELECT id, name FROM staff WHERE manager_id = 1 "+
+		"UNION ALL SELECT s.id, r.path || '/' || s.name FROM staff s JOIN reports r ON s.manager_id = r.id) "+
+		"SELECT path FROM reports ORDER BY path"),
+		"[cfo]", "[cfo/clerk]", "[cto]", "[cto/dev]", "[cto/dev/intern]")
+
+	// The chain of managers above the intern.
+	expectRows(t, mustExec(t, e, "WITH RECURSIVE chain AS ("+
+		"SELECT id, name, manager_id FROM staff WHERE name = 'intern' "+
+		"UNION SELECT s.id, s.name, s.manager_id FROM chain c, staff s WHERE s.id = c.manager_id) "+
+		"SELECT COUNT(*), STRING_AGG(name, '<') FROM chain"),
+		"[4 intern<dev<cto<ceo]")
+
+	// A non-recursive UNION runs each term once.
+	expectRows(t, mustExec(t, e, "WITH names AS (SELECT name FROM staff WHERE id < 3 UNION ALL SELECT name FROM employees WHERE id < 3) "+
+		"SELECT name FROM names ORDER BY name"),
+		"[ann]", "[bob]", "[ceo]", "[cto]")
+
+	// CTEs are usable from subqueries of the main query.
+	expectRows(t, mustExec(t, e, "WITH bosses AS (SELECT manager_id FROM staff) "+
+		"SELECT name FROM staff WHERE id IN (SELECT manager_id FROM bosses) ORDER BY id"),
+		"[ceo]", "[cto]", "[cfo]", "[dev]")
+
+	mustExec(t, e, "UPDATE staff SET manager_id = 5 WHERE id = 1")
+	cyclic := "WITH RECURSIVE up AS (SELECT id, manager_id FROM staff WHERE id = 5 " +
+		"UNION %s SELECT s.id, s.manager_id FROM staff s JOIN up ON s.id = up.manager_id) SELECT COUNT(*) FROM up"
+	// UNION stops once no new rows are found; UNION ALL hits the limit.
+	expectRows(t, mustExec(t, e, fmt.Sprintf(cyclic, "")), "[4]")
+	saved := maxRecursionDepth
+	maxRecursionDepth = 50
+	defer func() { maxRecursionDepth = saved }()
+	if _, err := run(e, fmt.Sprintf(cyclic, "ALL")); err == nil || !strings.Contains(err.Error(), "maximum depth") {
+		t.Errorf("expected a recursion depth error, got %v", err)
+	}
+
+	for _, sql := range []string{
+		"WITH a AS (SELECT id FROM staff), a AS (SELECT id FROM staff) SELECT id FROM a",
+		"WITH a (x, y) AS (SELECT id FROM staff) SELECT x FROM a",
+		"WITH a AS (SELECT id FROM staff UNION SELECT id, name FROM staff) SELECT id FROM a",
+		"WITH a AS (SELECT id FROM staff UNION SELECT name FROM staff) SELECT id FROM a",
+		"WITH RECURSIVE a AS (SELECT id FROM staff UNION SELECT a.id FROM a, a b) SELECT id FROM a",
+		"WITH RECURSIVE a AS (SELECT id FROM staff UNION SELECT id FROM staff WHERE id IN (SELECT id FROM a)) SELECT id FROM a",
+		"WITH a AS (SELECT id FROM staff) SELECT name FROM a",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE contractors (id INT, name TEXT, dept TEXT)")
+	for _, v := range []string{"(10, 'zed', 'ops')", "(11, 'ann', 'eng')", "(12, 'ann', 'eng')"} {
+		mustExec(t, e, "INSERT INTO contractors VALUES "+v)
+	}
+	mustExec(t, e, "INSERT INTO contractors (id, name) VALUES (13, 'yan')")
+
+	res := mustExec(t, e, "SELECT name, dept FROM employees UNION SELECT c.name, c.dept FROM contractors c ORDER BY name DESC, 2")
+	expectColumns(t, res, "name", "dept")
+	expectRows(t, res, "[zed ops]", "[yan <nil>]", "[eve hr]", "[dan ops]", "[cat ops]", "[bob eng]", "[ann eng]")
+
+	expectRows(t, mustExec(t, e, "SELECT dept FROM employees UNION ALL SELECT dept FROM contractors ORDER BY dept LIMIT 3 OFFSET 1"),
+		"[eng]", "[eng]", "[eng]")
+	expectRows(t, mustExec(t, e, "SELECT dept FROM contractors INTERSECT SELECT dept FROM employees"), "[ops]", "[eng]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM contractors INTERSECT ALL SELECT name FROM employees"), "[ann]")
+	expectRows(t, mustExec(t, e, "SELECT dept FROM employees EXCEPT SELECT dept FROM contractors"), "[hr]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM contractors EXCEPT ALL SELECT name FROM employees ORDER BY 1"),
+		"[ann]", "[yan]", "[zed]")
+	// NULLs are equal to each other in set operations.
+	expectRows(t, mustExec(t, e, "SELECT dept FROM contractors WHERE id = 13 INTERSECT SELECT dept FROM contractors"), "[<nil>]")
+
+	// INTERSECT binds tighter than UNION; UNION and EXCEPT go left to right.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE id = 1 UNION SELECT name FROM employees INTERSECT SELECT name FROM contractors"),
+		"[ann]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees UNION SELECT name FROM contractors EXCEPT SELECT name FROM employees ORDER BY name"),
+		"[yan]", "[zed]")
+
+	// A parenthesised query keeps its own ORDER BY and LIMIT, and groups.
+	expectRows(t, mustExec(t, e, "(SELECT name FROM employees WHERE salary IS NOT NULL ORDER BY salary DESC LIMIT 1) UNION ALL "+
+		"(SELECT name FROM contractors ORDER BY id DESC LIMIT 1) ORDER BY name"), "[ann]", "[yan]")
+	expectRows(t, mustExec(t, e, "(SELECT name FROM employees WHERE id = 1 UNION SELECT name FROM employees) INTERSECT SELECT name FROM contractors"),
+		"[ann]")
+	expectRows(t, mustExec(t, e, "SELECT dept FROM employees EXCEPT (SELECT dept FROM contractors ORDER BY id LIMIT 1) ORDER BY dept"),
+		"[eng]", "[hr]")
+
+	// INT and FLOAT columns unify to FLOAT.
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE id = 3 UNION SELECT CAST('2.5' AS FLOAT) FROM employees WHERE id = 1 ORDER BY salary"),
+		"[2.5]", "[50]")
+	res = mustExec(t, e, "SELECT AVG(salary) FROM employees UNION ALL SELECT id FROM employees WHERE id = 1")
+	if _, ok := res.Rows[1].Values[0].(float64); !ok {
+		t.Errorf("expected a float64, got %T", res.Rows[1].Values[0])
+	}
+
+	// Set operations work inside CTEs too.
+	expectRows(t, mustExec(t, e, "WITH everyone AS (SELECT name FROM employees UNION SELECT name FROM contractors) "+
+		"SELECT COUNT(*) FROM everyone"), "[7]")
+
+	for _, sql := range []string{
+		"SELECT id, name FROM employees UNION SELECT id FROM contractors",
+		"SELECT id FROM employees EXCEPT SELECT name FROM contractors",
+		"SELECT name FROM employees UNION SELECT name FROM contractors ORDER BY salary",
+		"SELECT name FROM employees UNION SELECT name FROM contractors ORDER BY 2",
+		"SELECT name FROM employees UNION SELECT name FROM contractors ORDER BY COUNT(*)",
+		"SELECT name FROM employees ORDER BY name UNION SELECT name FROM contractors",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id LIMIT 2"), "[ann]", "[bob]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id OFFSET 3"), "[dan]", "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id OFFSET 1 LIMIT 1"), "[bob]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id LIMIT 10 OFFSET 4"), "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id LIMIT 0"))
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees LIMIT 1 OFFSET 9"))
+	expectRows(t, mustExec(t, e, "SELECT DISTINCT dept FROM employees ORDER BY dept LIMIT 2"), "[eng]", "[hr]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id LIMIT NULL OFFSET 3"), "[dan]", "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY id LIMIT (SELECT COUNT(*) FROM employees WHERE dept = 'eng')"),
+		"[ann]", "[bob]")
+	// A scalar subquery with LIMIT 1 picks the top row.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE id = (SELECT id FROM employees ORDER BY salary LIMIT 1)"), "[cat]")
+
+	for _, sql := range []string{
+		"SELECT name FROM employees LIMIT id",
+		"SELECT name FROM employees LIMIT 'a'",
+		"SELECT name FROM employees LIMIT COUNT(*)",
+		"SELECT name FROM employees LIMIT 1 LIMIT 2",
+		"SELECT name FROM employees LIMIT -1",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestWindowFunctions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	res := mustExec(t, e, "SELECT name, ROW_NUMBER() OVER (ORDER BY id) FROM employees ORDER BY id")
+	expectRows(t, res, "[ann 1]", "[bob 2]", "[cat 3]", "[dan 4]", "[eve 5]")
+	expectColumns(t, res, "name", "ROW_NUMBER() OVER (ORDER BY id)")
+
+	// DESC puts the NULL salary first; cat and dan are peers.
+	expectRows(t, mustExec(t, e, `SELECT name, RANK() OVER (ORDER BY salary DESC), DENSE_RANK() OVER (ORDER BY salary DESC)
+		FROM employees ORDER BY id`),
+		"[ann 2 2]", "[bob 3 3]", "[cat 4 4]", "[dan 4 4]", "[eve 1 1]")
+	expectRows(t, mustExec(t, e, "SELECT name, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC, id) FROM employees ORDER BY id"),
+		"[ann 1]", "[bob 2]", "[cat 1]", "[dan 2]", "[eve 1]")
+	expectRows(t, mustExec(t, e, "SELECT name, LAG(name) OVER (ORDER BY id), LEAD(name, 2, 'none') OVER (ORDER BY id) FROM employees ORDER BY id"),
+		"[ann <nil> cat]", "[bob ann dan]", "[cat bob eve]", "[dan cat none]", "[eve dan none]")
+	expectRows(t, mustExec(t, e, "SELECT name, FIRST_VALUE(name) OVER (PARTITION BY dept ORDER BY salary) FROM employees ORDER BY id"),
+		"[ann bob]", "[bob bob]", "[cat cat]", "[dan cat]", "[eve eve]")
+
+	// Aggregates: the default frame with ORDER BY runs through the current
+	// row's peers; without it, it is the whole partition.
+	expectRows(t, mustExec(t, e, "SELECT name, SUM(salary) OVER (ORDER BY salary) FROM employees ORDER BY id"),
+		"[ann 280]", "[bob 180]", "[cat 100]", "[dan 100]", "[eve 280]")
+	expectRows(t, mustExec(t, e, "SELECT name, COUNT(*) OVER (PARTITION BY dept), SUM(salary) OVER () FROM employees ORDER BY id"),
+		"[ann 2 280]", "[bob 2 280]", "[cat 2 280]", "[dan 2 280]", "[eve 1 280]")
+	expectRows(t, mustExec(t, e, "SELECT name, SUM(salary) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) FROM employees ORDER BY id"),
+		"[ann 100]", "[bob 180]", "[cat 130]", "[dan 100]", "[eve 50]")
+	expectRows(t, mustExec(t, e, "SELECT name, MAX(name) OVER (ORDER BY id ROWS BETWEEN 1 FOLLOWING AND UNBOUNDED FOLLOWING) FROM employees ORDER BY id"),
+		"[ann eve]", "[bob eve]", "[cat eve]", "[dan eve]", "[eve <nil>]")
+	expectRows(t, mustExec(t, e, "SELECT name, COUNT(*) OVER (ORDER BY salary RANGE BETWEEN 30 PRECEDING AND 30 FOLLOWING) FROM employees ORDER BY id"),
+		"[ann 2]", "[bob 4]", "[cat 3]", "[dan 3]", "[eve 1]")
+	expectRows(t, mustExec(t, e, `SELECT name, SUM(salary) OVER (ORDER BY salary DESC RANGE BETWEEN UNBOUNDED PRECEDING AND 20 PRECEDING)
+		FROM employees ORDER BY id`),
+		"[ann <nil>]", "[bob 100]", "[cat 180]", "[dan 180]", "[eve <nil>]")
+
+	// Windows run after grouping, and ORDER BY may sort by them.
+	expectRows(t, mustExec(t, e, "SELECT dept, SUM(salary), RANK() OVER (ORDER BY SUM(salary) DESC) FROM employees GROUP BY dept ORDER BY dept"),
+		"[eng 180 2]", "[hr <nil> 1]", "[ops 100 3]")
+	expectRows(t, mustExec(t, e, "SELECT dept, SUM(SUM(salary)) OVER (ORDER BY dept) FROM employees GROUP BY dept ORDER BY dept"),
+		"[eng 180]", "[hr 180]", "[ops 280]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY ROW_NUMBER() OVER (ORDER BY id DESC)"),
+		"[eve]", "[dan]", "[cat]", "[bob]", "[ann]")
+	expectRows(t, mustExec(t, e, "SELECT name, ROW_NUMBER() OVER (ORDER BY name DESC) AS rn FROM employees ORDER BY rn LIMIT 2"),
+		"[eve 1]", "[dan 2]")
+	// A subquery numbers only its own rows.
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE id IN (SELECT ROW_NUMBER() OVER () FROM employees WHERE dept = 'ops') ORDER BY id"),
+		"[ann]", "[bob]")
+
+	for _, sql := range []string{
+		"SELECT name FROM employees WHERE ROW_NUMBER() OVER (ORDER BY id) = 1",
+		"SELECT dept FROM employees GROUP BY dept HAVING RANK() OVER () = 1",
+		"SELECT dept FROM employees GROUP BY ROW_NUMBER() OVER ()",
+		"SELECT ROW_NUMBER() FROM employees",
+		"SELECT ROW_NUMBER(id) OVER () FROM employees",
+		"SELECT LOWER(name) OVER () FROM employees",
+		"SELECT SUM(ROW_NUMBER() OVER ()) FROM employees",
+		"SELECT ROW_NUMBER() OVER (ORDER BY RANK() OVER ()) FROM employees",
+		"SELECT COUNT(DISTINCT salary) OVER () FROM employees",
+		"SELECT dept, SUM(salary) OVER () FROM employees GROUP BY dept",
+		"SELECT LAG(name, 'a') OVER () FROM employees",
+		"SELECT SUM(salary) OVER (ROWS BETWEEN 1 FOLLOWING AND CURRENT ROW) FROM employees",
+		"SELECT SUM(salary) OVER (ROWS UNBOUNDED FOLLOWING) FROM employees",
+		"SELECT SUM(salary) OVER (ROWS BETWEEN id PRECEDING AND CURRENT ROW) FROM employees",
+		"SELECT SUM(salary) OVER (ORDER BY name RANGE BETWEEN 1 PRECEDING AND CURRENT ROW) FROM employees",
+		"SELECT SUM(salary) OVER (ORDER BY id ROWS BETWEEN CAST('-1' AS INT) PRECEDING AND CURRENT ROW) FROM employees",
+		"UPDATE employees SET salary = ROW_NUMBER() OVER ()",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestCaseExpressions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	// eve's NULL salary matches no WHEN and falls through to ELSE.
+	expectRows(t, mustExec(t, e, `SELECT name, CASE WHEN salary >= 80 THEN 'high' WHEN salary >= 50 THEN 'mid' ELSE 'none' END
+		FROM employees ORDER BY id`),
+		"[ann high]", "[bob high]", "[cat mid]", "[dan mid]", "[eve none]")
+	expectRows(t, mustExec(t, e, "SELECT name, CASE dept WHEN 'eng' THEN 1 WHEN 'ops' THEN 2 END FROM employees ORDER BY id"),
+		"[ann 1]", "[bob 1]", "[cat 2]", "[dan 2]", "[eve <nil>]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE CASE WHEN dept = 'eng' THEN salary > 90 ELSE TRUE END ORDER BY id"),
+		"[ann]", "[cat]", "[dan]", "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees ORDER BY CASE dept WHEN 'hr' THEN 0 ELSE 1 END, id"),
+		"[eve]", "[ann]", "[bob]", "[cat]", "[dan]")
+	expectRows(t, mustExec(t, e, "SELECT dept, CASE WHEN COUNT(*) > 1 THEN 'team' ELSE 'solo' END FROM employees GROUP BY dept ORDER BY dept"),
+		"[eng team]", "[hr solo]", "[ops team]")
+	expectRows(t, mustExec(t, e, "SELECT CASE WHEN salary >= 80 THEN 'high' ELSE 'low' END AS band, COUNT(*) FROM employees GROUP BY band ORDER BY band"),
+		"[high 2]", "[low 3]")
+	expectRows(t, mustExec(t, e, "SELECT name, CASE WHEN id IN (SELECT id FROM employees WHERE salary > 90) THEN 'top' END FROM employees ORDER BY id LIMIT 2"),
+		"[ann top]", "[bob <nil>]")
+
+	// Branches of INT and FLOAT both yield FLOAT.
+	res := mustExec(t, e, "SELECT CASE WHEN id = 1 THEN CAST('2.5' AS FLOAT) ELSE id END FROM employees ORDER BY id")
+	if v, ok := res.Rows[1].Values[0].(float64); !ok || v != 2 {
+		t.Errorf("expected the INT branch as FLOAT 2, got %#v", res.Rows[1].Values[0])
+	}
+
+	mustExec(t, e, "UPDATE employees SET salary = CASE WHEN dept = 'ops' THEN 60 ELSE salary END")
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees ORDER BY id"), "[100]", "[80]", "[60]", "[60]", "[<nil>]")
+
+	for _, sql := range []string{
+		"SELECT CASE WHEN 1 THEN 'a' END FROM employees",
+		"SELECT CASE WHEN TRUE THEN 'a' ELSE 1 END FROM employees",
+		"SELECT CASE dept WHEN TRUE THEN 1 END FROM employees",
+		"SELECT CASE WHEN missing = 1 THEN 1 END FROM employees",
+		"UPDATE employees SET salary = CASE WHEN name THEN 1 END",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestIsNull(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE salary IS NULL"), "[eve]")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees WHERE salary IS NOT NULL"), "[4]")
+	// = NULL is never true; IS NULL is never NULL.
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees WHERE salary = NULL"), "[0]")
+	expectRows(t, mustExec(t, e, "SELECT name, salary IS NULL, NOT salary IS NULL FROM employees WHERE id > 3 ORDER BY id"),
+		"[dan false true]", "[eve true false]")
+	expectRows(t, mustExec(t, e, "SELECT name, CASE WHEN salary IS NULL THEN 0 ELSE salary END FROM employees WHERE id > 3 ORDER BY id"),
+		"[dan 50]", "[eve 0]")
+
+	// A LEFT JOIN keeps the unmatched rows, which IS NULL then selects.
+	mustExec(t, e, "CREATE TABLE depts (name TEXT, floor INT)")
+	mustExec(t, e, "INSERT INTO depts VALUES ('eng', 1), ('ops', 2)")
+	expectRows(t, mustExec(t, e, "SELECT e.name FROM employees e LEFT JOIN depts d ON d.name = e.dept WHERE d.name IS NULL"),
+		"[eve]")
+	expectRows(t, mustExec(t, e, "SELECT d.name FROM depts d LEFT JOIN employees e ON e.dept = d.name AND e.salary > 90 WHERE e.id IS NULL"),
+		"[ops]")
+
+	mustExec(t, e, "UPDATE employees SET salary = 0 WHERE salary IS NULL")
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE id = 5"), "[0]")
+	mustExec(t, e, "PREPARE missing AS SELECT COUNT(*) FROM employees WHERE salary IS NULL OR salary = $1")
+	expectRows(t, mustExec(t, e, "EXECUTE missing(50)"), "[2]")
+
+	if _, err := run(e, "SELECT name FROM employees WHERE missing IS NULL"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestMultiRowInsert(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE archive (id INT PRIMARY KEY, name TEXT)")
+
+	res := mustExec(t, e, "INSERT INTO archive VALUES (10, 'x'), (11, 'y'), (12, 'z')")
+	if res.Message != "Inserted 3 rows" {
+		t.Errorf("expected an insert count, got %q", res.Message)
+	}
+	mustExec(t, e, "INSERT INTO archive (id) VALUES (13), (14)")
+	expectRows(t, mustExec(t, e, "SELECT id, name FROM archive WHERE id > 11 ORDER BY id"),
+		"[12 z]", "[13 <nil>]", "[14 <nil>]")
+	// NULL is the keyword; the text 'NULL' is just text.
+	mustExec(t, e, "INSERT INTO archive VALUES (16, NULL), (17, 'NULL')")
+	expectRows(t, mustExec(t, e, "SELECT id, name, name = 'NULL' FROM archive WHERE id > 15 ORDER BY id"),
+		"[16 <nil> <nil>]", "[17 NULL true]")
+	mustExec(t, e, "DELETE FROM archive WHERE id > 15")
+	// Columns are INT or TEXT, so a fraction is rejected rather than truncated.
+	if _, err := run(e, "INSERT INTO archive VALUES (2.5, 'x')"); err == nil || !strings.Contains(err.Error(), "invalid value for column id (INT): 2.5") {
+		t.Errorf("expected an invalid INT value error, got %v", err)
+	}
+
+	mustExec(t, e, "INSERT INTO archive SELECT id, name FROM employees WHERE dept = 'eng'")
+	mustExec(t, e, "INSERT INTO archive (name, id) SELECT name, id FROM employees WHERE dept = 'ops' UNION SELECT name, id FROM employees WHERE id = 5")
+	mustExec(t, e, "INSERT INTO archive (id) WITH big AS (SELECT SUM(id) AS n FROM employees) SELECT n FROM big")
+	expectRows(t, mustExec(t, e, "SELECT id, name FROM archive WHERE id < 10 OR id = 15 ORDER BY id"),
+		"[1 ann]", "[2 bob]", "[3 cat]", "[4 dan]", "[5 eve]", "[15 <nil>]")
+
+	// A batch is checked as a whole: one bad row, or two rows clashing with
+	// each other, and nothing is inserted.
+	for _, sql := range []string{
+		"INSERT INTO archive VALUES (30, 'a'), (30, 'b')",
+		"INSERT INTO archive VALUES (31, 'a'), (10, 'b')",
+		"INSERT INTO archive VALUES (32, 'a'), (x, 'b')",
+		"INSERT INTO archive VALUES (33, 'a'), (34)",
+		"INSERT INTO archive (id, id) VALUES (35, 36)",
+		"INSERT INTO archive VALUES (NULL, 'a')",
+		"INSERT INTO archive (missing) VALUES (37)",
+		"INSERT INTO archive SELECT id FROM employees",
+		"INSERT INTO archive (name) SELECT name FROM employees",
+		"INSERT INTO archive SELECT id, name FROM employees WHERE id > 3",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM archive WHERE id >= 30"), "[0]")
+
+	// Foreign keys are checked once per batch, and rows of a self-referencing
+	// table may refer to each other.
+	mustExec(t, e, "CREATE TABLE staff (id INT PRIMARY KEY, boss INT REFERENCES staff(id), emp INT REFERENCES employees(id))")
+	mustExec(t, e, "INSERT INTO staff (id, emp) VALUES (1, 1)")
+	mustExec(t, e, "INSERT INTO staff VALUES (2, 1, 2), (3, 2, 3)")
+	if _, err := run(e, "INSERT INTO staff VALUES (4, 1, 4), (5, 1, 99)"); err == nil {
+		t.Error("expected a foreign key violation")
+	}
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM staff"), "[3]")
+
+	// A batch larger than a page.
+	var values []string
+	for i := 1000; i < 2000; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'n%d')", i, i))
+	}
+	mustExec(t, e, "INSERT INTO archive VALUES "+strings.Join(values, ", "))
+	mustExec(t, e, "CREATE TABLE copy (id INT PRIMARY KEY, name TEXT)")
+	mustExec(t, e, "INSERT INTO copy SELECT id, name FROM archive WHERE id >= 1000")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*), MIN(name), MAX(id) FROM copy"), "[1000 n1000 1999]")
+}
+
+func TestInsertOnConflict(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE stock (id INT PRIMARY KEY, sku TEXT UNIQUE, qty INT)")
+	mustExec(t, e, "INSERT INTO stock VALUES (1, 'a', 10), (2, 'b', 20)")
+
+	// Without a clause a duplicate key is still an error.
+	if _, err := run(e, "INSERT INTO stock VALUES (1, 'z', 0)"); err == nil {
+		t.Error("expected a UNIQUE constraint violation")
+	}
+
+	res := mustExec(t, e, "INSERT INTO stock VALUES (1, 'x', 0), (3, 'c', 30) ON CONFLICT (id) DO NOTHING")
+	if res.Message != "Inserted 1 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	// Without a target any unique column conflicts, and repeats within the
+	// batch are skipped too.
+	mustExec(t, e, "INSERT INTO stock VALUES (9, 'a', 0), (4, 'd', 40), (5, 'd', 50) ON CONFLICT DO NOTHING")
+	expectRows(t, mustExec(t, e, "SELECT id, sku, qty FROM stock ORDER BY id"),
+		"[1 a 10]", "[2 b 20]", "[3 c 30]", "[4 d 40]")
+
+	res = mustExec(t, e, "INSERT INTO stock (id, sku, qty) VALUES (2, 'b', 25), (6, 'f', 60) "+
+		"ON CONFLICT (id) DO UPDATE SET qty = EXCLUDED.qty")
+	if res.Message != "Inserted 1 rows, updated 1 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	// Bare columns read the existing row.
+	mustExec(t, e, "INSERT INTO stock VALUES (7, 'c', 99) ON CONFLICT (sku) DO UPDATE SET qty = COALESCE(excluded.qty, qty), sku = sku")
+	mustExec(t, e, "INSERT INTO stock (id, sku) VALUES (4, 'x') ON CONFLICT (id) DO UPDATE SET qty = COALESCE(excluded.qty, qty)")
+	mustExec(t, e, "INSERT INTO stock SELECT id, name, 0 FROM employees WHERE id = 1 ON CONFLICT (id) DO UPDATE SET sku = excluded.sku")
+	expectRows(t, mustExec(t, e, "SELECT id, sku, qty FROM stock ORDER BY id"),
+		"[1 ann 10]", "[2 b 25]", "[3 c 99]", "[4 d 40]", "[6 f 60]")
+
+	for _, sql := range []string{
+		// The same row cannot be updated twice by one statement.
+		"INSERT INTO stock VALUES (2, 'b', 1), (2, 'b', 2) ON CONFLICT (id) DO UPDATE SET qty = excluded.qty",
+		"INSERT INTO stock VALUES (8, 'h', 1), (8, 'h', 2) ON CONFLICT (id) DO UPDATE SET qty = excluded.qty",
+		// An update may not clash with another unique value.
+		"INSERT INTO stock VALUES (2, 'b', 0) ON CONFLICT (id) DO UPDATE SET sku = 'c'",
+		"INSERT INTO stock VALUES (2, 'b', 0), (8, 'z', 0) ON CONFLICT (id) DO UPDATE SET sku = 'z'",
+		// A conflict on another unique column is still an error.
+		"INSERT INTO stock VALUES (8, 'b', 0) ON CONFLICT (id) DO NOTHING",
+		// The target must be a single UNIQUE or PRIMARY KEY column.
+		"INSERT INTO stock VALUES (8, 'h', 0) ON CONFLICT (qty) DO NOTHING",
+		"INSERT INTO stock VALUES (8, 'h', 0) ON CONFLICT (id, sku) DO NOTHING",
+		"INSERT INTO stock VALUES (8, 'h', 0) ON CONFLICT (nope) DO NOTHING",
+		"INSERT INTO stock VALUES (8, 'h', 0) ON CONFLICT DO UPDATE SET qty = 1",
+		"INSERT INTO stock VALUES (2, 'b', 0) ON CONFLICT (id) DO UPDATE SET nope = 1",
+		"INSERT INTO stock VALUES (2, 'b', 0) ON CONFLICT (id) DO UPDATE SET qty = excluded.nope",
+		"INSERT INTO stock VALUES (2, 'b', 0) ON CONFLICT (id) DO UPDATE SET qty = 'many'",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	expectRows(t, mustExec(t, e, "SELECT id, sku, qty FROM stock ORDER BY id"),
+		"[1 ann 10]", "[2 b 25]", "[3 c 99]", "[4 d 40]", "[6 f 60]")
+
+	// Updated foreign keys must exist, and a referenced key cannot change.
+	mustExec(t, e, "CREATE TABLE assignment (id INT PRIMARY KEY, emp INT REFERENCES employees(id))")
+	mustExec(t, e, "INSERT INTO assignment VALUES (1, 1)")
+	if _, err := run(e, "INSERT INTO assignment VALUES (1, 99) ON CONFLICT (id) DO UPDATE SET emp = excluded.emp"); err == nil {
+		t.Error("expected a foreign key violation")
+	}
+	mustExec(t, e, "INSERT INTO assignment VALUES (1, 2) ON CONFLICT (id) DO UPDATE SET emp = excluded.emp")
+	expectRows(t, mustExec(t, e, "SELECT id, emp FROM assignment"), "[1 2]")
+	if _, err := run(e, "INSERT INTO employees (id, name) VALUES (2, 'bo') ON CONFLICT (id) DO UPDATE SET id = 20"); err == nil {
+		t.Error("expected a referenced key change to fail")
+	}
+}
+
+func TestReturning(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	res := mustExec(t, e, "INSERT INTO employees VALUES (6, 'fay', 'hr', 70), (7, 'gus', 'ops', 60) RETURNING *")
+	expectColumns(t, res, "id", "name", "dept", "salary")
+	expectRows(t, res, "[6 fay hr 70]", "[7 gus ops 60]")
+	if res.Message != "Inserted 2 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+
+	res = mustExec(t, e, "UPDATE employees SET salary = 90 WHERE dept = 'eng' RETURNING id, salary AS pay, UPPER(name)")
+	expectColumns(t, res, "id", "pay", "UPPER(name)")
+	expectRows(t, res, "[1 90 ANN]", "[2 90 BOB]")
+
+	// Deleted rows come back as they were; no match gives an empty result
+	// that still has its columns.
+	res = mustExec(t, e, "DELETE FROM employees WHERE dept = 'ops' RETURNING name, COALESCE(salary, 0)")
+	expectColumns(t, res, "name", "COALESCE(salary, 0)")
+	expectRows(t, res, "[cat 50]", "[dan 50]", "[gus 60]")
+	res = mustExec(t, e, "DELETE FROM employees WHERE id = 99 RETURNING id")
+	expectColumns(t, res, "id")
+	expectRows(t, res)
+
+	// Upserts return the rows they updated, then the rows they inserted.
+	res = mustExec(t, e, "INSERT INTO employees VALUES (8, 'hal', 'eng', 10), (1, 'ann', 'eng', 95) "+
+		"ON CONFLICT (id) DO UPDATE SET salary = excluded.salary RETURNING id, salary")
+	expectRows(t, res, "[1 95]", "[8 10]")
+	res = mustExec(t, e, "INSERT INTO employees VALUES (1, 'x', 'x', 0) ON CONFLICT (id) DO NOTHING RETURNING id")
+	expectRows(t, res)
+
+	for _, sql := range []string{
+		"DELETE FROM employees RETURNING nope",
+		"UPDATE employees SET salary = 1 RETURNING COUNT(*)",
+		"INSERT INTO employees VALUES (9, 'ivy', 'hr', 1) RETURNING ROW_NUMBER() OVER ()",
+		"INSERT INTO employees VALUES (9, 'ivy', 'hr', 1) RETURNING employees.nope",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	// A rejected statement writes nothing, despite RETURNING.
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees"), "[5]")
+}
+
+func TestArithmetic(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	expectRows(t, mustExec(t, e, "SELECT id + 1, id - 1, id * 2, 7 / id, 7 % id, -id, 2 + 3 * id - 1 FROM employees WHERE id <= 2 ORDER BY id"),
+		"[2 0 2 7 0 -1 4]", "[3 1 4 3 1 -2 7]")
+	// The lexer reads -1 as a number, but after an operand it subtracts.
+	expectRows(t, mustExec(t, e, "SELECT id -1, (id + 1) * -2, id - -1 FROM employees WHERE id = 3"), "[2 -8 4]")
+	expectRows(t, mustExec(t, e, "SELECT id-1, salary-id, e.salary-e.id FROM employees e WHERE id = 3"), "[2 47 47]")
+	mustExec(t, e, "UPDATE employees SET salary = salary-1 WHERE id = 3")
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE id = 3"), "[49]")
+	mustExec(t, e, "UPDATE employees SET salary = salary+1 WHERE id = 3")
+	// A FLOAT operand gives a FLOAT, and NULL propagates.
+	expectRows(t, mustExec(t, e, "SELECT 1.5 * salary, salary -0.5, salary / 4.0 FROM employees WHERE id = 3"), "[75 49.5 12.5]")
+	expectRows(t, mustExec(t, e, "SELECT salary / CAST('4' AS FLOAT), salary * 2 FROM employees WHERE id IN (3, 5) ORDER BY id"),
+		"[12.5 100]", "[<nil> <nil>]")
+	expectRows(t, mustExec(t, e, "SELECT name FROM employees WHERE salary - 30 > id * 10 ORDER BY name"), "[ann]", "[bob]")
+	expectRows(t, mustExec(t, e, "SELECT dept, SUM(salary) / COUNT(*) FROM employees GROUP BY dept HAVING SUM(salary) > 50 ORDER BY 1"),
+		"[eng 90]", "[ops 50]")
+
+	for _, sql := range []string{
+		"SELECT name + 1 FROM employees",
+		"SELECT -name FROM employees",
+		"SELECT (id > 1) * 2 FROM employees",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	if _, err := run(e, "SELECT id / (id - 1) FROM employees"); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("expected a division by zero error, got %v", err)
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	// Every assignment reads the row as it was before the update.
+	mustExec(t, e, "UPDATE employees SET salary = salary + 10, id = id * 10, name = name || '!' WHERE dept = 'eng'")
+	expectRows(t, mustExec(t, e, "SELECT id, name, salary FROM employees WHERE dept = 'eng' ORDER BY id"),
+		"[10 ann! 110]", "[20 bob! 90]")
+	mustExec(t, e, "UPDATE employees SET salary = COALESCE(salary, 0) + id WHERE dept = 'hr'")
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE dept = 'hr'"), "[5]")
+
+	mustExec(t, e, "CREATE TABLE counters (id INT PRIMARY KEY, hits INT)")
+	mustExec(t, e, "INSERT INTO counters VALUES (1, 0)")
+	for i := 0; i < 3; i++ {
+		mustExec(t, e, "INSERT INTO counters VALUES (1, 1) ON CONFLICT (id) DO UPDATE SET hits = hits + excluded.hits")
+	}
+	expectRows(t, mustExec(t, e, "SELECT hits FROM counters"), "[3]")
+
+	for _, sql := range []string{
+		"UPDATE employees SET salary = 1, salary = 2",
+		"UPDATE employees SET salary = salary + name",
+		"UPDATE employees SET nope = 1",
+		"UPDATE employees SET salary = salary * 100000000",
+		"INSERT INTO counters VALUES (1, 1) ON CONFLICT (id) DO UPDATE SET hits = 1, hits = 2",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestUpdateFromDeleteUsing(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE raises (dept TEXT, amount INT)")
+	mustExec(t, e, "INSERT INTO raises VALUES ('eng', 5), ('ops', 7), ('ops', 9)")
+
+	// A row joined to several FROM rows is updated once, from its first match.
+	// RETURNING sees the new values and the row they were joined with.
+	res := mustExec(t, e, "UPDATE employees SET salary = salary + r.amount FROM raises r WHERE r.dept = employees.dept RETURNING id, salary, r.amount")
+	expectRows(t, res, "[1 105 5]", "[2 85 5]", "[3 57 7]", "[4 57 7]")
+	if res.Message != "Updated 4 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	// * is the target's columns only.
+	res = mustExec(t, e, "UPDATE employees SET salary = salary FROM raises r WHERE r.dept = employees.dept AND r.amount = 5 RETURNING *")
+	expectColumns(t, res, "id", "name", "dept", "salary")
+	expectRows(t, res, "[1 ann eng 105]", "[2 bob eng 85]")
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE id = 5"), "[<nil>]")
+
+	// FROM may join several tables, and the condition need not be an equality.
+	mustExec(t, e, "CREATE TABLE bands (dept TEXT, cap INT)")
+	mustExec(t, e, "INSERT INTO bands VALUES ('eng', 100)")
+	mustExec(t, e, "UPDATE employees SET salary = b.cap FROM raises r JOIN bands b ON b.dept = r.dept "+
+		"WHERE r.dept = employees.dept AND employees.salary > b.cap")
+	expectRows(t, mustExec(t, e, "SELECT id, salary FROM employees WHERE dept = 'eng' ORDER BY id"), "[1 100]", "[2 85]")
+
+	// Without WHERE every row joins every FROM row.
+	mustExec(t, e, "UPDATE employees SET name = employees.name FROM bands")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees"), "[5]")
+
+	mustExec(t, e, "CREATE TABLE users (id INT PRIMARY KEY, active INT)")
+	mustExec(t, e, "CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES users(id))")
+	mustExec(t, e, "CREATE TABLE items (id INT, order_id INT REFERENCES orders(id))")
+	mustExec(t, e, "INSERT INTO users VALUES (1, 1), (2, 0), (3, 0)")
+	mustExec(t, e, "INSERT INTO orders VALUES (10, 1), (20, 2), (21, 2), (30, 3)")
+	mustExec(t, e, "INSERT INTO items VALUES (1, 30)")
+
+	// Child rows still block the delete, which then removes nothing.
+	if _, err := run(e, "DELETE FROM orders USING users u WHERE u.id = orders.user_id AND u.active = 0"); err == nil {
+		t.Error("expected an FK error deleting a referenced order")
+	}
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM orders"), "[4]")
+
+	res = mustExec(t, e, "DELETE FROM orders USING users u WHERE u.id = user_id AND u.active = 0 AND orders.id < 30 RETURNING orders.id, u.id")
+	expectRows(t, res, "[20 2]", "[21 2]")
+	if res.Message != "Deleted 2 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	expectRows(t, mustExec(t, e, "SELECT id FROM orders ORDER BY id"), "[10]", "[30]")
+
+	for _, sql := range []string{
+		"UPDATE employees SET salary = 1 FROM employees",
+		"UPDATE employees SET salary = 1 FROM raises WHERE nope = 1",
+		"UPDATE employees SET salary = 1 FROM raises r RETURNING r.nope",
+		"DELETE FROM orders USING users u WHERE u.id = user_id RETURNING id",
+		"UPDATE employees SET salary = 1 FROM raises WHERE COUNT(*) > 1",
+		"UPDATE employees SET r.amount = 1 FROM raises r",
+		"DELETE FROM orders USING orders",
+		"DELETE FROM orders USING users WHERE dept = 'x'",
+		"DELETE FROM orders USING nope",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestUpdateFromJoinMethods(t *testing.T) {
+	defer func(saved int) { maxSortRows = saved }(maxSortRows)
+	for _, tt := range []struct {
+		method   planner.JoinMethod
+		sortRows int
+	}{
+		{planner.NestedLoopJoin, maxSortRows},
+		{planner.HashJoin, maxSortRows},
+		{planner.MergeJoin, maxSortRows},
+		{planner.MergeJoin, 4}, // sorted in spilled runs
+	} {
+		maxSortRows = tt.sortRows
+		method := tt.method
+		e := newTestExecutor(t)
+		mustExec(t, e, "CREATE TABLE l (id INT PRIMARY KEY, k INT, v INT)")
+		mustExec(t, e, "CREATE TABLE r (k INT, v INT)")
+		for i := 1; i <= 40; i++ {
+			mustExec(t, e, fmt.Sprintf("INSERT INTO l VALUES (%d, %d, 0)", i, i%7))
+			mustExec(t, e, fmt.Sprintf("INSERT INTO r VALUES (%d, %d)", i%5, i))
+		}
+		// Every join method must keep the target rows' locations.
+		for _, sql := range []string{
+			"UPDATE l SET v = 1 FROM r WHERE r.k = l.k",
+			"DELETE FROM l USING r WHERE r.k = l.k AND l.v = 1 AND l.id > 20",
+		} {
+			stmt := parser.New(lexer.New(sql)).ParseProgram().Statements[0]
+			plan, err := planner.New(e).GeneratePlan(stmt)
+			if err != nil {
+				t.Fatalf("%s: %v", sql, err)
+			}
+			forceJoinMethod(plan, method)
+			if _, err := e.Execute(plan); err != nil {
+				t.Fatalf("%s (%s): %v", sql, method, err)
+			}
+		}
+		expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM l WHERE v = 1"), "[14]")
+		expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM l"), "[25]")
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	mustExec(t, e, "PREPARE by_id AS SELECT name FROM employees WHERE id = $1")
+	expectRows(t, mustExec(t, e, "EXECUTE by_id(2)"), "[bob]")
+	// The plan is reused, and arguments are converted to the parameter's type.
+	expectRows(t, mustExec(t, e, "EXECUTE by_id('3')"), "[cat]")
+	expectRows(t, mustExec(t, e, "EXECUTE by_id(1 + 3)"), "[dan]")
+
+	// A bound string is a value, never SQL.
+	mustExec(t, e, "PREPARE by_name AS SELECT id FROM employees WHERE name = ?")
+	expectRows(t, mustExec(t, e, "EXECUTE by_name('ann')"), "[1]")
+	expectRows(t, mustExec(t, e, "EXECUTE by_name('ann OR 1 = 1')"))
+
+	mustExec(t, e, "PREPARE add AS INSERT INTO employees (id, name, dept, salary) VALUES (?, ?, 'ops', ?), (?, '$1', 'hr', 1)")
+	mustExec(t, e, "EXECUTE add(6, 'fay', 70, 7)")
+	mustExec(t, e, "EXECUTE add('8', 'gus', NULL, 9)")
+	expectRows(t, mustExec(t, e, "SELECT id, name, dept, salary FROM employees WHERE id > 5 ORDER BY id"),
+		"[6 fay ops 70]", "[7 $1 hr 1]", "[8 gus ops <nil>]", "[9 $1 hr 1]")
+
+	mustExec(t, e, "PREPARE raise AS UPDATE employees SET salary = salary + $2 WHERE dept = $1 RETURNING id, salary")
+	expectRows(t, mustExec(t, e, "EXECUTE raise('eng', 5)"), "[1 105]", "[2 85]")
+
+	mustExec(t, e, "PREPARE top AS SELECT name FROM employees WHERE salary IN ($1, $2) ORDER BY salary DESC, name LIMIT $3")
+	expectRows(t, mustExec(t, e, "EXECUTE top(105, 85, 1)"), "[ann]")
+	expectRows(t, mustExec(t, e, "EXECUTE top(105, 85, 5)"), "[ann]", "[bob]")
+
+	mustExec(t, e, "PREPARE gone AS DELETE FROM employees WHERE id = $1")
+	res := mustExec(t, e, "EXECUTE gone(9)")
+	if res.Message != "Deleted 1 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	mustExec(t, e, "DEALLOCATE gone")
+
+	for _, sql := range []string{
+		"EXECUTE gone(8)",
+		"EXECUTE by_id",
+		"EXECUTE by_id(1, 2)",
+		"EXECUTE by_id('x')",
+		"EXECUTE by_id(id)",
+		"EXECUTE top(1, 2, 'many')",
+		"EXECUTE add(10, 'hal', 1, 99999999999)",
+		"PREPARE by_id AS SELECT 1 FROM employees",
+		"PREPARE mixed AS SELECT id FROM employees WHERE id = $1 AND name || $1 = 'x' AND $1",
+		"PREPARE bad AS SELECT nope FROM employees WHERE id = $1",
+		"SELECT id FROM employees WHERE id = $1",
+		"DEALLOCATE nope",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	// The failed EXECUTE of add inserted nothing.
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees WHERE id = 10"), "[0]")
+}
+
+func TestPreparedStatementSessions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	other := e.NewSession()
+
+	// Sessions share tables but not prepared statements.
+	mustExec(t, e, "PREPARE q AS SELECT name FROM employees WHERE id = $1")
+	mustExec(t, other, "PREPARE q AS SELECT dept FROM employees WHERE id = $1")
+	expectRows(t, mustExec(t, e, "EXECUTE q(1)"), "[ann]")
+	expectRows(t, mustExec(t, other, "EXECUTE q(1)"), "[eng]")
+	mustExec(t, other, "DEALLOCATE q")
+	expectRows(t, mustExec(t, e, "EXECUTE q(2)"), "[bob]")
+	if _, err := run(other, "EXECUTE q(2)"); err == nil {
+		t.Errorf("EXECUTE after DEALLOCATE in the same session: expected an error")
+	}
+
+	// Each session runs with its own bound values.
+	mustExec(t, other, "PREPARE q AS SELECT name FROM employees WHERE id = $1")
+	var wg sync.WaitGroup
+	for _, test := range []struct {
+		session *Executor
+		id      int
+		want    string
+	}{{e, 3, "[cat]"}, {other, 4, "[dan]"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				res, err := run(test.session, fmt.Sprintf("EXECUTE q(%d)", test.id))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := fmt.Sprint(rowsToStrings(res)); got != "["+test.want+"]" {
+					t.Errorf("EXECUTE q(%d) = %s, want %s", test.id, got, test.want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLexicalSyntax(t *testing.T) {
+	e := newTestExecutor(t)
+	// Quoted identifiers name columns after keywords.
+	mustExec(t, e, `CREATE TABLE notes ("key" INT PRIMARY KEY, "table" TEXT, body TEXT) -- a comment`)
+	mustExec(t, e, "INSERT INTO notes VALUES (1, 'it''s', E'two\\nlines'), /* skipped */ (2, 'x', 'y')")
+	mustExec(t, e, `UPDATE notes SET "table" = 'say ''hi''' WHERE "key" = 2`)
+
+	expectRows(t, mustExec(t, e, `SELECT n."key", "table" FROM notes n WHERE "table" != 'x' ORDER BY "key"`), "[1 it's]", "[2 say 'hi']")
+	res := mustExec(t, e, "SELECT body, LENGTH(body) FROM notes -- trailing\nWHERE \"key\" = 1")
+	expectRows(t, res, "[two\nlines 9]")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM notes WHERE body = E'\\x79' OR body = 'y'"), "[1]")
+	// Columns are named after the column, not its quoted spelling.
+	res = mustExec(t, e, `SELECT "table", COUNT("key") FROM notes GROUP BY "table" ORDER BY "table"`)
+	expectColumns(t, res, "table", `COUNT("key")`)
+	expectRows(t, res, "[it's 1]", "[say 'hi' 1]")
+
+	for _, sql := range []string{
+		"SELECT key FROM notes",
+		"SELECT body FROM notes WHERE body = 'open",
+		`SELECT "body FROM notes`,
+		"SELECT body FROM notes /* open",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+
+	planText := func(res ResultSet) []string {
+		var lines []string
+		for _, row := range res.Rows {
+			lines = append(lines, row.Values[0].(string))
+		}
+		return lines
+	}
+
+	res := mustExec(t, e, "EXPLAIN SELECT name FROM employees WHERE salary > 60 ORDER BY name LIMIT 2")
+	expectColumns(t, res, "plan", "estimated_rows")
+	want := []string{
+		"Limit: LIMIT 2",
+		"-> Project: name",
+		"  -> Sort: name",
+		"    -> Filter: salary > 60",
+		"      -> Seq Scan on employees",
+	}
+	if got := planText(res); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected plan %q, got %q", want, got)
+	}
+	// The scan counts the table's rows, and the filter keeps a third.
+	var estimates []interface{}
+	for _, row := range res.Rows {
+		estimates = append(estimates, row.Values[1])
+	}
+	if fmt.Sprint(estimates) != "[2 2 2 2 5]" {
+		t.Errorf("unexpected estimates %v", estimates)
+	}
+
+	// A plain EXPLAIN does not run the statement; EXPLAIN ANALYZE does.
+	mustExec(t, e, "EXPLAIN DELETE FROM employees WHERE id = 5")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM employees"), "[5]")
+	res = mustExec(t, e, "EXPLAIN ANALYZE UPDATE employees SET salary = 60 WHERE id = 5")
+	if got := fmt.Sprintln(res.Rows[0].Values[0], res.Rows[0].Values[2:4]); got != "Update on employees: SET salary = 60 WHERE id = 5 [1 1]\n" {
+		t.Errorf("unexpected UPDATE plan %s", got)
+	}
+	expectRows(t, mustExec(t, e, "SELECT salary FROM employees WHERE id = 5"), "[60]")
+
+	res = mustExec(t, e, "EXPLAIN ANALYZE SELECT e.name, (SELECT COUNT(*) FROM employees x WHERE x.dept = e.dept) "+
+		"FROM employees e WHERE e.salary >= 60")
+	expectColumns(t, res, "plan", "estimated_rows", "actual_rows", "loops", "pages_read", "time_ms")
+	want = []string{
+		"Project: e.name, (SELECT COUNT(*) FROM employees x WHERE x.dept = e.dept)",
+		"-> Filter: e.salary >= 60",
+		"  -> Seq Scan on employees e",
+		"-> SubPlan: scalar, correlated",
+		"  -> Project: COUNT(*)",
+		"    -> Aggregate: COUNT(*)",
+		"      -> Filter: x.dept = e.dept",
+		"        -> Seq Scan on employees x",
+	}
+	if got := planText(res); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected plan %q, got %q", want, got)
+	}
+	actual := func(i int) string { return fmt.Sprint(res.Rows[i].Values[2:5]) }
+	// The scan reads the table's single page once and yields every row;
+	// three of them pass the filter. Pages read include the subplan's.
+	if actual(2) != "[5 1 1]" || actual(1) != "[3 1 1]" || actual(0) != "[3 1 3]" {
+		t.Errorf("unexpected actual rows, loops and pages: %s %s %s", actual(0), actual(1), actual(2))
+	}
+	// The labelled SubPlan line has no figures of its own; the correlated
+	// subquery runs once per distinct outer dept (eng and hr).
+	if actual(3) != "[<nil> <nil> <nil>]" || actual(7) != "[10 2 2]" {
+		t.Errorf("unexpected subplan figures: %s %s", actual(3), actual(7))
+	}
+	for _, row := range res.Rows[:3] {
+		if ms, ok := row.Values[5].(float64); !ok || ms < 0 {
+			t.Errorf("expected a wall time, got %v", row.Values[5])
+		}
+	}
+
+	// DML reports the rows it wrote, as its message counts them.
+	twin := newTestExecutor(t)
+	seedEmployees(t, twin)
+	mustExec(t, twin, "UPDATE employees SET salary = 60 WHERE id = 5")
+	for _, sql := range []string{
+		"INSERT INTO employees VALUES (6, 'fay', 'hr', 40), (7, 'gus', 'hr', 70)",
+		"DELETE FROM employees WHERE salary < 70",
+		"UPDATE employees SET salary = salary + 1 WHERE dept = 'eng' RETURNING id",
+	} {
+		res := mustExec(t, e, "EXPLAIN ANALYZE "+sql)
+		msg := mustExec(t, twin, sql).Message
+		if want := strings.Fields(msg)[1]; fmt.Sprint(res.Rows[0].Values[2]) != want {
+			t.Errorf("EXPLAIN ANALYZE %s: expected %s actual rows (%s), got %v", sql, want, msg, res.Rows[0].Values[2])
+		}
+	}
+
+	for _, sql := range []string{
+		"EXPLAIN SELECT missing FROM employees",
+		"EXPLAIN ANALYZE INSERT INTO employees VALUES (1, 'dup', 'eng', 1)",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestExplainAnalyzeSessions(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	other := e.NewSession()
+	mustExec(t, other, "PREPARE q AS SELECT name FROM employees WHERE id = $1")
+
+	// Stats are collected for the analyzed statement only, never for what
+	// other sessions run meanwhile.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			if _, err := run(e, "EXPLAIN ANALYZE SELECT name FROM employees WHERE salary > 60"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			if _, err := run(other, "EXECUTE q(1)"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	if e.stats != nil || other.stats != nil {
+		t.Errorf("expected no stats left on the session executors")
+	}
+}
+
+func TestShow(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, `CREATE TABLE "order" (id INT PRIMARY KEY, code TEXT(8) UNIQUE, note TEXT NOT NULL, emp INT REFERENCES employees(id))`)
+	mustExec(t, e, "CREATE DATABASE archive")
+
+	expectRows(t, mustExec(t, e, "SHOW DATABASES"), "[archive]", "[test]")
+	res := mustExec(t, e, "SHOW TABLES")
+	expectColumns(t, res, "table")
+	expectRows(t, res, "[employees]", "[order]")
+
+	res = mustExec(t, e, `DESCRIBE "order"`)
+	expectColumns(t, res, "column", "type", "nullable", "key", "references")
+	expectRows(t, res,
+		"[id INT NO PRIMARY KEY <nil>]",
+		"[code TEXT(8) YES UNIQUE <nil>]",
+		"[note TEXT(32) NO <nil> <nil>]",
+		"[emp INT YES <nil> employees(id)]")
+
+	// SHOW CREATE TABLE gives back a statement that recreates the table.
+	res = mustExec(t, e, `SHOW CREATE TABLE "order"`)
+	want := "CREATE TABLE \"order\" (\n  id INT PRIMARY KEY,\n  code TEXT(8) UNIQUE,\n  note TEXT(32) NOT NULL,\n" +
+		"  emp INT REFERENCES employees(id)\n)"
+	if got := res.Rows[0].Values[1]; got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+	mustExec(t, e, "USE archive")
+	mustExec(t, e, "CREATE TABLE employees (id INT PRIMARY KEY)")
+	mustExec(t, e, want)
+	expectRows(t, mustExec(t, e, `SHOW CREATE TABLE "order"`), fmt.Sprint([]interface{}{"order", want}))
+
+	expectRows(t, mustExec(t, e, "SHOW INDEXES"),
+		"[employees employees_pkey id PRIMARY KEY]",
+		"[order order_pkey id PRIMARY KEY]",
+		"[order order_code_key code UNIQUE]")
+	expectRows(t, mustExec(t, e, "SHOW INDEXES FROM employees"), "[employees employees_pkey id PRIMARY KEY]")
+
+	for _, sql := range []string{
+		"DESCRIBE missing",
+		"SHOW CREATE TABLE missing",
+		"SHOW INDEXES FROM missing",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	fresh := New(storage.NewEngine(t.TempDir()))
+	if _, err := run(fresh, "SHOW TABLES"); err == nil {
+		t.Errorf("SHOW TABLES without a database: expected an error")
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	mustExec(t, e, "CREATE TABLE badges (id INT PRIMARY KEY, emp INT REFERENCES employees(id), label TEXT NOT NULL, note TEXT)")
+	e.ImportDir = t.TempDir()
+	file := func(name, content string) string {
+		if err := os.WriteFile(filepath.Join(e.ImportDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	copyFrom := func(path, rest string) string {
+		return fmt.Sprintf("COPY badges%s", strings.Replace(rest, "FILE", "'"+path+"'", 1))
+	}
+
+	csvFile := file("badges.csv", "id;label;emp\n1;\"gold; shiny\";1\n2;silver;\n3;\"multi\nline\";2\n")
+	res := mustExec(t, e, copyFrom(csvFile, " (id, label, emp) FROM FILE WITH (FORMAT csv, HEADER, DELIMITER ';')"))
+	if res.Message != "Copied 3 rows" {
+		t.Errorf("unexpected message %q", res.Message)
+	}
+	expectRows(t, mustExec(t, e, "SELECT id, emp, label, note FROM badges ORDER BY id"),
+		"[1 1 gold; shiny <nil>]", "[2 <nil> silver <nil>]", "[3 2 multi\nline <nil>]")
+
+	jsonFile := file("badges.jsonl", `{"id": 4, "emp": 3, "label": "bronze"}`+"\n\n"+`{"label": "tin", "id": 5, "note": null}`+"\n")
+	mustExec(t, e, copyFrom(jsonFile, " FROM FILE WITH (FORMAT jsonl)"))
+	expectRows(t, mustExec(t, e, "SELECT id, emp, label FROM badges WHERE id > 3 ORDER BY id"),
+		"[4 3 bronze]", "[5 <nil> tin]")
+
+	// A bad file is rejected as a whole, naming the line at fault.
+	for _, tt := range []struct {
+		name, content, rest, err string
+	}{
+		{"dup.csv", "10,1,a,\n11,1,b,\n10,2,c,\n", " FROM FILE", "line 3: UNIQUE constraint violation on column id"},
+		{"fk.csv", "10,1,a,\n\n11,9,b,\n", " FROM FILE", "line 3: FK constraint violation"},
+		{"null.csv", "10,1,,x\n", " FROM FILE", "line 1: column label cannot be NULL"},
+		{"type.csv", "id,label\n10,a\nten,b\n", " (id, label) FROM FILE WITH (HEADER true)", "line 3: invalid value for column id"},
+		{"count.csv", "10,1,a\n", " FROM FILE", "line 1: expected 4 values, got 3"},
+		{"frac.csv", "10,1,a,\n11,1.7,b,\n", " FROM FILE", "line 2: invalid value for column emp (INT): 1.7"},
+		{"frac.jsonl", `{"id": 10.5, "label": "a"}`, " FROM FILE WITH (FORMAT jsonl)", "line 1: invalid value for column id (INT): 10.5"},
+		{"dup.jsonl", `{"id": 10, "label": "a"}` + "\n" + `{"id": 1, "label": "b"}`, " FROM FILE WITH (FORMAT jsonl)", "line 2: UNIQUE constraint violation"},
+		{"col.jsonl", `{"id": 10, "label": "a", "size": 3}`, " FROM FILE WITH (FORMAT jsonl)", "line 1: column not found: size"},
+		{"obj.jsonl", "\n[1, 2]", " FROM FILE WITH (FORMAT jsonl)", "line 2: expected a JSON object"},
+	} {
+		_, err := run(e, copyFrom(file(tt.name, tt.content), tt.rest))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM badges"), "[5]")
+
+	for _, sql := range []string{
+		copyFrom("missing.csv", " FROM FILE"),
+		copyFrom(csvFile, " (size) FROM FILE"),
+		copyFrom(csvFile, " FROM FILE WITH (DELIMITER '||')"),
+		copyFrom(csvFile, " FROM FILE WITH (FORMAT jsonl, HEADER)"),
+		copyFrom(csvFile, " FROM FILE WITH (HEADER, HEADER false)"),
+		"COPY missing FROM 'x.csv'",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+
+	// Thousands of rows load in one batch.
+	var sb strings.Builder
+	for i := 1; i <= 3000; i++ {
+		fmt.Fprintf(&sb, "%d,item %d\n", i, i)
+	}
+	mustExec(t, e, "CREATE TABLE items (id INT PRIMARY KEY, name TEXT)")
+	mustExec(t, e, "COPY items FROM '"+file("items.csv", sb.String())+"'")
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*), MAX(name) FROM items WHERE id > 2990"), "[10 item 3000]")
+
+	// Only an explicit CAST rounds a value with a fraction to an INT.
+	if _, err := run(e, "UPDATE items SET id = CAST(id AS FLOAT) / 2 WHERE id = 3"); err == nil {
+		t.Errorf("expected 1.5 to be rejected for an INT column")
+	}
+	expectRows(t, mustExec(t, e, "SELECT CAST(CAST(id AS FLOAT) / 2 AS INT) FROM items WHERE id = 3"), "[2]")
+
+	// Files outside the import directory cannot be read.
+	secret := filepath.Join(t.TempDir(), "secret.csv")
+	if err := os.WriteFile(secret, []byte("99,secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(e.ImportDir, "link.csv")); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(e.ImportDir, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{secret, rel, "../secret.csv", "link.csv"} {
+		if _, err := run(e, "COPY items FROM '"+path+"'"); err == nil {
+			t.Errorf("COPY FROM %s: expected an error", path)
+		}
+	}
+	e.ImportDir = ""
+	if _, err := run(e, "COPY items FROM 'items.csv'"); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected COPY FROM to be disabled, got %v", err)
+	}
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*) FROM items"), "[3000]")
+}
+
+func TestCopyTo(t *testing.T) {
+	e := newTestExecutor(t)
+	seedEmployees(t, e)
+	e.ExportDir = t.TempDir()
+	export := func(sql, file string) string {
+		t.Helper()
+		res := mustExec(t, e, sql)
+		if !strings.HasPrefix(res.Message, "Copied ") {
+			t.Errorf("%s: unexpected message %q", sql, res.Message)
+		}
+		path := filepath.Join(e.ExportDir, file)
+		// Exports are readable by everyone, unlike the temporary file
+		// they are written to.
+		if info, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if info.Mode().Perm() != 0644 {
+			t.Errorf("%s: expected mode 0644, got %v", file, info.Mode().Perm())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	got := export(`COPY (SELECT id, name || ', jr' AS "full name", salary FROM employees WHERE id >= 4 ORDER BY id) `+
+		`TO 'snap/emp.csv' WITH (FORMAT csv, HEADER, NULL 'NA')`, "snap/emp.csv")
+	if want := "id,full name,salary\n4,\"dan, jr\",50\n5,\"eve, jr\",NA\n"; got != want {
+		t.Errorf("expected csv %q, got %q", want, got)
+	}
+	got = export("COPY (SELECT id, CAST(salary AS FLOAT) / 3 AS third, salary < 60 AS low FROM employees WHERE id > 3) "+
+		"TO 'emp.jsonl' WITH (FORMAT jsonl)", "emp.jsonl")
+	if want := `{"id":4,"third":16.666666666666668,"low":true}` + "\n" + `{"id":5,"third":null,"low":null}` + "\n"; got != want {
+		t.Errorf("expected jsonl %q, got %q", want, got)
+	}
+
+	// A whole table exported as CSV loads back with COPY FROM.
+	export("COPY employees TO 'all.csv'", "all.csv")
+	mustExec(t, e, "CREATE TABLE copied (id INT PRIMARY KEY, name TEXT, dept TEXT, salary INT)")
+	e.ImportDir = e.ExportDir
+	mustExec(t, e, "COPY copied FROM 'all.csv'")
+	expectRows(t, mustExec(t, e, "SELECT * FROM copied EXCEPT SELECT * FROM employees"))
+	expectRows(t, mustExec(t, e, "SELECT COUNT(*), COUNT(salary) FROM copied"), "[5 4]")
+
+	got = export("COPY (SELECT dept, AVG(salary) AS avg, COUNT(*) > 1 AS many FROM employees GROUP BY dept ORDER BY dept) "+
+		"TO 'depts.col' WITH (FORMAT columnar)", "depts.col")
+	columns, rows := readColumnar(t, []byte(got))
+	if fmt.Sprint(columns) != "[dept:3 avg:2 many:4]" {
+		t.Errorf("unexpected columnar schema %v", columns)
+	}
+	if fmt.Sprint(rows) != "[[eng 90 true] [hr <nil> false] [ops 50 true]]" {
+		t.Errorf("unexpected columnar rows %v", rows)
+	}
+
+	for _, sql := range []string{
+		"COPY employees TO '../escape.csv'",
+		"COPY employees TO '" + filepath.Join(e.ExportDir, "abs.csv") + "'",
+		"COPY employees (missing) TO 'x.csv'",
+		"COPY (SELECT missing FROM employees) TO 'x.csv'",
+		"COPY employees FROM 'x.col' WITH (FORMAT columnar)",
+		"COPY employees TO 'x.jsonl' WITH (FORMAT jsonl, DELIMITER ';')",
+	} {
+		if _, err := run(e, sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	e.ExportDir = ""
+	if _, err := run(e, "COPY employees TO 'x.csv'"); err == nil {
+		t.Errorf("COPY TO without an export directory: expected an error")
+	}
+}
+
+// readColumnar decodes a file written with FORMAT columnar into its
+// columns, as name:type, and its rows.
+func readColumnar(t *testing.T, data []byte) ([]string, [][]interface{}) {
+	t.Helper()
+	r := bytes.NewReader(data)
+	read := func(v interface{}) {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			t.Fatalf("truncated columnar file: %v", err)
+		}
+	}
+	magic := make([]byte, len(columnarMagic))
+	if read(magic); string(magic) != columnarMagic {
+		t.Fatalf("bad magic %q", magic)
+	}
+	var ncols uint32
+	read(&ncols)
+	var columns []string
+	types := make([]byte, ncols)
+	for i := range types {
+		var n uint16
+		read(&n)
+		name := make([]byte, n)
+		read(name)
+		read(&types[i])
+		columns = append(columns, fmt.Sprintf("%s:%d", name, types[i]))
+	}
+	var nrows uint64
+	read(&nrows)
+	rows := make([][]interface{}, nrows)
+	for i := range rows {
+		rows[i] = make([]interface{}, ncols)
+	}
+	for i, typ := range types {
+		var size uint64
+		read(&size)
+		nulls := make([]byte, (nrows+7)/8)
+		read(nulls)
+		for j := range rows {
+			if nulls[j/8]&(1<<(j%8)) != 0 {
+				continue
+			}
+			switch typ {
+			case columnarInt:
+				var v int64
+				read(&v)
+				rows[j][i] = v
+			case columnarFloat:
+				var v uint64
+				read(&v)
+				rows[j][i] = math.Float64frombits(v)
+			case columnarBool:
+				var v byte
+				read(&v)
+				rows[j][i] = v == 1
+			default:
+				var n uint32
+				read(&n)
+				text := make([]byte, n)
+				read(text)
+				rows[j][i] = string(text)
+			}
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d trailing bytes in columnar file", r.Len())
+	}
+	return columns, rows
+}
+
+func TestDump(t *testing.T) {
+	e := newTestExecutor(t)
+	for _, sql := range []string{
+		"CREATE TABLE staff (id INT PRIMARY KEY, name TEXT NOT NULL, boss INT REFERENCES staff(id))",
+		"CREATE TABLE accounts (id INT PRIMARY KEY, owner INT REFERENCES users(id), note TEXT)",
+		`CREATE TABLE users (id INT PRIMARY KEY, "select" TEXT(16) UNIQUE)`,
+		"INSERT INTO users VALUES (1, 'it''s'), (2, '007'), (3, 'two\nlines')",
+		// The text 'NULL' is not NULL.
+		"INSERT INTO accounts VALUES (1, 1, 'x'), (2, 2, 'NULL'), (3, 1, '-5'), (4, NULL, NULL)",
+		// Children stored before their parents, and a cycle (4 and 5).
+		"INSERT INTO staff VALUES (1, 'a', 2), (2, 'b', 3), (3, 'c', NULL), (4, 'd', 5), (5, 'e', 4), (6, 'f', 1)",
+	} {
+		mustExec(t, e, sql)
+	}
+
+	var sb strings.Builder
+	if err := e.Dump(&sb, 2); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+	if !strings.HasPrefix(script, "-- moDB dump of database test\nCREATE DATABASE test;\nUSE test;\n") {
+		t.Errorf("unexpected start of dump:\n%s", script)
+	}
+	// Referenced tables come first, and so do referenced rows.
+	for _, pair := range [][2]string{
+		{"CREATE TABLE users", "CREATE TABLE accounts"},
+		{"INSERT INTO users", "INSERT INTO accounts"},
+		{"(3, 'c', NULL), (2, 'b', 3);", "(1, 'a', 2)"},
+	} {
+		if i, j := strings.Index(script, pair[0]), strings.Index(script, pair[1]); i < 0 || j < 0 || i > j {
+			t.Errorf("expected %q before %q in dump:\n%s", pair[0], pair[1], script)
+		}
+	}
+	if !strings.Contains(script, "(2, 2, 'NULL');") || !strings.Contains(script, "(4, NULL, NULL);") {
+		t.Errorf("expected NULL and the text 'NULL' to be told apart:\n%s", script)
+	}
+	// The rows of the cycle go in one INSERT, however small the batches.
+	if !strings.Contains(script, "(4, 'd', 5), (5, 'e', 4)") {
+		t.Errorf("expected the cyclic rows in one INSERT:\n%s", script)
+	}
+
+	restored := New(storage.NewEngine(t.TempDir()))
+	if _, err := run(restored, script); err != nil {
+		t.Fatalf("restoring the dump: %v\n%s", err, script)
+	}
+	for _, table := range []string{"users", "accounts", "staff"} {
+		want := rowsToStrings(mustExec(t, e, "SELECT * FROM "+table+" ORDER BY id"))
+		got := rowsToStrings(mustExec(t, restored, "SELECT * FROM "+table+" ORDER BY id"))
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: expected rows %q after restore, got %q", table, want, got)
+		}
+	}
+	var again strings.Builder
+	if err := restored.Dump(&again, 2); err != nil || again.String() != script {
+		t.Errorf("dumping the restored database gave a different script (%v):\n%s", err, again.String())
+	}
+
+	// Tables whose foreign keys reference each other cannot be ordered.
+	mustExec(t, e, "CREATE TABLE a (id INT PRIMARY KEY, b INT REFERENCES b(id))")
+	mustExec(t, e, "CREATE TABLE b (id INT PRIMARY KEY, a INT REFERENCES a(id))")
+	if err := e.Dump(&sb, 2); err == nil || !strings.Contains(err.Error(), "cycle: a, b") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
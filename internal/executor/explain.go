@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// nodeStats is what one plan node did during EXPLAIN ANALYZE. A node runs
+// once per loop; a correlated subquery, say, loops once per outer row. The
+// counts add up over all loops, and pages and time include the node's
+// inputs.
+type nodeStats struct {
+	loops int
+	rows  int
+	pages uint64
+	time  time.Duration
+}
+
+// executeAnalyzed runs plan and adds what it did to e.stats.
+func (e *Executor) executeAnalyzed(plan planner.PlanNode) (ResultSet, error) {
+	pages, start := e.pagesRead(), time.Now()
+	res, err := e.execute(plan)
+	elapsed := time.Since(start)
+
+	s, ok := e.stats[plan]
+	if !ok {
+		s = &nodeStats{}
+		e.stats[plan] = s
+	}
+	s.loops++
+	switch plan.(type) {
+	case *planner.InsertNode, *planner.UpdateNode, *planner.DeleteNode:
+		// The rows written count, RETURNING or not.
+		s.rows += res.Affected
+	default:
+		s.rows += len(res.Rows)
+	}
+	s.pages += e.pagesRead() - pages
+	s.time += elapsed
+	return res, err
+}
+
+// pagesRead returns the number of pages read from all tables so far.
+func (e *Executor) pagesRead() uint64 {
+	var n uint64
+	for _, table := range e.Tables {
+		n += table.Pager.PagesRead()
+	}
+	return n
+}
+
+// executeExplain returns one row per plan node, indented under the node
+// that reads it. With ANALYZE the statement is run first, and its own
+// result discarded, to fill in the actual figures.
+func (e *Executor) executeExplain(n *planner.ExplainNode) (ResultSet, error) {
+	columns := []string{"plan", "estimated_rows"}
+	var stats map[planner.PlanNode]*nodeStats
+	if n.Analyze {
+		columns = append(columns, "actual_rows", "loops", "pages_read", "time_ms")
+		// The statement runs on a copy of the executor that collects the
+		// stats, so they cover this statement alone.
+		analyzer := *e
+		analyzer.stats = make(map[planner.PlanNode]*nodeStats)
+		_, err := analyzer.Execute(n.Plan)
+		stats = analyzer.stats
+		if err != nil {
+			return ResultSet{}, err
+		}
+	}
+
+	var rows []storage.Row
+	line := func(depth int, text string, node planner.PlanNode) {
+		if depth > 0 {
+			text = strings.Repeat("  ", depth-1) + "-> " + text
+		}
+		values := []interface{}{text, nil}
+		if node != nil {
+			values[1] = int64(n.Estimates[node])
+		}
+		if n.Analyze {
+			values = append(values, nil, nil, nil, nil)
+			if s, ok := stats[node]; ok {
+				values[2], values[3], values[4] = int64(s.rows), int64(s.loops), int64(s.pages)
+				values[5] = float64(s.time.Microseconds()) / 1000
+			} else if node != nil {
+				values[3] = int64(0) // never executed
+			}
+		}
+		rows = append(rows, storage.Row{Values: values})
+	}
+
+	var walk func(node planner.PlanNode, depth int)
+	walk = func(node planner.PlanNode, depth int) {
+		line(depth, planner.DescribeNode(node), node)
+		for _, in := range planner.NodeInputs(node) {
+			if in.Label == "" {
+				walk(in.Plan, depth+1)
+				continue
+			}
+			line(depth+1, in.Label, nil)
+			walk(in.Plan, depth+2)
+		}
+	}
+	walk(n.Plan, 0)
+	return ResultSet{Columns: columns, Rows: rows}, nil
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+)
+
+// executeCopyTo runs the query of a COPY TO and writes its result to a
+// file under the export directory. The file is written under a temporary
+// name and renamed into place, so readers never see half an export.
+func (e *Executor) executeCopyTo(n *planner.CopyToNode) (ResultSet, error) {
+	path, err := e.exportPath(n.File)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	res, err := e.Execute(n.Query)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ResultSet{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".copy-*")
+	if err != nil {
+		return ResultSet{}, err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	// CreateTemp makes the file private to the server, but exports are
+	// there for other users to read.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return ResultSet{}, err
+	}
+
+	w := bufio.NewWriter(tmp)
+	switch n.Format {
+	case "jsonl":
+		err = writeJSONLines(w, res)
+	case "columnar":
+		err = writeColumnar(w, res, n.Types)
+	default:
+		err = writeCSV(w, res, n.CopyOptions)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return ResultSet{}, err
+	}
+	return ResultSet{Message: fmt.Sprintf("Copied %d rows", len(res.Rows))}, nil
+}
+
+// exportPath resolves the file named by a COPY TO, which must be a
+// relative path that stays inside the export directory.
+func (e *Executor) exportPath(file string) (string, error) {
+	if e.ExportDir == "" {
+		return "", fmt.Errorf("COPY TO is disabled: no export directory is configured")
+	}
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("COPY TO file must be a relative path inside the export directory: %s", file)
+	}
+	return filepath.Join(e.ExportDir, file), nil
+}
+
+// writeCSV writes one record per row, after a header of column names if
+// asked for. NULL is written as the NULL option, by default an empty field.
+func writeCSV(w io.Writer, res ResultSet, opts planner.CopyOptions) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.Delimiter
+	if opts.Header {
+		if err := cw.Write(res.Columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(res.Columns))
+	for _, row := range res.Rows {
+		for i, v := range row.Values {
+			record[i] = opts.Null
+			if v != nil {
+				record[i] = formatValue(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSONLines writes each row as a JSON object on a line of its own,
+// with its keys in column order.
+func writeJSONLines(w io.Writer, res ResultSet) error {
+	keys := make([][]byte, len(res.Columns))
+	for i, name := range res.Columns {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+	var line []byte
+	for _, row := range res.Rows {
+		line = append(line[:0], '{')
+		for i, v := range row.Values {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			line = append(append(append(line, keys[i]...), ':'), value...)
+		}
+		line = append(line, '}', '\n')
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,414 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// evalFunc computes the value of an expression for one input row.
+type evalFunc func(row storage.Row) (interface{}, error)
+
+// resolveColumn finds name among a result's column names. A bare name also
+// matches a qualified "table.name" column as long as only one does.
+func resolveColumn(columns []string, name string) (int, error) {
+	for i, c := range columns {
+		if c == name {
+			return i, nil
+		}
+	}
+
+	idx := -1
+	for i, c := range columns {
+		if strings.HasSuffix(c, "."+name) {
+			if idx != -1 {
+				return -1, fmt.Errorf("column reference is ambiguous: %s", name)
+			}
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return -1, fmt.Errorf("column not found: %s", name)
+	}
+	return idx, nil
+}
+
+// columnName is the result column name for expr: a column reference keeps
+// the column's own name, anything else is named by its SQL text.
+func columnName(expr ast.Expression) string {
+	if id, ok := expr.(*ast.Identifier); ok {
+		return id.Value
+	}
+	return expr.String()
+}
+
+// compileExpr resolves the column references in expr against columns once,
+// returning a function that evaluates it for each row. An expression whose
+// SQL text names an input column (an aggregate or GROUP BY expression
+// computed by an AggregateNode, or a WindowNode's window function call)
+// reads that column.
+func (e *Executor) compileExpr(expr ast.Expression, columns []string) (evalFunc, error) {
+	switch expr.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.NullLiteral, *ast.BooleanLiteral, *ast.Parameter:
+	default:
+		name := expr.String()
+		for i, c := range columns {
+			if c == name {
+				return columnRef(i), nil
+			}
+		}
+	}
+
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		idx, err := resolveColumn(columns, n.Value)
+		if err != nil {
+			if outer, ok := e.outerColumn(n.Value); ok {
+				return outer, nil
+			}
+			return nil, err
+		}
+		return columnRef(idx), nil
+	case *ast.IntegerLiteral:
+		return constant(n.Value), nil
+	case *ast.FloatLiteral:
+		return constant(n.Value), nil
+	case *ast.StringLiteral:
+		return constant(n.Value), nil
+	case *ast.NullLiteral:
+		return constant(nil), nil
+	case *ast.BooleanLiteral:
+		return constant(n.Value), nil
+	case *ast.Parameter:
+		v, err := e.param(n.Index)
+		if err != nil {
+			return nil, err
+		}
+		return constant(v), nil
+	case *ast.Star:
+		return nil, fmt.Errorf("* is only allowed in the select list or COUNT(*)")
+	case *ast.PrefixExpression:
+		return e.compilePrefix(n, columns)
+	case *ast.InfixExpression:
+		return e.compileInfix(n, columns)
+	case *ast.FunctionCall:
+		if n.Over != nil {
+			return nil, fmt.Errorf("window function %s is not allowed here", n.String())
+		}
+		if planner.IsAggregate(n.Name) {
+			return nil, fmt.Errorf("aggregate function %s is not allowed here", n.String())
+		}
+		return e.compileFunctionCall(n, columns)
+	case *ast.InExpression:
+		return e.compileInList(n, columns)
+	case *ast.IsNullExpression:
+		inner, err := e.compileExpr(n.Expr, columns)
+		if err != nil {
+			return nil, err
+		}
+		not := n.Not
+		return func(row storage.Row) (interface{}, error) {
+			v, err := inner(row)
+			if err != nil {
+				return nil, err
+			}
+			return (v == nil) != not, nil
+		}, nil
+	case *planner.Subquery:
+		return e.compileSubquery(n, columns)
+	case *planner.Case:
+		return e.compileCase(n.Expr, n.Type, columns)
+	case *ast.CaseExpression:
+		return e.compileCase(n, planner.TypeUnknown, columns)
+	case *ast.CastExpression:
+		inner, err := e.compileExpr(n.Expr, columns)
+		if err != nil {
+			return nil, err
+		}
+		t, err := planner.ParseTypeName(n.Type)
+		if err != nil {
+			return nil, err
+		}
+		return func(row storage.Row) (interface{}, error) {
+			v, err := inner(row)
+			if err != nil {
+				return nil, err
+			}
+			return castValue(v, t)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported expression: %s", expr.String())
+}
+
+func columnRef(idx int) evalFunc {
+	return func(row storage.Row) (interface{}, error) {
+		return row.Values[idx], nil
+	}
+}
+
+func constant(v interface{}) evalFunc {
+	return func(storage.Row) (interface{}, error) {
+		return v, nil
+	}
+}
+
+func (e *Executor) compilePrefix(n *ast.PrefixExpression, columns []string) (evalFunc, error) {
+	right, err := e.compileExpr(n.Right, columns)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Operator {
+	case "NOT":
+		return func(row storage.Row) (interface{}, error) {
+			v, err := right(row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("argument of NOT must be boolean, got %v", v)
+			}
+			return !b, nil
+		}, nil
+	case "-":
+		return func(row storage.Row) (interface{}, error) {
+			v, err := right(row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			if i, ok := toInt64(v); ok {
+				return -i, nil
+			}
+			if f, ok := v.(float64); ok {
+				return -f, nil
+			}
+			return nil, fmt.Errorf("operator - requires a numeric operand, got %v", v)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator: %s", n.Operator)
+}
+
+func (e *Executor) compileInfix(n *ast.InfixExpression, columns []string) (evalFunc, error) {
+	left, err := e.compileExpr(n.Left, columns)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.compileExpr(n.Right, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Operator {
+	case "AND", "OR":
+		isAnd := n.Operator == "AND"
+		return func(row storage.Row) (interface{}, error) {
+			l, err := evalBool(left, row)
+			if err != nil {
+				return nil, err
+			}
+			// Short-circuit: FALSE AND x is FALSE, TRUE OR x is TRUE.
+			if l != nil && *l != isAnd {
+				return *l, nil
+			}
+			r, err := evalBool(right, row)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil && *r != isAnd {
+				return *r, nil
+			}
+			if l == nil || r == nil {
+				return nil, nil
+			}
+			return isAnd, nil
+		}, nil
+	case "||":
+		return func(row storage.Row) (interface{}, error) {
+			l, err := left(row)
+			if err != nil || l == nil {
+				return nil, err
+			}
+			r, err := right(row)
+			if err != nil || r == nil {
+				return nil, err
+			}
+			return formatValue(l) + formatValue(r), nil
+		}, nil
+	case "=", "!=", "<", ">", "<=", ">=":
+		op := n.Operator
+		return func(row storage.Row) (interface{}, error) {
+			l, err := left(row)
+			if err != nil {
+				return nil, err
+			}
+			r, err := right(row)
+			if err != nil {
+				return nil, err
+			}
+			return compareOp(op, l, r)
+		}, nil
+	case "+", "-", "*", "/", "%":
+		op := n.Operator
+		return func(row storage.Row) (interface{}, error) {
+			l, err := left(row)
+			if err != nil || l == nil {
+				return nil, err
+			}
+			r, err := right(row)
+			if err != nil || r == nil {
+				return nil, err
+			}
+			return arithmetic(op, l, r)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator: %s", n.Operator)
+}
+
+func (e *Executor) compileFunctionCall(n *ast.FunctionCall, columns []string) (evalFunc, error) {
+	fn, ok := scalarFunctions[n.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function: %s", n.Name)
+	}
+	args := make([]evalFunc, len(n.Args))
+	for i, a := range n.Args {
+		var err error
+		if args[i], err = e.compileExpr(a, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(row storage.Row) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			v, err := arg(row)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil && !fn.nullSafe {
+				return nil, nil
+			}
+			values[i] = v
+		}
+		return fn.eval(values)
+	}, nil
+}
+
+// compileCase compiles a CASE whose results are converted to t, unless t
+// is unknown. Only the chosen branch is evaluated.
+func (e *Executor) compileCase(n *ast.CaseExpression, t planner.Type, columns []string) (evalFunc, error) {
+	var operand, otherwise evalFunc
+	var err error
+	if n.Operand != nil {
+		if operand, err = e.compileExpr(n.Operand, columns); err != nil {
+			return nil, err
+		}
+	}
+	conds := make([]evalFunc, len(n.Whens))
+	results := make([]evalFunc, len(n.Whens))
+	for i, w := range n.Whens {
+		if conds[i], err = e.compileExpr(w.Condition, columns); err != nil {
+			return nil, err
+		}
+		if results[i], err = e.compileExpr(w.Result, columns); err != nil {
+			return nil, err
+		}
+	}
+	otherwise = constant(nil)
+	if n.Else != nil {
+		if otherwise, err = e.compileExpr(n.Else, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	choose := func(row storage.Row) (evalFunc, error) {
+		var value interface{}
+		if operand != nil {
+			v, err := operand(row)
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		for i, cond := range conds {
+			var match interface{}
+			if operand != nil {
+				c, err := cond(row)
+				if err != nil {
+					return nil, err
+				}
+				if match, err = compareOp("=", value, c); err != nil {
+					return nil, err
+				}
+			} else {
+				b, err := evalBool(cond, row)
+				if err != nil {
+					return nil, err
+				}
+				match = b != nil && *b
+			}
+			if isTrue(match) {
+				return results[i], nil
+			}
+		}
+		return otherwise, nil
+	}
+	return func(row storage.Row) (interface{}, error) {
+		branch, err := choose(row)
+		if err != nil {
+			return nil, err
+		}
+		v, err := branch(row)
+		if err != nil || t == planner.TypeUnknown {
+			return v, err
+		}
+		return castValue(v, t)
+	}, nil
+}
+
+// evalBool evaluates a predicate, returning nil for NULL.
+func evalBool(fn evalFunc, row storage.Row) (*bool, error) {
+	v, err := fn(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("expected a boolean condition, got %v", v)
+	}
+	return &b, nil
+}
+
+// compareOp applies a comparison operator. Comparing with NULL yields NULL.
+func compareOp(op string, l, r interface{}) (interface{}, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	c, ok := compareValues(l, r)
+	if !ok {
+		return nil, fmt.Errorf("cannot compare %v with %v", l, r)
+	}
+	switch op {
+	case "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unsupported operator: %s", op)
+}
+
+// isTrue reports whether a predicate result selects the row; NULL does not.
+func isTrue(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
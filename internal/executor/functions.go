@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+)
+
+// param describes what one argument position of a function accepts.
+type param struct {
+	name    string
+	accepts func(planner.Type) bool
+}
+
+var (
+	anyParam     = param{"any", func(planner.Type) bool { return true }}
+	textParam    = param{"TEXT", func(t planner.Type) bool { return t == planner.TypeText || t == planner.TypeUnknown }}
+	intParam     = param{"INT", func(t planner.Type) bool { return t == planner.TypeInt || t == planner.TypeUnknown }}
+	numericParam = param{"numeric", planner.Type.IsNumeric}
+)
+
+// scalarFunction is an entry in the function registry.
+type scalarFunction struct {
+	params   []param // with variadic, the last param repeats
+	minArgs  int
+	variadic bool
+	// result returns the result type for (already accepted) argument types.
+	result func(args []planner.Type) (planner.Type, error)
+	// eval computes the result. Unless nullSafe is set, it is only called
+	// when every argument is non-NULL and the call is NULL otherwise.
+	eval     func(args []interface{}) (interface{}, error)
+	nullSafe bool
+}
+
+func returns(t planner.Type) func([]planner.Type) (planner.Type, error) {
+	return func([]planner.Type) (planner.Type, error) { return t, nil }
+}
+
+// sameAsFirst types a function like ABS whose result has its argument's type.
+func sameAsFirst(args []planner.Type) (planner.Type, error) {
+	return args[0], nil
+}
+
+// commonType types a function like COALESCE whose result is any of its
+// arguments.
+func commonType(args []planner.Type) (planner.Type, error) {
+	return planner.UnifyTypes(args...)
+}
+
+// scalarFunctions is the function registry, keyed by upper-case name.
+var scalarFunctions = map[string]*scalarFunction{
+	"UPPER": {
+		params: []param{textParam}, minArgs: 1, result: returns(planner.TypeText),
+		eval: func(args []interface{}) (interface{}, error) {
+			return strings.ToUpper(args[0].(string)), nil
+		},
+	},
+	"LOWER": {
+		params: []param{textParam}, minArgs: 1, result: returns(planner.TypeText),
+		eval: func(args []interface{}) (interface{}, error) {
+			return strings.ToLower(args[0].(string)), nil
+		},
+	},
+	"LENGTH": {
+		params: []param{textParam}, minArgs: 1, result: returns(planner.TypeInt),
+		eval: func(args []interface{}) (interface{}, error) {
+			return int64(len([]rune(args[0].(string)))), nil
+		},
+	},
+	"SUBSTR": {
+		params: []param{textParam, intParam, intParam}, minArgs: 2, result: returns(planner.TypeText),
+		eval: evalSubstr,
+	},
+	"TRIM": {
+		params: []param{textParam, textParam}, minArgs: 1, result: returns(planner.TypeText),
+		eval: func(args []interface{}) (interface{}, error) {
+			if len(args) == 2 {
+				return strings.Trim(args[0].(string), args[1].(string)), nil
+			}
+			return strings.TrimSpace(args[0].(string)), nil
+		},
+	},
+	"REPLACE": {
+		params: []param{textParam, textParam, textParam}, minArgs: 3, result: returns(planner.TypeText),
+		eval: func(args []interface{}) (interface{}, error) {
+			return strings.ReplaceAll(args[0].(string), args[1].(string), args[2].(string)), nil
+		},
+	},
+	"CONCAT": {
+		params: []param{anyParam}, minArgs: 1, variadic: true, nullSafe: true, result: returns(planner.TypeText),
+		eval: func(args []interface{}) (interface{}, error) {
+			var sb strings.Builder
+			for _, a := range args {
+				if a != nil {
+					sb.WriteString(formatValue(a))
+				}
+			}
+			return sb.String(), nil
+		},
+	},
+	"ABS": {
+		params: []param{numericParam}, minArgs: 1, result: sameAsFirst,
+		eval: func(args []interface{}) (interface{}, error) {
+			if n, ok := toInt64(args[0]); ok {
+				if n < 0 {
+					return -n, nil
+				}
+				return n, nil
+			}
+			f, _ := toFloat64(args[0])
+			return math.Abs(f), nil
+		},
+	},
+	"ROUND": {
+		params: []param{numericParam, intParam}, minArgs: 1, result: sameAsFirst,
+		eval: evalRound,
+	},
+	"COALESCE": {
+		params: []param{anyParam}, minArgs: 1, variadic: true, nullSafe: true, result: commonType,
+		eval: func(args []interface{}) (interface{}, error) {
+			for _, a := range args {
+				if a != nil {
+					return a, nil
+				}
+			}
+			return nil, nil
+		},
+	},
+	"NULLIF": {
+		params: []param{anyParam, anyParam}, minArgs: 2, nullSafe: true, result: commonType,
+		eval: func(args []interface{}) (interface{}, error) {
+			if args[0] != nil && args[1] != nil {
+				if c, ok := compareValues(args[0], args[1]); ok && c == 0 {
+					return nil, nil
+				}
+			}
+			return args[0], nil
+		},
+	},
+}
+
+// FunctionType implements planner.Catalog: it checks the arity and argument
+// types of a call against the registry and returns the result type.
+func (e *Executor) FunctionType(name string, args []planner.Type) (planner.Type, error) {
+	fn, ok := scalarFunctions[name]
+	if !ok {
+		return planner.TypeUnknown, fmt.Errorf("unknown function: %s", name)
+	}
+	if len(args) < fn.minArgs || (!fn.variadic && len(args) > len(fn.params)) {
+		return planner.TypeUnknown, fmt.Errorf("wrong number of arguments for %s: got %d", name, len(args))
+	}
+	for i, t := range args {
+		p := fn.params[min(i, len(fn.params)-1)]
+		if !p.accepts(t) {
+			return planner.TypeUnknown, fmt.Errorf("argument %d of %s must be %s, got %s", i+1, name, p.name, t)
+		}
+	}
+	return fn.result(args)
+}
+
+// evalSubstr implements SUBSTR(text, start [, length]) with 1-based
+// positions; a start before 1 shortens the result as in PostgreSQL.
+func evalSubstr(args []interface{}) (interface{}, error) {
+	runes := []rune(args[0].(string))
+	start, _ := toInt64(args[1])
+	end := int64(len(runes)) + 1
+	if len(args) == 3 {
+		length, _ := toInt64(args[2])
+		if length < 0 {
+			return nil, fmt.Errorf("negative substring length not allowed")
+		}
+		end = min(end, start+length)
+	}
+	start = max(start, 1)
+	if start >= end {
+		return "", nil
+	}
+	return string(runes[start-1 : end-1]), nil
+}
+
+// evalRound implements ROUND(x [, digits]), rounding halves away from zero.
+func evalRound(args []interface{}) (interface{}, error) {
+	digits := int64(0)
+	if len(args) == 2 {
+		digits, _ = toInt64(args[1])
+	}
+	if n, ok := toInt64(args[0]); ok {
+		if digits >= 0 {
+			return n, nil
+		}
+		scale := math.Pow(10, float64(-digits))
+		return int64(math.Round(float64(n)/scale) * scale), nil
+	}
+	f, _ := toFloat64(args[0])
+	scale := math.Pow(10, float64(digits))
+	return math.Round(f*scale) / scale, nil
+}
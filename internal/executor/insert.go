@@ -0,0 +1,340 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// executeInsert adds the rows of an INSERT as one batch. Every row is
+// converted and checked against the table's constraints before any is
+// written, so a bad row leaves the table unchanged.
+func (e *Executor) executeInsert(n *planner.InsertNode) (ResultSet, error) {
+	table, ok := e.Tables[n.TableName]
+	if !ok {
+		return ResultSet{}, fmt.Errorf("table not found: %s", n.TableName)
+	}
+	rows, err := e.insertRows(n, table.Schema)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	var updates []conflictUpdate
+	if n.OnConflict != nil {
+		if rows, updates, err = e.resolveConflicts(n, table, rows); err != nil {
+			return ResultSet{}, err
+		}
+	}
+	if err := e.checkInsertConstraints(n.TableName, table, rows); err != nil {
+		return ResultSet{}, err
+	}
+	for _, u := range updates {
+		if err := table.Update(u.row.PageID, u.row.SlotID, u.values); err != nil {
+			return ResultSet{}, err
+		}
+	}
+	if err := table.InsertMany(rows); err != nil {
+		return ResultSet{}, err
+	}
+
+	msg := fmt.Sprintf("Inserted %d rows", len(rows))
+	if n.OnConflict != nil && n.OnConflict.DoUpdate {
+		msg += fmt.Sprintf(", updated %d rows", len(updates))
+	}
+	// RETURNING lists rows in the order they were written.
+	written := make([][]interface{}, 0, len(updates)+len(rows))
+	for _, u := range updates {
+		written = append(written, u.values)
+	}
+	written = append(written, rows...)
+	return e.returningResult(columnNames(n.TableName, table.Schema), written, n.Returning, msg)
+}
+
+// insertRows builds the full table rows of an INSERT. Columns it does not
+// name are NULL.
+func (e *Executor) insertRows(n *planner.InsertNode, schema *storage.Schema) ([][]interface{}, error) {
+	// targets[i] is the table column the i-th value goes to.
+	targets := make([]int, len(schema.Columns))
+	for i := range targets {
+		targets[i] = i
+	}
+	if len(n.Columns) > 0 {
+		targets = targets[:0]
+		for _, name := range n.Columns {
+			idx := -1
+			for i, col := range schema.Columns {
+				if col.Name == name {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				return nil, fmt.Errorf("column not found: %s", name)
+			}
+			targets = append(targets, idx)
+		}
+	}
+
+	var rows [][]interface{}
+	if n.Query == nil {
+		for r, values := range n.Rows {
+			if len(values) != len(targets) {
+				return nil, fmt.Errorf("column count (%d) does not match value count (%d)", len(targets), len(values))
+			}
+			// Columns left out of the list are NULL.
+			full := make([]string, len(schema.Columns))
+			nulls := make([]bool, len(schema.Columns))
+			for i := range nulls {
+				nulls[i] = true
+			}
+			for i, idx := range targets {
+				full[idx] = values[i]
+				nulls[idx] = n.Nulls != nil && n.Nulls[r] != nil && n.Nulls[r][i]
+			}
+			var params []int
+			if n.Params != nil && n.Params[r] != nil {
+				params = make([]int, len(schema.Columns))
+				for i, idx := range targets {
+					params[idx] = n.Params[r][i]
+				}
+			}
+			converted, err := e.convertRow(full, nulls, params, schema)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, converted)
+		}
+		return rows, nil
+	}
+
+	res, err := e.Execute(n.Query)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range res.Rows {
+		full := make([]interface{}, len(schema.Columns))
+		for i, idx := range targets {
+			full[idx] = row.Values[i]
+		}
+		for i, col := range schema.Columns {
+			if full[i], err = coerceToColumn(full[i], col); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, full)
+	}
+	return rows, nil
+}
+
+// conflictUpdate is an existing row that ON CONFLICT DO UPDATE rewrites.
+type conflictUpdate struct {
+	row    storage.Row
+	values []interface{}
+}
+
+// resolveConflicts applies an ON CONFLICT clause to a batch. It returns the
+// rows still to insert and, for DO UPDATE, the new values of the existing
+// rows they clashed with. A row conflicting with an earlier row of the same
+// batch is skipped by DO NOTHING; DO UPDATE rejects it, along with a second
+// update of the same existing row, since the outcome would depend on the
+// order of the rows.
+func (e *Executor) resolveConflicts(n *planner.InsertNode, table *storage.Table, rows [][]interface{}) ([][]interface{}, []conflictUpdate, error) {
+	oc := n.OnConflict
+	schema := table.Schema
+	var keyCols []int
+	for i, col := range schema.Columns {
+		if oc.Column == "" && (col.IsUnique || col.IsPrimaryKey) || col.Name == oc.Column {
+			keyCols = append(keyCols, i)
+		}
+	}
+	if len(keyCols) == 0 {
+		// Nothing can conflict.
+		return rows, nil, nil
+	}
+
+	existing, err := table.SelectAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	// taken maps each key column's values to the existing row holding it,
+	// or to -1 for a row of the batch.
+	taken := make([]map[string]int, len(keyCols))
+	for i, colIdx := range keyCols {
+		taken[i] = make(map[string]int, len(existing)+len(rows))
+		for j, row := range existing {
+			taken[i][valueKey(row.Values[colIdx])] = j
+		}
+	}
+
+	var sets []setColumn
+	if oc.DoUpdate {
+		columns := columnNames(n.TableName, schema)
+		columns = append(columns, columnNames("excluded", schema)...)
+		if sets, err = e.compileSets(oc.Sets, schema, columns); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var inserts [][]interface{}
+	var updates []conflictUpdate
+	updated := make(map[int]bool)
+	for _, values := range rows {
+		match, conflictCol, conflict := 0, 0, false
+		for i, colIdx := range keyCols {
+			if match, conflict = taken[i][valueKey(values[colIdx])]; conflict {
+				conflictCol = colIdx
+				break
+			}
+		}
+		switch {
+		case !conflict:
+			for i, colIdx := range keyCols {
+				taken[i][valueKey(values[colIdx])] = -1
+			}
+			inserts = append(inserts, values)
+			continue
+		case !oc.DoUpdate:
+			continue
+		case match == -1 || updated[match]:
+			return nil, nil, fmt.Errorf("ON CONFLICT DO UPDATE cannot affect a row twice: value %v for column %s is proposed more than once",
+				values[conflictCol], schema.Columns[conflictCol].Name)
+		}
+
+		old := existing[match]
+		updated[match] = true
+		input := storage.Row{Values: append(append([]interface{}{}, old.Values...), values...)}
+		newValues, err := applySets(sets, schema, input, old.Values)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := e.checkUpdateForeignKeys(table, old, newValues); err != nil {
+			return nil, nil, err
+		}
+		updates = append(updates, conflictUpdate{row: old, values: newValues})
+	}
+
+	if err := checkUpdatedUnique(schema, existing, updated, updates, inserts); err != nil {
+		return nil, nil, err
+	}
+	return inserts, updates, nil
+}
+
+// checkUpdatedUnique checks that the rows rewritten by DO UPDATE keep their
+// UNIQUE and PRIMARY KEY values distinct from every other row, existing or
+// about to be inserted.
+func checkUpdatedUnique(schema *storage.Schema, existing []storage.Row, updated map[int]bool,
+	updates []conflictUpdate, inserts [][]interface{}) error {
+	for colIdx, col := range schema.Columns {
+		if !col.IsUnique && !col.IsPrimaryKey {
+			continue
+		}
+		count := make(map[string]int, len(existing)+len(inserts))
+		for j, row := range existing {
+			if !updated[j] {
+				count[valueKey(row.Values[colIdx])]++
+			}
+		}
+		for _, u := range updates {
+			count[valueKey(u.values[colIdx])]++
+		}
+		for _, values := range inserts {
+			count[valueKey(values[colIdx])]++
+		}
+		for _, u := range updates {
+			if v := u.values[colIdx]; count[valueKey(v)] > 1 {
+				return fmt.Errorf("UNIQUE constraint violation on column %s: value %v already exists", col.Name, v)
+			}
+		}
+	}
+	return nil
+}
+
+// rowError is a constraint violation by one row of a batch; row is the
+// row's index in the batch.
+type rowError struct {
+	row int
+	err error
+}
+
+func (e *rowError) Error() string { return e.err.Error() }
+
+func (e *rowError) Unwrap() error { return e.err }
+
+// checkInsertConstraints checks a batch of new rows against the UNIQUE and
+// PRIMARY KEY columns of the table, and each other, and against its foreign
+// keys. Each table involved is read once for the whole batch. A violation
+// is returned as a *rowError naming the offending row.
+func (e *Executor) checkInsertConstraints(name string, table *storage.Table, rows [][]interface{}) error {
+	var existing []storage.Row
+	loaded := false
+	for colIdx, col := range table.Schema.Columns {
+		if !col.IsUnique && !col.IsPrimaryKey {
+			continue
+		}
+		if !loaded {
+			var err error
+			if existing, err = table.SelectAll(); err != nil {
+				return err
+			}
+			loaded = true
+		}
+		seen := make(map[string]bool, len(existing)+len(rows))
+		for _, row := range existing {
+			seen[valueKey(row.Values[colIdx])] = true
+		}
+		for i, values := range rows {
+			k := valueKey(values[colIdx])
+			if seen[k] {
+				return &rowError{row: i, err: fmt.Errorf("UNIQUE constraint violation on column %s: value %v already exists", col.Name, values[colIdx])}
+			}
+			seen[k] = true
+		}
+	}
+
+	// Foreign Key enforcement: verify referenced parent rows exist
+	for colIdx, col := range table.Schema.Columns {
+		if col.References == nil {
+			continue
+		}
+		parentTable, ok := e.Tables[col.References.Table]
+		if !ok {
+			return fmt.Errorf("FK error: referenced table '%s' not found", col.References.Table)
+		}
+		parentColIdx := -1
+		for i, pc := range parentTable.Schema.Columns {
+			if pc.Name == col.References.Column {
+				parentColIdx = i
+				break
+			}
+		}
+		if parentColIdx == -1 {
+			return fmt.Errorf("FK error: referenced column '%s' not found in table '%s'", col.References.Column, col.References.Table)
+		}
+		parentRows, err := parentTable.SelectAll()
+		if err != nil {
+			return err
+		}
+		parents := make(map[string]bool, len(parentRows))
+		for _, pr := range parentRows {
+			parents[fmt.Sprintf("%v", pr.Values[parentColIdx])] = true
+		}
+		// A self-referencing table's new rows may refer to each other.
+		if col.References.Table == name {
+			for _, values := range rows {
+				parents[fmt.Sprintf("%v", values[parentColIdx])] = true
+			}
+		}
+		for i, values := range rows {
+			childVal := values[colIdx]
+			// NULL FK values are allowed (optional relationship)
+			if childVal == nil {
+				continue
+			}
+			if !parents[fmt.Sprintf("%v", childVal)] {
+				return &rowError{row: i, err: fmt.Errorf("FK constraint violation: value %v for column '%s' does not exist in '%s.%s'",
+					childVal, col.Name, col.References.Table, col.References.Column)}
+			}
+		}
+	}
+	return nil
+}
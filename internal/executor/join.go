@@ -0,0 +1,466 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// maxSortRows caps the rows of a merge join input that are sorted in
+// memory. A larger input is sorted in runs of this many rows, which are
+// spilled to temporary files and merged back as the join reads them.
+var maxSortRows = 1 << 16
+
+// joinState accumulates a join's output and pads unmatched rows with NULLs
+// for outer joins. Semi and anti joins emit left rows only, by whether
+// they matched.
+type joinState struct {
+	match      func(storage.Row) (bool, error)
+	kind       ast.JoinKind
+	keepLeft   bool
+	keepRight  bool
+	leftNulls  []interface{}
+	rightNulls []interface{}
+	rows       []storage.Row
+}
+
+// pair emits l joined with r if the join condition holds for them. Joined
+// rows keep the location of their left row, which UPDATE ... FROM and
+// DELETE ... USING write back to.
+func (j *joinState) pair(l, r storage.Row) (bool, error) {
+	combined := storage.Row{
+		Values: append(append([]interface{}{}, l.Values...), r.Values...),
+		PageID: l.PageID,
+		SlotID: l.SlotID,
+	}
+	ok, err := j.match(combined)
+	if ok && j.kind != ast.SemiJoin && j.kind != ast.AntiJoin {
+		j.rows = append(j.rows, combined)
+	}
+	return ok, err
+}
+
+// leftDone is called once per left row after its matches were emitted. It
+// emits the row for a semi join if it matched and for an anti join if it
+// did not, and pads an unmatched row for LEFT and FULL joins.
+func (j *joinState) leftDone(l storage.Row, found bool) {
+	switch {
+	case j.kind == ast.SemiJoin && found, j.kind == ast.AntiJoin && !found:
+		j.rows = append(j.rows, l)
+	case j.keepLeft && !found:
+		j.rows = append(j.rows, storage.Row{
+			Values: append(append([]interface{}{}, l.Values...), j.rightNulls...),
+			PageID: l.PageID,
+			SlotID: l.SlotID,
+		})
+	}
+}
+
+// rightUnmatched emits the right rows that matched nothing, for RIGHT and
+// FULL joins.
+func (j *joinState) rightUnmatched(right []keyedRow, matched []bool) {
+	if !j.keepRight {
+		return
+	}
+	for i, r := range right {
+		if !matched[i] {
+			j.rows = append(j.rows, storage.Row{Values: append(append([]interface{}{}, j.leftNulls...), r.row.Values...)})
+		}
+	}
+}
+
+// executeJoin joins the results of its two children with the method the
+// planner chose.
+func (e *Executor) executeJoin(n *planner.JoinNode) (ResultSet, error) {
+	left, err := e.Execute(n.Left)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	right, err := e.Execute(n.Right)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	// Scans qualify their columns by table reference, so the combined
+	// columns stay distinct even in a self-join.
+	combinedCols := append(append([]string{}, left.Columns...), right.Columns...)
+	outCols := combinedCols
+	if n.Kind == ast.SemiJoin || n.Kind == ast.AntiJoin {
+		outCols = left.Columns
+	}
+
+	j := &joinState{
+		match:      func(storage.Row) (bool, error) { return true, nil },
+		kind:       n.Kind,
+		keepLeft:   n.Kind == ast.LeftJoin || n.Kind == ast.FullJoin,
+		keepRight:  n.Kind == ast.RightJoin || n.Kind == ast.FullJoin,
+		leftNulls:  make([]interface{}, len(left.Columns)),
+		rightNulls: make([]interface{}, len(right.Columns)),
+		rows:       []storage.Row{},
+	}
+	if n.Condition != nil {
+		cond, err := e.compileExpr(n.Condition, combinedCols)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		j.match = func(row storage.Row) (bool, error) {
+			v, err := cond(row)
+			return isTrue(v), err
+		}
+	}
+
+	switch n.Method {
+	case planner.NestedLoopJoin:
+		err = j.nestedLoop(left.Rows, right.Rows)
+	case planner.HashJoin:
+		var leftKeyed, rightKeyed []keyedRow
+		leftKeyed, err = e.keyRows(left, n.LeftKeys)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		rightKeyed, err = e.keyRows(right, n.RightKeys)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		err = j.hashJoin(leftKeyed, rightKeyed)
+	default:
+		err = e.mergeJoin(j, left, right, n.LeftKeys, n.RightKeys)
+	}
+	if err != nil {
+		return ResultSet{}, err
+	}
+	return ResultSet{Columns: outCols, Rows: j.rows}, nil
+}
+
+// keyRows evaluates the join keys of every row of res.
+func (e *Executor) keyRows(res ResultSet, keys []ast.Expression) ([]keyedRow, error) {
+	fns, err := e.compileKeys(keys, res.Columns)
+	if err != nil {
+		return nil, err
+	}
+	keyed := make([]keyedRow, len(res.Rows))
+	for i, row := range res.Rows {
+		if keyed[i], err = keyRow(fns, row); err != nil {
+			return nil, err
+		}
+	}
+	return keyed, nil
+}
+
+func (e *Executor) compileKeys(keys []ast.Expression, columns []string) ([]evalFunc, error) {
+	fns := make([]evalFunc, len(keys))
+	for i, k := range keys {
+		fn, err := e.compileExpr(k, columns)
+		if err != nil {
+			return nil, err
+		}
+		fns[i] = fn
+	}
+	return fns, nil
+}
+
+func keyRow(fns []evalFunc, row storage.Row) (keyedRow, error) {
+	values := make([]interface{}, len(fns))
+	for k, fn := range fns {
+		v, err := fn(row)
+		if err != nil {
+			return keyedRow{}, err
+		}
+		values[k] = v
+	}
+	return keyedRow{keys: values, row: row}, nil
+}
+
+// hasNullKey reports whether a key tuple contains NULL, which never equals
+// anything.
+func hasNullKey(keys []interface{}) bool {
+	for _, k := range keys {
+		if k == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedLoop compares every left row with every right row.
+func (j *joinState) nestedLoop(left, right []storage.Row) error {
+	rightKeyed := make([]keyedRow, len(right))
+	for i, r := range right {
+		rightKeyed[i] = keyedRow{row: r}
+	}
+	matched := make([]bool, len(right))
+	for _, l := range left {
+		found := false
+		for i, r := range right {
+			ok, err := j.pair(l, r)
+			if err != nil {
+				return err
+			}
+			if ok {
+				found = true
+				matched[i] = true
+			}
+		}
+		j.leftDone(l, found)
+	}
+	j.rightUnmatched(rightKeyed, matched)
+	return nil
+}
+
+// hashJoin builds a hash table over the right input and probes it with the
+// left. The planner only picks it for build sides that fit in memory.
+func (j *joinState) hashJoin(left, right []keyedRow) error {
+	table := make(map[string][]int)
+	for i, r := range right {
+		if !hasNullKey(r.keys) {
+			k := rowKey(r.keys)
+			table[k] = append(table[k], i)
+		}
+	}
+
+	matched := make([]bool, len(right))
+	for _, l := range left {
+		found := false
+		if !hasNullKey(l.keys) {
+			for _, i := range table[rowKey(l.keys)] {
+				ok, err := j.pair(l.row, right[i].row)
+				if err != nil {
+					return err
+				}
+				if ok {
+					found = true
+					matched[i] = true
+				}
+			}
+		}
+		j.leftDone(l.row, found)
+	}
+	j.rightUnmatched(right, matched)
+	return nil
+}
+
+// mergeJoin sorts both inputs on their keys and merges them, joining each
+// left row with the run of right rows that has its key. Only that run is
+// held in memory; the inputs may be spilled runs on disk.
+func (e *Executor) mergeJoin(j *joinState, left, right ResultSet, leftKeys, rightKeys []ast.Expression) error {
+	// Rows with a NULL key can never match.
+	l, err := e.sortInput(left, leftKeys, func(r storage.Row) { j.leftDone(r, false) })
+	if err != nil {
+		return err
+	}
+	defer l.close()
+	r, err := e.sortInput(right, rightKeys, func(row storage.Row) {
+		j.rightUnmatched([]keyedRow{{row: row}}, []bool{false})
+	})
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	lrow, lok := l.next()
+	rrow, rok := r.next()
+	for lok && rok {
+		c := compareRows(lrow.keys, rrow.keys, nil)
+		switch {
+		case c < 0:
+			j.leftDone(lrow.row, false)
+			lrow, lok = l.next()
+		case c > 0:
+			j.rightUnmatched([]keyedRow{rrow}, []bool{false})
+			rrow, rok = r.next()
+		default:
+			group := []keyedRow{rrow}
+			for rrow, rok = r.next(); rok && compareRows(rrow.keys, group[0].keys, nil) == 0; rrow, rok = r.next() {
+				group = append(group, rrow)
+			}
+			matched := make([]bool, len(group))
+			for ; lok && compareRows(lrow.keys, group[0].keys, nil) == 0; lrow, lok = l.next() {
+				found := false
+				for i, g := range group {
+					ok, err := j.pair(lrow.row, g.row)
+					if err != nil {
+						return err
+					}
+					if ok {
+						found = true
+						matched[i] = true
+					}
+				}
+				j.leftDone(lrow.row, found)
+			}
+			j.rightUnmatched(group, matched)
+		}
+	}
+	for ; lok; lrow, lok = l.next() {
+		j.leftDone(lrow.row, false)
+	}
+	for ; rok; rrow, rok = r.next() {
+		j.rightUnmatched([]keyedRow{rrow}, []bool{false})
+	}
+	if l.err != nil {
+		return l.err
+	}
+	return r.err
+}
+
+// sortInput keys the rows of res and returns them in key order, passing
+// rows with a NULL key to nulls instead. An input of more than maxSortRows
+// rows is sorted in runs that are spilled to temporary files, and each
+// row is released from res once it is on disk.
+func (e *Executor) sortInput(res ResultSet, keys []ast.Expression, nulls func(storage.Row)) (*sortedRuns, error) {
+	fns, err := e.compileKeys(keys, res.Columns)
+	if err != nil {
+		return nil, err
+	}
+	runs := &sortedRuns{}
+	for start := 0; start < len(res.Rows); start += maxSortRows {
+		end := min(start+maxSortRows, len(res.Rows))
+		var chunk []keyedRow
+		for _, row := range res.Rows[start:end] {
+			k, err := keyRow(fns, row)
+			if err != nil {
+				runs.close()
+				return nil, err
+			}
+			if hasNullKey(k.keys) {
+				nulls(row)
+			} else {
+				chunk = append(chunk, k)
+			}
+		}
+		sortKeyedRows(chunk, nil)
+		if len(res.Rows) <= maxSortRows {
+			runs.runs = append(runs.runs, &sortedRun{rows: chunk})
+			break
+		}
+		run, err := spillRun(chunk)
+		if err != nil {
+			runs.close()
+			return nil, err
+		}
+		runs.runs = append(runs.runs, run)
+		clear(res.Rows[start:end])
+	}
+	for _, run := range runs.runs {
+		if err := run.advance(); err != nil {
+			runs.close()
+			return nil, err
+		}
+	}
+	return runs, nil
+}
+
+// sortedRuns merges sorted runs into one sequence in key order.
+type sortedRuns struct {
+	runs []*sortedRun
+	err  error
+}
+
+// next returns the row with the smallest key. It returns false at the end
+// or on an error, which is then kept in err.
+func (s *sortedRuns) next() (keyedRow, bool) {
+	if s.err != nil {
+		return keyedRow{}, false
+	}
+	var best *sortedRun
+	for _, run := range s.runs {
+		// Ties go to the earlier run, which keeps the sort stable.
+		if run.ok && (best == nil || compareRows(run.head.keys, best.head.keys, nil) < 0) {
+			best = run
+		}
+	}
+	if best == nil {
+		return keyedRow{}, false
+	}
+	row := best.head
+	if s.err = best.advance(); s.err != nil {
+		return keyedRow{}, false
+	}
+	return row, true
+}
+
+func (s *sortedRuns) close() {
+	for _, run := range s.runs {
+		if run.file != nil {
+			run.file.Close()
+			os.Remove(run.file.Name())
+		}
+	}
+}
+
+// sortedRun is one sorted run of rows, in memory or in a spill file. head
+// is its next row while ok is true.
+type sortedRun struct {
+	rows []keyedRow
+	file *os.File
+	dec  *gob.Decoder
+	head keyedRow
+	ok   bool
+}
+
+// spilledRow is the on-disk form of a keyedRow.
+type spilledRow struct {
+	Keys   []interface{}
+	Values []interface{}
+	PageID uint32
+	SlotID uint16
+}
+
+// spillRun writes a sorted run to a temporary file.
+func spillRun(rows []keyedRow) (*sortedRun, error) {
+	f, err := os.CreateTemp("", "modb-merge-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spill merge join run: %w", err)
+	}
+	run := &sortedRun{file: f}
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, r := range rows {
+		if err = enc.Encode(spilledRow{Keys: r.keys, Values: r.row.Values, PageID: r.row.PageID, SlotID: r.row.SlotID}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to spill merge join run: %w", err)
+	}
+	run.dec = gob.NewDecoder(bufio.NewReader(f))
+	return run, nil
+}
+
+// advance moves head to the run's next row.
+func (r *sortedRun) advance() error {
+	if r.file == nil {
+		r.ok = len(r.rows) > 0
+		if r.ok {
+			r.head, r.rows = r.rows[0], r.rows[1:]
+		}
+		return nil
+	}
+	var sr spilledRow
+	err := r.dec.Decode(&sr)
+	if err == io.EOF {
+		r.ok = false
+		return nil
+	}
+	if err != nil {
+		r.ok = false
+		return fmt.Errorf("failed to read merge join run: %w", err)
+	}
+	r.head = keyedRow{keys: sr.Keys, row: storage.Row{Values: sr.Values, PageID: sr.PageID, SlotID: sr.SlotID}}
+	r.ok = true
+	return nil
+}
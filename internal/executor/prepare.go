@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+func (e *Executor) executePrepare(n *planner.PrepareNode) (ResultSet, error) {
+	if _, ok := e.prepared[n.Name]; ok {
+		return ResultSet{}, fmt.Errorf("prepared statement already exists: %s", n.Name)
+	}
+	if e.prepared == nil {
+		e.prepared = make(map[string]*planner.PrepareNode)
+	}
+	e.prepared[n.Name] = n
+	return ResultSet{}, nil
+}
+
+// executePrepared runs the cached plan of a prepared statement. Each
+// argument is converted to the type of its parameter before the plan runs,
+// so a value that does not fit is rejected up front.
+func (e *Executor) executePrepared(n *planner.ExecuteNode) (ResultSet, error) {
+	prep, ok := e.prepared[n.Name]
+	if !ok {
+		return ResultSet{}, fmt.Errorf("prepared statement not found: %s", n.Name)
+	}
+	if len(n.Args) != len(prep.Params) {
+		return ResultSet{}, fmt.Errorf("prepared statement %s takes %d parameters, got %d", n.Name, len(prep.Params), len(n.Args))
+	}
+
+	params := make([]interface{}, len(n.Args))
+	for i, arg := range n.Args {
+		fn, err := e.compileExpr(arg, nil)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		v, err := fn(storage.Row{})
+		if err != nil {
+			return ResultSet{}, err
+		}
+		if t := prep.Params[i]; t != planner.TypeUnknown {
+			if v, err = castValue(v, t); err != nil {
+				return ResultSet{}, fmt.Errorf("parameter $%d: %v", i+1, err)
+			}
+		}
+		params[i] = v
+	}
+
+	saved := e.params
+	e.params = params
+	defer func() { e.params = saved }()
+	return e.Execute(prep.Plan)
+}
+
+func (e *Executor) executeDeallocate(n *planner.DeallocateNode) (ResultSet, error) {
+	if _, ok := e.prepared[n.Name]; !ok {
+		return ResultSet{}, fmt.Errorf("prepared statement not found: %s", n.Name)
+	}
+	delete(e.prepared, n.Name)
+	return ResultSet{}, nil
+}
+
+// param returns the value bound to a bind parameter.
+func (e *Executor) param(index int) (interface{}, error) {
+	if index > len(e.params) {
+		return nil, fmt.Errorf("no value bound for parameter $%d", index)
+	}
+	return e.params[index-1], nil
+}
+
+// convertRow converts the values of a VALUES row, given for every table
+// column. nulls marks the values that are NULL, and params, when set, holds
+// the bind parameter number of each value, or 0 for a literal.
+func (e *Executor) convertRow(values []string, nulls []bool, params []int, schema *storage.Schema) ([]interface{}, error) {
+	converted := make([]interface{}, len(schema.Columns))
+	for i, col := range schema.Columns {
+		var err error
+		switch {
+		case nulls[i]:
+			converted[i], err = coerceToColumn(nil, col)
+		case params != nil && params[i] != 0:
+			var v interface{}
+			if v, err = e.param(params[i]); err == nil {
+				converted[i], err = coerceToColumn(v, col)
+			}
+		default:
+			converted[i], err = e.convertSingleValue(values[i], col)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
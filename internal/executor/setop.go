@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// executeSetOp combines the rows of both inputs. Rows compare equal when
+// all their values do, NULLs included. The left input's order is kept, with
+// UNION appending the right input's rows after it.
+func (e *Executor) executeSetOp(n *planner.SetOpNode) (ResultSet, error) {
+	left, err := e.Execute(n.Left)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	right, err := e.Execute(n.Right)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	leftRows := conformRows(left.Rows, n.Types)
+	rightRows := conformRows(right.Rows, n.Types)
+
+	rows := []storage.Row{}
+	seen := make(map[string]bool)
+	// emit adds row unless duplicates are being removed and it was added
+	// before.
+	emit := func(row storage.Row, k string) {
+		if !n.All {
+			if seen[k] {
+				return
+			}
+			seen[k] = true
+		}
+		rows = append(rows, row)
+	}
+
+	if n.Op == ast.Union {
+		for _, row := range append(leftRows, rightRows...) {
+			emit(row, rowKey(row.Values))
+		}
+		return ResultSet{Columns: n.Columns, Rows: rows}, nil
+	}
+
+	// counts is how many times each row occurs on the right. With ALL,
+	// each right row cancels or matches a single left row.
+	counts := make(map[string]int)
+	for _, row := range rightRows {
+		counts[rowKey(row.Values)]++
+	}
+	for _, row := range leftRows {
+		k := rowKey(row.Values)
+		found := counts[k] > 0
+		if found && n.All {
+			counts[k]--
+		}
+		if found == (n.Op == ast.Intersect) {
+			emit(row, k)
+		}
+	}
+	return ResultSet{Columns: n.Columns, Rows: rows}, nil
+}
+
+// conformRows converts the numbers in FLOAT columns to float64, so a column
+// unified from INT and FLOAT inputs holds values of one type.
+func conformRows(rows []storage.Row, types []planner.Type) []storage.Row {
+	var floats []int
+	for i, t := range types {
+		if t == planner.TypeFloat {
+			floats = append(floats, i)
+		}
+	}
+	if len(floats) == 0 {
+		return rows
+	}
+	out := make([]storage.Row, len(rows))
+	for r, row := range rows {
+		values := append([]interface{}{}, row.Values...)
+		for _, i := range floats {
+			if f, ok := toFloat64(values[i]); ok {
+				values[i] = f
+			}
+		}
+		out[r] = storage.Row{Values: values}
+	}
+	return out
+}
+
+// executeLimit skips the first Offset rows of its input and returns at most
+// Limit of the rest. A NULL limit or offset is ignored.
+func (e *Executor) executeLimit(n *planner.LimitNode) (ResultSet, error) {
+	res, err := e.Execute(n.Child)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	bound := func(name string, expr ast.Expression) (int64, bool, error) {
+		if expr == nil {
+			return 0, false, nil
+		}
+		fn, err := e.compileExpr(expr, nil)
+		if err != nil {
+			return 0, false, err
+		}
+		v, err := fn(storage.Row{})
+		if err != nil || v == nil {
+			return 0, false, err
+		}
+		count, _ := toInt64(v)
+		if count < 0 {
+			return 0, false, fmt.Errorf("%s must not be negative", name)
+		}
+		return count, true, nil
+	}
+
+	rows := res.Rows
+	offset, ok, err := bound("OFFSET", n.Offset)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	if ok {
+		rows = rows[min(offset, int64(len(rows))):]
+	}
+	limit, ok, err := bound("LIMIT", n.Limit)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	if ok && limit < int64(len(rows)) {
+		rows = rows[:limit]
+	}
+	return ResultSet{Columns: res.Columns, Rows: rows}, nil
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// executeShow answers SHOW and DESCRIBE from the engine's directory and the
+// schemas of the loaded tables.
+func (e *Executor) executeShow(n *planner.ShowNode) (ResultSet, error) {
+	var rows []storage.Row
+	switch n.Kind {
+	case ast.ShowDatabases:
+		entries, err := os.ReadDir(e.Engine.BaseDir)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				rows = append(rows, storage.Row{Values: []interface{}{entry.Name()}})
+			}
+		}
+		return ResultSet{Columns: []string{"database"}, Rows: rows}, nil
+
+	case ast.ShowTables:
+		if e.Engine.ActiveDB == "" {
+			return ResultSet{}, fmt.Errorf("no database selected")
+		}
+		for _, name := range e.tableNames() {
+			rows = append(rows, storage.Row{Values: []interface{}{name}})
+		}
+		return ResultSet{Columns: []string{"table"}, Rows: rows}, nil
+
+	case ast.ShowColumns:
+		for _, col := range e.Tables[n.TableName].Schema.Columns {
+			def := columnDefinition(col)
+			typ := def.DataType
+			if def.Size > 0 {
+				typ += "(" + strconv.Itoa(def.Size) + ")"
+			}
+			nullable := "YES"
+			if !col.IsNullable || col.IsPrimaryKey {
+				nullable = "NO"
+			}
+			var key, references interface{}
+			switch {
+			case col.IsPrimaryKey:
+				key = "PRIMARY KEY"
+			case col.IsUnique:
+				key = "UNIQUE"
+			}
+			if col.References != nil {
+				references = col.References.Table + "(" + col.References.Column + ")"
+			}
+			rows = append(rows, storage.Row{Values: []interface{}{col.Name, typ, nullable, key, references}})
+		}
+		return ResultSet{Columns: []string{"column", "type", "nullable", "key", "references"}, Rows: rows}, nil
+
+	case ast.ShowCreateTable:
+		stmt := tableDefinition(n.TableName, e.Tables[n.TableName].Schema)
+		rows = append(rows, storage.Row{Values: []interface{}{n.TableName, ast.Format(stmt)}})
+		return ResultSet{Columns: []string{"table", "create_table"}, Rows: rows}, nil
+	}
+
+	// SHOW INDEXES: moDB keeps no separate index structures, so these are
+	// the unique keys its PRIMARY KEY and UNIQUE columns declare and every
+	// write checks.
+	names := []string{n.TableName}
+	if n.TableName == "" {
+		names = e.tableNames()
+	}
+	for _, name := range names {
+		for _, col := range e.Tables[name].Schema.Columns {
+			switch {
+			case col.IsPrimaryKey:
+				rows = append(rows, storage.Row{Values: []interface{}{name, name + "_pkey", col.Name, "PRIMARY KEY"}})
+			case col.IsUnique:
+				rows = append(rows, storage.Row{Values: []interface{}{name, name + "_" + col.Name + "_key", col.Name, "UNIQUE"}})
+			}
+		}
+	}
+	return ResultSet{Columns: []string{"table", "index", "column", "kind"}, Rows: rows}, nil
+}
+
+// tableNames returns the names of the loaded tables in sorted order.
+func (e *Executor) tableNames() []string {
+	names := make([]string, 0, len(e.Tables))
+	for name := range e.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tableDefinition rebuilds the CREATE TABLE statement for a stored schema.
+func tableDefinition(name string, schema *storage.Schema) *ast.CreateTableStatement {
+	stmt := &ast.CreateTableStatement{Table: name}
+	for _, col := range schema.Columns {
+		stmt.Columns = append(stmt.Columns, columnDefinition(col))
+	}
+	return stmt
+}
+
+// columnDefinition is the inverse of the type mapping in CREATE TABLE:
+// integers come back as INT and text columns as TEXT with their size.
+func columnDefinition(col storage.Column) ast.ColumnDefinition {
+	def := ast.ColumnDefinition{
+		Name:         col.Name,
+		DataType:     "INT",
+		IsNullable:   col.IsNullable,
+		IsUnique:     col.IsUnique,
+		IsPrimaryKey: col.IsPrimaryKey,
+	}
+	if col.Type == storage.TypeFixedText {
+		def.DataType = "TEXT"
+		def.Size = int(col.Size)
+	}
+	if col.References != nil {
+		def.References = &ast.ForeignKeyRef{Table: col.References.Table, Column: col.References.Column}
+	}
+	return def
+}
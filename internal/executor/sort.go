@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"sort"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+func (e *Executor) executeSort(n *planner.SortNode) (ResultSet, error) {
+	res, err := e.Execute(n.Child)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	keyFns := make([]evalFunc, len(n.Keys))
+	desc := make([]bool, len(n.Keys))
+	for i, key := range n.Keys {
+		if keyFns[i], err = e.compileExpr(key.Expr, res.Columns); err != nil {
+			return ResultSet{}, err
+		}
+		desc[i] = key.Desc
+	}
+
+	keyed := make([]keyedRow, len(res.Rows))
+	for i, row := range res.Rows {
+		keys := make([]interface{}, len(keyFns))
+		for j, fn := range keyFns {
+			if keys[j], err = fn(row); err != nil {
+				return ResultSet{}, err
+			}
+		}
+		keyed[i] = keyedRow{keys: keys, row: row}
+	}
+	sortKeyedRows(keyed, desc)
+
+	rows := make([]storage.Row, len(keyed))
+	for i, k := range keyed {
+		rows[i] = k.row
+	}
+	return ResultSet{Columns: res.Columns, Rows: rows}, nil
+}
+
+// sortKeyedRows sorts rows by their keys, keeping the input order of rows
+// with equal keys.
+func sortKeyedRows(rows []keyedRow, desc []bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareRows(rows[i].keys, rows[j].keys, desc) < 0
+	})
+}
+
+func (e *Executor) executeDistinct(n *planner.DistinctNode) (ResultSet, error) {
+	res, err := e.Execute(n.Child)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	// DISTINCT ON keys are the trailing columns of the projection.
+	width := len(res.Columns) - len(n.On)
+	seen := make(map[string]bool)
+	var rows []storage.Row
+	for _, row := range res.Rows {
+		key := row.Values
+		if len(n.On) > 0 {
+			key = row.Values[width:]
+		}
+		k := rowKey(key)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		rows = append(rows, storage.Row{Values: row.Values[:width]})
+	}
+	return ResultSet{Columns: res.Columns[:width], Rows: rows}, nil
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// outerFrame binds the outer column references of a correlated subquery
+// while its plan runs.
+type outerFrame struct {
+	columns []string
+	values  []interface{}
+}
+
+// outerColumn compiles a reference to a column of an enclosing query. The
+// frame is looked up when the subquery's plan is compiled, which happens
+// each time it runs, so the index stays valid while the reference is used.
+func (e *Executor) outerColumn(name string) (evalFunc, bool) {
+	for fi := len(e.frames) - 1; fi >= 0; fi-- {
+		idx, err := resolveColumn(e.frames[fi].columns, name)
+		if err != nil {
+			continue
+		}
+		frame := e.frames[fi]
+		return func(storage.Row) (interface{}, error) { return frame.values[idx], nil }, true
+	}
+	return nil, false
+}
+
+// subqueryResult is what an expression needs from one run of a subquery.
+type subqueryResult struct {
+	scalar  interface{}
+	exists  bool
+	members map[string]bool // IN: the value keys of the non-NULL results
+	hasNull bool            // IN: whether any result was NULL
+}
+
+// compileSubquery evaluates a planned subquery. Results are cached by the
+// values of its outer references, so an uncorrelated subquery runs once
+// and a correlated one once per distinct outer row.
+func (e *Executor) compileSubquery(n *planner.Subquery, columns []string) (evalFunc, error) {
+	refs := make([]evalFunc, len(n.OuterRefs))
+	for i, name := range n.OuterRefs {
+		fn, err := e.compileExpr(&ast.Identifier{Value: name}, columns)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = fn
+	}
+	var left evalFunc
+	if n.Kind == planner.InSubquery {
+		var err error
+		if left, err = e.compileExpr(n.Left, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	cache := make(map[string]*subqueryResult)
+	run := func(row storage.Row) (*subqueryResult, error) {
+		values := make([]interface{}, len(refs))
+		for i, ref := range refs {
+			v, err := ref(row)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		key := rowKey(values)
+		if res, ok := cache[key]; ok {
+			return res, nil
+		}
+
+		e.frames = append(e.frames, outerFrame{columns: n.OuterRefs, values: values})
+		rs, err := e.Execute(n.Plan)
+		e.frames = e.frames[:len(e.frames)-1]
+		if err != nil {
+			return nil, err
+		}
+
+		res := &subqueryResult{exists: len(rs.Rows) > 0}
+		switch n.Kind {
+		case planner.ScalarSubquery:
+			if len(rs.Rows) > 1 {
+				return nil, fmt.Errorf("more than one row returned by a subquery used as an expression")
+			}
+			if len(rs.Rows) == 1 {
+				res.scalar = rs.Rows[0].Values[0]
+			}
+		case planner.InSubquery:
+			res.members = make(map[string]bool, len(rs.Rows))
+			for _, r := range rs.Rows {
+				if r.Values[0] == nil {
+					res.hasNull = true
+				} else {
+					res.members[valueKey(r.Values[0])] = true
+				}
+			}
+		}
+		cache[key] = res
+		return res, nil
+	}
+
+	switch n.Kind {
+	case planner.ScalarSubquery:
+		return func(row storage.Row) (interface{}, error) {
+			res, err := run(row)
+			if err != nil {
+				return nil, err
+			}
+			return res.scalar, nil
+		}, nil
+	case planner.ExistsSubquery:
+		return func(row storage.Row) (interface{}, error) {
+			res, err := run(row)
+			if err != nil {
+				return nil, err
+			}
+			return res.exists, nil
+		}, nil
+	}
+	return func(row storage.Row) (interface{}, error) {
+		v, err := left(row)
+		if err != nil {
+			return nil, err
+		}
+		res, err := run(row)
+		if err != nil {
+			return nil, err
+		}
+		var in interface{}
+		switch {
+		case !res.exists:
+			in = false
+		case v != nil && res.members[valueKey(v)]:
+			in = true
+		case v == nil || res.hasNull:
+			in = nil
+		default:
+			in = false
+		}
+		return negateIf(n.Not, in), nil
+	}, nil
+}
+
+// compileInList evaluates x [NOT] IN (a, b, ...) with SQL's NULL rules: no
+// match is NULL rather than FALSE if x or any list value is NULL.
+func (e *Executor) compileInList(n *ast.InExpression, columns []string) (evalFunc, error) {
+	left, err := e.compileExpr(n.Left, columns)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]evalFunc, len(n.List))
+	for i, item := range n.List {
+		if items[i], err = e.compileExpr(item, columns); err != nil {
+			return nil, err
+		}
+	}
+	return func(row storage.Row) (interface{}, error) {
+		v, err := left(row)
+		if err != nil {
+			return nil, err
+		}
+		var result interface{} = false
+		for _, item := range items {
+			iv, err := item(row)
+			if err != nil {
+				return nil, err
+			}
+			eq, err := compareOp("=", v, iv)
+			if err != nil {
+				return nil, err
+			}
+			if eq == true {
+				result = true
+				break
+			}
+			if eq == nil {
+				result = nil
+			}
+		}
+		return negateIf(n.Not, result), nil
+	}, nil
+}
+
+// negateIf applies NOT to a three-valued boolean when not is set.
+func negateIf(not bool, v interface{}) interface{} {
+	if b, ok := v.(bool); ok && not {
+		return !b
+	}
+	return v
+}
@@ -2,10 +2,8 @@ package executor
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
 
-	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
 	"github.com/Mohammad-y-abbass/moDB/internal/storage"
 )
 
@@ -56,191 +54,163 @@ func (e *Executor) checkReferencingChildren(parent *storage.Table, parentRow sto
 	return nil
 }
 
-// ── Join execution ───────────────────────────────────────────────────────────
-
-// resolveQualifiedCol splits "table.col" into (tableName, colName).
-// If there is no dot the full string is returned as colName with an empty tableName.
-func resolveQualifiedCol(qualified string) (table, col string) {
-	parts := strings.SplitN(qualified, ".", 2)
-	if len(parts) == 2 {
-		return parts[0], parts[1]
-	}
-	return "", qualified
-}
-
-// executeJoin performs a Nested Loop inner join between two tables.
-func (e *Executor) executeJoin(n *planner.JoinNode) (ResultSet, error) {
-	leftTable, ok := e.Tables[n.Left.TableName]
-	if !ok {
-		return ResultSet{}, fmt.Errorf("table not found: %s", n.Left.TableName)
-	}
-	rightTable, ok := e.Tables[n.Right.TableName]
-	if !ok {
-		return ResultSet{}, fmt.Errorf("table not found: %s", n.Right.TableName)
-	}
+// checkUpdateForeignKeys checks an update of row to newValues against the
+// foreign keys: a changed FK value must exist in the parent table, and a
+// changed PK must not be referenced by child rows.
+func (e *Executor) checkUpdateForeignKeys(table *storage.Table, row storage.Row, newValues []interface{}) error {
+	// 1. If we are updating a CHILD column (FK), verify the new value exists in the parent
+	for colIdx, col := range table.Schema.Columns {
+		if col.References == nil {
+			continue
+		}
+		// Did this column change?
+		if fmt.Sprintf("%v", row.Values[colIdx]) == fmt.Sprintf("%v", newValues[colIdx]) {
+			continue
+		}
 
-	leftRows, err := leftTable.SelectAll()
-	if err != nil {
-		return ResultSet{}, err
-	}
-	rightRows, err := rightTable.SelectAll()
-	if err != nil {
-		return ResultSet{}, err
-	}
+		parentTable, ok := e.Tables[col.References.Table]
+		if !ok {
+			return fmt.Errorf("FK error: parent table '%s' not found", col.References.Table)
+		}
+		parentColIdx := -1
+		for i, pc := range parentTable.Schema.Columns {
+			if pc.Name == col.References.Column {
+				parentColIdx = i
+				break
+			}
+		}
+		if parentColIdx == -1 {
+			return fmt.Errorf("FK error: ref column '%s' not found in '%s'", col.References.Column, col.References.Table)
+		}
 
-	// Resolve ON-clause column indices -----------------------------------------
-	_, leftColName := resolveQualifiedCol(n.LeftKey)
-	_, rightColName := resolveQualifiedCol(n.RightKey)
+		newFKVal := newValues[colIdx]
+		if newFKVal == nil {
+			continue // NULL allowed
+		}
 
-	leftKeyIdx := -1
-	for i, c := range leftTable.Schema.Columns {
-		if c.Name == leftColName {
-			leftKeyIdx = i
-			break
+		parentRows, _ := parentTable.SelectAll()
+		found := false
+		for _, pr := range parentRows {
+			if fmt.Sprintf("%v", pr.Values[parentColIdx]) == fmt.Sprintf("%v", newFKVal) {
+				found = true
+				break
+			}
 		}
-	}
-	rightKeyIdx := -1
-	for i, c := range rightTable.Schema.Columns {
-		if c.Name == rightColName {
-			rightKeyIdx = i
-			break
+		if !found {
+			return fmt.Errorf("FK constraint violation on update: value %v does not exist in '%s.%s'",
+				newFKVal, col.References.Table, col.References.Column)
 		}
 	}
-	if leftKeyIdx == -1 {
-		return ResultSet{}, fmt.Errorf("join key column '%s' not found in table '%s'", leftColName, n.Left.TableName)
-	}
-	if rightKeyIdx == -1 {
-		return ResultSet{}, fmt.Errorf("join key column '%s' not found in table '%s'", rightColName, n.Right.TableName)
-	}
 
-	// Build combined column names (qualified as "table.col") -------------------
-	var combinedCols []string
-	for _, c := range leftTable.Schema.Columns {
-		combinedCols = append(combinedCols, n.Left.TableName+"."+c.Name)
-	}
-	for _, c := range rightTable.Schema.Columns {
-		combinedCols = append(combinedCols, n.Right.TableName+"."+c.Name)
-	}
-
-	// Nested Loop Join ---------------------------------------------------------
-	var joinedRows []storage.Row
-	for _, lr := range leftRows {
-		for _, rr := range rightRows {
-			lv := fmt.Sprintf("%v", lr.Values[leftKeyIdx])
-			rv := fmt.Sprintf("%v", rr.Values[rightKeyIdx])
-			if lv != rv {
-				continue
-			}
-			combined := storage.Row{
-				Values: append(append([]interface{}{}, lr.Values...), rr.Values...),
-			}
-			joinedRows = append(joinedRows, combined)
+	// 2. If we are updating a PARENT row's PK, block if children reference the OLD value
+	for colIdx, col := range table.Schema.Columns {
+		if !col.IsPrimaryKey {
+			continue
+		}
+		// Did PK change?
+		if fmt.Sprintf("%v", row.Values[colIdx]) == fmt.Sprintf("%v", newValues[colIdx]) {
+			continue
+		}
+		// PK changed – check children
+		if err2 := e.checkReferencingChildren(table, row); err2 != nil {
+			return fmt.Errorf("FK constraint violation on update: cannot change PK because %v", err2)
 		}
 	}
+	return nil
+}
 
-	// Apply WHERE filter on combined rows if present ---------------------------
-	if n.Where != nil {
-		// Resolve the filter column in the combined schema
-		filterColIdx := -1
-		filterCol := n.Where.Left
-		for i, cName := range combinedCols {
-			// Match both "table.col" and bare "col"
-			if cName == filterCol || strings.HasSuffix(cName, "."+filterCol) {
-				filterColIdx = i
+// setColumn is a compiled SET assignment: the index of the column it
+// assigns and its value.
+type setColumn struct {
+	idx   int
+	value evalFunc
+}
+
+// compileSets resolves the target columns of SET assignments and compiles
+// their values against columns.
+func (e *Executor) compileSets(sets []ast.SetClause, schema *storage.Schema, columns []string) ([]setColumn, error) {
+	compiled := make([]setColumn, len(sets))
+	for i, set := range sets {
+		colIdx := -1
+		for j, col := range schema.Columns {
+			if col.Name == set.Column {
+				colIdx = j
 				break
 			}
 		}
-		if filterColIdx == -1 {
-			return ResultSet{}, fmt.Errorf("WHERE column '%s' not found in join result", filterCol)
+		if colIdx == -1 {
+			return nil, fmt.Errorf("column not found in SET: %s", set.Column)
 		}
-
-		// Build a one-column schema-compatible filter using a dummy schema
-		var filtered []storage.Row
-		for _, row := range joinedRows {
-			val := row.Values[filterColIdx]
-			match, err2 := e.compareValue(val, n.Where.Op, n.Where.Right)
-			if err2 != nil {
-				return ResultSet{}, err2
-			}
-			if match {
-				filtered = append(filtered, row)
-			}
+		fn, err := e.compileExpr(set.Value, columns)
+		if err != nil {
+			return nil, err
 		}
-		joinedRows = filtered
+		compiled[i] = setColumn{idx: colIdx, value: fn}
 	}
+	return compiled, nil
+}
 
-	// Apply projection ---------------------------------------------------------
-	if len(n.Columns) > 0 && n.Columns[0] != "*" {
-		colIndices := make([]int, 0, len(n.Columns))
-		for _, want := range n.Columns {
-			found := false
-			for i, cName := range combinedCols {
-				if cName == want || strings.HasSuffix(cName, "."+want) {
-					colIndices = append(colIndices, i)
-					found = true
-					break
-				}
-			}
-			if !found {
-				return ResultSet{}, fmt.Errorf("column '%s' not found in join result", want)
-			}
+// applySets returns a copy of values with the assignments made, in order.
+// Every value is computed from input, the row as it was before the update,
+// and converted to the Go type stored for its column.
+func applySets(sets []setColumn, schema *storage.Schema, input storage.Row, values []interface{}) ([]interface{}, error) {
+	newValues := append([]interface{}{}, values...)
+	for _, set := range sets {
+		v, err := set.value(input)
+		if err != nil {
+			return nil, err
 		}
-		var projected []storage.Row
-		for _, row := range joinedRows {
-			newVals := make([]interface{}, len(colIndices))
-			for i, idx := range colIndices {
-				newVals[i] = row.Values[idx]
-			}
-			projected = append(projected, storage.Row{Values: newVals})
+		if newValues[set.idx], err = coerceToColumn(v, schema.Columns[set.idx]); err != nil {
+			return nil, err
 		}
-		return ResultSet{Columns: n.Columns, Rows: projected}, nil
 	}
+	return newValues, nil
+}
 
-	return ResultSet{Columns: combinedCols, Rows: joinedRows}, nil
+// returningResult evaluates the RETURNING list of an INSERT, UPDATE or
+// DELETE against the rows it wrote or deleted, whose columns are named by
+// columns. Without a list the result is just message.
+func (e *Executor) returningResult(columns []string, rows [][]interface{},
+	returning []ast.Expression, message string) (ResultSet, error) {
+	if len(returning) == 0 {
+		return ResultSet{Message: message, Affected: len(rows)}, nil
+	}
+	written := ResultSet{Columns: columns}
+	for _, values := range rows {
+		written.Rows = append(written.Rows, storage.Row{Values: values})
+	}
+	res, err := e.applyProjection(written, returning)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	res.Message, res.Affected = message, len(rows)
+	return res, nil
 }
 
-// compareValue compares an interface{} cell value against a string literal
-// using the given operator. Used in the join WHERE path.
-func (e *Executor) compareValue(val interface{}, op, right string) (bool, error) {
-	if val == nil {
-		return false, nil
+// ── Join execution ───────────────────────────────────────────────────────────
+
+// columnNames lists a table's column names in schema order, qualified by
+// the table name as a scan would qualify them.
+func columnNames(table string, schema *storage.Schema) []string {
+	cols := make([]string, len(schema.Columns))
+	for i, c := range schema.Columns {
+		cols[i] = table + "." + c.Name
 	}
-	switch v := val.(type) {
-	case int32:
-		target, err := strconv.Atoi(right)
-		if err != nil {
-			return false, fmt.Errorf("invalid int value in WHERE: %s", right)
-		}
-		rhs := int32(target)
-		switch op {
-		case "=":
-			return v == rhs, nil
-		case "!=":
-			return v != rhs, nil
-		case ">":
-			return v > rhs, nil
-		case "<":
-			return v < rhs, nil
-		case ">=":
-			return v >= rhs, nil
-		case "<=":
-			return v <= rhs, nil
-		}
-	case string:
-		switch op {
-		case "=":
-			return v == right, nil
-		case "!=":
-			return v != right, nil
-		case ">":
-			return v > right, nil
-		case "<":
-			return v < right, nil
-		case ">=":
-			return v >= right, nil
-		case "<=":
-			return v <= right, nil
-		}
+	return cols
+}
+
+// compileWhere compiles an optional DML WHERE clause against a table's
+// columns. A missing clause matches every row.
+func (e *Executor) compileWhere(where *ast.WhereClause, table string, schema *storage.Schema) (func(storage.Row) (bool, error), error) {
+	if where == nil {
+		return func(storage.Row) (bool, error) { return true, nil }, nil
+	}
+	cond, err := e.compileExpr(where.Condition, columnNames(table, schema))
+	if err != nil {
+		return nil, err
 	}
-	return false, nil
+	return func(row storage.Row) (bool, error) {
+		v, err := cond(row)
+		return isTrue(v), err
+	}, nil
 }
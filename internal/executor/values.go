@@ -0,0 +1,334 @@
+package executor
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// Cell values flowing through the executor are nil (SQL NULL), int32 and
+// uint32 (stored columns), int64 (integer literals and computed integers),
+// float64, string or bool.
+
+// toInt64 converts any integer cell value to int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// toFloat64 converts any numeric cell value to float64.
+func toFloat64(v interface{}) (float64, bool) {
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	return 0, false
+}
+
+// arithmetic applies +, -, *, / or % to two non-NULL numbers. Integers
+// stay integers, with / and % truncating toward zero; any FLOAT operand
+// makes the result a FLOAT.
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "*":
+				return x * y, nil
+			}
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				return x / y, nil
+			}
+			return x % y, nil
+		}
+	}
+
+	x, okA := toFloat64(a)
+	y, okB := toFloat64(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("operator %s requires numeric operands, got %v and %v", op, a, b)
+	}
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if op == "/" {
+		return x / y, nil
+	}
+	return math.Mod(x, y), nil
+}
+
+// compareValues orders two non-NULL values, returning -1, 0 or 1. A string
+// compared with a number is read as a number, mirroring how literals in
+// WHERE clauses have always been coerced to the column type. ok is false
+// when the values cannot be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if s, isStr := a.(string); isStr {
+		if _, num := toFloat64(b); num {
+			if parsed, ok := parseNumber(s); ok {
+				a = parsed
+			}
+		}
+	}
+	if s, isStr := b.(string); isStr {
+		if _, num := toFloat64(a); num {
+			if parsed, ok := parseNumber(s); ok {
+				b = parsed
+			}
+		}
+	}
+
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			return cmp.Compare(x, y), true
+		}
+	}
+	if x, ok := toFloat64(a); ok {
+		if y, ok := toFloat64(b); ok {
+			return cmp.Compare(x, y), true
+		}
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func parseNumber(s string) (interface{}, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// valueKey encodes a value so that values SQL considers equal (including
+// two NULLs, and int32 1 vs int64 1) share a key and all others differ.
+func valueKey(v interface{}) string {
+	if v == nil {
+		return "N"
+	}
+	if n, ok := toInt64(v); ok {
+		return "i" + strconv.FormatInt(n, 10)
+	}
+	switch x := v.(type) {
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<63 {
+			return "i" + strconv.FormatInt(int64(x), 10)
+		}
+		return "f" + strconv.FormatFloat(x, 'g', -1, 64)
+	case string:
+		return "s" + x
+	case bool:
+		return "b" + strconv.FormatBool(x)
+	}
+	return fmt.Sprintf("?%v", v)
+}
+
+// rowKey combines the keys of several values into one unambiguous string.
+func rowKey(values []interface{}) string {
+	var sb strings.Builder
+	for _, v := range values {
+		k := valueKey(v)
+		sb.WriteString(strconv.Itoa(len(k)))
+		sb.WriteByte(':')
+		sb.WriteString(k)
+	}
+	return sb.String()
+}
+
+// compareRows orders two key tuples column by column. NULLs sort after all
+// other values; desc[i] reverses column i (which puts its NULLs first).
+func compareRows(a, b []interface{}, desc []bool) int {
+	for i := range a {
+		c := compareKey(a[i], b[i])
+		if c == 0 {
+			continue
+		}
+		if i < len(desc) && desc[i] {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+func compareKey(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	// Incomparable values still need a stable order.
+	return strings.Compare(valueKey(a), valueKey(b))
+}
+
+// formatValue renders a non-NULL value as text, as CAST(x AS TEXT) does.
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// castValue converts a value to the given type. NULL stays NULL.
+func castValue(v interface{}, t planner.Type) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch t {
+	case planner.TypeInt:
+		if n, ok := toInt64(v); ok {
+			return n, nil
+		}
+		switch x := v.(type) {
+		case float64:
+			return int64(math.Round(x)), nil
+		case bool:
+			if x {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+				return int64(math.Round(f)), nil
+			}
+		}
+	case planner.TypeFloat:
+		if f, ok := toFloat64(v); ok {
+			return f, nil
+		}
+		if x, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+				return f, nil
+			}
+		}
+	case planner.TypeText:
+		return formatValue(v), nil
+	case planner.TypeBool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "t", "yes", "1":
+				return true, nil
+			case "false", "f", "no", "0":
+				return false, nil
+			}
+		default:
+			if n, ok := toInt64(v); ok {
+				return n != 0, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot cast %v to %s", v, t)
+}
+
+// coerceToColumn converts an evaluated value to the Go type stored for col.
+func coerceToColumn(v interface{}, col storage.Column) (interface{}, error) {
+	if v == nil {
+		if !col.IsNullable {
+			return nil, fmt.Errorf("column %s cannot be NULL", col.Name)
+		}
+		return nil, nil
+	}
+
+	switch col.Type {
+	case storage.TypeInt32, storage.TypeUint32:
+		// Only an explicit CAST rounds; a value with a fraction is not an
+		// INT.
+		c, err := castValue(v, planner.TypeInt)
+		if err == nil && hasFraction(v) {
+			err = fmt.Errorf("%v has a fractional part", v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for column %s (INT): %v", col.Name, v)
+		}
+		n := c.(int64)
+		if col.Type == storage.TypeUint32 {
+			if n < 0 || n > math.MaxUint32 {
+				return nil, fmt.Errorf("value %d out of range for column %s", n, col.Name)
+			}
+			return uint32(n), nil
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d out of range for column %s", n, col.Name)
+		}
+		return int32(n), nil
+	case storage.TypeFixedText:
+		return formatValue(v), nil
+	}
+	return nil, fmt.Errorf("unknown column type for conversion")
+}
+
+// hasFraction reports whether v is a number, or the text of one, that is
+// not a whole number.
+func hasFraction(v interface{}) bool {
+	f, ok := v.(float64)
+	if x, isText := v.(string); isText {
+		var err error
+		f, err = strconv.ParseFloat(strings.TrimSpace(x), 64)
+		ok = err == nil
+	}
+	return ok && f != math.Trunc(f)
+}
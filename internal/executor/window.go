@@ -0,0 +1,389 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// windowCall is a window function call compiled against the WindowNode's
+// input.
+type windowCall struct {
+	call      *ast.FunctionCall
+	partition []evalFunc
+	order     []evalFunc
+	desc      []bool
+	args      []evalFunc
+	agg       aggregateSpec // for aggregates
+	// start and end are the frame's offsets, when it has them: int64 for
+	// ROWS frames, float64 for RANGE frames.
+	start, end interface{}
+}
+
+func (e *Executor) executeWindow(n *planner.WindowNode) (ResultSet, error) {
+	res, err := e.Execute(n.Child)
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	width := len(res.Columns)
+	columns := append([]string{}, res.Columns...)
+	for _, call := range n.Windows {
+		columns = append(columns, call.String())
+	}
+	// The output rows are filled in place: each call sorts its own keyed
+	// view of them, which shares their values.
+	rows := make([]storage.Row, len(res.Rows))
+	for i, row := range res.Rows {
+		values := make([]interface{}, len(columns))
+		copy(values, row.Values)
+		rows[i] = storage.Row{Values: values}
+	}
+
+	for i, call := range n.Windows {
+		w, err := e.compileWindow(call, res.Columns)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		if err := w.run(rows, width+i); err != nil {
+			return ResultSet{}, err
+		}
+	}
+	return ResultSet{Columns: columns, Rows: rows}, nil
+}
+
+func (e *Executor) compileWindow(call *ast.FunctionCall, columns []string) (*windowCall, error) {
+	w := &windowCall{call: call}
+	var err error
+	compile := func(exprs []ast.Expression) ([]evalFunc, error) {
+		fns := make([]evalFunc, len(exprs))
+		for i, expr := range exprs {
+			if fns[i], err = e.compileExpr(expr, columns); err != nil {
+				return nil, err
+			}
+		}
+		return fns, nil
+	}
+
+	if w.partition, err = compile(call.Over.PartitionBy); err != nil {
+		return nil, err
+	}
+	for _, item := range call.Over.OrderBy {
+		fn, err := e.compileExpr(item.Expr, columns)
+		if err != nil {
+			return nil, err
+		}
+		w.order = append(w.order, fn)
+		w.desc = append(w.desc, item.Desc)
+	}
+
+	if planner.IsAggregate(call.Name) {
+		w.agg.call = call
+		if _, star := call.Args[0].(*ast.Star); !star {
+			if w.agg.arg, err = e.compileExpr(call.Args[0], columns); err != nil {
+				return nil, err
+			}
+		}
+	} else if w.args, err = compile(call.Args); err != nil {
+		return nil, err
+	}
+
+	if f := call.Over.Frame; f != nil {
+		if w.start, err = e.frameOffset(f, f.Start); err != nil {
+			return nil, err
+		}
+		if w.end, err = e.frameOffset(f, f.End); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// frameOffset evaluates the offset of a frame bound, which reads no
+// columns. Bounds without an offset yield nil.
+func (e *Executor) frameOffset(f *ast.WindowFrame, b ast.FrameBound) (interface{}, error) {
+	if b.Offset == nil {
+		return nil, nil
+	}
+	fn, err := e.compileExpr(b.Offset, nil)
+	if err != nil {
+		return nil, err
+	}
+	v, err := fn(storage.Row{})
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("frame offset must not be NULL")
+	}
+	if f.Unit == ast.RowsFrame {
+		n, ok := toInt64(v)
+		if !ok {
+			return nil, fmt.Errorf("ROWS frame offset must be an integer, got %v", v)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("frame offset must not be negative")
+		}
+		return n, nil
+	}
+	x, ok := toFloat64(v)
+	if !ok {
+		return nil, fmt.Errorf("RANGE frame offset must be a number, got %v", v)
+	}
+	if x < 0 {
+		return nil, fmt.Errorf("frame offset must not be negative")
+	}
+	return x, nil
+}
+
+// run sorts rows by partition and ORDER BY keys and stores the call's
+// result for each row in column col.
+func (w *windowCall) run(rows []storage.Row, col int) error {
+	keyed := make([]keyedRow, len(rows))
+	for i, row := range rows {
+		keys := make([]interface{}, 0, len(w.partition)+len(w.order))
+		for _, fn := range append(append([]evalFunc{}, w.partition...), w.order...) {
+			v, err := fn(row)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, v)
+		}
+		keyed[i] = keyedRow{keys: keys, row: row}
+	}
+	sortKeyedRows(keyed, append(make([]bool, len(w.partition)), w.desc...))
+
+	np := len(w.partition)
+	for start := 0; start < len(keyed); {
+		end := start + 1
+		for end < len(keyed) && compareRows(keyed[start].keys[:np], keyed[end].keys[:np], nil) == 0 {
+			end++
+		}
+		if err := w.runPartition(keyed[start:end], col); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// runPartition computes the call over one partition, sorted by its ORDER
+// BY keys.
+func (w *windowCall) runPartition(part []keyedRow, col int) error {
+	np := len(w.partition)
+	// Rows with equal ORDER BY keys are peers; without ORDER BY, every row
+	// of the partition is.
+	peerStart, peerEnd := make([]int, len(part)), make([]int, len(part))
+	for i := 0; i < len(part); {
+		j := i + 1
+		for j < len(part) && compareRows(part[i].keys[np:], part[j].keys[np:], nil) == 0 {
+			j++
+		}
+		for k := i; k < j; k++ {
+			peerStart[k], peerEnd[k] = i, j
+		}
+		i = j
+	}
+	set := func(i int, v interface{}) { part[i].row.Values[col] = v }
+
+	switch w.call.Name {
+	case "ROW_NUMBER":
+		for i := range part {
+			set(i, int64(i+1))
+		}
+		return nil
+	case "RANK":
+		for i := range part {
+			set(i, int64(peerStart[i]+1))
+		}
+		return nil
+	case "DENSE_RANK":
+		var rank int64
+		for i := range part {
+			if peerStart[i] == i {
+				rank++
+			}
+			set(i, rank)
+		}
+		return nil
+	case "LAG", "LEAD":
+		return w.shift(part, set)
+	}
+
+	frame := func(i int) (lo, hi int) {
+		return w.frameBounds(part, i, peerStart, peerEnd)
+	}
+	if w.call.Name == "FIRST_VALUE" {
+		for i := range part {
+			lo, hi := frame(i)
+			if lo >= hi {
+				set(i, nil)
+				continue
+			}
+			v, err := w.args[0](part[lo].row)
+			if err != nil {
+				return err
+			}
+			set(i, v)
+		}
+		return nil
+	}
+
+	// Frame ends only move forward, so a frame that starts at the
+	// partition's first row grows one aggregator; any other frame is
+	// aggregated afresh for each row.
+	specs := []aggregateSpec{w.agg}
+	var running *aggregateGroup
+	added := 0
+	for i := range part {
+		lo, hi := frame(i)
+		g := running
+		if lo != 0 || g == nil {
+			g, added = newGroup(nil, specs), lo
+		}
+		for ; added < hi; added++ {
+			if err := g.add(part[added].row, specs); err != nil {
+				return err
+			}
+		}
+		if lo == 0 {
+			running = g
+		}
+		set(i, g.aggs[0].result())
+	}
+	return nil
+}
+
+// shift computes LAG and LEAD: the argument read offset rows before or
+// after the current one, or the default when that row is outside the
+// partition.
+func (w *windowCall) shift(part []keyedRow, set func(int, interface{})) error {
+	sign := int64(-1)
+	if w.call.Name == "LEAD" {
+		sign = 1
+	}
+	for i, k := range part {
+		offset := int64(1)
+		if len(w.args) > 1 {
+			v, err := w.args[1](k.row)
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				set(i, nil)
+				continue
+			}
+			n, ok := toInt64(v)
+			if !ok {
+				return fmt.Errorf("%s offset must be an integer, got %v", w.call.Name, v)
+			}
+			offset = n
+		}
+
+		var v interface{}
+		var err error
+		if j := int64(i) + sign*offset; j >= 0 && j < int64(len(part)) {
+			v, err = w.args[0](part[j].row)
+		} else if len(w.args) > 2 {
+			v, err = w.args[2](k.row)
+		}
+		if err != nil {
+			return err
+		}
+		set(i, v)
+	}
+	return nil
+}
+
+// frameBounds returns the rows [lo, hi) of the partition in row i's frame.
+// The default frame runs from the partition's start through the row's last
+// peer.
+func (w *windowCall) frameBounds(part []keyedRow, i int, peerStart, peerEnd []int) (lo, hi int) {
+	f := w.call.Over.Frame
+	if f == nil {
+		return 0, peerEnd[i]
+	}
+	lo = w.bound(f, f.Start, w.start, true, part, i, peerStart, peerEnd)
+	hi = w.bound(f, f.End, w.end, false, part, i, peerStart, peerEnd)
+	lo, hi = max(lo, 0), min(hi, len(part))
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// bound finds where a frame bound falls for row i: the first row in the
+// frame for a start bound, one past the last for an end bound.
+func (w *windowCall) bound(f *ast.WindowFrame, b ast.FrameBound, offset interface{}, start bool,
+	part []keyedRow, i int, peerStart, peerEnd []int) int {
+	switch b.Kind {
+	case ast.UnboundedPreceding:
+		return 0
+	case ast.UnboundedFollowing:
+		return len(part)
+	case ast.CurrentRow:
+		if f.Unit == ast.RowsFrame {
+			if start {
+				return i
+			}
+			return i + 1
+		}
+		if start {
+			return peerStart[i]
+		}
+		return peerEnd[i]
+	}
+
+	if f.Unit == ast.RowsFrame {
+		n := int(offset.(int64))
+		if b.Kind == ast.OffsetPreceding {
+			n = -n
+		}
+		if start {
+			return i + n
+		}
+		return i + n + 1
+	}
+
+	// A RANGE offset is measured along the single ORDER BY key. NULL keys
+	// sort together at one end of the partition, and a NULL row's frame is
+	// its peers.
+	np := len(w.partition)
+	key := part[i].keys[np]
+	if key == nil {
+		if start {
+			return peerStart[i]
+		}
+		return peerEnd[i]
+	}
+	lo, hi := 0, len(part)
+	for lo < hi && part[lo].keys[np] == nil {
+		lo++
+	}
+	for hi > lo && part[hi-1].keys[np] == nil {
+		hi--
+	}
+	// Flipping the sign of a descending key makes the keys ascend, with
+	// PRECEDING rows below the current one either way.
+	sign := 1.0
+	if w.desc[0] {
+		sign = -1
+	}
+	v, _ := toFloat64(key)
+	target := sign * v
+	if b.Kind == ast.OffsetPreceding {
+		target -= offset.(float64)
+	} else {
+		target += offset.(float64)
+	}
+	return lo + sort.Search(hi-lo, func(j int) bool {
+		k, _ := toFloat64(part[lo+j].keys[np])
+		if start {
+			return sign*k >= target
+		}
+		return sign*k > target
+	})
+}
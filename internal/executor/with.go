@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/planner"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// maxRecursionDepth caps the number of times the recursive term of a WITH
+// RECURSIVE query runs, so a query over cyclic data fails instead of
+// looping forever.
+var maxRecursionDepth = 1000
+
+// executeWith computes the node's CTEs in order, each able to read the ones
+// before it, and then runs its child.
+func (e *Executor) executeWith(n *planner.WithNode) (ResultSet, error) {
+	if e.ctes == nil {
+		e.ctes = make(map[*planner.CTE][]storage.Row)
+	}
+	defer func() {
+		for _, cte := range n.CTEs {
+			delete(e.ctes, cte)
+		}
+	}()
+	for _, cte := range n.CTEs {
+		rows, err := e.materializeCTE(cte)
+		if err != nil {
+			return ResultSet{}, err
+		}
+		e.ctes[cte] = rows
+	}
+	return e.Execute(n.Child)
+}
+
+// materializeCTE computes the rows of cte. A recursive CTE is evaluated to
+// a fixpoint: the recursive term runs over the rows added by its previous
+// run (the seed rows to begin with) until it adds none. Without ALL, rows
+// already in the result are not added again, which is also what ends a
+// recursion over cyclic data.
+func (e *Executor) materializeCTE(cte *planner.CTE) ([]storage.Row, error) {
+	res, err := e.Execute(cte.Plan)
+	if err != nil {
+		return nil, err
+	}
+	if cte.Recursive == nil {
+		return res.Rows, nil
+	}
+
+	seen := make(map[string]bool)
+	addNew := func(rows []storage.Row) []storage.Row {
+		rows = conformRows(rows, cte.Types)
+		if cte.UnionAll {
+			return rows
+		}
+		var added []storage.Row
+		for _, row := range rows {
+			if k := rowKey(row.Values); !seen[k] {
+				seen[k] = true
+				added = append(added, row)
+			}
+		}
+		return added
+	}
+
+	result := addNew(res.Rows)
+	working := result
+	for depth := 0; len(working) > 0; depth++ {
+		if depth == maxRecursionDepth {
+			return nil, fmt.Errorf("recursive query %s exceeded the maximum depth of %d iterations", cte.Name, maxRecursionDepth)
+		}
+		e.ctes[cte] = working
+		res, err := e.Execute(cte.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		working = addNew(res.Rows)
+		result = append(result, working...)
+	}
+	return result, nil
+}
+
+// scanCTE reads the materialized rows of a CTE, qualifying its columns
+// like a table scan.
+func (e *Executor) scanCTE(n *planner.ScanNode) (ResultSet, error) {
+	rows, ok := e.ctes[n.CTE]
+	if !ok {
+		return ResultSet{}, fmt.Errorf("WITH query %s has not been computed", n.CTE.Name)
+	}
+	cols := make([]string, len(n.CTE.Columns))
+	for i, c := range n.CTE.Columns {
+		cols[i] = n.Ref() + "." + c
+	}
+	// Copied, since a sort above the scan reorders its input in place.
+	return ResultSet{Columns: cols, Rows: append([]storage.Row{}, rows...)}, nil
+}
@@ -1,12 +1,18 @@
 package lexer
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input  string
 	column int
 	Line   int
 	cursor int
+	// Comments counts the comments skipped so far; they produce no tokens.
+	Comments int
 }
 
 func New(input string) *Lexer {
@@ -18,6 +24,9 @@ func New(input string) *Lexer {
 	}
 }
 
+// advance consumes one byte. Line and column follow it: a newline starts a
+// new line, and the column counts characters, not the bytes of their UTF-8
+// encoding.
 func (l *Lexer) advance() byte {
 	if l.cursor >= len(l.input) {
 		return 0
@@ -25,7 +34,13 @@ func (l *Lexer) advance() byte {
 
 	char := l.input[l.cursor]
 	l.cursor++
-	l.column++
+	switch {
+	case char == '\n':
+		l.Line++
+		l.column = 1
+	case utf8.RuneStart(char):
+		l.column++
+	}
 	return char
 }
 
@@ -36,31 +51,104 @@ func (l *Lexer) peek() byte {
 	return l.input[l.cursor]
 }
 
+// peekAt returns the byte n positions after the current one.
+func (l *Lexer) peekAt(n int) byte {
+	if l.cursor+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.cursor+n]
+}
+
+// skipWhitespace skips whitespace and comments: -- to the end of the line,
+// and /* */ blocks, which may nest. An unterminated block is left for
+// NextToken to report.
 func (l *Lexer) skipWhitespace() {
 	for {
-		char := l.peek()
-		switch char {
-		case ' ', '\t', '\r':
+		switch char := l.peek(); {
+		case char == ' ', char == '\t', char == '\r', char == '\n':
 			l.advance()
-		case '\n':
-			l.Line++
-			l.column = 1
-			l.cursor++
+		case char == '-' && l.peekAt(1) == '-':
+			l.Comments++
+			for l.cursor < len(l.input) && l.peek() != '\n' {
+				l.advance()
+			}
+		case char == '/' && l.peekAt(1) == '*':
+			end := blockCommentEnd(l.input[l.cursor:])
+			if end < 0 {
+				return
+			}
+			l.Comments++
+			for i := 0; i < end; i++ {
+				l.advance()
+			}
 		default:
 			return
 		}
 	}
 }
 
+// blockCommentEnd returns the length of the /* */ comment input starts
+// with, counting nested comments, or -1 if it is not closed.
+func blockCommentEnd(input string) int {
+	depth := 0
+	for i := 0; i+1 < len(input); i++ {
+		switch input[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// ReadIdentifier reads a keyword or an identifier. An identifier is made of
+// parts separated by dots, each bare or double-quoted; a quoted part may
+// contain anything, with "" standing for a double quote, and keeps its
+// case. An identifier with a quoted part is never a keyword.
 func (l *Lexer) ReadIdentifier() Token {
 	start := l.cursor
+	startLine := l.Line
 	startCol := l.column
 
-	for l.cursor < len(l.input) && isIdentifierPart(l.input[l.cursor]) {
-		l.advance()
+	var b strings.Builder
+	quoted := false
+	partStart := true // at the start of the identifier or after a dot
+	for l.cursor < len(l.input) {
+		char := l.peek()
+		if char == '"' && partStart {
+			part, ok := l.readQuoted()
+			if !ok || part == "" {
+				problem := "Empty quoted identifier"
+				if !ok {
+					problem = "Unterminated quoted identifier"
+				}
+				return Token{Type: ILLEGAL, Value: l.input[start:l.cursor], Line: startLine, Col: startCol, Problem: problem}
+			}
+			b.WriteString(part)
+			quoted = true
+			if l.peek() != '.' {
+				break
+			}
+			b.WriteByte(l.advance())
+			continue
+		}
+		if !isIdentifierPart(char) {
+			break
+		}
+		b.WriteByte(l.advance())
+		partStart = char == '.'
 	}
 
-	value := l.input[start:l.cursor]
+	value := b.String()
+	if quoted {
+		return Token{Type: IDENTIFIER, Value: value, Line: startLine, Col: startCol}
+	}
 
 	upperValue := strings.ToUpper(value)
 
@@ -117,6 +205,122 @@ func (l *Lexer) ReadIdentifier() Token {
 		return Token{Type: REFERENCES_TOKEN, Value: value, Line: l.Line, Col: startCol}
 	case "FOREIGN":
 		return Token{Type: FOREIGN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "GROUP":
+		return Token{Type: GROUP_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "BY":
+		return Token{Type: BY_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "HAVING":
+		return Token{Type: HAVING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DISTINCT":
+		return Token{Type: DISTINCT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "AND":
+		return Token{Type: AND_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "OR":
+		return Token{Type: OR_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ORDER":
+		return Token{Type: ORDER_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ASC":
+		return Token{Type: ASC_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DESC":
+		return Token{Type: DESC_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "CAST":
+		return Token{Type: CAST_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "AS":
+		return Token{Type: AS_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "INNER":
+		return Token{Type: INNER_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "LEFT":
+		return Token{Type: LEFT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "RIGHT":
+		return Token{Type: RIGHT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "FULL":
+		return Token{Type: FULL_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "OUTER":
+		return Token{Type: OUTER_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "CROSS":
+		return Token{Type: CROSS_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "IN":
+		return Token{Type: IN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "IS":
+		return Token{Type: IS_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "EXISTS":
+		return Token{Type: EXISTS_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "WITH":
+		return Token{Type: WITH_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "RECURSIVE":
+		return Token{Type: RECURSIVE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "UNION":
+		return Token{Type: UNION_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ALL":
+		return Token{Type: ALL_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "INTERSECT":
+		return Token{Type: INTERSECT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "EXCEPT":
+		return Token{Type: EXCEPT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "LIMIT":
+		return Token{Type: LIMIT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "OFFSET":
+		return Token{Type: OFFSET_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "OVER":
+		return Token{Type: OVER_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "PARTITION":
+		return Token{Type: PARTITION_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ROWS":
+		return Token{Type: ROWS_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "RANGE":
+		return Token{Type: RANGE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "BETWEEN":
+		return Token{Type: BETWEEN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "UNBOUNDED":
+		return Token{Type: UNBOUNDED_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "PRECEDING":
+		return Token{Type: PRECEDING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "FOLLOWING":
+		return Token{Type: FOLLOWING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "CURRENT":
+		return Token{Type: CURRENT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ROW":
+		return Token{Type: ROW_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "CASE":
+		return Token{Type: CASE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "WHEN":
+		return Token{Type: WHEN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "THEN":
+		return Token{Type: THEN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ELSE":
+		return Token{Type: ELSE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "END":
+		return Token{Type: END_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "CONFLICT":
+		return Token{Type: CONFLICT_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DO":
+		return Token{Type: DO_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "NOTHING":
+		return Token{Type: NOTHING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "RETURNING":
+		return Token{Type: RETURNING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "USING":
+		return Token{Type: USING_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "PREPARE":
+		return Token{Type: PREPARE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "EXECUTE":
+		return Token{Type: EXECUTE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DEALLOCATE":
+		return Token{Type: DEALLOCATE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "EXPLAIN":
+		return Token{Type: EXPLAIN_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "ANALYZE":
+		return Token{Type: ANALYZE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "SHOW":
+		return Token{Type: SHOW_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DESCRIBE":
+		return Token{Type: DESCRIBE_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "DATABASES":
+		return Token{Type: DATABASES_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "TABLES":
+		return Token{Type: TABLES_TOKEN, Value: value, Line: l.Line, Col: startCol}
+	case "INDEXES":
+		return Token{Type: INDEXES_TOKEN, Value: value, Line: l.Line, Col: startCol}
 	default:
 		return Token{Type: IDENTIFIER, Value: value, Line: l.Line, Col: startCol}
 	}
@@ -134,13 +338,18 @@ func (l *Lexer) readNumber() Token {
 		l.advance()
 	}
 
-	value := l.input[start:l.cursor]
-
-	// Use ILLEGAL if it's just a lone dash
+	// A dash not followed by digits is the minus operator
 	tokenType := NUMBER
-	if value == "-" {
-		tokenType = ILLEGAL
+	if l.input[start:l.cursor] == "-" {
+		tokenType = MINUS
+	} else if l.peek() == '.' && isDigit(l.peekAt(1)) {
+		l.advance() // move past .
+		for l.cursor < len(l.input) && isDigit(l.peek()) {
+			l.advance()
+		}
+		tokenType = FLOAT
 	}
+	value := l.input[start:l.cursor]
 
 	return Token{
 		Type:  tokenType,
@@ -150,54 +359,191 @@ func (l *Lexer) readNumber() Token {
 	}
 }
 
-func (l *Lexer) readString() Token {
-	startCol := l.column
-	l.advance() // Skip the opening quote
+// readParam reads a numbered bind parameter such as $1. A $ not followed by
+// a digit starts an identifier instead.
+func (l *Lexer) readParam() Token {
 	start := l.cursor
-
-	for l.cursor < len(l.input) && l.peek() != '\'' {
+	startCol := l.column
+	l.advance() // Skip the $
+	for l.cursor < len(l.input) && isDigit(l.peek()) {
 		l.advance()
 	}
+	return Token{Type: PARAM, Value: l.input[start:l.cursor], Line: l.Line, Col: startCol}
+}
 
-	value := l.input[start:l.cursor]
+// readQuoted reads a double-quoted identifier part and returns its text.
+// It reports false if the closing quote is missing.
+func (l *Lexer) readQuoted() (string, bool) {
+	l.advance() // Skip the opening quote
+	var b strings.Builder
+	for l.cursor < len(l.input) {
+		char := l.advance()
+		if char != '"' {
+			b.WriteByte(char)
+			continue
+		}
+		if l.peek() != '"' {
+			return b.String(), true
+		}
+		b.WriteByte(l.advance()) // "" is an escaped quote
+	}
+	return b.String(), false
+}
 
-	if l.cursor >= len(l.input) {
-		return Token{Type: ILLEGAL, Value: value, Line: l.Line, Col: startCol}
+// readString reads a string literal. A quote inside it is written as two
+// quotes. With escapes set, for E'...' strings, a backslash also starts an
+// escape sequence: \b, \f, \n, \r, \t, \xHH, \uXXXX or \UXXXXXXXX, or any
+// other character standing for itself.
+func (l *Lexer) readString(escapes bool) Token {
+	start := l.cursor
+	startLine := l.Line
+	startCol := l.column
+	if escapes {
+		l.advance() // Skip the E
 	}
+	l.advance() // Skip the opening quote
 
-	l.advance() // Skip the closing quote
+	var b strings.Builder
+	for l.cursor < len(l.input) {
+		char := l.advance()
+		switch {
+		case char == '\'':
+			if l.peek() != '\'' {
+				return Token{Type: STRING, Value: b.String(), Line: startLine, Col: startCol}
+			}
+			b.WriteByte(l.advance())
+		case char == '\\' && escapes:
+			if !l.readEscape(&b) {
+				problem := "Invalid escape sequence in string"
+				if l.cursor >= len(l.input) {
+					problem = "Unterminated string"
+				}
+				return Token{Type: ILLEGAL, Value: l.input[start:l.cursor], Line: startLine, Col: startCol, Problem: problem}
+			}
+		default:
+			b.WriteByte(char)
+		}
+	}
+	return Token{Type: ILLEGAL, Value: l.input[start:l.cursor], Line: startLine, Col: startCol, Problem: "Unterminated string"}
+}
 
-	return Token{
-		Type:  STRING,
-		Value: value,
-		Line:  l.Line,
-		Col:   startCol,
+// readEscape reads the escape sequence after a backslash in an E'...' string
+// and writes the character it stands for. It reports false for a malformed
+// hex or Unicode escape.
+func (l *Lexer) readEscape(b *strings.Builder) bool {
+	char := l.advance()
+	digits := 0
+	switch char {
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	case 0:
+		return false
+	default:
+		b.WriteByte(char)
+	}
+	if digits == 0 {
+		return true
+	}
+
+	n := 0
+	for ; n < digits && isHexDigit(l.peekAt(n)); n++ {
+	}
+	// \x takes one or two digits; \u and \U take exactly four or eight.
+	if n == 0 || char != 'x' && n < digits {
+		return false
+	}
+	code, err := strconv.ParseUint(l.input[l.cursor:l.cursor+n], 16, 32)
+	for i := 0; i < n; i++ {
+		l.advance()
+	}
+	if err != nil {
+		return false
+	}
+	if char == 'x' {
+		b.WriteByte(byte(code))
+		return true
 	}
+	if !utf8.ValidRune(rune(code)) {
+		return false
+	}
+	b.WriteRune(rune(code))
+	return true
 }
 
+// NextToken returns the next token of the input, or EOF_TOKEN at its end.
 func (l *Lexer) NextToken() Token {
 	l.skipWhitespace()
+	tok := l.readToken()
+	tok.EndLine, tok.EndCol = l.Line, l.column
+	return tok
+}
 
+// Input returns the source text being tokenized.
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+func (l *Lexer) readToken() Token {
 	if l.cursor >= len(l.input) {
 		return Token{Type: EOF_TOKEN, Value: "", Line: l.Line, Col: l.column}
 	}
 
 	char := l.peek()
 
-	if isAlpha(char) || char == '.' {
+	if char == '$' && l.cursor+1 < len(l.input) && isDigit(l.input[l.cursor+1]) {
+		return l.readParam()
+	}
+	if (char == 'E' || char == 'e') && l.peekAt(1) == '\'' {
+		return l.readString(true)
+	}
+	if isAlpha(char) || char == '.' || char == '"' {
 		return l.ReadIdentifier()
 	}
 	if isDigit(char) || char == '-' {
 		return l.readNumber()
 	}
 	if char == '\'' {
-		return l.readString()
+		return l.readString(false)
 	}
 
 	switch char {
+	case '?':
+		l.advance()
+		return Token{Type: PARAM, Value: "?", Line: l.Line, Col: l.column - 1}
 	case '*':
 		l.advance()
 		return Token{Type: ASTERISK, Value: "*", Line: l.Line, Col: l.column - 1}
+	case '+':
+		l.advance()
+		return Token{Type: PLUS, Value: "+", Line: l.Line, Col: l.column - 1}
+	case '/':
+		if l.peekAt(1) == '*' {
+			// skipWhitespace leaves only unterminated comments.
+			line, col, start := l.Line, l.column, l.cursor
+			for l.cursor < len(l.input) {
+				l.advance()
+			}
+			return Token{Type: ILLEGAL, Value: l.input[start:], Line: line, Col: col, Problem: "Unterminated comment"}
+		}
+		l.advance()
+		return Token{Type: SLASH, Value: "/", Line: l.Line, Col: l.column - 1}
+	case '%':
+		l.advance()
+		return Token{Type: PERCENT, Value: "%", Line: l.Line, Col: l.column - 1}
 	case ',':
 		l.advance()
 		return Token{Type: COMMA, Value: ",", Line: l.Line, Col: l.column - 1}
@@ -234,6 +580,13 @@ func (l *Lexer) NextToken() Token {
 	case ')':
 		l.advance()
 		return Token{Type: RPAREN, Value: ")", Line: l.Line, Col: l.column - 1}
+	case '|':
+		l.advance()
+		if l.peek() == '|' {
+			l.advance()
+			return Token{Type: CONCAT, Value: "||", Line: l.Line, Col: l.column - 2}
+		}
+		return Token{Type: ILLEGAL, Value: "|", Line: l.Line, Col: l.column - 1}
 	}
 
 	l.advance()
@@ -94,7 +94,7 @@ func TestReadIdentifierTable(t *testing.T) {
 		{"user1", IDENTIFIER, "user1"},
 		{"dev@test.com", IDENTIFIER, "dev@test.com"},
 		{"system.info", IDENTIFIER, "system.info"},
-		{"my-table", IDENTIFIER, "my-table"},
+		{"my-table", IDENTIFIER, "my"}, // - is the minus operator
 		{"$price", IDENTIFIER, "$price"},
 	}
 
@@ -126,6 +126,10 @@ func TestReadNumber(t *testing.T) {
 		{"0", NUMBER, "0"},
 		{"999", NUMBER, "999"},
 		{"-123", NUMBER, "-123"},
+		{"2.5", FLOAT, "2.5"},
+		{"-0.25", FLOAT, "-0.25"},
+		{"1.x", NUMBER, "1"},
+		{"1.", NUMBER, "1"},
 	}
 
 	for _, tt := range tests {
@@ -160,7 +164,27 @@ func TestNextToken(t *testing.T) {
 		{"123", NUMBER, "123"},
 		{"0", NUMBER, "0"},
 		{"999", NUMBER, "999"},
-		{"-", ILLEGAL, "-"},
+		{"1.5)", FLOAT, "1.5"},
+		{"-", MINUS, "-"},
+		{"- 1", MINUS, "-"},
+		{"+", PLUS, "+"},
+		{"/", SLASH, "/"},
+		{"%", PERCENT, "%"},
+		{"?", PARAM, "?"},
+		{"$1", PARAM, "$1"},
+		{"$12)", PARAM, "$12"},
+		{"$price", IDENTIFIER, "$price"},
+		{"PREPARE", PREPARE_TOKEN, "PREPARE"},
+		{"execute", EXECUTE_TOKEN, "execute"},
+		{"DEALLOCATE", DEALLOCATE_TOKEN, "DEALLOCATE"},
+		{"EXPLAIN", EXPLAIN_TOKEN, "EXPLAIN"},
+		{"analyze", ANALYZE_TOKEN, "analyze"},
+		{"SHOW", SHOW_TOKEN, "SHOW"},
+		{"describe", DESCRIBE_TOKEN, "describe"},
+		{"DATABASES", DATABASES_TOKEN, "DATABASES"},
+		{"Tables", TABLES_TOKEN, "Tables"},
+		{"INDEXES", INDEXES_TOKEN, "INDEXES"},
+		{"copy", IDENTIFIER, "copy"},
 	}
 
 	for _, tt := range tests {
@@ -180,3 +204,146 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestMinusAfterIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"qty-1", []Token{{Type: IDENTIFIER, Value: "qty"}, {Type: NUMBER, Value: "-1"}}},
+		{"x-0.5", []Token{{Type: IDENTIFIER, Value: "x"}, {Type: FLOAT, Value: "-0.5"}}},
+		{"a-b", []Token{{Type: IDENTIFIER, Value: "a"}, {Type: MINUS, Value: "-"}, {Type: IDENTIFIER, Value: "b"}}},
+		{"t.qty--1", []Token{{Type: IDENTIFIER, Value: "t.qty"}}},
+		{`"my-table"-x`, []Token{{Type: IDENTIFIER, Value: "my-table"}, {Type: MINUS, Value: "-"}, {Type: IDENTIFIER, Value: "x"}}},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		for _, want := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != want.Type || tok.Value != want.Value {
+				t.Errorf("input %q: expected %s %q, got %s %q", tt.input, want.Type, want.Value, tok.Type, tok.Value)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != EOF_TOKEN {
+			t.Errorf("input %q: expected EOF, got %s %q", tt.input, tok.Type, tok.Value)
+		}
+	}
+}
+
+func TestReadString(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType TokenType
+		expectedVal  string
+	}{
+		{"'abc'", STRING, "abc"},
+		{"''", STRING, ""},
+		{"'it''s'", STRING, "it's"},
+		{"''''", STRING, "'"},
+		{`'a\nb'`, STRING, `a\nb`},
+		{"'two\nlines'", STRING, "two\nlines"},
+		{`E'a\nb\tc'`, STRING, "a\nb\tc"},
+		{`e'\'quoted\' and ''doubled'''`, STRING, "'quoted' and 'doubled'"},
+		{`E'\\ \q \x41\x4a2 é \U0001F600'`, STRING, `\ q AJ2 é 😀`},
+		{"'open", ILLEGAL, "'open"},
+		{"'open''", ILLEGAL, "'open''"},
+		{`E'bad \u12'`, ILLEGAL, `E'bad \u`},
+		{`E'bad \x'`, ILLEGAL, `E'bad \x`},
+		{`E'end \`, ILLEGAL, `E'end \`},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("input %q: expected type %v, got %v", tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Value != tt.expectedVal {
+			t.Errorf("input %q: expected value %q, got %q", tt.input, tt.expectedVal, tok.Value)
+		}
+	}
+}
+
+func TestQuotedIdentifiers(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType TokenType
+		expectedVal  string
+	}{
+		{`"key"`, IDENTIFIER, "key"},
+		{`"SELECT"`, IDENTIFIER, "SELECT"},
+		{`"Mixed Case"`, IDENTIFIER, "Mixed Case"},
+		{`"say ""hi"""`, IDENTIFIER, `say "hi"`},
+		{`t."table"`, IDENTIFIER, "t.table"},
+		{`"t".col`, IDENTIFIER, "t.col"},
+		{`"t"."key" AS k`, IDENTIFIER, "t.key"},
+		{`"open`, ILLEGAL, `"open`},
+		{`""`, ILLEGAL, `""`},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("input %q: expected type %v, got %v", tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Value != tt.expectedVal {
+			t.Errorf("input %q: expected value %q, got %q", tt.input, tt.expectedVal, tok.Value)
+		}
+	}
+}
+
+func TestCommentsAndPositions(t *testing.T) {
+	input := "SELECT a, -- the first column\n" +
+		"  /* a block\n     comment /* nested */ */ b-- trailing\n" +
+		"FROM \"t\"\t/**/WHERE x = 'multi\nline' AND y = 'é' --end"
+	expected := []struct {
+		typ       TokenType
+		val       string
+		line, col int
+	}{
+		{SELECT_TOKEN, "SELECT", 1, 1},
+		{IDENTIFIER, "a", 1, 8},
+		{COMMA, ",", 1, 9},
+		{IDENTIFIER, "b", 3, 30},
+		{FROM_TOKEN, "FROM", 4, 1},
+		{IDENTIFIER, "t", 4, 6},
+		{WHERE_TOKEN, "WHERE", 4, 14},
+		{IDENTIFIER, "x", 4, 20},
+		{EQ, "=", 4, 22},
+		{STRING, "multi\nline", 4, 24},
+		{AND_TOKEN, "AND", 5, 7},
+		{IDENTIFIER, "y", 5, 11},
+		{EQ, "=", 5, 13},
+		{STRING, "é", 5, 15},
+		{EOF_TOKEN, "", 5, 24},
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want.typ || tok.Value != want.val {
+			t.Fatalf("token %d: expected %v %q, got %v %q", i, want.typ, want.val, tok.Type, tok.Value)
+		}
+		if tok.Line != want.line || tok.Col != want.col {
+			t.Errorf("token %d (%q): expected line %d, column %d, got line %d, column %d",
+				i, tok.Value, want.line, want.col, tok.Line, tok.Col)
+		}
+	}
+	if l.Comments != 5 {
+		t.Errorf("expected 5 comments, got %d", l.Comments)
+	}
+
+	for _, input := range []string{"/* open", "/* open /* nested */", "SELECT /* a */ 1 /*"} {
+		l := New(input)
+		tok := l.NextToken()
+		for tok.Type != EOF_TOKEN && tok.Type != ILLEGAL {
+			tok = l.NextToken()
+		}
+		if tok.Type != ILLEGAL {
+			t.Errorf("input %q: expected an unterminated comment to be ILLEGAL", input)
+		}
+	}
+}
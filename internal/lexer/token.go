@@ -29,13 +29,76 @@ const (
 	ON_TOKEN         TokenType = "ON"
 	REFERENCES_TOKEN TokenType = "REFERENCES"
 	FOREIGN_TOKEN    TokenType = "FOREIGN"
+	GROUP_TOKEN      TokenType = "GROUP"
+	BY_TOKEN         TokenType = "BY"
+	HAVING_TOKEN     TokenType = "HAVING"
+	DISTINCT_TOKEN   TokenType = "DISTINCT"
+	AND_TOKEN        TokenType = "AND"
+	OR_TOKEN         TokenType = "OR"
+	ORDER_TOKEN      TokenType = "ORDER"
+	ASC_TOKEN        TokenType = "ASC"
+	DESC_TOKEN       TokenType = "DESC"
+	CAST_TOKEN       TokenType = "CAST"
+	AS_TOKEN         TokenType = "AS"
+	INNER_TOKEN      TokenType = "INNER"
+	LEFT_TOKEN       TokenType = "LEFT"
+	RIGHT_TOKEN      TokenType = "RIGHT"
+	FULL_TOKEN       TokenType = "FULL"
+	OUTER_TOKEN      TokenType = "OUTER"
+	CROSS_TOKEN      TokenType = "CROSS"
+	IN_TOKEN         TokenType = "IN"
+	IS_TOKEN         TokenType = "IS"
+	EXISTS_TOKEN     TokenType = "EXISTS"
+	WITH_TOKEN       TokenType = "WITH"
+	RECURSIVE_TOKEN  TokenType = "RECURSIVE"
+	UNION_TOKEN      TokenType = "UNION"
+	ALL_TOKEN        TokenType = "ALL"
+	INTERSECT_TOKEN  TokenType = "INTERSECT"
+	EXCEPT_TOKEN     TokenType = "EXCEPT"
+	LIMIT_TOKEN      TokenType = "LIMIT"
+	OFFSET_TOKEN     TokenType = "OFFSET"
+	OVER_TOKEN       TokenType = "OVER"
+	PARTITION_TOKEN  TokenType = "PARTITION"
+	ROWS_TOKEN       TokenType = "ROWS"
+	RANGE_TOKEN      TokenType = "RANGE"
+	BETWEEN_TOKEN    TokenType = "BETWEEN"
+	UNBOUNDED_TOKEN  TokenType = "UNBOUNDED"
+	PRECEDING_TOKEN  TokenType = "PRECEDING"
+	FOLLOWING_TOKEN  TokenType = "FOLLOWING"
+	CURRENT_TOKEN    TokenType = "CURRENT"
+	ROW_TOKEN        TokenType = "ROW"
+	CASE_TOKEN       TokenType = "CASE"
+	WHEN_TOKEN       TokenType = "WHEN"
+	THEN_TOKEN       TokenType = "THEN"
+	ELSE_TOKEN       TokenType = "ELSE"
+	END_TOKEN        TokenType = "END"
+	CONFLICT_TOKEN   TokenType = "CONFLICT"
+	DO_TOKEN         TokenType = "DO"
+	NOTHING_TOKEN    TokenType = "NOTHING"
+	RETURNING_TOKEN  TokenType = "RETURNING"
+	USING_TOKEN      TokenType = "USING"
+	PREPARE_TOKEN    TokenType = "PREPARE"
+	EXECUTE_TOKEN    TokenType = "EXECUTE"
+	DEALLOCATE_TOKEN TokenType = "DEALLOCATE"
+	EXPLAIN_TOKEN    TokenType = "EXPLAIN"
+	ANALYZE_TOKEN    TokenType = "ANALYZE"
+	SHOW_TOKEN       TokenType = "SHOW"
+	DESCRIBE_TOKEN   TokenType = "DESCRIBE"
+	DATABASES_TOKEN  TokenType = "DATABASES"
+	TABLES_TOKEN     TokenType = "TABLES"
+	INDEXES_TOKEN    TokenType = "INDEXES"
 	EOF_TOKEN        TokenType = "EOF"
 	IDENTIFIER       TokenType = "IDENTIFIER"
 	NUMBER           TokenType = "NUMBER"
+	FLOAT            TokenType = "FLOAT" // a number with a fraction, e.g. 2.5
 	COMMA            TokenType = ","
 	SEMICOLON        TokenType = ";"
 	ILLEGAL          TokenType = "ILLEGAL"
 	ASTERISK         TokenType = "*"
+	PLUS             TokenType = "+"
+	MINUS            TokenType = "-"
+	SLASH            TokenType = "/"
+	PERCENT          TokenType = "%"
 	EQ               TokenType = "="
 	NOT_EQ           TokenType = "!="
 	GT               TokenType = ">"
@@ -44,7 +107,9 @@ const (
 	LTE              TokenType = "<="
 	LPAREN           TokenType = "("
 	RPAREN           TokenType = ")"
+	CONCAT           TokenType = "||"
 	STRING           TokenType = "STRING"
+	PARAM            TokenType = "PARAM" // a bind parameter, ? or $n
 )
 
 type Token struct {
@@ -52,4 +117,11 @@ type Token struct {
 	Value string
 	Line  int
 	Col   int
+	// EndLine and EndCol are the position just past the token's last
+	// character, set by NextToken.
+	EndLine int
+	EndCol  int
+	// Problem says what is wrong with an ILLEGAL token that is more than
+	// a stray character, such as "Unterminated string".
+	Problem string
 }
@@ -54,7 +54,7 @@ func TestIsIdentifierPart(t *testing.T) {
 		{'1', true},
 		{'_', true},
 		{'.', true},
-		{'-', true},
+		{'-', false},
 		{'@', true},
 		{'$', true},
 		{' ', false},
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// Position is a 1-based line and column in the parser's input.
+type Position struct {
+	Line int
+	Col  int
+}
+
+// Span covers the source text from Start up to, but not including, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// ParseError is one syntax error: its message, the source it points at, the
+// token that was found there and the token types that would have been
+// accepted instead (empty when any expression would do).
+type ParseError struct {
+	Message  string
+	Span     Span
+	Expected []lexer.TokenType
+	Found    lexer.Token
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// statementTokens are the keywords that can start a statement.
+var statementTokens = []lexer.TokenType{
+	lexer.SELECT_TOKEN, lexer.WITH_TOKEN, lexer.INSERT_TOKEN, lexer.UPDATE_TOKEN, lexer.DELETE_TOKEN,
+	lexer.CREATE_TOKEN, lexer.USE_TOKEN, lexer.PREPARE_TOKEN, lexer.EXECUTE_TOKEN, lexer.DEALLOCATE_TOKEN,
+	lexer.EXPLAIN_TOKEN, lexer.SHOW_TOKEN, lexer.DESCRIBE_TOKEN,
+}
+
+// errorAt records a syntax error pointing at tok. When tok is text the
+// lexer could not read, such as an unterminated string, that is the error
+// reported instead of msg, wherever the parser ran into it.
+func (p *Parser) errorAt(tok lexer.Token, msg string, expected ...lexer.TokenType) {
+	if tok.Type == lexer.ILLEGAL && tok.Problem != "" {
+		msg = fmt.Sprintf("%s starting at line %d, column %d", tok.Problem, tok.Line, tok.Col)
+	}
+	p.errors = append(p.errors, &ParseError{
+		Message:  msg,
+		Span:     spanOf(tok),
+		Expected: expected,
+		Found:    tok,
+	})
+}
+
+// spanOf returns the source covered by tok. Tokens without text (EOF) still
+// get one column so there is something to point at.
+func spanOf(tok lexer.Token) Span {
+	span := Span{
+		Start: Position{Line: tok.Line, Col: tok.Col},
+		End:   Position{Line: tok.EndLine, Col: tok.EndCol},
+	}
+	if span.End.Line < span.Start.Line || (span.End.Line == span.Start.Line && span.End.Col <= span.Start.Col) {
+		span.End = Position{Line: span.Start.Line, Col: span.Start.Col + 1}
+	}
+	return span
+}
+
+// tokenText describes tok for an error message: its text in quotes, or end
+// of input at EOF.
+func tokenText(tok lexer.Token) string {
+	if tok.Type == lexer.EOF_TOKEN {
+		return "end of input"
+	}
+	return "'" + tok.Value + "'"
+}
+
+// Errors returns the messages of all syntax errors found so far.
+func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Message
+	}
+	return msgs
+}
+
+// ParseErrors returns all syntax errors found so far.
+func (p *Parser) ParseErrors() []*ParseError {
+	return p.errors
+}
+
+// skipStatement recovers from an error by moving to the ';' that ends the
+// current statement, or to EOF.
+func (p *Parser) skipStatement() {
+	for p.currentToken.Type != lexer.SEMICOLON && p.currentToken.Type != lexer.EOF_TOKEN {
+		p.nextToken()
+	}
+}
+
+// Diagnostics renders every syntax error with the source line it occurred
+// on and a ^ marker under the offending text:
+//
+//	Parsing error: Expected FROM keyword at line 1, column 10, but got 'users'
+//	 1 | SELECT id users
+//	   |           ^^^^^
+func (p *Parser) Diagnostics() string {
+	lines := strings.Split(p.l.Input(), "\n")
+	var sb strings.Builder
+	for i, err := range p.errors {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString("Parsing error: " + err.Message)
+		start := err.Span.Start
+		if start.Line < 1 || start.Line > len(lines) {
+			continue
+		}
+		line := strings.TrimSuffix(lines[start.Line-1], "\r")
+		number := strconv.Itoa(start.Line)
+		gutter := strings.Repeat(" ", len(number)+1)
+		sb.WriteString("\n " + number + " | " + line + "\n" + gutter + " | ")
+		sb.WriteString(caretLine(line, err.Span))
+	}
+	return sb.String()
+}
+
+// caretLine returns the marker row for span under line. Tabs before the
+// marker are kept so it lines up however the line is displayed.
+func caretLine(line string, span Span) string {
+	runes := []rune(line)
+	var sb strings.Builder
+	col := 1
+	for ; col < span.Start.Col && col <= len(runes); col++ {
+		if runes[col-1] == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	for ; col < span.Start.Col; col++ {
+		sb.WriteByte(' ')
+	}
+
+	end := span.End.Col
+	if span.End.Line != span.Start.Line {
+		end = len(runes) + 1
+	}
+	sb.WriteString(strings.Repeat("^", max(end-span.Start.Col, 1)))
+	return sb.String()
+}
@@ -0,0 +1,528 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// infixOperators maps binary operator tokens to the operator text stored in
+// the AST.
+var infixOperators = map[lexer.TokenType]string{
+	lexer.EQ:        "=",
+	lexer.NOT_EQ:    "!=",
+	lexer.GT:        ">",
+	lexer.LT:        "<",
+	lexer.GTE:       ">=",
+	lexer.LTE:       "<=",
+	lexer.AND_TOKEN: "AND",
+	lexer.OR_TOKEN:  "OR",
+	lexer.CONCAT:    "||",
+	lexer.PLUS:      "+",
+	lexer.MINUS:     "-",
+	lexer.ASTERISK:  "*",
+	lexer.SLASH:     "/",
+	lexer.PERCENT:   "%",
+}
+
+func (p *Parser) peekPrecedence() int {
+	switch p.peekToken.Type {
+	case lexer.IN_TOKEN, lexer.NOT_TOKEN: // x [NOT] IN (...)
+		return ast.Precedence("IN")
+	case lexer.IS_TOKEN: // x IS [NOT] NULL
+		return ast.Precedence("IS")
+	case lexer.NUMBER, lexer.FLOAT: // x -1 is read as x - 1
+		if isNegativeNumber(p.peekToken) {
+			return ast.Precedence("-")
+		}
+	}
+	if op, ok := infixOperators[p.peekToken.Type]; ok {
+		return ast.Precedence(op)
+	}
+	return ast.LOWEST
+}
+
+// isNegativeNumber reports whether tok is a number with a leading minus.
+func isNegativeNumber(tok lexer.Token) bool {
+	return (tok.Type == lexer.NUMBER || tok.Type == lexer.FLOAT) && strings.HasPrefix(tok.Value, "-")
+}
+
+// startsExpression reports whether the current token can begin an expression.
+func (p *Parser) startsExpression() bool {
+	switch p.currentToken.Type {
+	case lexer.IDENTIFIER, lexer.NUMBER, lexer.FLOAT, lexer.STRING, lexer.NULL_TOKEN,
+		lexer.TRUE_TOKEN, lexer.FALSE_TOKEN, lexer.NOT_TOKEN, lexer.LPAREN, lexer.ASTERISK, lexer.CAST_TOKEN,
+		lexer.EXISTS_TOKEN, lexer.CASE_TOKEN, lexer.MINUS, lexer.PARAM:
+		return true
+	}
+	return false
+}
+
+// parseExpression parses an expression starting at the current token using
+// precedence climbing. On return the current token is the last token of the
+// expression.
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	left := p.parsePrefix()
+	if left == nil {
+		return nil
+	}
+
+	for p.peekToken.Type != lexer.SEMICOLON && precedence < p.peekPrecedence() {
+		p.nextToken() // move to operator
+		left = p.parseInfix(left)
+		if left == nil {
+			return nil
+		}
+	}
+	return left
+}
+
+func (p *Parser) parsePrefix() ast.Expression {
+	tok := p.currentToken
+	switch tok.Type {
+	case lexer.IDENTIFIER:
+		if p.peekToken.Type == lexer.LPAREN {
+			return p.parseFunctionCall()
+		}
+		return &ast.Identifier{Token: tok, Value: tok.Value}
+	case lexer.NUMBER:
+		v, err := strconv.ParseInt(tok.Value, 10, 64)
+		if err != nil {
+			p.errorAt(tok, fmt.Sprintf("Invalid number '%s' at line %d, column %d", tok.Value, tok.Line, tok.Col))
+			return nil
+		}
+		return &ast.IntegerLiteral{Token: tok, Value: v}
+	case lexer.FLOAT:
+		v, err := strconv.ParseFloat(tok.Value, 64)
+		if err != nil {
+			p.errorAt(tok, fmt.Sprintf("Invalid number '%s' at line %d, column %d", tok.Value, tok.Line, tok.Col))
+			return nil
+		}
+		return &ast.FloatLiteral{Token: tok, Value: v}
+	case lexer.STRING:
+		return &ast.StringLiteral{Token: tok, Value: tok.Value}
+	case lexer.NULL_TOKEN:
+		return &ast.NullLiteral{Token: tok}
+	case lexer.TRUE_TOKEN:
+		return &ast.BooleanLiteral{Token: tok, Value: true}
+	case lexer.FALSE_TOKEN:
+		return &ast.BooleanLiteral{Token: tok, Value: false}
+	case lexer.ASTERISK:
+		return &ast.Star{Token: tok}
+	case lexer.PARAM:
+		return p.parseParameter()
+	case lexer.NOT_TOKEN:
+		p.nextToken() // move to operand
+		right := p.parseExpression(ast.NOT_PREC)
+		if right == nil {
+			return nil
+		}
+		return &ast.PrefixExpression{Token: tok, Operator: "NOT", Right: right}
+	case lexer.MINUS:
+		p.nextToken() // move to operand
+		right := p.parseExpression(ast.PREFIX_PREC)
+		if right == nil {
+			return nil
+		}
+		return &ast.PrefixExpression{Token: tok, Operator: "-", Right: right}
+	case lexer.CAST_TOKEN:
+		return p.parseCast()
+	case lexer.CASE_TOKEN:
+		return p.parseCase()
+	case lexer.EXISTS_TOKEN:
+		if !p.expectPeek(lexer.LPAREN) {
+			return nil
+		}
+		sel := p.parseSubquery()
+		if sel == nil {
+			return nil
+		}
+		return &ast.ExistsExpression{Token: tok, Select: sel}
+	case lexer.LPAREN:
+		if p.peekToken.Type == lexer.SELECT_TOKEN {
+			sel := p.parseSubquery()
+			if sel == nil {
+				return nil
+			}
+			return &ast.SubqueryExpression{Token: tok, Select: sel}
+		}
+		p.nextToken() // move past (
+		expr := p.parseExpression(ast.LOWEST)
+		if expr == nil {
+			return nil
+		}
+		if !p.expectPeek(lexer.RPAREN) {
+			return nil
+		}
+		return expr
+	}
+
+	p.errorAt(tok, fmt.Sprintf("Expected expression at line %d, column %d, but got %s",
+		tok.Line, tok.Col, tokenText(tok)))
+	return nil
+}
+
+func (p *Parser) parseInfix(left ast.Expression) ast.Expression {
+	if p.currentToken.Type == lexer.IN_TOKEN || p.currentToken.Type == lexer.NOT_TOKEN {
+		return p.parseIn(left)
+	}
+	if p.currentToken.Type == lexer.IS_TOKEN {
+		return p.parseIsNull(left)
+	}
+	expr := &ast.InfixExpression{
+		Token:    p.currentToken,
+		Left:     left,
+		Operator: infixOperators[p.currentToken.Type],
+	}
+	if isNegativeNumber(p.currentToken) {
+		// The lexer reads "-1" as one number; after an operand it is a
+		// minus followed by the number.
+		tok := p.currentToken
+		expr.Token = lexer.Token{Type: lexer.MINUS, Value: "-", Line: tok.Line, Col: tok.Col}
+		expr.Operator = "-"
+		p.currentToken = lexer.Token{Type: tok.Type, Value: tok.Value[1:], Line: tok.Line, Col: tok.Col + 1}
+	} else {
+		p.nextToken() // move to right operand
+	}
+	precedence := ast.Precedence(expr.Operator)
+	expr.Right = p.parseExpression(precedence)
+	if expr.Right == nil {
+		return nil
+	}
+	return expr
+}
+
+// parseFunctionCall parses name([DISTINCT] arg, ...) [OVER (...)]. The
+// current token is the function name.
+func (p *Parser) parseFunctionCall() ast.Expression {
+	call := &ast.FunctionCall{Token: p.currentToken, Name: strings.ToUpper(p.currentToken.Value)}
+	p.nextToken() // move to (
+
+	if p.peekToken.Type == lexer.RPAREN {
+		p.nextToken() // move to )
+	} else {
+		if p.peekToken.Type == lexer.DISTINCT_TOKEN {
+			p.nextToken() // move to DISTINCT
+			call.Distinct = true
+		}
+		p.nextToken() // move to first argument
+		call.Args = p.parseExpressionList()
+		if call.Args == nil {
+			return nil
+		}
+		if !p.expectPeek(lexer.RPAREN) {
+			return nil
+		}
+	}
+
+	if p.peekToken.Type == lexer.OVER_TOKEN {
+		p.nextToken() // move to OVER
+		if call.Over = p.parseWindowSpec(); call.Over == nil {
+			return nil
+		}
+	}
+	return call
+}
+
+// parseWindowSpec parses ([PARTITION BY expr, ...] [ORDER BY ...] [frame]).
+// The current token is OVER; it ends on the closing parenthesis.
+func (p *Parser) parseWindowSpec() *ast.WindowSpec {
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+	spec := &ast.WindowSpec{}
+	if p.peekToken.Type == lexer.PARTITION_TOKEN {
+		p.nextToken() // move to PARTITION
+		if !p.expectPeek(lexer.BY_TOKEN) {
+			return nil
+		}
+		p.nextToken() // move to first key
+		if spec.PartitionBy = p.parseExpressionList(); spec.PartitionBy == nil {
+			return nil
+		}
+	}
+	if p.peekToken.Type == lexer.ORDER_TOKEN {
+		p.nextToken() // move to ORDER
+		if !p.expectPeek(lexer.BY_TOKEN) {
+			return nil
+		}
+		if spec.OrderBy = p.parseOrderBy(); spec.OrderBy == nil {
+			return nil
+		}
+	}
+	if p.peekToken.Type == lexer.ROWS_TOKEN || p.peekToken.Type == lexer.RANGE_TOKEN {
+		p.nextToken() // move to ROWS/RANGE
+		if spec.Frame = p.parseWindowFrame(); spec.Frame == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return spec
+}
+
+// parseWindowFrame parses ROWS|RANGE {bound | BETWEEN bound AND bound}. The
+// current token is ROWS or RANGE.
+func (p *Parser) parseWindowFrame() *ast.WindowFrame {
+	frame := &ast.WindowFrame{End: ast.FrameBound{Kind: ast.CurrentRow}}
+	if p.currentToken.Type == lexer.RANGE_TOKEN {
+		frame.Unit = ast.RangeFrame
+	}
+	between := p.peekToken.Type == lexer.BETWEEN_TOKEN
+	if between {
+		p.nextToken() // move to BETWEEN
+	}
+	start, ok := p.parseFrameBound()
+	if !ok {
+		return nil
+	}
+	frame.Start = start
+	if between {
+		if !p.expectPeek(lexer.AND_TOKEN) {
+			return nil
+		}
+		if frame.End, ok = p.parseFrameBound(); !ok {
+			return nil
+		}
+	}
+	return frame
+}
+
+// parseFrameBound parses UNBOUNDED PRECEDING|FOLLOWING, CURRENT ROW or
+// expr PRECEDING|FOLLOWING, starting at the token after the current one.
+func (p *Parser) parseFrameBound() (ast.FrameBound, bool) {
+	switch p.peekToken.Type {
+	case lexer.UNBOUNDED_TOKEN:
+		p.nextToken() // move to UNBOUNDED
+		switch p.peekToken.Type {
+		case lexer.PRECEDING_TOKEN:
+			p.nextToken()
+			return ast.FrameBound{Kind: ast.UnboundedPreceding}, true
+		case lexer.FOLLOWING_TOKEN:
+			p.nextToken()
+			return ast.FrameBound{Kind: ast.UnboundedFollowing}, true
+		}
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected PRECEDING or FOLLOWING after UNBOUNDED at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.PRECEDING_TOKEN, lexer.FOLLOWING_TOKEN)
+		return ast.FrameBound{}, false
+	case lexer.CURRENT_TOKEN:
+		p.nextToken() // move to CURRENT
+		if !p.expectPeek(lexer.ROW_TOKEN) {
+			return ast.FrameBound{}, false
+		}
+		return ast.FrameBound{Kind: ast.CurrentRow}, true
+	}
+
+	p.nextToken() // move to offset
+	// Parse above AND so that BETWEEN 1 PRECEDING AND ... stops at AND.
+	offset := p.parseExpression(ast.AND_PREC)
+	if offset == nil {
+		return ast.FrameBound{}, false
+	}
+	switch p.peekToken.Type {
+	case lexer.PRECEDING_TOKEN:
+		p.nextToken()
+		return ast.FrameBound{Kind: ast.OffsetPreceding, Offset: offset}, true
+	case lexer.FOLLOWING_TOKEN:
+		p.nextToken()
+		return ast.FrameBound{Kind: ast.OffsetFollowing, Offset: offset}, true
+	}
+	p.errorAt(p.peekToken, fmt.Sprintf("Expected PRECEDING or FOLLOWING after frame offset at line %d, column %d, but got %s",
+		p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.PRECEDING_TOKEN, lexer.FOLLOWING_TOKEN)
+	return ast.FrameBound{}, false
+}
+
+// parseCast parses CAST(expr AS type). The current token is CAST.
+func (p *Parser) parseCast() ast.Expression {
+	cast := &ast.CastExpression{Token: p.currentToken}
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+	p.nextToken() // move to expression
+	cast.Expr = p.parseExpression(ast.LOWEST)
+	if cast.Expr == nil || !p.expectPeek(lexer.AS_TOKEN) {
+		return nil
+	}
+	switch p.peekToken.Type {
+	case lexer.INT_TOKEN, lexer.TEXT_TOKEN, lexer.IDENTIFIER:
+		p.nextToken() // move to type name
+		cast.Type = strings.ToUpper(p.currentToken.Value)
+	default:
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected type name in CAST at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)))
+		return nil
+	}
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return cast
+}
+
+// parseCase parses CASE [operand] WHEN x THEN y ... [ELSE z] END. The
+// current token is CASE; it ends on END.
+func (p *Parser) parseCase() ast.Expression {
+	expr := &ast.CaseExpression{Token: p.currentToken}
+	if p.peekToken.Type != lexer.WHEN_TOKEN {
+		p.nextToken() // move to operand
+		if expr.Operand = p.parseExpression(ast.LOWEST); expr.Operand == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(lexer.WHEN_TOKEN) {
+		return nil
+	}
+	for {
+		p.nextToken() // move to condition
+		cond := p.parseExpression(ast.LOWEST)
+		if cond == nil || !p.expectPeek(lexer.THEN_TOKEN) {
+			return nil
+		}
+		p.nextToken() // move to result
+		result := p.parseExpression(ast.LOWEST)
+		if result == nil {
+			return nil
+		}
+		expr.Whens = append(expr.Whens, ast.WhenClause{Condition: cond, Result: result})
+		if p.peekToken.Type != lexer.WHEN_TOKEN {
+			break
+		}
+		p.nextToken() // move to WHEN
+	}
+	if p.peekToken.Type == lexer.ELSE_TOKEN {
+		p.nextToken() // move to ELSE
+		p.nextToken() // move to result
+		if expr.Else = p.parseExpression(ast.LOWEST); expr.Else == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(lexer.END_TOKEN) {
+		return nil
+	}
+	return expr
+}
+
+// parseExpressionList parses a comma-separated list of expressions, leaving
+// the current token on the last token of the final expression.
+func (p *Parser) parseExpressionList() []ast.Expression {
+	var list []ast.Expression
+	for {
+		expr := p.parseExpression(ast.LOWEST)
+		if expr == nil {
+			return nil
+		}
+		list = append(list, expr)
+
+		if p.peekToken.Type != lexer.COMMA {
+			return list
+		}
+		p.nextToken() // move to comma
+		p.nextToken() // move to next expression
+	}
+}
+
+// maxParams bounds the number of a $n bind parameter.
+const maxParams = 65535
+
+// parseParameter parses a bind parameter. A ? is numbered after the ? before
+// it; a statement may not mix the two styles.
+func (p *Parser) parseParameter() *ast.Parameter {
+	tok := p.currentToken
+	param := &ast.Parameter{Token: tok}
+	if tok.Value == "?" {
+		if p.params.numbered {
+			p.errorAt(tok, fmt.Sprintf("Cannot mix ? and $n parameters at line %d, column %d", tok.Line, tok.Col))
+			return nil
+		}
+		p.params.positional++
+		param.Index = p.params.positional
+	} else {
+		if p.params.positional > 0 {
+			p.errorAt(tok, fmt.Sprintf("Cannot mix ? and $n parameters at line %d, column %d", tok.Line, tok.Col))
+			return nil
+		}
+		n, err := strconv.Atoi(tok.Value[1:])
+		if err != nil || n < 1 || n > maxParams {
+			p.errorAt(tok, fmt.Sprintf("Invalid parameter '%s' at line %d, column %d", tok.Value, tok.Line, tok.Col))
+			return nil
+		}
+		p.params.numbered = true
+		param.Index = n
+	}
+	if p.params.count == 0 {
+		p.params.first = tok
+	}
+	p.params.count = max(p.params.count, param.Index)
+	return param
+}
+
+// expectPeek advances when the next token has the wanted type and records an
+// error otherwise.
+func (p *Parser) expectPeek(t lexer.TokenType) bool {
+	if p.peekToken.Type != t {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected %s at line %d, column %d, but got %s",
+			t, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), t)
+		return false
+	}
+	p.nextToken()
+	return true
+}
+
+// parseSubquery parses "(SELECT ...)" starting at the opening parenthesis
+// and leaves the closing one as the current token.
+func (p *Parser) parseSubquery() *ast.SelectStatement {
+	if !p.expectPeek(lexer.SELECT_TOKEN) {
+		return nil
+	}
+	sel := p.parseSelectStatement()
+	if sel == nil || !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return sel
+}
+
+// parseIn parses "[NOT] IN (SELECT ...)" or "[NOT] IN (expr, ...)" after
+// its left operand.
+func (p *Parser) parseIn(left ast.Expression) ast.Expression {
+	expr := &ast.InExpression{Token: p.currentToken, Left: left}
+	if p.currentToken.Type == lexer.NOT_TOKEN {
+		expr.Not = true
+		if !p.expectPeek(lexer.IN_TOKEN) {
+			return nil
+		}
+		expr.Token = p.currentToken
+	}
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+	if p.peekToken.Type == lexer.SELECT_TOKEN {
+		expr.Select = p.parseSubquery()
+		if expr.Select == nil {
+			return nil
+		}
+		return expr
+	}
+	p.nextToken() // move to first value
+	expr.List = p.parseExpressionList()
+	if expr.List == nil || !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return expr
+}
+
+// parseIsNull parses "IS [NOT] NULL" after its operand. The current token
+// is IS; it ends on NULL.
+func (p *Parser) parseIsNull(left ast.Expression) ast.Expression {
+	expr := &ast.IsNullExpression{Token: p.currentToken, Expr: left}
+	if p.peekToken.Type == lexer.NOT_TOKEN {
+		p.nextToken() // move to NOT
+		expr.Not = true
+	}
+	if !p.expectPeek(lexer.NULL_TOKEN) {
+		return nil
+	}
+	return expr
+}
@@ -13,13 +13,24 @@ type Parser struct {
 	l            *lexer.Lexer
 	currentToken lexer.Token
 	peekToken    lexer.Token
-	errors       []string
+	errors       []*ParseError
+	// params tracks the bind parameters of the statement being parsed.
+	params paramState
+}
+
+// paramState records the bind parameters seen in a statement. A statement
+// numbers them either all by position (?) or all explicitly ($n).
+type paramState struct {
+	first      lexer.Token // the first parameter, for error messages
+	count      int         // the highest parameter number
+	positional int         // the number of ? parameters
+	numbered   bool
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 	// Read two tokens to fill currentToken and peekToken
 	p.nextToken()
@@ -32,20 +43,26 @@ func (p *Parser) nextToken() {
 	p.peekToken = p.l.NextToken()
 }
 
-func (p *Parser) Errors() []string {
-	return p.errors
-}
-
-func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, msg)
-}
-
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{Statements: []ast.Statement{}}
 
 	for p.currentToken.Type != lexer.EOF_TOKEN {
+		p.params = paramState{}
+		errs := len(p.errors)
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if _, prepare := stmt.(*ast.PrepareStatement); len(p.errors) == errs && !prepare && p.params.count > 0 {
+			p.errorAt(p.params.first, fmt.Sprintf("Bind parameter '%s' at line %d, column %d is only allowed in PREPARE",
+				p.params.first.Value, p.params.first.Line, p.params.first.Col))
+		}
+		if len(p.errors) == errs && stmt != nil && p.peekToken.Type != lexer.SEMICOLON && p.peekToken.Type != lexer.EOF_TOKEN {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected ; or end of input after the statement at line %d, column %d, but got %s",
+				p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.SEMICOLON, lexer.EOF_TOKEN)
+		}
+		// A statement with errors is dropped and parsing resumes after the
+		// next ';', so one mistake does not hide the rest of the script.
+		if len(p.errors) > errs {
+			p.skipStatement()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -54,9 +71,16 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	// COPY is not reserved, so tables and columns can still be named copy;
+	// it is only a keyword at the start of a statement.
+	if p.currentToken.Type == lexer.IDENTIFIER && strings.EqualFold(p.currentToken.Value, "COPY") {
+		return p.parseCopyStatement()
+	}
 	switch p.currentToken.Type {
-	case lexer.SELECT_TOKEN:
-		return p.parseSelectStatement()
+	case lexer.SELECT_TOKEN, lexer.LPAREN:
+		return p.parseQuery()
+	case lexer.WITH_TOKEN:
+		return p.parseWithQuery()
 	case lexer.INSERT_TOKEN:
 		return p.parseInsertStatement()
 	case lexer.UPDATE_TOKEN:
@@ -67,8 +91,20 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseCreateStatement()
 	case lexer.USE_TOKEN:
 		return p.parseUseStatement()
+	case lexer.PREPARE_TOKEN:
+		return p.parsePrepareStatement()
+	case lexer.EXECUTE_TOKEN:
+		return p.parseExecuteStatement()
+	case lexer.DEALLOCATE_TOKEN:
+		return p.parseDeallocateStatement()
+	case lexer.EXPLAIN_TOKEN:
+		return p.parseExplainStatement()
+	case lexer.SHOW_TOKEN:
+		return p.parseShowStatement()
+	case lexer.DESCRIBE_TOKEN:
+		return p.parseDescribeStatement()
 	case lexer.ILLEGAL:
-		p.addError(fmt.Sprintf("Illegal character '%s' at line %d, column %d",
+		p.errorAt(p.currentToken, fmt.Sprintf("Illegal character '%s' at line %d, column %d",
 			p.currentToken.Value, p.currentToken.Line, p.currentToken.Col))
 		return nil
 	case lexer.SEMICOLON:
@@ -76,8 +112,8 @@ func (p *Parser) parseStatement() ast.Statement {
 	case lexer.EOF_TOKEN:
 		return nil
 	default:
-		p.addError(fmt.Sprintf("Unexpected token '%s' at line %d, column %d. Expected a statement (e.g., SELECT)",
-			p.currentToken.Value, p.currentToken.Line, p.currentToken.Col))
+		p.errorAt(p.currentToken, fmt.Sprintf("Unexpected token '%s' at line %d, column %d. Expected a statement (e.g., SELECT)",
+			p.currentToken.Value, p.currentToken.Line, p.currentToken.Col), statementTokens...)
 		return nil
 	}
 }
@@ -87,23 +123,39 @@ func (p *Parser) parseSelectStatement() *ast.SelectStatement {
 
 	p.nextToken()
 
-	// Check for columns or asterisk
-	switch p.currentToken.Type {
-	case lexer.ASTERISK:
-		stmt.Columns = []string{"*"}
+	// Parse optional DISTINCT or DISTINCT ON (expr, ...)
+	if p.currentToken.Type == lexer.DISTINCT_TOKEN {
+		stmt.Distinct = true
+		if p.peekToken.Type == lexer.ON_TOKEN {
+			p.nextToken() // move to ON
+			if !p.expectPeek(lexer.LPAREN) {
+				return nil
+			}
+			p.nextToken() // move to first expression
+			stmt.DistinctOn = p.parseExpressionList()
+			if stmt.DistinctOn == nil || !p.expectPeek(lexer.RPAREN) {
+				return nil
+			}
+		}
 		p.nextToken()
-	case lexer.IDENTIFIER:
-		stmt.Columns = p.parseColumns()
-	default:
-		p.addError(fmt.Sprintf("Expected column name or '*' after SELECT at line %d, column %d, but got '%s'",
-			p.currentToken.Line, p.currentToken.Col, p.currentToken.Value))
+	}
+
+	// Check for columns or asterisk
+	if !p.startsExpression() {
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected column name or '*' after SELECT at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)))
 		return nil
 	}
+	stmt.Columns = p.parseColumns()
+	if stmt.Columns == nil {
+		return nil
+	}
+	p.nextToken()
 
 	// Expect FROM keyword
 	if p.currentToken.Type != lexer.FROM_TOKEN {
-		p.addError(fmt.Sprintf("Expected FROM keyword at line %d, column %d, but got '%s'",
-			p.currentToken.Line, p.currentToken.Col, p.currentToken.Value))
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected FROM keyword at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.FROM_TOKEN)
 		return nil
 	}
 
@@ -111,76 +163,333 @@ func (p *Parser) parseSelectStatement() *ast.SelectStatement {
 
 	// Expect table name
 	if p.currentToken.Type != lexer.IDENTIFIER {
-		p.addError(fmt.Sprintf("Expected table name after FROM at line %d, column %d, but got '%s'",
-			p.currentToken.Line, p.currentToken.Col, p.currentToken.Value))
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected table name after FROM at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.IDENTIFIER)
 		return nil
 	}
 
 	stmt.Table = p.currentToken.Value
+	alias, ok := p.parseAlias()
+	if !ok {
+		return nil
+	}
+	stmt.Alias = alias
+
+	// Parse any chained joins: [INNER|LEFT|RIGHT|FULL [OUTER]|CROSS] JOIN
+	// table [alias] [ON condition], or a comma for a cross join.
+	for p.startsJoin() {
+		p.nextToken() // move to the first token of the join
+		join := p.parseJoinClause()
+		if join == nil {
+			return nil
+		}
+		stmt.Joins = append(stmt.Joins, join)
+	}
 
-	// Parse optional JOIN clause: JOIN table ON left_col = right_col
-	if p.peekToken.Type == lexer.JOIN_TOKEN {
-		p.nextToken() // move to JOIN
-		if p.peekToken.Type != lexer.IDENTIFIER {
-			p.addError(fmt.Sprintf("Expected table name after JOIN at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+	if p.peekToken.Type == lexer.WHERE_TOKEN {
+		p.nextToken() // move to WHERE
+		stmt.Where = p.parseWhereClause()
+		if stmt.Where == nil {
 			return nil
 		}
-		p.nextToken() // move to join table name
-		joinTable := p.currentToken.Value
+	}
 
-		if p.peekToken.Type != lexer.ON_TOKEN {
-			p.addError(fmt.Sprintf("Expected ON after JOIN table name at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+	// Parse optional GROUP BY expr, ...
+	if p.peekToken.Type == lexer.GROUP_TOKEN {
+		p.nextToken() // move to GROUP
+		if p.peekToken.Type != lexer.BY_TOKEN {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected BY after GROUP at line %d, column %d, but got %s",
+				p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.BY_TOKEN)
+			return nil
+		}
+		p.nextToken() // move to BY
+		p.nextToken() // move to first expression
+		stmt.GroupBy = p.parseExpressionList()
+		if stmt.GroupBy == nil {
 			return nil
 		}
-		p.nextToken() // move to ON
+	}
 
-		if p.peekToken.Type != lexer.IDENTIFIER {
-			p.addError(fmt.Sprintf("Expected left column in ON clause at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+	// Parse optional HAVING condition
+	if p.peekToken.Type == lexer.HAVING_TOKEN {
+		p.nextToken() // move to HAVING
+		p.nextToken() // move to condition
+		stmt.Having = p.parseExpression(ast.LOWEST)
+		if stmt.Having == nil {
 			return nil
 		}
-		p.nextToken() // move to left column
-		leftKey := p.currentToken.Value
+	}
 
-		if p.peekToken.Type != lexer.EQ {
-			p.addError(fmt.Sprintf("Expected = in ON clause at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+	if !p.parseOrderAndLimit(&stmt.OrderBy, &stmt.Limit, &stmt.Offset) {
+		return nil
+	}
+	return stmt
+}
+
+// parseOrderAndLimit parses optional ORDER BY expr [ASC|DESC], ... and
+// LIMIT and OFFSET clauses into orderBy, limit and offset.
+func (p *Parser) parseOrderAndLimit(orderBy *[]ast.OrderByItem, limit, offset *ast.Expression) bool {
+	if p.peekToken.Type == lexer.ORDER_TOKEN {
+		p.nextToken() // move to ORDER
+		if p.peekToken.Type != lexer.BY_TOKEN {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected BY after ORDER at line %d, column %d, but got %s",
+				p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.BY_TOKEN)
+			return false
+		}
+		p.nextToken() // move to BY
+		if *orderBy = p.parseOrderBy(); *orderBy == nil {
+			return false
+		}
+	}
+	return p.parseLimit(limit, offset)
+}
+
+// parseLimit parses optional LIMIT count and OFFSET skip clauses, in either
+// order, into limit and offset.
+func (p *Parser) parseLimit(limit, offset *ast.Expression) bool {
+	for p.peekToken.Type == lexer.LIMIT_TOKEN || p.peekToken.Type == lexer.OFFSET_TOKEN {
+		p.nextToken() // move to LIMIT/OFFSET
+		target := limit
+		if p.currentToken.Type == lexer.OFFSET_TOKEN {
+			target = offset
+		}
+		if *target != nil {
+			p.errorAt(p.currentToken, fmt.Sprintf("Duplicate %s clause at line %d, column %d",
+				p.currentToken.Value, p.currentToken.Line, p.currentToken.Col))
+			return false
+		}
+		p.nextToken() // move to expression
+		if *target = p.parseExpression(ast.LOWEST); *target == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// setOperatorPrecedences ranks the set operators; INTERSECT binds tighter
+// than UNION and EXCEPT.
+var setOperatorPrecedences = map[lexer.TokenType]int{
+	lexer.UNION_TOKEN:     1,
+	lexer.EXCEPT_TOKEN:    1,
+	lexer.INTERSECT_TOKEN: 2,
+}
+
+// parseQuery parses a query: SELECTs or parenthesised queries combined by
+// any UNION, INTERSECT or EXCEPT. ORDER BY, LIMIT and OFFSET after the last
+// SELECT of a set operation are moved to it, since they apply to the
+// combined result; after a closing parenthesis they are read here.
+func (p *Parser) parseQuery() ast.Query {
+	q, tail := p.parseSetOperations(1)
+	if q == nil {
+		return nil
+	}
+	if tail != nil {
+		if so, ok := q.(*ast.SetOperation); ok {
+			so.OrderBy, so.Limit, so.Offset = tail.OrderBy, tail.Limit, tail.Offset
+			tail.OrderBy, tail.Limit, tail.Offset = nil, nil, nil
+		}
+		return q
+	}
+
+	var orderBy *[]ast.OrderByItem
+	var limit, offset *ast.Expression
+	switch n := q.(type) {
+	case *ast.SelectStatement:
+		orderBy, limit, offset = &n.OrderBy, &n.Limit, &n.Offset
+	case *ast.SetOperation:
+		orderBy, limit, offset = &n.OrderBy, &n.Limit, &n.Offset
+	}
+	switch p.peekToken.Type {
+	case lexer.ORDER_TOKEN, lexer.LIMIT_TOKEN, lexer.OFFSET_TOKEN:
+		// The query in parentheses is complete, so its clauses are not
+		// merged with these, which would change what they apply to.
+		if len(*orderBy) > 0 || *limit != nil || *offset != nil {
+			p.errorAt(p.peekToken, fmt.Sprintf("%s after a parenthesised query with ORDER BY, LIMIT or OFFSET at line %d, column %d",
+				p.peekToken.Value, p.peekToken.Line, p.peekToken.Col))
 			return nil
 		}
-		p.nextToken() // move to =
+	}
+	if !p.parseOrderAndLimit(orderBy, limit, offset) {
+		return nil
+	}
+	return q
+}
 
-		if p.peekToken.Type != lexer.IDENTIFIER {
-			p.addError(fmt.Sprintf("Expected right column in ON clause at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+// parseSetOperations parses a query operand followed by set operators of
+// at least minPrec precedence, grouping left to right. tail is the last
+// operand when it is a SELECT without parentheses, whose ORDER BY, LIMIT
+// and OFFSET belong to the whole query.
+func (p *Parser) parseSetOperations(minPrec int) (q ast.Query, tail *ast.SelectStatement) {
+	left, tail := p.parseSetOperand()
+	if left == nil {
+		return nil, nil
+	}
+	for {
+		prec, ok := setOperatorPrecedences[p.peekToken.Type]
+		if !ok || prec < minPrec {
+			return left, tail
+		}
+		if tail != nil && (len(tail.OrderBy) > 0 || tail.Limit != nil || tail.Offset != nil) {
+			p.errorAt(p.peekToken, fmt.Sprintf("ORDER BY, LIMIT and OFFSET must follow the last query of a %s at line %d, column %d",
+				p.peekToken.Value, p.peekToken.Line, p.peekToken.Col))
+			return nil, nil
+		}
+		p.nextToken() // move to the operator
+		op := &ast.SetOperation{Token: p.currentToken, Left: left}
+		switch p.currentToken.Type {
+		case lexer.INTERSECT_TOKEN:
+			op.Op = ast.Intersect
+		case lexer.EXCEPT_TOKEN:
+			op.Op = ast.Except
+		}
+		if p.peekToken.Type == lexer.ALL_TOKEN {
+			p.nextToken() // move to ALL
+			op.All = true
+		}
+		if p.peekToken.Type == lexer.LPAREN {
+			p.nextToken() // move to (
+		} else if !p.expectPeek(lexer.SELECT_TOKEN) {
+			return nil, nil
+		}
+		if op.Right, tail = p.parseSetOperations(prec + 1); op.Right == nil {
+			return nil, nil
+		}
+		left = op
+	}
+}
+
+// parseSetOperand parses a SELECT, or a query in parentheses, which may
+// have its own ORDER BY, LIMIT and OFFSET. tail is the SELECT when it has
+// no parentheses.
+func (p *Parser) parseSetOperand() (q ast.Query, tail *ast.SelectStatement) {
+	if p.currentToken.Type != lexer.LPAREN {
+		sel := p.parseSelectStatement()
+		if sel == nil {
+			return nil, nil
+		}
+		return sel, sel
+	}
+	p.nextToken() // move past (
+	switch p.currentToken.Type {
+	case lexer.SELECT_TOKEN, lexer.LPAREN:
+		q = p.parseQuery()
+	case lexer.WITH_TOKEN:
+		q = p.parseWithQuery()
+	default:
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected a query after ( at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.SELECT_TOKEN, lexer.WITH_TOKEN)
+		return nil, nil
+	}
+	if q == nil || !p.expectPeek(lexer.RPAREN) {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// parseWithQuery parses a query preceded by a WITH clause:
+// WITH [RECURSIVE] name [(col, ...)] AS (query), ... SELECT ...
+func (p *Parser) parseWithQuery() ast.Query {
+	with := &ast.WithClause{Token: p.currentToken}
+	if p.peekToken.Type == lexer.RECURSIVE_TOKEN {
+		p.nextToken() // move to RECURSIVE
+		with.Recursive = true
+	}
+	for {
+		cte := p.parseCommonTableExpression()
+		if cte == nil {
 			return nil
 		}
-		p.nextToken() // move to right column
-		rightKey := p.currentToken.Value
+		with.CTEs = append(with.CTEs, cte)
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // move to comma
+	}
 
-		stmt.Join = &ast.JoinClause{
-			Table:    joinTable,
-			LeftKey:  leftKey,
-			RightKey: rightKey,
+	if !p.expectPeek(lexer.SELECT_TOKEN) {
+		return nil
+	}
+	q := p.parseQuery()
+	switch n := q.(type) {
+	case *ast.SelectStatement:
+		n.With = with
+	case *ast.SetOperation:
+		n.With = with
+	}
+	return q
+}
+
+// parseCommonTableExpression parses one entry of a WITH list, leaving its
+// closing parenthesis as the current token.
+func (p *Parser) parseCommonTableExpression() *ast.CommonTableExpression {
+	if p.peekToken.Type != lexer.IDENTIFIER {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected a name for the WITH query at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
+		return nil
+	}
+	p.nextToken() // move to name
+	cte := &ast.CommonTableExpression{Token: p.currentToken, Name: p.currentToken.Value}
+
+	if p.peekToken.Type == lexer.LPAREN {
+		p.nextToken() // move to (
+		p.nextToken() // move to first column
+		cte.Columns = p.parseCommaSeparatedList(lexer.RPAREN)
+		if cte.Columns == nil {
+			return nil
 		}
 	}
 
-	if p.peekToken.Type == lexer.WHERE_TOKEN {
-		p.nextToken() // move to WHERE
-		p.nextToken() // move to identifier
-		stmt.Where = p.parseWhereClause()
+	if !p.expectPeek(lexer.AS_TOKEN) || !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+	if p.peekToken.Type == lexer.LPAREN {
+		p.nextToken() // move to the ( of the first operand
+	} else if !p.expectPeek(lexer.SELECT_TOKEN) {
+		return nil
+	}
+	if cte.Query = p.parseQuery(); cte.Query == nil {
+		return nil
+	}
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
 	}
+	return cte
+}
 
-	return stmt
+// parseOrderBy parses the keys after ORDER BY. The current token is BY.
+func (p *Parser) parseOrderBy() []ast.OrderByItem {
+	var items []ast.OrderByItem
+	for {
+		p.nextToken() // move to expression
+		expr := p.parseExpression(ast.LOWEST)
+		if expr == nil {
+			return nil
+		}
+		item := ast.OrderByItem{Expr: expr}
+		if p.peekToken.Type == lexer.ASC_TOKEN || p.peekToken.Type == lexer.DESC_TOKEN {
+			p.nextToken() // move to ASC/DESC
+			item.Desc = p.currentToken.Type == lexer.DESC_TOKEN
+		}
+		items = append(items, item)
+
+		if p.peekToken.Type != lexer.COMMA {
+			return items
+		}
+		p.nextToken() // move to comma
+	}
 }
 
 func (p *Parser) parseInsertStatement() *ast.InsertStatement {
 	stmt := &ast.InsertStatement{Token: p.currentToken}
 
 	if p.peekToken.Type != lexer.INTO_TOKEN {
-		p.addError("Expected INTO after INSERT")
+		p.errorAt(p.peekToken, "Expected INTO after INSERT", lexer.INTO_TOKEN)
 		return nil
 	}
 	p.nextToken() // Move to INTO
 
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError("Expected table name after INTO")
+		p.errorAt(p.peekToken, "Expected table name after INTO", lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken() // Move to table name
@@ -192,60 +501,285 @@ func (p *Parser) parseInsertStatement() *ast.InsertStatement {
 		stmt.Columns = p.parseCommaSeparatedList(lexer.RPAREN)
 	}
 
+	switch p.peekToken.Type {
+	case lexer.SELECT_TOKEN:
+		p.nextToken() // Move to SELECT
+		if stmt.Query = p.parseQuery(); stmt.Query == nil {
+			return nil
+		}
+		return p.parseInsertSuffix(stmt)
+	case lexer.WITH_TOKEN:
+		p.nextToken() // Move to WITH
+		if stmt.Query = p.parseWithQuery(); stmt.Query == nil {
+			return nil
+		}
+		return p.parseInsertSuffix(stmt)
+	}
+
 	if p.peekToken.Type != lexer.VALUES_TOKEN {
-		p.addError("Expected VALUES keyword")
+		p.errorAt(p.peekToken, "Expected VALUES keyword", lexer.VALUES_TOKEN)
 		return nil
 	}
 	p.nextToken() // Move to VALUES
 
-	if p.peekToken.Type != lexer.LPAREN {
-		p.addError("Expected ( after VALUES")
-		return nil
+	var params [][]int
+	var nulls [][]bool
+	hasParams, hasNulls := false, false
+	for {
+		if p.peekToken.Type != lexer.LPAREN {
+			p.errorAt(p.peekToken, "Expected ( after VALUES", lexer.LPAREN)
+			return nil
+		}
+		p.nextToken() // Move to (
+		p.nextToken() // Move to first val
+		row, rowParams, rowNulls := p.parseValuesRow()
+		if row == nil {
+			return nil
+		}
+		stmt.Rows = append(stmt.Rows, row)
+		params = append(params, rowParams)
+		nulls = append(nulls, rowNulls)
+		hasParams = hasParams || rowParams != nil
+		hasNulls = hasNulls || rowNulls != nil
+
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // Move to comma
 	}
-	p.nextToken() // Move to (
-	p.nextToken() // Move to first val
-	stmt.Values = p.parseCommaSeparatedList(lexer.RPAREN)
+	if hasParams {
+		stmt.Params = params
+	}
+	if hasNulls {
+		stmt.Nulls = nulls
+	}
+	return p.parseInsertSuffix(stmt)
+}
 
+// parseValuesRow parses one row of a VALUES list, starting at its first
+// value and ending on the closing parenthesis. A bind parameter is stored
+// as its $n text and marked in params by its number, and the NULL keyword
+// is stored as NULL and marked in nulls; params and nulls are nil when the
+// row has no such value.
+func (p *Parser) parseValuesRow() (row []string, params []int, nulls []bool) {
+	numbers := []int{}
+	isNull := []bool{}
+	hasParams, hasNulls := false, false
+	for {
+		switch p.currentToken.Type {
+		case lexer.IDENTIFIER, lexer.NUMBER, lexer.FLOAT, lexer.STRING:
+			row = append(row, p.currentToken.Value)
+			numbers = append(numbers, 0)
+			isNull = append(isNull, false)
+		case lexer.NULL_TOKEN:
+			row = append(row, "NULL")
+			numbers = append(numbers, 0)
+			isNull = append(isNull, true)
+			hasNulls = true
+		case lexer.PARAM:
+			param := p.parseParameter()
+			if param == nil {
+				return nil, nil, nil
+			}
+			row = append(row, param.String())
+			numbers = append(numbers, param.Index)
+			isNull = append(isNull, false)
+			hasParams = true
+		default:
+			p.errorAt(p.currentToken, fmt.Sprintf("Expected identifier, number, string or NULL at line %d, column %d, but got %s", p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.IDENTIFIER, lexer.NUMBER, lexer.STRING, lexer.NULL_TOKEN)
+			return nil, nil, nil
+		}
+
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // Move to comma
+		p.nextToken() // Move to next value
+	}
+
+	if p.peekToken.Type != lexer.RPAREN {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected %s at line %d, column %d, but got %s", lexer.RPAREN, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.RPAREN)
+		return nil, nil, nil
+	}
+	p.nextToken() // Move to )
+	if hasParams {
+		params = numbers
+	}
+	if hasNulls {
+		nulls = isNull
+	}
+	return row, params, nulls
+}
+
+// parseInsertSuffix parses the optional ON CONFLICT and RETURNING clauses
+// ending an INSERT.
+func (p *Parser) parseInsertSuffix(stmt *ast.InsertStatement) *ast.InsertStatement {
+	if p.peekToken.Type == lexer.ON_TOKEN {
+		p.nextToken() // Move to ON
+		if stmt.OnConflict = p.parseOnConflict(); stmt.OnConflict == nil {
+			return nil
+		}
+	}
+	var ok bool
+	if stmt.Returning, ok = p.parseReturning(); !ok {
+		return nil
+	}
 	return stmt
 }
 
+// parseOnConflict parses an ON CONFLICT clause. The current token is ON.
+func (p *Parser) parseOnConflict() *ast.OnConflictClause {
+	clause := &ast.OnConflictClause{Token: p.currentToken}
+	if !p.expectPeek(lexer.CONFLICT_TOKEN) {
+		return nil
+	}
+
+	if p.peekToken.Type == lexer.LPAREN {
+		p.nextToken() // Move to (
+		p.nextToken() // Move to first col
+		if clause.Columns = p.parseCommaSeparatedList(lexer.RPAREN); clause.Columns == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(lexer.DO_TOKEN) {
+		return nil
+	}
+	switch p.peekToken.Type {
+	case lexer.NOTHING_TOKEN:
+		p.nextToken() // Move to NOTHING
+	case lexer.UPDATE_TOKEN:
+		p.nextToken() // Move to UPDATE
+		if !p.expectPeek(lexer.SET_TOKEN) {
+			return nil
+		}
+		clause.DoUpdate = true
+		if clause.Sets = p.parseSetList(); clause.Sets == nil {
+			return nil
+		}
+	default:
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected NOTHING or UPDATE after DO at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.NOTHING_TOKEN, lexer.UPDATE_TOKEN)
+		return nil
+	}
+	return clause
+}
+
 func (p *Parser) parseUpdateStatement() *ast.UpdateStatement {
 	stmt := &ast.UpdateStatement{Token: p.currentToken}
 
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError("Expected table name after UPDATE")
+		p.errorAt(p.peekToken, "Expected table name after UPDATE", lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken()
 	stmt.Table = p.currentToken.Value
 
 	if p.peekToken.Type != lexer.SET_TOKEN {
-		p.addError("Expected SET keyword")
+		p.errorAt(p.peekToken, "Expected SET keyword", lexer.SET_TOKEN)
 		return nil
 	}
 	p.nextToken()
 
-	stmt.Sets = make(map[string]string)
+	if stmt.Sets = p.parseSetList(); stmt.Sets == nil {
+		return nil
+	}
+
+	if p.peekToken.Type == lexer.FROM_TOKEN {
+		p.nextToken() // Move to FROM
+		if stmt.From = p.parseFromClause(); stmt.From == nil {
+			return nil
+		}
+	}
+
+	if p.peekToken.Type == lexer.WHERE_TOKEN {
+		p.nextToken()
+		stmt.Where = p.parseWhereClause()
+		if stmt.Where == nil {
+			return nil
+		}
+	}
+
+	var ok bool
+	if stmt.Returning, ok = p.parseReturning(); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// parseReturning parses an optional RETURNING list ending a data
+// modification statement.
+func (p *Parser) parseReturning() ([]ast.Expression, bool) {
+	if p.peekToken.Type != lexer.RETURNING_TOKEN {
+		return nil, true
+	}
+	p.nextToken() // Move to RETURNING
+	p.nextToken() // Move to first column
+	if !p.startsExpression() {
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected column name or '*' after RETURNING at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)))
+		return nil, false
+	}
+	columns := p.parseColumns()
+	return columns, columns != nil
+}
+
+// parseFromClause parses the table list after UPDATE ... FROM or DELETE
+// ... USING: a table and any joins. The current token is FROM or USING.
+func (p *Parser) parseFromClause() *ast.FromClause {
+	from := &ast.FromClause{Token: p.currentToken}
+	if p.peekToken.Type != lexer.IDENTIFIER {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected table name after %s at line %d, column %d, but got %s",
+			from.Token.Value, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
+		return nil
+	}
+	p.nextToken() // Move to table name
+	from.Table = p.currentToken.Value
+	alias, ok := p.parseAlias()
+	if !ok {
+		return nil
+	}
+	from.Alias = alias
+
+	for p.startsJoin() {
+		p.nextToken() // Move to the first token of the join
+		join := p.parseJoinClause()
+		if join == nil {
+			return nil
+		}
+		from.Joins = append(from.Joins, join)
+	}
+	return from
+}
+
+// parseSetList parses the col = value assignments after SET. The current
+// token is SET.
+func (p *Parser) parseSetList() []ast.SetClause {
+	var sets []ast.SetClause
 	for {
 		p.nextToken() // Move to col
 		if p.currentToken.Type != lexer.IDENTIFIER {
-			p.addError("Expected column name in SET")
+			p.errorAt(p.currentToken, "Expected column name in SET", lexer.IDENTIFIER)
 			return nil
 		}
 		col := p.currentToken.Value
 
 		if p.peekToken.Type != lexer.EQ {
-			p.addError("Expected = in SET")
+			p.errorAt(p.peekToken, "Expected = in SET", lexer.EQ)
 			return nil
 		}
 		p.nextToken()
 		p.nextToken() // Move to val
 
-		if p.currentToken.Type != lexer.IDENTIFIER && p.currentToken.Type != lexer.NUMBER && p.currentToken.Type != lexer.STRING {
-			p.addError("Expected value in SET")
+		if !p.startsExpression() {
+			p.errorAt(p.currentToken, "Expected value in SET")
 			return nil
 		}
-		stmt.Sets[col] = p.currentToken.Value
+		value := p.parseExpression(ast.LOWEST)
+		if value == nil {
+			return nil
+		}
+		sets = append(sets, ast.SetClause{Column: col, Value: value})
 
 		if p.peekToken.Type == lexer.COMMA {
 			p.nextToken() // Move to comma
@@ -253,38 +787,44 @@ func (p *Parser) parseUpdateStatement() *ast.UpdateStatement {
 			break
 		}
 	}
-
-	if p.peekToken.Type == lexer.WHERE_TOKEN {
-		p.nextToken()
-		p.nextToken()
-		stmt.Where = p.parseWhereClause()
-	}
-
-	return stmt
+	return sets
 }
 
 func (p *Parser) parseDeleteStatement() *ast.DeleteStatement {
 	stmt := &ast.DeleteStatement{Token: p.currentToken}
 
 	if p.peekToken.Type != lexer.FROM_TOKEN {
-		p.addError("Expected FROM after DELETE")
+		p.errorAt(p.peekToken, "Expected FROM after DELETE", lexer.FROM_TOKEN)
 		return nil
 	}
 	p.nextToken()
 
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError("Expected table name after FROM")
+		p.errorAt(p.peekToken, "Expected table name after FROM", lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken()
 	stmt.Table = p.currentToken.Value
 
+	if p.peekToken.Type == lexer.USING_TOKEN {
+		p.nextToken() // Move to USING
+		if stmt.Using = p.parseFromClause(); stmt.Using == nil {
+			return nil
+		}
+	}
+
 	if p.peekToken.Type == lexer.WHERE_TOKEN {
-		p.nextToken()
 		p.nextToken()
 		stmt.Where = p.parseWhereClause()
+		if stmt.Where == nil {
+			return nil
+		}
 	}
 
+	var ok bool
+	if stmt.Returning, ok = p.parseReturning(); !ok {
+		return nil
+	}
 	return stmt
 }
 
@@ -294,8 +834,8 @@ func (p *Parser) parseCreateStatement() ast.Statement {
 	} else if p.peekToken.Type == lexer.TABLE_TOKEN {
 		return p.parseCreateTableStatement()
 	} else {
-		p.addError(fmt.Sprintf("Expected DATABASE or TABLE after CREATE at line %d, column %d, but got '%s'",
-			p.peekToken.Line, p.peekToken.Col, p.peekToken.Value))
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected DATABASE or TABLE after CREATE at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.DATABASE_TOKEN, lexer.TABLE_TOKEN)
 		return nil
 	}
 }
@@ -305,8 +845,8 @@ func (p *Parser) parseCreateDatabaseStatement() *ast.CreateDatabaseStatement {
 
 	p.nextToken() // Move to DATABASE
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError(fmt.Sprintf("Expected database name after CREATE DATABASE at line %d, column %d, but got '%s'",
-			p.peekToken.Line, p.peekToken.Col, p.peekToken.Value))
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected database name after CREATE DATABASE at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken() // Move to database name
@@ -318,8 +858,8 @@ func (p *Parser) parseUseStatement() *ast.UseDatabaseStatement {
 	stmt := &ast.UseDatabaseStatement{Token: p.currentToken}
 
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError(fmt.Sprintf("Expected database name after USE at line %d, column %d, but got '%s'",
-			p.peekToken.Line, p.peekToken.Col, p.peekToken.Value))
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected database name after USE at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken() // Move to database name
@@ -327,26 +867,291 @@ func (p *Parser) parseUseStatement() *ast.UseDatabaseStatement {
 	return stmt
 }
 
+// parsePrepareStatement parses PREPARE name AS statement, where the
+// statement is a query, INSERT, UPDATE or DELETE.
+func (p *Parser) parsePrepareStatement() *ast.PrepareStatement {
+	stmt := &ast.PrepareStatement{Token: p.currentToken}
+	if !p.expectPeek(lexer.IDENTIFIER) {
+		return nil
+	}
+	stmt.Name = p.currentToken.Value
+	if !p.expectPeek(lexer.AS_TOKEN) {
+		return nil
+	}
+	p.nextToken() // Move to the statement
+
+	switch p.currentToken.Type {
+	case lexer.SELECT_TOKEN, lexer.LPAREN, lexer.WITH_TOKEN, lexer.INSERT_TOKEN, lexer.UPDATE_TOKEN, lexer.DELETE_TOKEN:
+	default:
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected SELECT, INSERT, UPDATE or DELETE after AS at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), statementTokens[:5]...)
+		return nil
+	}
+	errs := len(p.errors)
+	stmt.Statement = p.parseStatement()
+	if len(p.errors) > errs {
+		return nil
+	}
+	stmt.Params = p.params.count
+	return stmt
+}
+
+// parseExplainStatement parses EXPLAIN [ANALYZE] followed by a query,
+// INSERT, UPDATE or DELETE.
+func (p *Parser) parseExplainStatement() *ast.ExplainStatement {
+	stmt := &ast.ExplainStatement{Token: p.currentToken}
+	if p.peekToken.Type == lexer.ANALYZE_TOKEN {
+		p.nextToken() // Move to ANALYZE
+		stmt.Analyze = true
+	}
+	p.nextToken() // Move to the statement
+
+	switch p.currentToken.Type {
+	case lexer.SELECT_TOKEN, lexer.LPAREN, lexer.WITH_TOKEN, lexer.INSERT_TOKEN, lexer.UPDATE_TOKEN, lexer.DELETE_TOKEN:
+	default:
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected SELECT, INSERT, UPDATE or DELETE after EXPLAIN at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), statementTokens[:5]...)
+		return nil
+	}
+	errs := len(p.errors)
+	stmt.Statement = p.parseStatement()
+	if len(p.errors) > errs {
+		return nil
+	}
+	return stmt
+}
+
+// parseShowStatement parses SHOW DATABASES, SHOW TABLES, SHOW CREATE TABLE
+// name and SHOW INDEXES [FROM name].
+func (p *Parser) parseShowStatement() *ast.ShowStatement {
+	stmt := &ast.ShowStatement{Token: p.currentToken}
+	switch p.peekToken.Type {
+	case lexer.DATABASES_TOKEN:
+		stmt.Kind = ast.ShowDatabases
+		p.nextToken() // Move to DATABASES
+	case lexer.TABLES_TOKEN:
+		stmt.Kind = ast.ShowTables
+		p.nextToken() // Move to TABLES
+	case lexer.CREATE_TOKEN:
+		stmt.Kind = ast.ShowCreateTable
+		p.nextToken() // Move to CREATE
+		if !p.expectPeek(lexer.TABLE_TOKEN) || !p.expectPeek(lexer.IDENTIFIER) {
+			return nil
+		}
+		stmt.Table = p.currentToken.Value
+	case lexer.INDEXES_TOKEN:
+		stmt.Kind = ast.ShowIndexes
+		p.nextToken() // Move to INDEXES
+		if p.peekToken.Type == lexer.FROM_TOKEN {
+			p.nextToken() // Move to FROM
+			if !p.expectPeek(lexer.IDENTIFIER) {
+				return nil
+			}
+			stmt.Table = p.currentToken.Value
+		}
+	default:
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected DATABASES, TABLES, CREATE TABLE or INDEXES after SHOW at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)),
+			lexer.DATABASES_TOKEN, lexer.TABLES_TOKEN, lexer.CREATE_TOKEN, lexer.INDEXES_TOKEN)
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseDescribeStatement() *ast.ShowStatement {
+	stmt := &ast.ShowStatement{Token: p.currentToken, Kind: ast.ShowColumns}
+	if p.peekToken.Type != lexer.IDENTIFIER {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected table name after DESCRIBE at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
+		return nil
+	}
+	p.nextToken() // Move to table name
+	stmt.Table = p.currentToken.Value
+	return stmt
+}
+
+// parseCopyStatement parses COPY table [(cols)] FROM 'file', and COPY
+// table [(cols)] TO 'file' or COPY (query) TO 'file', each followed by an
+// optional WITH (option, ...) list. TO is not a keyword either.
+func (p *Parser) parseCopyStatement() *ast.CopyStatement {
+	stmt := &ast.CopyStatement{Token: p.currentToken}
+	switch p.peekToken.Type {
+	case lexer.LPAREN:
+		p.nextToken() // Move to (
+		p.nextToken() // Move to the query
+		switch p.currentToken.Type {
+		case lexer.SELECT_TOKEN:
+			stmt.Query = p.parseQuery()
+		case lexer.WITH_TOKEN:
+			stmt.Query = p.parseWithQuery()
+		default:
+			p.errorAt(p.currentToken, fmt.Sprintf("Expected a query after COPY ( at line %d, column %d, but got %s",
+				p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.SELECT_TOKEN, lexer.WITH_TOKEN)
+			return nil
+		}
+		if stmt.Query == nil || !p.expectPeek(lexer.RPAREN) {
+			return nil
+		}
+	case lexer.IDENTIFIER:
+		p.nextToken() // Move to table name
+		stmt.Table = p.currentToken.Value
+		if p.peekToken.Type == lexer.LPAREN {
+			p.nextToken() // Move to (
+			p.nextToken() // Move to first col
+			if stmt.Columns = p.parseCommaSeparatedList(lexer.RPAREN); stmt.Columns == nil {
+				return nil
+			}
+		}
+	default:
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected table name or (query) after COPY at line %d, column %d, but got %s",
+			p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER, lexer.LPAREN)
+		return nil
+	}
+
+	switch {
+	case p.peekToken.Type == lexer.IDENTIFIER && strings.EqualFold(p.peekToken.Value, "TO"):
+		stmt.To = true
+	case p.peekToken.Type == lexer.FROM_TOKEN && stmt.Query == nil:
+	default:
+		expected := "FROM or TO"
+		if stmt.Query != nil {
+			expected = "TO"
+		}
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected %s at line %d, column %d, but got %s",
+			expected, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.FROM_TOKEN, lexer.IDENTIFIER)
+		return nil
+	}
+	direction := strings.ToUpper(p.peekToken.Value)
+	p.nextToken() // Move to FROM or TO
+	if p.peekToken.Type != lexer.STRING {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected a file name string after %s at line %d, column %d, but got %s",
+			direction, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.STRING)
+		return nil
+	}
+	p.nextToken() // Move to the file name
+	stmt.File = p.currentToken.Value
+
+	if p.peekToken.Type != lexer.WITH_TOKEN {
+		return stmt
+	}
+	p.nextToken() // Move to WITH
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+	for {
+		p.nextToken() // Move to the option name
+		opt, ok := p.parseCopyOption()
+		if !ok {
+			return nil
+		}
+		stmt.Options = append(stmt.Options, opt)
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // Move to comma
+	}
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return stmt
+}
+
+// parseCopyOption parses one COPY option: FORMAT csv, jsonl or columnar,
+// HEADER [TRUE | FALSE], DELIMITER 'c' or NULL 'text'. The option names
+// are not keywords, so they can still name columns.
+func (p *Parser) parseCopyOption() (ast.CopyOption, bool) {
+	name := strings.ToUpper(p.currentToken.Value)
+	if p.currentToken.Type != lexer.IDENTIFIER && p.currentToken.Type != lexer.NULL_TOKEN {
+		name = ""
+	}
+	opt := ast.CopyOption{Name: name}
+	switch name {
+	case "FORMAT":
+		format := strings.ToLower(p.peekToken.Value)
+		if p.peekToken.Type != lexer.IDENTIFIER || format != "csv" && format != "jsonl" && format != "columnar" {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected csv, jsonl or columnar after FORMAT at line %d, column %d, but got %s",
+				p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
+			return opt, false
+		}
+		p.nextToken() // Move to the format
+		opt.Value = format
+	case "HEADER":
+		opt.Value = "true"
+		switch p.peekToken.Type {
+		case lexer.TRUE_TOKEN:
+			p.nextToken() // Move to TRUE
+		case lexer.FALSE_TOKEN:
+			p.nextToken() // Move to FALSE
+			opt.Value = "false"
+		}
+	case "DELIMITER", "NULL":
+		if p.peekToken.Type != lexer.STRING {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected a string after %s at line %d, column %d, but got %s",
+				name, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.STRING)
+			return opt, false
+		}
+		p.nextToken() // Move to the string
+		opt.Value = p.currentToken.Value
+	default:
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected a COPY option (FORMAT, HEADER, DELIMITER or NULL) at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.IDENTIFIER, lexer.NULL_TOKEN)
+		return opt, false
+	}
+	return opt, true
+}
+
+// parseExecuteStatement parses EXECUTE name, optionally followed by a
+// parenthesised list of argument expressions.
+func (p *Parser) parseExecuteStatement() *ast.ExecuteStatement {
+	stmt := &ast.ExecuteStatement{Token: p.currentToken}
+	if !p.expectPeek(lexer.IDENTIFIER) {
+		return nil
+	}
+	stmt.Name = p.currentToken.Value
+	if p.peekToken.Type != lexer.LPAREN {
+		return stmt
+	}
+	p.nextToken() // Move to (
+	if p.peekToken.Type == lexer.RPAREN {
+		p.nextToken() // Move to )
+		return stmt
+	}
+	p.nextToken() // Move to the first argument
+	if stmt.Args = p.parseExpressionList(); stmt.Args == nil || !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseDeallocateStatement() *ast.DeallocateStatement {
+	stmt := &ast.DeallocateStatement{Token: p.currentToken}
+	if !p.expectPeek(lexer.IDENTIFIER) {
+		return nil
+	}
+	stmt.Name = p.currentToken.Value
+	return stmt
+}
+
 func (p *Parser) parseCreateTableStatement() *ast.CreateTableStatement {
 	stmt := &ast.CreateTableStatement{Token: p.currentToken}
 
 	p.nextToken() // Move to TABLE
 
 	if p.currentToken.Type != lexer.TABLE_TOKEN {
-		p.addError(fmt.Sprintf("Expected TABLE after CREATE at line %d, column %d, but got '%s'",
-			p.currentToken.Line, p.currentToken.Col, p.currentToken.Value))
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected TABLE after CREATE at line %d, column %d, but got %s",
+			p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.TABLE_TOKEN)
 		return nil
 	}
 
 	if p.peekToken.Type != lexer.IDENTIFIER {
-		p.addError("Expected table name after CREATE TABLE")
+		p.errorAt(p.peekToken, "Expected table name after CREATE TABLE", lexer.IDENTIFIER)
 		return nil
 	}
 	p.nextToken() // Move to table name
 	stmt.Table = p.currentToken.Value
 
 	if p.peekToken.Type != lexer.LPAREN {
-		p.addError("Expected ( after table name")
+		p.errorAt(p.peekToken, "Expected ( after table name", lexer.LPAREN)
 		return nil
 	}
 	p.nextToken() // Move to (
@@ -365,7 +1170,7 @@ func (p *Parser) parseCreateTableStatement() *ast.CreateTableStatement {
 			p.nextToken() // Move to )
 			break
 		} else {
-			p.addError(fmt.Sprintf("Expected , or ) in table definition, got %s", p.peekToken.Value))
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected , or ) in table definition at line %d, column %d, but got %s", p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.COMMA, lexer.RPAREN)
 			return nil
 		}
 	}
@@ -375,30 +1180,30 @@ func (p *Parser) parseCreateTableStatement() *ast.CreateTableStatement {
 
 func (p *Parser) parseColumnDefinition() ast.ColumnDefinition {
 	if p.currentToken.Type != lexer.IDENTIFIER {
-		p.addError("Expected column name")
+		p.errorAt(p.currentToken, "Expected column name", lexer.IDENTIFIER)
 		return ast.ColumnDefinition{}
 	}
 	col := ast.ColumnDefinition{Name: p.currentToken.Value, IsNullable: true}
 
 	if p.peekToken.Type != lexer.INT_TOKEN && p.peekToken.Type != lexer.TEXT_TOKEN {
-		p.addError(fmt.Sprintf("Expected data type for column %s, got %s", col.Name, p.peekToken.Value))
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected data type for column %s at line %d, column %d, but got %s", col.Name, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.INT_TOKEN, lexer.TEXT_TOKEN)
 		return ast.ColumnDefinition{}
 	}
 	p.nextToken()
-	col.DataType = p.currentToken.Value
+	col.DataType = strings.ToUpper(p.currentToken.Value)
 
 	// Handle optional (size) e.g., TEXT(255)
 	if p.peekToken.Type == lexer.LPAREN {
 		p.nextToken() // Move to (
 		if p.peekToken.Type != lexer.NUMBER {
-			p.addError("Expected number for size")
+			p.errorAt(p.peekToken, "Expected number for size", lexer.NUMBER)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // Move to number
 		size, _ := strconv.Atoi(p.currentToken.Value)
 		col.Size = size
 		if p.peekToken.Type != lexer.RPAREN {
-			p.addError("Expected ) after size")
+			p.errorAt(p.peekToken, "Expected ) after size", lexer.RPAREN)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // Move to )
@@ -410,7 +1215,7 @@ func (p *Parser) parseColumnDefinition() ast.ColumnDefinition {
 		switch p.currentToken.Type {
 		case lexer.NOT_TOKEN:
 			if p.peekToken.Type != lexer.NULL_TOKEN {
-				p.addError("Expected NULL after NOT")
+				p.errorAt(p.peekToken, "Expected NULL after NOT", lexer.NULL_TOKEN)
 				return ast.ColumnDefinition{}
 			}
 			p.nextToken()
@@ -419,7 +1224,7 @@ func (p *Parser) parseColumnDefinition() ast.ColumnDefinition {
 			col.IsUnique = true
 		case lexer.PRIMARY_TOKEN:
 			if p.peekToken.Type != lexer.KEY_TOKEN {
-				p.addError("Expected KEY after PRIMARY")
+				p.errorAt(p.peekToken, "Expected KEY after PRIMARY", lexer.KEY_TOKEN)
 				return ast.ColumnDefinition{}
 			}
 			p.nextToken()
@@ -433,27 +1238,27 @@ func (p *Parser) parseColumnDefinition() ast.ColumnDefinition {
 	if p.peekToken.Type == lexer.REFERENCES_TOKEN {
 		p.nextToken() // move to REFERENCES
 		if p.peekToken.Type != lexer.IDENTIFIER {
-			p.addError("Expected table name after REFERENCES")
+			p.errorAt(p.peekToken, "Expected table name after REFERENCES", lexer.IDENTIFIER)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // move to parent table name
 		refTable := p.currentToken.Value
 
 		if p.peekToken.Type != lexer.LPAREN {
-			p.addError("Expected ( after table name in REFERENCES")
+			p.errorAt(p.peekToken, "Expected ( after table name in REFERENCES", lexer.LPAREN)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // move to (
 
 		if p.peekToken.Type != lexer.IDENTIFIER {
-			p.addError("Expected column name in REFERENCES")
+			p.errorAt(p.peekToken, "Expected column name in REFERENCES", lexer.IDENTIFIER)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // move to parent column name
 		refCol := p.currentToken.Value
 
 		if p.peekToken.Type != lexer.RPAREN {
-			p.addError("Expected ) after column name in REFERENCES")
+			p.errorAt(p.peekToken, "Expected ) after column name in REFERENCES", lexer.RPAREN)
 			return ast.ColumnDefinition{}
 		}
 		p.nextToken() // move to )
@@ -464,28 +1269,20 @@ func (p *Parser) parseColumnDefinition() ast.ColumnDefinition {
 	return col
 }
 
+// parseWhereClause parses the condition after WHERE. The current token is
+// WHERE.
 func (p *Parser) parseWhereClause() *ast.WhereClause {
 	where := &ast.WhereClause{Token: p.currentToken}
 
-	if p.currentToken.Type != lexer.IDENTIFIER {
-		p.addError(fmt.Sprintf("Expected column name in WHERE clause, got %s", p.currentToken.Value))
-		return nil
-	}
-	where.Left = p.currentToken.Value
-
-	p.nextToken()
-	if !isComparisonOperator(p.currentToken.Type) {
-		p.addError(fmt.Sprintf("Expected comparison operator in WHERE clause, got %s", p.currentToken.Value))
+	p.nextToken() // move to condition
+	if !p.startsExpression() {
+		p.errorAt(p.currentToken, fmt.Sprintf("Expected condition in WHERE clause at line %d, column %d, but got %s", p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)))
 		return nil
 	}
-	where.Op = p.currentToken.Value
-
-	p.nextToken()
-	if p.currentToken.Type != lexer.IDENTIFIER && p.currentToken.Type != lexer.NUMBER && p.currentToken.Type != lexer.STRING {
-		p.addError(fmt.Sprintf("Expected value in WHERE clause, got %s", p.currentToken.Value))
+	where.Condition = p.parseExpression(ast.LOWEST)
+	if where.Condition == nil {
 		return nil
 	}
-	where.Right = p.currentToken.Value
 
 	return where
 }
@@ -497,7 +1294,7 @@ func (p *Parser) parseCommaSeparatedList(endToken lexer.TokenType) []string {
 		if p.currentToken.Type == lexer.IDENTIFIER || p.currentToken.Type == lexer.NUMBER || p.currentToken.Type == lexer.STRING {
 			list = append(list, p.currentToken.Value)
 		} else {
-			p.addError(fmt.Sprintf("Expected identifier, number, or string, got %s", p.currentToken.Value))
+			p.errorAt(p.currentToken, fmt.Sprintf("Expected identifier, number, or string at line %d, column %d, but got %s", p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.IDENTIFIER, lexer.NUMBER, lexer.STRING)
 			return nil
 		}
 
@@ -510,7 +1307,7 @@ func (p *Parser) parseCommaSeparatedList(endToken lexer.TokenType) []string {
 	}
 
 	if p.peekToken.Type != endToken {
-		p.addError(fmt.Sprintf("Expected %s, got %s", endToken, p.peekToken.Value))
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected %s at line %d, column %d, but got %s", endToken, p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), endToken)
 		return nil
 	}
 	p.nextToken() // Move to end token
@@ -518,46 +1315,148 @@ func (p *Parser) parseCommaSeparatedList(endToken lexer.TokenType) []string {
 	return list
 }
 
-func isComparisonOperator(t lexer.TokenType) bool {
-	switch t {
-	case lexer.EQ, lexer.NOT_EQ, lexer.GT, lexer.LT, lexer.GTE, lexer.LTE:
+// startsJoin reports whether the next token begins another FROM item.
+func (p *Parser) startsJoin() bool {
+	switch p.peekToken.Type {
+	case lexer.JOIN_TOKEN, lexer.INNER_TOKEN, lexer.LEFT_TOKEN, lexer.RIGHT_TOKEN,
+		lexer.FULL_TOKEN, lexer.CROSS_TOKEN, lexer.COMMA:
 		return true
-	default:
-		return false
 	}
+	return false
 }
 
-func (p *Parser) parseColumns() []string {
-	var columns []string
+// parseJoinClause parses one join, starting at its first token.
+func (p *Parser) parseJoinClause() *ast.JoinClause {
+	join := &ast.JoinClause{Token: p.currentToken, Kind: ast.InnerJoin}
 
-	if p.currentToken.Type == lexer.IDENTIFIER {
-		columns = append(columns, p.currentToken.Value)
+	switch p.currentToken.Type {
+	case lexer.COMMA, lexer.CROSS_TOKEN:
+		join.Kind = ast.CrossJoin
+	case lexer.LEFT_TOKEN:
+		join.Kind = ast.LeftJoin
+	case lexer.RIGHT_TOKEN:
+		join.Kind = ast.RightJoin
+	case lexer.FULL_TOKEN:
+		join.Kind = ast.FullJoin
+	}
+	if join.Kind != ast.InnerJoin && join.Kind != ast.CrossJoin && p.peekToken.Type == lexer.OUTER_TOKEN {
+		p.nextToken() // move to OUTER
+	}
+	if p.currentToken.Type != lexer.COMMA && p.currentToken.Type != lexer.JOIN_TOKEN && !p.expectPeek(lexer.JOIN_TOKEN) {
+		return nil
 	}
 
-	for p.peekToken.Type == lexer.COMMA {
-		p.nextToken() // Move to comma
-		p.nextToken() // Move to next identifier
+	if p.peekToken.Type != lexer.IDENTIFIER {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected table name after JOIN at line %d, column %d", p.peekToken.Line, p.peekToken.Col), lexer.IDENTIFIER)
+		return nil
+	}
+	p.nextToken() // move to join table name
+	join.Table = p.currentToken.Value
+	alias, ok := p.parseAlias()
+	if !ok {
+		return nil
+	}
+	join.Alias = alias
 
-		if p.currentToken.Type != lexer.IDENTIFIER {
-			p.addError(fmt.Sprintf("Expected column name after comma at line %d, column %d, but got '%s'",
-				p.currentToken.Line, p.currentToken.Col, p.currentToken.Value))
+	if join.Kind == ast.CrossJoin {
+		return join
+	}
+	if p.peekToken.Type != lexer.ON_TOKEN {
+		p.errorAt(p.peekToken, fmt.Sprintf("Expected ON after JOIN table name at line %d, column %d", p.peekToken.Line, p.peekToken.Col), lexer.ON_TOKEN)
+		return nil
+	}
+	p.nextToken() // move to ON
+	p.nextToken() // move to the condition
+	join.On = p.parseExpression(ast.LOWEST)
+	if join.On == nil {
+		return nil
+	}
+	return join
+}
+
+// parseAlias parses an optional "[AS] name" following the current token and
+// returns the alias, or "" when there is none. It reports false on a syntax
+// error.
+func (p *Parser) parseAlias() (string, bool) {
+	if p.peekToken.Type == lexer.AS_TOKEN {
+		p.nextToken() // move to AS
+		if p.peekToken.Type != lexer.IDENTIFIER {
+			p.errorAt(p.peekToken, fmt.Sprintf("Expected alias after AS at line %d, column %d, but got %s",
+				p.peekToken.Line, p.peekToken.Col, tokenText(p.peekToken)), lexer.IDENTIFIER)
+			return "", false
+		}
+	}
+	if p.peekToken.Type != lexer.IDENTIFIER {
+		return "", true
+	}
+	p.nextToken() // move to alias
+	if strings.Contains(p.currentToken.Value, ".") {
+		p.errorAt(p.currentToken, fmt.Sprintf("Invalid alias '%s' at line %d, column %d",
+			p.currentToken.Value, p.currentToken.Line, p.currentToken.Col))
+		return "", false
+	}
+	return p.currentToken.Value, true
+}
+
+// parseColumns parses a select list, leaving its last token as the current
+// token.
+func (p *Parser) parseColumns() []ast.Expression {
+	var columns []ast.Expression
+
+	for {
+		col := p.parseExpression(ast.LOWEST)
+		if col == nil {
+			return nil
+		}
+		if p.peekToken.Type == lexer.AS_TOKEN || p.peekToken.Type == lexer.IDENTIFIER {
+			if _, star := col.(*ast.Star); star {
+				p.errorAt(p.peekToken, fmt.Sprintf("Cannot alias '*' at line %d, column %d", p.peekToken.Line, p.peekToken.Col))
+				return nil
+			}
+			tok := p.peekToken
+			alias, ok := p.parseAlias()
+			if !ok {
+				return nil
+			}
+			col = &ast.AliasExpression{Token: tok, Expr: col, Alias: alias}
+		}
+		columns = append(columns, col)
+
+		if p.peekToken.Type != lexer.COMMA {
 			break
 		}
+		p.nextToken() // Move to comma
+		p.nextToken() // Move to next column
 
-		columns = append(columns, p.currentToken.Value)
+		if !p.startsExpression() {
+			p.errorAt(p.currentToken, fmt.Sprintf("Expected column name after comma at line %d, column %d, but got %s",
+				p.currentToken.Line, p.currentToken.Col, tokenText(p.currentToken)), lexer.IDENTIFIER)
+			return nil
+		}
 	}
-
-	p.nextToken()
 	return columns
 }
 
+// formatReturning formats the RETURNING line of a statement's AST, if it
+// has one.
+func formatReturning(returning []ast.Expression, indentStr string) string {
+	if len(returning) == 0 {
+		return ""
+	}
+	cols := make([]string, len(returning))
+	for i, c := range returning {
+		cols[i] = c.String()
+	}
+	return indentStr + "  Returning: [" + strings.Join(cols, ", ") + "]\n"
+}
+
 // GetErrorMessage returns the first parsing error if any
 func (p *Parser) GetErrorMessage() string {
 	if len(p.errors) == 0 {
 		return ""
 	}
 
-	return fmt.Sprintf("Parsing error: %s", p.errors[0])
+	return fmt.Sprintf("Parsing error: %s", p.errors[0].Message)
 }
 
 // FormatAST returns a formatted tree representation of the AST
@@ -598,7 +1497,7 @@ func (p *Parser) formatStatement(stmt ast.Statement, indent int) string {
 		if len(s.Columns) > 0 {
 			builder.WriteString("\n")
 			for i, col := range s.Columns {
-				builder.WriteString(indentStr + "    \"" + col + "\"")
+				builder.WriteString(indentStr + "    \"" + col.String() + "\"")
 				if i < len(s.Columns)-1 {
 					builder.WriteString(",\n")
 				} else {
@@ -625,30 +1524,54 @@ func (p *Parser) formatStatement(stmt ast.Statement, indent int) string {
 		builder.WriteString(indentStr + "InsertStatement {\n")
 		builder.WriteString(indentStr + "  Table: \"" + s.Table + "\",\n")
 		builder.WriteString(indentStr + "  Columns: [" + strings.Join(s.Columns, ", ") + "],\n")
-		builder.WriteString(indentStr + "  Values: [" + strings.Join(s.Values, ", ") + "]\n")
+		if s.Query != nil {
+			builder.WriteString(indentStr + "  Query: " + s.Query.String() + "\n")
+		} else {
+			rows := make([]string, len(s.Rows))
+			for i, row := range s.Rows {
+				rows[i] = "(" + strings.Join(row, ", ") + ")"
+			}
+			builder.WriteString(indentStr + "  Values: [" + strings.Join(rows, ", ") + "]\n")
+		}
+		if s.OnConflict != nil {
+			builder.WriteString(indentStr + "  OnConflict: " + s.OnConflict.String() + "\n")
+		}
+		builder.WriteString(formatReturning(s.Returning, indentStr))
 		builder.WriteString(indentStr + "}")
 		return builder.String()
 	case *ast.UpdateStatement:
 		var builder strings.Builder
 		builder.WriteString(indentStr + "UpdateStatement {\n")
 		builder.WriteString(indentStr + "  Table: \"" + s.Table + "\",\n")
-		builder.WriteString(indentStr + "  Sets: " + fmt.Sprint(s.Sets))
+		sets := make([]string, len(s.Sets))
+		for i, set := range s.Sets {
+			sets[i] = set.String()
+		}
+		builder.WriteString(indentStr + "  Sets: [" + strings.Join(sets, ", ") + "]")
+		if s.From != nil {
+			builder.WriteString(",\n" + indentStr + "  From: " + s.From.String())
+		}
 		if s.Where != nil {
 			builder.WriteString(",\n" + indentStr + "  Where: " + s.Where.String() + "\n")
 		} else {
 			builder.WriteString("\n")
 		}
+		builder.WriteString(formatReturning(s.Returning, indentStr))
 		builder.WriteString(indentStr + "}")
 		return builder.String()
 	case *ast.DeleteStatement:
 		var builder strings.Builder
 		builder.WriteString(indentStr + "DeleteStatement {\n")
 		builder.WriteString(indentStr + "  Table: \"" + s.Table + "\"")
+		if s.Using != nil {
+			builder.WriteString(",\n" + indentStr + "  Using: " + s.Using.String())
+		}
 		if s.Where != nil {
 			builder.WriteString(",\n" + indentStr + "  Where: " + s.Where.String() + "\n")
 		} else {
 			builder.WriteString("\n")
 		}
+		builder.WriteString(formatReturning(s.Returning, indentStr))
 		builder.WriteString(indentStr + "}")
 		return builder.String()
 	case *ast.CreateDatabaseStatement:
@@ -680,6 +1603,63 @@ func (p *Parser) formatStatement(stmt ast.Statement, indent int) string {
 		builder.WriteString(indentStr + "  ]\n")
 		builder.WriteString(indentStr + "}")
 		return builder.String()
+	case *ast.CopyStatement:
+		var builder strings.Builder
+		builder.WriteString(indentStr + "CopyStatement {\n")
+		if s.Query != nil {
+			builder.WriteString(indentStr + "  Query:\n" + p.formatStatement(s.Query, indent+4) + ",\n")
+		} else {
+			builder.WriteString(indentStr + "  Table: \"" + s.Table + "\",\n")
+			builder.WriteString(fmt.Sprintf("%s  Columns: %q,\n", indentStr, s.Columns))
+		}
+		builder.WriteString(fmt.Sprintf("%s  To: %t,\n", indentStr, s.To))
+		builder.WriteString(indentStr + "  File: \"" + s.File + "\",\n")
+		builder.WriteString(indentStr + "  Options: [\n")
+		for _, opt := range s.Options {
+			builder.WriteString(indentStr + "    " + opt.String() + "\n")
+		}
+		builder.WriteString(indentStr + "  ]\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
+	case *ast.ShowStatement:
+		var builder strings.Builder
+		builder.WriteString(indentStr + "ShowStatement {\n")
+		builder.WriteString(indentStr + "  Kind: " + s.Kind.String() + ",\n")
+		builder.WriteString(indentStr + "  Table: \"" + s.Table + "\"\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
+	case *ast.ExplainStatement:
+		var builder strings.Builder
+		builder.WriteString(indentStr + "ExplainStatement {\n")
+		builder.WriteString(fmt.Sprintf("%s  Analyze: %t,\n", indentStr, s.Analyze))
+		builder.WriteString(indentStr + "  Statement:\n" + p.formatStatement(s.Statement, indent+4) + "\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
+	case *ast.PrepareStatement:
+		var builder strings.Builder
+		builder.WriteString(indentStr + "PrepareStatement {\n")
+		builder.WriteString(indentStr + "  Name: \"" + s.Name + "\",\n")
+		builder.WriteString(fmt.Sprintf("%s  Params: %d,\n", indentStr, s.Params))
+		builder.WriteString(indentStr + "  Statement:\n" + p.formatStatement(s.Statement, indent+4) + "\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
+	case *ast.ExecuteStatement:
+		args := make([]string, len(s.Args))
+		for i, a := range s.Args {
+			args[i] = a.String()
+		}
+		var builder strings.Builder
+		builder.WriteString(indentStr + "ExecuteStatement {\n")
+		builder.WriteString(indentStr + "  Name: \"" + s.Name + "\",\n")
+		builder.WriteString(indentStr + "  Args: [" + strings.Join(args, ", ") + "]\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
+	case *ast.DeallocateStatement:
+		var builder strings.Builder
+		builder.WriteString(indentStr + "DeallocateStatement {\n")
+		builder.WriteString(indentStr + "  Name: \"" + s.Name + "\"\n")
+		builder.WriteString(indentStr + "}")
+		return builder.String()
 	default:
 		return indentStr + "UnknownStatement {}"
 	}
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Mohammad-y-abbass/moDB/internal/ast"
@@ -55,8 +58,8 @@ func TestParseSelectStatement(t *testing.T) {
 		}
 
 		for i, col := range tt.expectedColumns {
-			if stmt.Columns[i] != col {
-				t.Errorf("input %q: expected column %d to be %q, got %q", tt.input, i, col, stmt.Columns[i])
+			if stmt.Columns[i].String() != col {
+				t.Errorf("input %q: expected column %d to be %q, got %q", tt.input, i, col, stmt.Columns[i].String())
 			}
 		}
 
@@ -73,15 +76,15 @@ func TestParserErrors(t *testing.T) {
 	}{
 		{
 			input:         "SELECT",
-			expectedError: "Expected column name or '*' after SELECT at line 1, column 7, but got ''",
+			expectedError: "Expected column name or '*' after SELECT at line 1, column 7, but got end of input",
 		},
 		{
 			input:         "SELECT *",
-			expectedError: "Expected FROM keyword at line 1, column 9, but got ''",
+			expectedError: "Expected FROM keyword at line 1, column 9, but got end of input",
 		},
 		{
 			input:         "SELECT * FROM",
-			expectedError: "Expected table name after FROM at line 1, column 14, but got ''",
+			expectedError: "Expected table name after FROM at line 1, column 14, but got end of input",
 		},
 		{
 			input:         "SELECT name, FROM users",
@@ -91,6 +94,34 @@ func TestParserErrors(t *testing.T) {
 			input:         "FROM users",
 			expectedError: "Unexpected token 'FROM' at line 1, column 1. Expected a statement (e.g., SELECT)",
 		},
+		{
+			input:         "SELECT a FROM t SELECT b FROM u",
+			expectedError: "Expected ; or end of input after the statement at line 1, column 17, but got 'SELECT'",
+		},
+		{
+			input:         "INSERT INTO t VALUES (1, 2",
+			expectedError: "Expected ) at line 1, column 27, but got end of input",
+		},
+		{
+			input:         "SELECT a FROM t WHERE b = 'x",
+			expectedError: "Unterminated string starting at line 1, column 27",
+		},
+		{
+			input:         "SELECT a\nFROM t WHERE b = E'x\\n",
+			expectedError: "Unterminated string starting at line 2, column 18",
+		},
+		{
+			input:         `SELECT a FROM t WHERE b = E'\u12'`,
+			expectedError: "Invalid escape sequence in string starting at line 1, column 27",
+		},
+		{
+			input:         "SELECT a FROM t /* open",
+			expectedError: "Unterminated comment starting at line 1, column 17",
+		},
+		{
+			input:         `SELECT "a FROM t`,
+			expectedError: "Unterminated quoted identifier starting at line 1, column 8",
+		},
 	}
 
 	for _, tt := range tests {
@@ -121,14 +152,18 @@ func TestParseWhereClause(t *testing.T) {
 	if stmt.Where == nil {
 		t.Fatal("Where clause is nil")
 	}
-	if stmt.Where.Left != "id" {
-		t.Errorf("expected id, got %s", stmt.Where.Left)
+	cond, ok := stmt.Where.Condition.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("expected *ast.InfixExpression, got %T", stmt.Where.Condition)
 	}
-	if stmt.Where.Op != "=" {
-		t.Errorf("expected =, got %s", stmt.Where.Op)
+	if cond.Left.String() != "id" {
+		t.Errorf("expected id, got %s", cond.Left.String())
 	}
-	if stmt.Where.Right != "1" {
-		t.Errorf("expected 1, got %s", stmt.Where.Right)
+	if cond.Operator != "=" {
+		t.Errorf("expected =, got %s", cond.Operator)
+	}
+	if cond.Right.String() != "1" {
+		t.Errorf("expected 1, got %s", cond.Right.String())
 	}
 }
 
@@ -146,8 +181,8 @@ func TestParseInsertStatement(t *testing.T) {
 	if len(stmt.Columns) != 2 || stmt.Columns[0] != "name" || stmt.Columns[1] != "age" {
 		t.Errorf("columns mismatch: %v", stmt.Columns)
 	}
-	if len(stmt.Values) != 2 || stmt.Values[0] != "john" || stmt.Values[1] != "30" {
-		t.Errorf("values mismatch: %v", stmt.Values)
+	if len(stmt.Rows) != 1 || len(stmt.Rows[0]) != 2 || stmt.Rows[0][0] != "john" || stmt.Rows[0][1] != "30" {
+		t.Errorf("values mismatch: %v", stmt.Rows)
 	}
 }
 
@@ -162,10 +197,10 @@ func TestParseUpdateStatement(t *testing.T) {
 	if stmt.Table != "users" {
 		t.Errorf("expected users, got %s", stmt.Table)
 	}
-	if stmt.Sets["age"] != "31" || stmt.Sets["name"] != "johnny" {
+	if len(stmt.Sets) != 2 || stmt.Sets[0].String() != "age = 31" || stmt.Sets[1].String() != "name = johnny" {
 		t.Errorf("sets mismatch: %v", stmt.Sets)
 	}
-	if stmt.Where == nil || stmt.Where.Left != "id" {
+	if stmt.Where == nil || stmt.Where.Condition.String() != "id = 1" {
 		t.Errorf("where mismatch")
 	}
 }
@@ -181,11 +216,609 @@ func TestParseDeleteStatement(t *testing.T) {
 	if stmt.Table != "users" {
 		t.Errorf("expected users, got %s", stmt.Table)
 	}
-	if stmt.Where == nil || stmt.Where.Left != "id" {
+	if stmt.Where == nil || stmt.Where.Condition.String() != "id = 1" {
 		t.Errorf("where mismatch")
 	}
 }
 
+func TestParseGroupByHaving(t *testing.T) {
+	input := "SELECT dept, COUNT(*), SUM(DISTINCT salary), STRING_AGG(name, ',') FROM employees GROUP BY dept HAVING COUNT(*) > 1 AND MAX(salary) >= 100"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	expectedColumns := []string{"dept", "COUNT(*)", "SUM(DISTINCT salary)", "STRING_AGG(name, ',')"}
+	if len(stmt.Columns) != len(expectedColumns) {
+		t.Fatalf("expected %d columns, got %d", len(expectedColumns), len(stmt.Columns))
+	}
+	for i, col := range expectedColumns {
+		if stmt.Columns[i].String() != col {
+			t.Errorf("expected column %d to be %q, got %q", i, col, stmt.Columns[i].String())
+		}
+	}
+	if len(stmt.GroupBy) != 1 || stmt.GroupBy[0].String() != "dept" {
+		t.Errorf("group by mismatch: %v", stmt.GroupBy)
+	}
+	if stmt.Having == nil || stmt.Having.String() != "COUNT(*) > 1 AND MAX(salary) >= 100" {
+		t.Errorf("having mismatch: %v", stmt.Having)
+	}
+}
+
+func TestParseExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT * FROM t HAVING a = 1 OR b = 2 AND c = 3", "a = 1 OR b = 2 AND c = 3"},
+		{"SELECT * FROM t HAVING (a = 1 OR b = 2) AND c = 3", "(a = 1 OR b = 2) AND c = 3"},
+		{"SELECT * FROM t HAVING NOT a = 1 AND b = 2", "NOT a = 1 AND b = 2"},
+		{"SELECT * FROM t HAVING NOT (a = 1 AND b = 2)", "NOT (a = 1 AND b = 2)"},
+		{"SELECT * FROM t HAVING a || 'x' = CAST(b AS text) || upper(c)", "a || 'x' = CAST(b AS TEXT) || UPPER(c)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.SelectStatement)
+		if stmt.Having.String() != tt.expected {
+			t.Errorf("input %q: expected %q, got %q", tt.input, tt.expected, stmt.Having.String())
+		}
+	}
+}
+
+func TestParseDistinctOrderBy(t *testing.T) {
+	input := "SELECT DISTINCT ON (dept, team) dept, name FROM employees ORDER BY dept, team ASC, salary DESC"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	if !stmt.Distinct {
+		t.Errorf("expected Distinct to be set")
+	}
+	if len(stmt.DistinctOn) != 2 || stmt.DistinctOn[0].String() != "dept" || stmt.DistinctOn[1].String() != "team" {
+		t.Errorf("distinct on mismatch: %v", stmt.DistinctOn)
+	}
+	if len(stmt.Columns) != 2 {
+		t.Errorf("expected 2 columns, got %d", len(stmt.Columns))
+	}
+	expected := []string{"dept", "team", "salary DESC"}
+	if len(stmt.OrderBy) != len(expected) {
+		t.Fatalf("expected %d ORDER BY items, got %d", len(expected), len(stmt.OrderBy))
+	}
+	for i, item := range expected {
+		if stmt.OrderBy[i].String() != item {
+			t.Errorf("expected ORDER BY item %d to be %q, got %q", i, item, stmt.OrderBy[i].String())
+		}
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	input := "SELECT u.name AS username, COUNT(*) n FROM users AS u JOIN orders o ON o.user_id = u.id"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	expected := []string{"u.name AS username", "COUNT(*) AS n"}
+	for i, col := range expected {
+		if stmt.Columns[i].String() != col {
+			t.Errorf("expected column %d to be %q, got %q", i, col, stmt.Columns[i].String())
+		}
+	}
+	if stmt.Table != "users" || stmt.Alias != "u" {
+		t.Errorf("expected users AS u, got %s AS %s", stmt.Table, stmt.Alias)
+	}
+	if len(stmt.Joins) != 1 || stmt.Joins[0].Table != "orders" || stmt.Joins[0].Alias != "o" {
+		t.Fatalf("join mismatch: %+v", stmt.Joins)
+	}
+
+	for _, bad := range []string{
+		"SELECT * AS everything FROM users",
+		"SELECT name AS FROM users",
+		"SELECT name FROM users AS",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseJoins(t *testing.T) {
+	input := "SELECT * FROM a JOIN b ON a.id = b.a_id AND b.x > 1 LEFT OUTER JOIN c ON c.id = b.c_id " +
+		"RIGHT JOIN d ON TRUE FULL JOIN e x ON x.id = a.id CROSS JOIN f, g INNER JOIN h ON h.id = g.id"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	expected := []struct {
+		kind  ast.JoinKind
+		table string
+		on    string
+	}{
+		{ast.InnerJoin, "b", "a.id = b.a_id AND b.x > 1"},
+		{ast.LeftJoin, "c", "c.id = b.c_id"},
+		{ast.RightJoin, "d", "TRUE"},
+		{ast.FullJoin, "e", "x.id = a.id"},
+		{ast.CrossJoin, "f", ""},
+		{ast.CrossJoin, "g", ""},
+		{ast.InnerJoin, "h", "h.id = g.id"},
+	}
+	if len(stmt.Joins) != len(expected) {
+		t.Fatalf("expected %d joins, got %d", len(expected), len(stmt.Joins))
+	}
+	for i, tt := range expected {
+		join := stmt.Joins[i]
+		on := ""
+		if join.On != nil {
+			on = join.On.String()
+		}
+		if join.Kind != tt.kind || join.Table != tt.table || on != tt.on {
+			t.Errorf("join %d: expected %s %s ON %q, got %s %s ON %q", i, tt.kind, tt.table, tt.on, join.Kind, join.Table, on)
+		}
+	}
+
+	for _, bad := range []string{
+		"SELECT * FROM a LEFT b ON a.id = b.id",
+		"SELECT * FROM a JOIN b",
+		"SELECT * FROM a CROSS JOIN b ON a.id = b.id",
+		"SELECT * FROM a JOIN ON a.id = 1",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseSubqueries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT a FROM t WHERE a IN (SELECT b FROM u)", "a IN (SELECT b FROM u)"},
+		{"SELECT a FROM t WHERE a NOT IN (1, 2, 3)", "a NOT IN (1, 2, 3)"},
+		{"SELECT a FROM t WHERE NOT EXISTS (SELECT b FROM u WHERE u.b = t.a) AND a > 1",
+			"NOT EXISTS (SELECT b FROM u WHERE u.b = t.a) AND a > 1"},
+		{"SELECT a FROM t WHERE a = (SELECT MAX(b) FROM u) OR a IN (2)", "a = (SELECT MAX(b) FROM u) OR a IN (2)"},
+		{"SELECT a FROM t WHERE a IS NULL OR NOT b is not null", "a IS NULL OR NOT b IS NOT NULL"},
+		{"SELECT a FROM t WHERE (a = 1) IS NULL AND a || 'x' IS NOT NULL", "(a = 1) IS NULL AND a || 'x' IS NOT NULL"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		where := program.Statements[0].(*ast.SelectStatement).Where.Condition.String()
+		if where != tt.expected {
+			t.Errorf("input %q: expected WHERE %q, got %q", tt.input, tt.expected, where)
+		}
+	}
+
+	// NOT binds tighter than AND.
+	p := New(lexer.New("SELECT a FROM t WHERE NOT EXISTS (SELECT b FROM u) AND a > 1"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	and, ok := program.Statements[0].(*ast.SelectStatement).Where.Condition.(*ast.InfixExpression)
+	if !ok || and.Operator != "AND" {
+		t.Fatalf("expected AND at the top, got %T", program.Statements[0].(*ast.SelectStatement).Where.Condition)
+	}
+
+	for _, bad := range []string{
+		"SELECT a FROM t WHERE a IN ()",
+		"SELECT a FROM t WHERE a IN (SELECT b FROM u",
+		"SELECT a FROM t WHERE EXISTS b",
+		"SELECT a FROM t WHERE a NOT 1",
+		"SELECT a FROM t WHERE a IS 1",
+		"SELECT a FROM t WHERE a IS NOT",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	input := "WITH RECURSIVE r (id, boss) AS (SELECT id, boss FROM staff WHERE boss = 1 " +
+		"UNION ALL SELECT s.id, s.boss FROM staff s JOIN r ON s.boss = r.id), n AS (SELECT id FROM r) SELECT id FROM n"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	if stmt.With == nil || !stmt.With.Recursive || len(stmt.With.CTEs) != 2 {
+		t.Fatalf("expected a WITH RECURSIVE clause with 2 queries, got %v", stmt.With)
+	}
+	r, n := stmt.With.CTEs[0], stmt.With.CTEs[1]
+	union, ok := r.Query.(*ast.SetOperation)
+	if r.Name != "r" || strings.Join(r.Columns, ",") != "id,boss" || !ok || union.Op != ast.Union || !union.All {
+		t.Fatalf("unexpected first query %s", r.String())
+	}
+	if term := union.Right.(*ast.SelectStatement); term.Table != "staff" || len(term.Joins) != 1 {
+		t.Errorf("unexpected recursive term %s", term.String())
+	}
+	if sel, ok := n.Query.(*ast.SelectStatement); n.Name != "n" || !ok || sel.Table != "r" || stmt.Table != "n" {
+		t.Errorf("unexpected second query %s", n.String())
+	}
+	if stmt.String() != input {
+		t.Errorf("expected %q, got %q", input, stmt.String())
+	}
+
+	for _, bad := range []string{
+		"WITH SELECT id FROM t",
+		"WITH a (SELECT id FROM t) SELECT id FROM a",
+		"WITH a AS SELECT id FROM t SELECT id FROM a",
+		"WITH a AS (SELECT id FROM t UNION) SELECT id FROM a",
+		"WITH a AS (SELECT id FROM t) UPDATE t SET id = 1",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseSetOperations(t *testing.T) {
+	input := "SELECT a FROM t UNION ALL SELECT b FROM u INTERSECT SELECT c FROM v EXCEPT SELECT d FROM w ORDER BY a DESC LIMIT 5 OFFSET 2"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	// ((t UNION ALL (u INTERSECT v)) EXCEPT w) ORDER BY ... LIMIT ... OFFSET ...
+	except, ok := program.Statements[0].(*ast.SetOperation)
+	if !ok || except.Op != ast.Except || except.All {
+		t.Fatalf("expected EXCEPT at the top, got %s", program.Statements[0].String())
+	}
+	if len(except.OrderBy) != 1 || except.Limit.String() != "5" || except.Offset.String() != "2" {
+		t.Errorf("expected ORDER BY, LIMIT and OFFSET on the set operation, got %s", except.String())
+	}
+	if w := except.Right.(*ast.SelectStatement); w.Table != "w" || w.OrderBy != nil || w.Limit != nil {
+		t.Errorf("unexpected last query %s", w.String())
+	}
+	union, ok := except.Left.(*ast.SetOperation)
+	if !ok || union.Op != ast.Union || !union.All {
+		t.Fatalf("expected UNION ALL on the left, got %s", except.Left.String())
+	}
+	if intersect, ok := union.Right.(*ast.SetOperation); !ok || intersect.Op != ast.Intersect {
+		t.Errorf("expected INTERSECT to bind tighter, got %s", union.Right.String())
+	}
+	if except.String() != input {
+		t.Errorf("expected %q, got %q", input, except.String())
+	}
+
+	// A parenthesised operand keeps its own ORDER BY and LIMIT, and can
+	// group set operations against precedence.
+	input = "(SELECT a FROM t ORDER BY a LIMIT 1) UNION (SELECT b FROM u LIMIT 2) ORDER BY 1 LIMIT 3"
+	p = New(lexer.New(input))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	union, ok = program.Statements[0].(*ast.SetOperation)
+	if !ok || union.Op != ast.Union || len(union.OrderBy) != 1 || union.Limit.String() != "3" {
+		t.Fatalf("expected UNION with ORDER BY and LIMIT 3, got %s", program.Statements[0].String())
+	}
+	if left := union.Left.(*ast.SelectStatement); len(left.OrderBy) != 1 || left.Limit.String() != "1" {
+		t.Errorf("expected the left query to keep ORDER BY and LIMIT 1, got %s", left.String())
+	}
+	if right := union.Right.(*ast.SelectStatement); right.Limit.String() != "2" {
+		t.Errorf("expected the right query to keep LIMIT 2, got %s", right.String())
+	}
+	if union.String() != input {
+		t.Errorf("expected %q, got %q", input, union.String())
+	}
+	p = New(lexer.New("(SELECT a FROM t UNION SELECT a FROM u) INTERSECT SELECT a FROM v"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if intersect, ok := program.Statements[0].(*ast.SetOperation); !ok || intersect.Op != ast.Intersect {
+		t.Errorf("expected INTERSECT at the top, got %s", program.Statements[0].String())
+	}
+
+	for _, bad := range []string{
+		"SELECT a FROM t UNION",
+		"SELECT a FROM t UNION ALL ALL SELECT a FROM u",
+		"SELECT a FROM t ORDER BY a UNION SELECT a FROM u",
+		"SELECT a FROM t LIMIT 1 INTERSECT SELECT a FROM u",
+		"SELECT a FROM t LIMIT",
+		"SELECT a FROM t OFFSET 1 OFFSET 2",
+		"(SELECT a FROM t UNION SELECT a FROM u",
+		"SELECT a FROM t UNION (INSERT INTO t VALUES (1))",
+		"(SELECT a FROM t LIMIT 1) LIMIT 2",
+		"(SELECT a FROM t) ORDER BY a UNION SELECT a FROM u",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseWindowFunctions(t *testing.T) {
+	input := "SELECT RANK() OVER (PARTITION BY dept, team ORDER BY salary DESC), " +
+		"SUM(salary) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND 1 FOLLOWING), " +
+		"COUNT(*) OVER (ORDER BY id RANGE UNBOUNDED PRECEDING), LAG(name, 1, 'x') OVER () FROM t"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	rank, ok := stmt.Columns[0].(*ast.FunctionCall)
+	if !ok || rank.Over == nil {
+		t.Fatalf("expected a window function call, got %s", stmt.Columns[0].String())
+	}
+	if len(rank.Over.PartitionBy) != 2 || len(rank.Over.OrderBy) != 1 || !rank.Over.OrderBy[0].Desc || rank.Over.Frame != nil {
+		t.Errorf("unexpected window %s", rank.Over.String())
+	}
+	frame := stmt.Columns[1].(*ast.FunctionCall).Over.Frame
+	if frame == nil || frame.Unit != ast.RowsFrame || frame.Start.Kind != ast.OffsetPreceding ||
+		frame.Start.Offset.String() != "2" || frame.End.Kind != ast.OffsetFollowing {
+		t.Errorf("unexpected frame %v", frame)
+	}
+	// A single bound ends the frame at the current row.
+	frame = stmt.Columns[2].(*ast.FunctionCall).Over.Frame
+	if frame == nil || frame.Unit != ast.RangeFrame || frame.Start.Kind != ast.UnboundedPreceding || frame.End.Kind != ast.CurrentRow {
+		t.Errorf("unexpected frame %v", frame)
+	}
+
+	expected := []string{
+		"RANK() OVER (PARTITION BY dept, team ORDER BY salary DESC)",
+		"SUM(salary) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND 1 FOLLOWING)",
+		"COUNT(*) OVER (ORDER BY id RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)",
+		"LAG(name, 1, 'x') OVER ()",
+	}
+	for i, want := range expected {
+		if got := stmt.Columns[i].String(); got != want {
+			t.Errorf("column %d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	for _, bad := range []string{
+		"SELECT RANK() OVER FROM t",
+		"SELECT RANK() OVER (PARTITION dept) FROM t",
+		"SELECT SUM(a) OVER (ROWS BETWEEN 1 PRECEDING) FROM t",
+		"SELECT SUM(a) OVER (ROWS UNBOUNDED) FROM t",
+		"SELECT SUM(a) OVER (ROWS CURRENT) FROM t",
+		"SELECT SUM(a) OVER (ROWS 1) FROM t",
+		"SELECT SUM(a) OVER (ORDER BY a FROM t",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseCaseExpressions(t *testing.T) {
+	input := "SELECT CASE WHEN a > 1 THEN 'big' WHEN a = 1 THEN 'one' ELSE 'small' END, CASE b WHEN 1 THEN 2 END FROM t " +
+		"ORDER BY CASE WHEN c THEN 0 ELSE 1 END"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	searched, ok := stmt.Columns[0].(*ast.CaseExpression)
+	if !ok || searched.Operand != nil || len(searched.Whens) != 2 || searched.Else == nil {
+		t.Fatalf("expected a searched CASE with two WHENs and an ELSE, got %s", stmt.Columns[0].String())
+	}
+	simple, ok := stmt.Columns[1].(*ast.CaseExpression)
+	if !ok || simple.Operand == nil || simple.Operand.String() != "b" || simple.Else != nil {
+		t.Fatalf("expected a simple CASE on b, got %s", stmt.Columns[1].String())
+	}
+	if got, want := searched.String(), "CASE WHEN a > 1 THEN 'big' WHEN a = 1 THEN 'one' ELSE 'small' END"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if len(stmt.OrderBy) != 1 || stmt.OrderBy[0].Expr.String() != "CASE WHEN c THEN 0 ELSE 1 END" {
+		t.Errorf("unexpected ORDER BY %v", stmt.OrderBy)
+	}
+
+	p = New(lexer.New("UPDATE t SET a = CASE a WHEN 1 THEN 2 ELSE a END WHERE b = 1"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if set := program.Statements[0].(*ast.UpdateStatement).Sets[0]; set.Value.String() != "CASE a WHEN 1 THEN 2 ELSE a END" {
+		t.Errorf("unexpected SET value %s", set.String())
+	}
+
+	for _, bad := range []string{
+		"SELECT CASE END FROM t",
+		"SELECT CASE WHEN a THEN 1 FROM t",
+		"SELECT CASE WHEN a 1 END FROM t",
+		"SELECT CASE a ELSE 1 END FROM t",
+		"SELECT CASE WHEN a THEN 1 ELSE END FROM t",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseInsertBatch(t *testing.T) {
+	p := New(lexer.New("INSERT INTO users VALUES (1, 'a'), (2, 'b'), (3, 'c')"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.InsertStatement)
+	if len(stmt.Rows) != 3 || stmt.Rows[2][0] != "3" || stmt.Rows[2][1] != "c" || stmt.Query != nil {
+		t.Errorf("rows mismatch: %v", stmt.Rows)
+	}
+
+	for _, input := range []string{
+		"INSERT INTO archive (id, name) SELECT id, name FROM users WHERE age > 30",
+		"INSERT INTO archive SELECT id FROM users UNION SELECT id FROM admins",
+		"INSERT INTO archive WITH old AS (SELECT id FROM users) SELECT id FROM old",
+	} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.InsertStatement)
+		if stmt.Query == nil || stmt.Rows != nil {
+			t.Errorf("input %q: expected a query, got rows %v", input, stmt.Rows)
+		}
+	}
+
+	for _, bad := range []string{
+		"INSERT INTO users VALUES (1), ",
+		"INSERT INTO users VALUES (1) (2)",
+		"INSERT INTO users VALUES",
+		"INSERT INTO users (id) FROM users",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseOnConflict(t *testing.T) {
+	p := New(lexer.New("INSERT INTO t VALUES (1, 2) ON CONFLICT (id) DO UPDATE SET b = EXCLUDED.b, c = c"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	oc := program.Statements[0].(*ast.InsertStatement).OnConflict
+	if oc == nil || !oc.DoUpdate || len(oc.Columns) != 1 || oc.Columns[0] != "id" {
+		t.Fatalf("unexpected ON CONFLICT clause %v", oc)
+	}
+	if got, want := oc.String(), "ON CONFLICT (id) DO UPDATE SET b = EXCLUDED.b, c = c"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	p = New(lexer.New("INSERT INTO t SELECT a, b FROM s ON CONFLICT DO NOTHING; SELECT a FROM t"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.InsertStatement)
+	if stmt.Query == nil || stmt.OnConflict == nil || stmt.OnConflict.DoUpdate || len(stmt.OnConflict.Columns) != 0 {
+		t.Errorf("unexpected statement %s", p.formatStatement(stmt, 0))
+	}
+	if len(program.Statements) != 2 {
+		t.Errorf("expected 2 statements, got %d", len(program.Statements))
+	}
+
+	for _, bad := range []string{
+		"INSERT INTO t VALUES (1) ON CONFLICT",
+		"INSERT INTO t VALUES (1) ON CONFLICT (id)",
+		"INSERT INTO t VALUES (1) ON CONFLICT (id) DO",
+		"INSERT INTO t VALUES (1) ON CONFLICT (id) DO DELETE",
+		"INSERT INTO t VALUES (1) ON CONFLICT (id) DO UPDATE b = 1",
+		"INSERT INTO t VALUES (1) ON CONFLICT (id) DO UPDATE SET",
+		"INSERT INTO t VALUES (1) ON DUPLICATE DO NOTHING",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseReturning(t *testing.T) {
+	p := New(lexer.New("INSERT INTO t VALUES (1) ON CONFLICT DO NOTHING RETURNING *; " +
+		"UPDATE t SET a = 1 WHERE b = 2 RETURNING a, b AS bee; DELETE FROM t RETURNING UPPER(c)"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+	ins := program.Statements[0].(*ast.InsertStatement)
+	if _, star := ins.Returning[0].(*ast.Star); len(ins.Returning) != 1 || !star || ins.OnConflict == nil {
+		t.Errorf("unexpected INSERT %s", p.formatStatement(ins, 0))
+	}
+	upd := program.Statements[1].(*ast.UpdateStatement)
+	if len(upd.Returning) != 2 || upd.Returning[1].String() != "b AS bee" || upd.Where == nil {
+		t.Errorf("unexpected UPDATE %s", p.formatStatement(upd, 0))
+	}
+	del := program.Statements[2].(*ast.DeleteStatement)
+	if len(del.Returning) != 1 || del.Returning[0].String() != "UPPER(c)" {
+		t.Errorf("unexpected DELETE %s", p.formatStatement(del, 0))
+	}
+
+	for _, bad := range []string{
+		"DELETE FROM t RETURNING",
+		"DELETE FROM t RETURNING a,",
+		"UPDATE t SET a = 1 RETURNING * AS x",
+		"INSERT INTO t VALUES (1) RETURNING a WHERE b = 1",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT a + b * c FROM t", "a + b * c"},
+		{"SELECT (a + b) * c FROM t", "(a + b) * c"},
+		{"SELECT a - b - c FROM t", "a - b - c"},
+		{"SELECT a - (b - c) FROM t", "a - (b - c)"},
+		{"SELECT a / b % c FROM t", "a / b % c"},
+		{"SELECT a -1 FROM t", "a - 1"},
+		{"SELECT 2-1 FROM t", "2 - 1"},
+		{"SELECT -1 * -a FROM t", "-1 * -a"},
+		{"SELECT - -a FROM t", "- -a"},
+		{"SELECT -(a + 1) FROM t", "-(a + 1)"},
+		{"SELECT a || b + 1 FROM t", "a || b + 1"},
+		{"SELECT a + 1 > b * 2 FROM t", "a + 1 > b * 2"},
+		{"SELECT COUNT(*) * 2 FROM t", "COUNT(*) * 2"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.SelectStatement)
+		if got := stmt.Columns[0].String(); got != tt.expected {
+			t.Errorf("input %q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	// The "-1" in "b -1" still splits, so (b - 1) binds tighter than *.
+	p := New(lexer.New("SELECT a * b -1 FROM t"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	infix, ok := program.Statements[0].(*ast.SelectStatement).Columns[0].(*ast.InfixExpression)
+	if !ok || infix.Operator != "-" || infix.Left.String() != "a * b" {
+		t.Errorf("expected (a * b) - 1, got %v", program.Statements[0].String())
+	}
+
+	p = New(lexer.New("UPDATE t SET b = a, a = a + 1"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	sets := program.Statements[0].(*ast.UpdateStatement).Sets
+	if len(sets) != 2 || sets[0].Column != "b" || sets[1].String() != "a = a + 1" {
+		t.Errorf("unexpected SET list %v", sets)
+	}
+
+	for _, bad := range []string{
+		"SELECT a + FROM t",
+		"SELECT a * FROM t",
+		"SELECT - FROM t",
+		"SELECT a % % b FROM t",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -198,3 +831,364 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestParseUpdateFromDeleteUsing(t *testing.T) {
+	p := New(lexer.New("UPDATE t SET a = s.b FROM s JOIN u ON u.id = s.id WHERE s.id = t.id RETURNING a; " +
+		"DELETE FROM t USING s x WHERE x.id = t.id"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(program.Statements))
+	}
+	upd := program.Statements[0].(*ast.UpdateStatement)
+	if upd.From == nil || upd.From.String() != "s JOIN u ON u.id = s.id" || upd.Where == nil || len(upd.Returning) != 1 {
+		t.Errorf("unexpected UPDATE %s", p.formatStatement(upd, 0))
+	}
+	del := program.Statements[1].(*ast.DeleteStatement)
+	if del.Using == nil || del.Using.Table != "s" || del.Using.Alias != "x" || del.Where == nil {
+		t.Errorf("unexpected DELETE %s", p.formatStatement(del, 0))
+	}
+
+	for _, bad := range []string{
+		"UPDATE t SET a = 1 FROM",
+		"UPDATE t SET a = 1 FROM s JOIN",
+		"DELETE FROM t USING WHERE a = 1",
+		"DELETE FROM t USING s JOIN u",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParsePrepare(t *testing.T) {
+	p := New(lexer.New("PREPARE q AS SELECT a FROM t WHERE a = ? AND b > ?; " +
+		"PREPARE i AS INSERT INTO t VALUES (1, $2), ($3, 'x'); " +
+		"EXECUTE q(1, 'two'); EXECUTE i; DEALLOCATE q"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 5 {
+		t.Fatalf("expected 5 statements, got %d", len(program.Statements))
+	}
+	q := program.Statements[0].(*ast.PrepareStatement)
+	sel, ok := q.Statement.(*ast.SelectStatement)
+	if !ok || q.Name != "q" || q.Params != 2 || sel.Where.Condition.String() != "a = $1 AND b > $2" {
+		t.Errorf("unexpected PREPARE %s", p.formatStatement(q, 0))
+	}
+	ins := program.Statements[1].(*ast.PrepareStatement).Statement.(*ast.InsertStatement)
+	if fmt.Sprint(ins.Rows) != "[[1 $2] [$3 x]]" || fmt.Sprint(ins.Params) != "[[0 2] [3 0]]" {
+		t.Errorf("unexpected INSERT %s", p.formatStatement(ins, 0))
+	}
+	if program.Statements[1].(*ast.PrepareStatement).Params != 3 {
+		t.Errorf("expected 3 parameters")
+	}
+	if s := program.Statements[2].String(); s != "EXECUTE q(1, 'two')" {
+		t.Errorf("unexpected EXECUTE %s", s)
+	}
+	if s := program.Statements[3].(*ast.ExecuteStatement); s.Name != "i" || len(s.Args) != 0 {
+		t.Errorf("unexpected EXECUTE %s", s)
+	}
+	if s := program.Statements[4].String(); s != "DEALLOCATE q" {
+		t.Errorf("unexpected DEALLOCATE %s", s)
+	}
+
+	for _, bad := range []string{
+		"SELECT a FROM t WHERE a = ?",
+		"INSERT INTO t VALUES ($1)",
+		"EXECUTE q($1)",
+		"PREPARE q AS SELECT a FROM t WHERE a = ? AND b = $2",
+		"PREPARE q AS SELECT a FROM t WHERE a = $0",
+		"PREPARE q AS CREATE TABLE t (a INT)",
+		"PREPARE q SELECT 1",
+		"EXECUTE q(1",
+		"DEALLOCATE",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	input := "SELECT FROM t;\nSELECT a FROM u;\nINSERT t VALUES (1)"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	errs := p.ParseErrors()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %q", len(errs), p.Errors())
+	}
+	if len(program.Statements) != 1 || program.Statements[0].(*ast.SelectStatement).Table != "u" {
+		t.Fatalf("expected only the second statement to parse, got %d statements", len(program.Statements))
+	}
+
+	insert := errs[1]
+	if insert.Message != "Expected INTO after INSERT" {
+		t.Errorf("unexpected message %q", insert.Message)
+	}
+	want := Span{Start: Position{Line: 3, Col: 8}, End: Position{Line: 3, Col: 9}}
+	if insert.Span != want {
+		t.Errorf("expected span %+v, got %+v", want, insert.Span)
+	}
+	if len(insert.Expected) != 1 || insert.Expected[0] != lexer.INTO_TOKEN || insert.Found.Value != "t" {
+		t.Errorf("unexpected expected set %v, found %q", insert.Expected, insert.Found.Value)
+	}
+
+	expected := "Parsing error: Expected column name or '*' after SELECT at line 1, column 8, but got 'FROM'\n" +
+		" 1 | SELECT FROM t;\n" +
+		"   |        ^^^^\n" +
+		"Parsing error: Expected INTO after INSERT\n" +
+		" 3 | INSERT t VALUES (1)\n" +
+		"   |        ^"
+	if got := p.Diagnostics(); got != expected {
+		t.Errorf("unexpected diagnostics:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// A missing ';' is an error for the first statement; recovery then
+	// skips to the next ';'.
+	p = New(lexer.New("SELECT a FROM t SELECT b FROM u; SELECT c FROM v"))
+	program = p.ParseProgram()
+	if len(p.Errors()) != 1 || len(program.Statements) != 1 || program.Statements[0].(*ast.SelectStatement).Table != "v" {
+		t.Errorf("expected one error and the last statement, got %q and %d statements", p.Errors(), len(program.Statements))
+	}
+
+	p = New(lexer.New("INSERT INTO t VALUES (1, 2"))
+	p.ParseProgram()
+	expected = "Parsing error: Expected ) at line 1, column 27, but got end of input\n" +
+		" 1 | INSERT INTO t VALUES (1, 2\n" +
+		"   |                           ^"
+	if got := p.Diagnostics(); got != expected {
+		t.Errorf("unexpected diagnostics:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// The caret lines up under tabs and points one past the end at EOF.
+	p = New(lexer.New("\tUPDATE t SET"))
+	p.ParseProgram()
+	expected = "Parsing error: Expected column name in SET\n" +
+		" 1 | \tUPDATE t SET\n" +
+		"   | \t            ^"
+	if got := p.Diagnostics(); got != expected {
+		t.Errorf("unexpected diagnostics:\n%q\nwant:\n%q", got, expected)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		"CREATE DATABASE shop",
+		"USE shop",
+		`CREATE TABLE "order" (id INT PRIMARY KEY, name TEXT(40) NOT NULL UNIQUE, note varchar, "user" INT REFERENCES users(id))`,
+		"INSERT INTO users VALUES (1, 'it''s'), (-2, '10'), (3, 'NULL')",
+		"INSERT INTO users VALUES (4, NULL), (NULL, 'x')",
+		"INSERT INTO prices VALUES (1, 2.5), (2, -0.75), (3, '.5')",
+		"SELECT ROUND(2.5), 1.5 * price, price -0.5, -price * 2.0 FROM t WHERE price > 1.25",
+		`INSERT INTO t ("key", b) VALUES (1, 2) ON CONFLICT ("key") DO UPDATE SET b = EXCLUDED.b RETURNING "key", b AS "from"`,
+		"INSERT INTO archive (id, name) SELECT id, name FROM users WHERE age > 30 ON CONFLICT DO NOTHING",
+		"INSERT INTO archive WITH old AS (SELECT id FROM users) SELECT id FROM old",
+		"UPDATE t SET a = CASE a WHEN 1 THEN 2 ELSE a END, b = b * (a - 1) FROM s JOIN u ON u.id = s.id WHERE s.id = t.id RETURNING *",
+		"DELETE FROM t USING s x WHERE x.id = t.id AND NOT (t.a = 1 OR t.b = 2)",
+		"DELETE FROM t",
+		"SELECT CASE WHEN a IS NULL THEN 0 END FROM t WHERE (a IS NULL) = (b IS NOT NULL)",
+		"SELECT DISTINCT ON (dept) dept, name AS n FROM emp e LEFT JOIN dept d ON d.id = e.dept CROSS JOIN x " +
+			"WHERE e.a IN (1, 2) AND NOT EXISTS (SELECT b FROM u WHERE u.b = e.a) GROUP BY dept, name HAVING COUNT(*) > 1 " +
+			"ORDER BY dept DESC, n LIMIT 10 OFFSET 5",
+		"WITH RECURSIVE r (n) AS (SELECT id FROM t UNION ALL SELECT n + 1 FROM r WHERE n < 5) SELECT n FROM r",
+		"SELECT a FROM t UNION SELECT a FROM u INTERSECT SELECT a FROM v EXCEPT SELECT a FROM w ORDER BY a LIMIT 3",
+		"(SELECT a FROM t UNION SELECT a FROM u) INTERSECT (SELECT a FROM v ORDER BY a LIMIT 1) EXCEPT (SELECT a FROM w EXCEPT SELECT a FROM x)",
+		"(WITH x AS (SELECT a FROM t) SELECT a FROM x) UNION ALL SELECT b FROM u ORDER BY 1",
+		"WITH x AS ((SELECT a FROM t LIMIT 1) UNION SELECT a FROM u) SELECT a FROM x",
+		"EXPLAIN (SELECT a FROM t LIMIT 1) UNION SELECT b FROM u",
+		"SELECT SUM(DISTINCT a) OVER (PARTITION BY b ORDER BY c ROWS BETWEEN 2 PRECEDING AND 1 FOLLOWING), " +
+			"CAST(a AS TEXT) || 'x', -a, - -1, (SELECT MAX(b) FROM u) FROM t",
+		`SELECT "select", t."from", "odd""name" FROM "my table" t`,
+		"PREPARE q AS UPDATE t SET a = $1 WHERE b = $2",
+		"PREPARE ins AS INSERT INTO t VALUES ($1, 'x', $2)",
+		"PREPARE mixed AS INSERT INTO t VALUES ($1, NULL), (2, 'x')",
+		"EXECUTE q(1, 'a')",
+		"DEALLOCATE q",
+		"EXPLAIN SELECT a FROM t WHERE a > 1",
+		"EXPLAIN ANALYZE DELETE FROM t WHERE a = 1 RETURNING a",
+		"SHOW DATABASES",
+		"SHOW TABLES",
+		`DESCRIBE "order"`,
+		"SHOW CREATE TABLE users",
+		"SHOW INDEXES",
+		"SHOW INDEXES FROM users",
+		"COPY users FROM 'users.csv'",
+		`COPY "order" (id, "from") FROM 'it''s.csv' WITH (FORMAT csv, HEADER false, DELIMITER ';', NULL 'NA')`,
+		"COPY users FROM 'users.jsonl' WITH (FORMAT jsonl)",
+		"COPY copy FROM 'copy.csv'",
+		"COPY (SELECT a, COUNT(*) FROM t WHERE a > 1 GROUP BY a ORDER BY a) TO 'out/a.col' WITH (FORMAT columnar)",
+		"COPY (WITH x AS (SELECT a FROM t) SELECT a FROM x UNION SELECT b FROM u) TO 'x.jsonl' WITH (FORMAT jsonl)",
+		`COPY users (id, "to") TO 'users.csv' WITH (HEADER true)`,
+	} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("input %q: expected 1 statement, got %d", input, len(program.Statements))
+		}
+		stmt := program.Statements[0]
+
+		for _, sql := range []string{stmt.String(), ast.Format(stmt)} {
+			p2 := New(lexer.New(sql))
+			again := p2.ParseProgram()
+			checkParserErrors(t, p2)
+			if len(again.Statements) != 1 {
+				t.Fatalf("%q: expected 1 statement, got %d", sql, len(again.Statements))
+			}
+			if !sameTree(reflect.ValueOf(stmt), reflect.ValueOf(again.Statements[0])) {
+				t.Errorf("%q does not parse back to the tree of %q:\n%s", sql, input, p2.formatStatement(again.Statements[0], 0))
+			}
+		}
+	}
+}
+
+// sameTree reports whether two ASTs are equal apart from their tokens,
+// which record where in the source each node was written.
+func sameTree(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return sameTree(a.Elem(), b.Elem())
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !sameTree(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() == reflect.TypeOf(lexer.Token{}) {
+			return true
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !sameTree(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func TestParseExplain(t *testing.T) {
+	p := New(lexer.New("EXPLAIN ANALYZE SELECT a FROM t; explain UPDATE t SET a = 1"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(program.Statements))
+	}
+	analyze := program.Statements[0].(*ast.ExplainStatement)
+	if _, ok := analyze.Statement.(*ast.SelectStatement); !ok || !analyze.Analyze {
+		t.Errorf("unexpected EXPLAIN %s", p.formatStatement(analyze, 0))
+	}
+	plain := program.Statements[1].(*ast.ExplainStatement)
+	if _, ok := plain.Statement.(*ast.UpdateStatement); !ok || plain.Analyze {
+		t.Errorf("unexpected EXPLAIN %s", p.formatStatement(plain, 0))
+	}
+
+	for _, bad := range []string{
+		"EXPLAIN",
+		"EXPLAIN ANALYZE",
+		"EXPLAIN CREATE TABLE t (a INT)",
+		"EXPLAIN EXPLAIN SELECT a FROM t",
+		"EXPLAIN SELECT FROM t",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseShow(t *testing.T) {
+	p := New(lexer.New("show databases; SHOW TABLES; DESCRIBE users; SHOW CREATE TABLE users; SHOW INDEXES; SHOW INDEXES FROM users"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	want := []ast.ShowStatement{
+		{Kind: ast.ShowDatabases},
+		{Kind: ast.ShowTables},
+		{Kind: ast.ShowColumns, Table: "users"},
+		{Kind: ast.ShowCreateTable, Table: "users"},
+		{Kind: ast.ShowIndexes},
+		{Kind: ast.ShowIndexes, Table: "users"},
+	}
+	if len(program.Statements) != len(want) {
+		t.Fatalf("expected %d statements, got %d", len(want), len(program.Statements))
+	}
+	for i, stmt := range program.Statements {
+		show, ok := stmt.(*ast.ShowStatement)
+		if !ok || show.Kind != want[i].Kind || show.Table != want[i].Table {
+			t.Errorf("statement %d: expected %s, got %s", i, want[i].String(), p.formatStatement(stmt, 0))
+		}
+	}
+
+	for _, bad := range []string{
+		"SHOW",
+		"SHOW users",
+		"SHOW CREATE users",
+		"SHOW CREATE TABLE",
+		"SHOW INDEXES FROM",
+		"DESCRIBE",
+		"DESCRIBE 1",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
+
+func TestParseCopy(t *testing.T) {
+	p := New(lexer.New("COPY users (id, name) FROM 'users.csv' WITH (format CSV, header, delimiter '|', null '')"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt, ok := program.Statements[0].(*ast.CopyStatement)
+	if !ok {
+		t.Fatalf("expected a COPY statement, got %T", program.Statements[0])
+	}
+	want := "COPY users (id, name) FROM 'users.csv' WITH (FORMAT csv, HEADER true, DELIMITER '|', NULL '')"
+	if stmt.String() != want {
+		t.Errorf("expected %s, got %s", want, stmt.String())
+	}
+
+	for _, bad := range []string{
+		"COPY",
+		"COPY users",
+		"COPY users 'users.csv'",
+		"COPY users FROM users",
+		"COPY users () FROM 'users.csv'",
+		"COPY users FROM 'users.csv' WITH",
+		"COPY users FROM 'users.csv' WITH ()",
+		"COPY users FROM 'users.csv' WITH (FORMAT xml)",
+		"COPY users FROM 'users.csv' WITH (DELIMITER)",
+		"COPY users FROM 'users.csv' WITH (QUOTE '\"')",
+		"COPY users FROM 'users.csv' WITH (HEADER,)",
+		"COPY users TO",
+		"COPY users INTO 'users.csv'",
+		"COPY (SELECT a FROM t) FROM 'a.csv'",
+		"COPY (SELECT a FROM t TO 'a.csv'",
+		"COPY (DELETE FROM t) TO 'a.csv'",
+		"COPY () TO 'a.csv'",
+	} {
+		p := New(lexer.New(bad))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected a parser error", bad)
+		}
+	}
+}
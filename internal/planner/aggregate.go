@@ -0,0 +1,130 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// aggregateFunctions lists the functions computed by an AggregateNode rather
+// than row by row.
+var aggregateFunctions = map[string]bool{
+	"COUNT":      true,
+	"SUM":        true,
+	"AVG":        true,
+	"MIN":        true,
+	"MAX":        true,
+	"STRING_AGG": true,
+}
+
+// IsAggregate reports whether name is an aggregate function.
+func IsAggregate(name string) bool {
+	return aggregateFunctions[name]
+}
+
+// isAggregateCall reports whether e is an aggregate call computed by an
+// AggregateNode. An aggregate with OVER is a window function instead.
+func isAggregateCall(e ast.Expression) bool {
+	fc, ok := e.(*ast.FunctionCall)
+	return ok && fc.Over == nil && IsAggregate(fc.Name)
+}
+
+// containsAggregate reports whether expr calls an aggregate function anywhere.
+func containsAggregate(expr ast.Expression) bool {
+	found := false
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		if isAggregateCall(e) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// collectAggregates returns the distinct aggregate calls in exprs, in order of
+// first appearance, after checking their arguments.
+func collectAggregates(exprs ...ast.Expression) ([]*ast.FunctionCall, error) {
+	var aggregates []*ast.FunctionCall
+	seen := make(map[string]bool)
+	var err error
+
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(e ast.Expression) bool {
+			if !isAggregateCall(e) || err != nil {
+				return err == nil
+			}
+			fc := e.(*ast.FunctionCall)
+			if err = checkAggregateCall(fc); err != nil {
+				return false
+			}
+			if !seen[fc.String()] {
+				seen[fc.String()] = true
+				aggregates = append(aggregates, fc)
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return aggregates, nil
+}
+
+func checkAggregateCall(fc *ast.FunctionCall) error {
+	want := 1
+	if fc.Name == "STRING_AGG" {
+		want = 2
+	}
+	if len(fc.Args) != want {
+		return fmt.Errorf("%s expects %d argument(s), got %d", fc.Name, want, len(fc.Args))
+	}
+	for _, arg := range fc.Args {
+		// A window aggregate runs after grouping, so it may read aggregates.
+		if fc.Over == nil && containsAggregate(arg) {
+			return fmt.Errorf("aggregate function calls cannot be nested: %s", fc.String())
+		}
+		if containsWindow(arg) {
+			return fmt.Errorf("aggregate function calls cannot contain window function calls: %s", fc.String())
+		}
+	}
+	if _, star := fc.Args[0].(*ast.Star); star && (fc.Name != "COUNT" || fc.Distinct) {
+		return fmt.Errorf("%s(*) is not supported; only COUNT(*) is", fc.Name)
+	}
+	if fc.Name == "STRING_AGG" {
+		if _, ok := fc.Args[1].(*ast.StringLiteral); !ok {
+			return fmt.Errorf("STRING_AGG delimiter must be a string literal, got %s", fc.Args[1].String())
+		}
+	}
+	return nil
+}
+
+// checkGrouped verifies that every column referenced by expr outside of an
+// aggregate is one of the GROUP BY expressions. Columns of an enclosing
+// query are constant within each group and always allowed.
+func checkGrouped(expr ast.Expression, groupBy []ast.Expression, sc *scope) error {
+	var err error
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		if err != nil {
+			return false
+		}
+		for _, g := range groupBy {
+			if e.String() == g.String() {
+				return false
+			}
+		}
+		if isAggregateCall(e) {
+			return false
+		}
+		switch n := e.(type) {
+		case *ast.Identifier:
+			if _, local, _ := sc.resolveLocal(n.Value); !local && sc.outer != nil {
+				return false
+			}
+			err = fmt.Errorf("column %s must appear in the GROUP BY clause or be used in an aggregate function", n.Value)
+		case *ast.Star:
+			err = fmt.Errorf("SELECT * cannot be used with GROUP BY or aggregate functions")
+		}
+		return err == nil
+	})
+	return err
+}
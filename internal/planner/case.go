@@ -0,0 +1,64 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// Case is a type-checked CASE expression. The planner substitutes it for
+// the AST node in the expressions of plan nodes, so the executor can
+// convert every branch's value to the type the branches unify to.
+type Case struct {
+	Expr *ast.CaseExpression
+	Type Type
+}
+
+func (c *Case) ExpressionNode()      {}
+func (c *Case) TokenLiteral() string { return c.Expr.TokenLiteral() }
+func (c *Case) String() string       { return c.Expr.String() }
+
+// typeOfCase checks the WHEN clauses of a CASE, which are conditions or,
+// after an operand, values compared with it, and unifies the types of its
+// results.
+func (p *Planner) typeOfCase(n *ast.CaseExpression, sc *scope) (Type, error) {
+	operand := TypeUnknown
+	if n.Operand != nil {
+		var err error
+		if operand, err = p.typeOf(n.Operand, sc); err != nil {
+			return TypeUnknown, err
+		}
+	}
+
+	results := make([]Type, 0, len(n.Whens)+1)
+	for _, w := range n.Whens {
+		t, err := p.typeOf(w.Condition, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		if n.Operand == nil {
+			if err := expectBool("CASE/WHEN", t); err != nil {
+				return TypeUnknown, err
+			}
+		} else if (operand == TypeBool) != (t == TypeBool) && operand != TypeUnknown && t != TypeUnknown {
+			return TypeUnknown, fmt.Errorf("cannot compare %s with %s: %s", operand, t, n.String())
+		}
+		if t, err = p.typeOf(w.Result, sc); err != nil {
+			return TypeUnknown, err
+		}
+		results = append(results, t)
+	}
+	if n.Else != nil {
+		t, err := p.typeOf(n.Else, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		results = append(results, t)
+	}
+
+	t, err := UnifyTypes(results...)
+	if err != nil {
+		return TypeUnknown, fmt.Errorf("CASE %v", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// CopyOptions are the WITH options of a COPY, resolved to their values
+// with defaults filled in.
+type CopyOptions struct {
+	Format    string // "csv", "jsonl" or "columnar"
+	Header    bool   // csv: the first line names the columns
+	Delimiter rune   // csv: the field separator
+	Null      string // csv: the field text that stands for NULL
+}
+
+// CopyNode bulk-loads the rows of a file into a table. Columns lists the
+// target columns when they were named; the rest are NULL.
+type CopyNode struct {
+	TableName string
+	Columns   []string
+	File      string
+	CopyOptions
+}
+
+func (n *CopyNode) PlanNode() {}
+
+// CopyToNode writes the result of Query to a file. Types are the types of
+// the result columns.
+type CopyToNode struct {
+	Query PlanNode
+	Types []Type
+	File  string
+	CopyOptions
+}
+
+func (n *CopyToNode) PlanNode() {}
+
+func (p *Planner) planCopy(s *ast.CopyStatement) (PlanNode, error) {
+	opts, err := copyOptions(s.Options)
+	if err != nil {
+		return nil, err
+	}
+	if s.To {
+		return p.planCopyTo(s, opts)
+	}
+	if opts.Format == "columnar" {
+		return nil, fmt.Errorf("COPY FROM does not read FORMAT columnar")
+	}
+
+	schema, ok := p.catalog.TableSchema(s.Table)
+	if !ok {
+		return nil, fmt.Errorf("table not found: %s", s.Table)
+	}
+	for i, name := range s.Columns {
+		found := false
+		for _, col := range schema.Columns {
+			found = found || col.Name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("column not found: %s", name)
+		}
+		for _, prev := range s.Columns[:i] {
+			if prev == name {
+				return nil, fmt.Errorf("column %s specified more than once", name)
+			}
+		}
+	}
+	return &CopyNode{TableName: s.Table, Columns: s.Columns, File: s.File, CopyOptions: opts}, nil
+}
+
+// planCopyTo plans the query of a COPY TO. COPY table [(cols)] TO reads
+// like SELECT cols FROM table.
+func (p *Planner) planCopyTo(s *ast.CopyStatement, opts CopyOptions) (PlanNode, error) {
+	query := s.Query
+	if query == nil {
+		sel := &ast.SelectStatement{Table: s.Table, Columns: []ast.Expression{&ast.Star{}}}
+		if len(s.Columns) > 0 {
+			sel.Columns = make([]ast.Expression, len(s.Columns))
+			for i, name := range s.Columns {
+				sel.Columns[i] = &ast.Identifier{Value: name}
+			}
+		}
+		query = sel
+	}
+	q, err := p.planAnyQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return &CopyToNode{Query: q.plan, Types: q.types, File: s.File, CopyOptions: opts}, nil
+}
+
+// copyOptions resolves the WITH list of a COPY. HEADER, DELIMITER and NULL
+// only apply to csv.
+func copyOptions(options []ast.CopyOption) (CopyOptions, error) {
+	opts := CopyOptions{Format: "csv", Delimiter: ','}
+	seen := make(map[string]bool)
+	for _, opt := range options {
+		if seen[opt.Name] {
+			return opts, fmt.Errorf("COPY option %s given more than once", opt.Name)
+		}
+		seen[opt.Name] = true
+		switch opt.Name {
+		case "FORMAT":
+			opts.Format = opt.Value
+		case "HEADER":
+			opts.Header = opt.Value == "true"
+		case "DELIMITER":
+			r, size := utf8.DecodeRuneInString(opt.Value)
+			if size == 0 || size != len(opt.Value) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+				return opts, fmt.Errorf("COPY delimiter must be a single character other than a quote or newline, got '%s'", opt.Value)
+			}
+			opts.Delimiter = r
+		case "NULL":
+			opts.Null = opt.Value
+		}
+	}
+	if opts.Format != "csv" {
+		for _, name := range []string{"HEADER", "DELIMITER", "NULL"} {
+			if seen[name] {
+				return opts, fmt.Errorf("COPY option %s is only allowed with FORMAT csv", name)
+			}
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,45 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// planTargetJoin plans the rows an UPDATE ... FROM or DELETE ... USING acts
+// on: the target table joined with the other tables on the WHERE
+// condition, so that a condition equating their columns runs as a hash or
+// merge join. It returns the join and the scope of all its tables.
+func (p *Planner) planTargetJoin(table string, from *ast.FromClause, where *ast.WhereClause) (*JoinNode, *scope, error) {
+	target := &ScanNode{TableName: table}
+	right, scans, err := p.planFrom(&ast.SelectStatement{Table: from.Table, Alias: from.Alias, Joins: from.Joins}, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetScope, err := p.tableScope(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc, err := p.tableScope(append([]*ScanNode{target}, scans...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := &JoinNode{Left: target, Right: right, Kind: ast.CrossJoin}
+	if where != nil {
+		if containsAggregate(where.Condition) {
+			return nil, nil, fmt.Errorf("aggregate functions are not allowed in WHERE")
+		}
+		if err := p.checkWhere(where, sc); err != nil {
+			return nil, nil, err
+		}
+		b := &binder{p: p, sc: sc}
+		n.Kind = ast.InnerJoin
+		if n.Condition = b.expr(where.Condition); b.err != nil {
+			return nil, nil, b.err
+		}
+		n.LeftKeys, n.RightKeys = p.equiJoinKeys(n.Condition, targetScope, sc)
+	}
+	n.Method = p.chooseJoinMethod(n)
+	return n, sc, nil
+}
@@ -0,0 +1,314 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// ExplainNode shows the plan of a statement instead of its result. With
+// Analyze the executor also runs Plan and reports what every node did.
+// Estimates holds the planner's row estimate for each node of the plan.
+type ExplainNode struct {
+	Plan      PlanNode
+	Analyze   bool
+	Estimates map[PlanNode]int
+}
+
+func (n *ExplainNode) PlanNode() {}
+
+// NodeInput is one input of a plan node as EXPLAIN shows it. Label is set
+// for inputs that are not the node's plain children: the queries of a
+// WITH, and subqueries run by the node's expressions.
+type NodeInput struct {
+	Label string
+	Plan  PlanNode
+}
+
+func (p *Planner) planExplain(s *ast.ExplainStatement) (PlanNode, error) {
+	plan, err := p.GeneratePlan(s.Statement)
+	if err != nil {
+		return nil, err
+	}
+	n := &ExplainNode{Plan: plan, Analyze: s.Analyze, Estimates: make(map[PlanNode]int)}
+	var estimate func(node PlanNode)
+	estimate = func(node PlanNode) {
+		n.Estimates[node] = p.estimateRows(node)
+		for _, in := range NodeInputs(node) {
+			estimate(in.Plan)
+		}
+	}
+	estimate(plan)
+	return n, nil
+}
+
+// NodeInputs lists the plans whose rows node reads, in the order the
+// executor runs them.
+func NodeInputs(node PlanNode) []NodeInput {
+	var inputs []NodeInput
+	switch n := node.(type) {
+	case *FilterNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *ProjectNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *AggregateNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *WindowNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *SortNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *DistinctNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *LimitNode:
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *JoinNode:
+		inputs = append(inputs, NodeInput{Plan: n.Left}, NodeInput{Plan: n.Right})
+	case *SetOpNode:
+		inputs = append(inputs, NodeInput{Plan: n.Left}, NodeInput{Plan: n.Right})
+	case *WithNode:
+		for _, cte := range n.CTEs {
+			inputs = append(inputs, NodeInput{Label: "CTE " + cte.Name, Plan: cte.Plan})
+			if cte.Recursive != nil {
+				inputs = append(inputs, NodeInput{Label: "Recursive CTE " + cte.Name, Plan: cte.Recursive})
+			}
+		}
+		inputs = append(inputs, NodeInput{Plan: n.Child})
+	case *InsertNode:
+		if n.Query != nil {
+			inputs = append(inputs, NodeInput{Plan: n.Query})
+		}
+	case *UpdateNode:
+		if n.From != nil {
+			inputs = append(inputs, NodeInput{Plan: n.From})
+		}
+	case *DeleteNode:
+		if n.Using != nil {
+			inputs = append(inputs, NodeInput{Plan: n.Using})
+		}
+	}
+
+	for _, sq := range subqueriesIn(nodeExpressions(node)...) {
+		label := "SubPlan: "
+		switch {
+		case sq.Kind == ExistsSubquery:
+			label += "EXISTS"
+		case sq.Kind == InSubquery && sq.Not:
+			label += "NOT IN"
+		case sq.Kind == InSubquery:
+			label += "IN"
+		default:
+			label += "scalar"
+		}
+		if len(sq.OuterRefs) > 0 {
+			label += ", correlated"
+		}
+		inputs = append(inputs, NodeInput{Label: label, Plan: sq.Plan})
+	}
+	return inputs
+}
+
+// DescribeNode renders a plan node for EXPLAIN: its kind, the table it
+// reads or writes, and the expressions it evaluates.
+func DescribeNode(node PlanNode) string {
+	switch n := node.(type) {
+	case *ScanNode:
+		s := "Seq Scan on " + n.TableName
+		if n.CTE != nil {
+			s = "CTE Scan on " + n.TableName
+		}
+		if n.Alias != "" {
+			s += " " + n.Alias
+		}
+		return s
+	case *FilterNode:
+		return "Filter: " + n.Condition.String()
+	case *ProjectNode:
+		return "Project: " + joinExprs(n.Columns)
+	case *JoinNode:
+		kind := strings.TrimSuffix(n.Kind.String(), " JOIN")
+		if n.Kind == ast.InnerJoin {
+			kind = "INNER"
+		}
+		s := n.Method.String() + ": " + kind
+		if n.Condition != nil {
+			s += " ON " + n.Condition.String()
+		}
+		return s
+	case *AggregateNode:
+		var parts []string
+		if len(n.Aggregates) > 0 {
+			calls := make([]ast.Expression, len(n.Aggregates))
+			for i, call := range n.Aggregates {
+				calls[i] = call
+			}
+			parts = append(parts, joinExprs(calls))
+		}
+		if len(n.GroupBy) > 0 {
+			parts = append(parts, "GROUP BY "+joinExprs(n.GroupBy))
+		}
+		if n.Having != nil {
+			parts = append(parts, "HAVING "+n.Having.String())
+		}
+		return "Aggregate: " + strings.Join(parts, " ")
+	case *WindowNode:
+		calls := make([]ast.Expression, len(n.Windows))
+		for i, call := range n.Windows {
+			calls[i] = call
+		}
+		return "Window: " + joinExprs(calls)
+	case *SortNode:
+		keys := make([]string, len(n.Keys))
+		for i, k := range n.Keys {
+			keys[i] = k.String()
+		}
+		return "Sort: " + strings.Join(keys, ", ")
+	case *DistinctNode:
+		if len(n.On) > 0 {
+			return "Distinct: ON (" + joinExprs(n.On) + ")"
+		}
+		return "Distinct"
+	case *LimitNode:
+		var parts []string
+		if n.Limit != nil {
+			parts = append(parts, "LIMIT "+n.Limit.String())
+		}
+		if n.Offset != nil {
+			parts = append(parts, "OFFSET "+n.Offset.String())
+		}
+		return "Limit: " + strings.Join(parts, " ")
+	case *SetOpNode:
+		op := n.Op.String()
+		if n.All {
+			op += " ALL"
+		}
+		return "Set Operation: " + op
+	case *WithNode:
+		return "With"
+	case *InsertNode:
+		s := "Insert on " + n.TableName
+		if n.Query == nil {
+			s += fmt.Sprintf(": %d VALUES rows", len(n.Rows))
+		}
+		if n.OnConflict != nil {
+			s += " ON CONFLICT"
+			if n.OnConflict.Column != "" {
+				s += " (" + n.OnConflict.Column + ")"
+			}
+			if n.OnConflict.DoUpdate {
+				s += " DO UPDATE SET " + joinSets(n.OnConflict.Sets)
+			} else {
+				s += " DO NOTHING"
+			}
+		}
+		return s + describeReturning(n.Returning)
+	case *UpdateNode:
+		s := "Update on " + n.TableName + ": SET " + joinSets(n.Sets)
+		if n.Where != nil {
+			s += " " + n.Where.String()
+		}
+		return s + describeReturning(n.Returning)
+	case *DeleteNode:
+		s := "Delete on " + n.TableName
+		if n.Where != nil {
+			s += ": " + n.Where.String()
+		}
+		return s + describeReturning(n.Returning)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func describeReturning(returning []ast.Expression) string {
+	if len(returning) == 0 {
+		return ""
+	}
+	return " RETURNING " + joinExprs(returning)
+}
+
+func joinExprs(exprs []ast.Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinSets(sets []ast.SetClause) string {
+	parts := make([]string, len(sets))
+	for i, set := range sets {
+		parts[i] = set.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// nodeExpressions lists the expressions a plan node evaluates itself, not
+// those of its inputs.
+func nodeExpressions(node PlanNode) []ast.Expression {
+	var exprs []ast.Expression
+	switch n := node.(type) {
+	case *FilterNode:
+		exprs = append(exprs, n.Condition)
+	case *ProjectNode:
+		exprs = append(exprs, n.Columns...)
+	case *JoinNode:
+		exprs = append(exprs, n.Condition)
+	case *AggregateNode:
+		exprs = append(exprs, n.GroupBy...)
+		for _, call := range n.Aggregates {
+			exprs = append(exprs, call)
+		}
+		exprs = append(exprs, n.Having)
+	case *WindowNode:
+		for _, call := range n.Windows {
+			exprs = append(exprs, call)
+		}
+	case *SortNode:
+		for _, k := range n.Keys {
+			exprs = append(exprs, k.Expr)
+		}
+	case *DistinctNode:
+		exprs = append(exprs, n.On...)
+	case *InsertNode:
+		if n.OnConflict != nil {
+			for _, set := range n.OnConflict.Sets {
+				exprs = append(exprs, set.Value)
+			}
+		}
+		exprs = append(exprs, n.Returning...)
+	case *UpdateNode:
+		for _, set := range n.Sets {
+			exprs = append(exprs, set.Value)
+		}
+		if n.Where != nil {
+			exprs = append(exprs, n.Where.Condition)
+		}
+		exprs = append(exprs, n.Returning...)
+	case *DeleteNode:
+		if n.Where != nil {
+			exprs = append(exprs, n.Where.Condition)
+		}
+		exprs = append(exprs, n.Returning...)
+	}
+	return exprs
+}
+
+// subqueriesIn finds the planned subqueries in exprs, outermost first.
+func subqueriesIn(exprs ...ast.Expression) []*Subquery {
+	var found []*Subquery
+	var visit func(e ast.Expression) bool
+	visit = func(e ast.Expression) bool {
+		switch n := e.(type) {
+		case *Subquery:
+			found = append(found, n)
+			ast.Inspect(n.Left, visit)
+		case *Case:
+			ast.Inspect(n.Expr, visit)
+			return false
+		}
+		return true
+	}
+	for _, e := range exprs {
+		ast.Inspect(e, visit)
+	}
+	return found
+}
@@ -0,0 +1,216 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// planInsert checks that every row of an INSERT, or its query, supplies one
+// value per target column.
+func (p *Planner) planInsert(s *ast.InsertStatement) (PlanNode, error) {
+	schema, ok := p.catalog.TableSchema(s.Table)
+	if !ok {
+		return nil, fmt.Errorf("table not found: %s", s.Table)
+	}
+	width := len(schema.Columns)
+	if len(s.Columns) > 0 {
+		for i, name := range s.Columns {
+			found := false
+			for _, c := range schema.Columns {
+				found = found || c.Name == name
+			}
+			if !found {
+				return nil, fmt.Errorf("column not found: %s", name)
+			}
+			for _, prev := range s.Columns[:i] {
+				if prev == name {
+					return nil, fmt.Errorf("column %s specified more than once", name)
+				}
+			}
+		}
+		width = len(s.Columns)
+	}
+
+	node := &InsertNode{TableName: s.Table, Columns: s.Columns, Rows: s.Rows, Params: s.Params, Nulls: s.Nulls}
+	var err error
+	if s.OnConflict != nil {
+		if node.OnConflict, err = p.planOnConflict(s.Table, s.OnConflict); err != nil {
+			return nil, err
+		}
+	}
+	if node.Returning, err = p.planReturning(s.Table, s.Returning, nil); err != nil {
+		return nil, err
+	}
+	if s.Query == nil {
+		for i, row := range s.Rows {
+			if len(row) != width {
+				return nil, fmt.Errorf("column count (%d) does not match value count (%d)", width, len(row))
+			}
+			if err := p.inferValuesParams(s, schema, i); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	}
+
+	q, err := p.planAnyQuery(s.Query)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.names) != width {
+		return nil, fmt.Errorf("column count (%d) does not match value count (%d)", width, len(q.names))
+	}
+	node.Query = q.plan
+	return node, nil
+}
+
+// inferValuesParams gives the bind parameters of the i-th VALUES row the
+// types of their target columns.
+func (p *Planner) inferValuesParams(s *ast.InsertStatement, schema *storage.Schema, i int) error {
+	if s.Params == nil {
+		return nil
+	}
+	for j, index := range s.Params[i] {
+		if index == 0 {
+			continue
+		}
+		param := &ast.Parameter{Index: index}
+		if index > len(p.params) {
+			return fmt.Errorf("bind parameter %s is only allowed in PREPARE", param)
+		}
+		col := schema.Columns[j]
+		if len(s.Columns) > 0 {
+			for _, c := range schema.Columns {
+				if c.Name == s.Columns[j] {
+					col = c
+				}
+			}
+		}
+		if _, err := p.inferParam(param, TypeUnknown, ColumnType(col)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// excludedTable is the name ON CONFLICT DO UPDATE gives the proposed row.
+const excludedTable = "excluded"
+
+// OnConflict is the resolved ON CONFLICT clause of an INSERT. A proposed
+// row conflicts when its value for Column, or for any UNIQUE or PRIMARY KEY
+// column when Column is empty, is already taken. Such rows are skipped, or,
+// with DoUpdate, the existing row they clash with is updated with Sets.
+type OnConflict struct {
+	Column   string
+	DoUpdate bool
+	// Sets are bound with every column reference qualified: "t.col" reads
+	// the existing row and "excluded.col" the proposed one.
+	Sets []ast.SetClause
+}
+
+// planOnConflict resolves the conflict target against the table's UNIQUE
+// and PRIMARY KEY columns and type-checks the DO UPDATE assignments.
+func (p *Planner) planOnConflict(table string, c *ast.OnConflictClause) (*OnConflict, error) {
+	schema, _ := p.catalog.TableSchema(table)
+	oc := &OnConflict{DoUpdate: c.DoUpdate}
+	switch {
+	case len(c.Columns) > 1:
+		return nil, fmt.Errorf("ON CONFLICT supports a single conflict column, got (%s)", strings.Join(c.Columns, ", "))
+	case len(c.Columns) == 1:
+		oc.Column = c.Columns[0]
+		found := false
+		for _, col := range schema.Columns {
+			if col.Name != oc.Column {
+				continue
+			}
+			found = true
+			if !col.IsUnique && !col.IsPrimaryKey {
+				return nil, fmt.Errorf("ON CONFLICT column %s is not a UNIQUE or PRIMARY KEY column", oc.Column)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column not found: %s", oc.Column)
+		}
+	case c.DoUpdate:
+		return nil, fmt.Errorf("ON CONFLICT DO UPDATE requires a conflict column")
+	}
+	if !c.DoUpdate {
+		return oc, nil
+	}
+
+	sc, err := p.tableScope(&ScanNode{TableName: table}, &ScanNode{TableName: table, Alias: excludedTable})
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]ast.SetClause, len(c.Sets))
+	for i, set := range c.Sets {
+		sets[i] = ast.SetClause{Column: set.Column, Value: qualifyConflictColumns(set.Value, table)}
+	}
+	if oc.Sets, err = p.planSets(table, sets, &binder{p: p, sc: sc}); err != nil {
+		return nil, err
+	}
+	return oc, nil
+}
+
+// qualifyConflictColumns qualifies the bare column names of a DO UPDATE
+// assignment with the table, so that they read the existing row rather
+// than clash with the proposed one, and spells EXCLUDED in lower case.
+func qualifyConflictColumns(expr ast.Expression, table string) ast.Expression {
+	return ast.Rewrite(expr, func(e ast.Expression) ast.Expression {
+		id, ok := e.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		c := *id
+		qualifier, name, qualified := strings.Cut(id.Value, ".")
+		switch {
+		case !qualified:
+			c.Value = table + "." + id.Value
+		case strings.EqualFold(qualifier, excludedTable):
+			c.Value = excludedTable + "." + name
+		}
+		return &c
+	})
+}
+
+// planSets type-checks the assignments of an UPDATE or ON CONFLICT DO
+// UPDATE against b's scope and binds them. Each must name a column of the
+// table, at most once.
+func (p *Planner) planSets(table string, sets []ast.SetClause, b *binder) ([]ast.SetClause, error) {
+	schema, ok := p.catalog.TableSchema(table)
+	if !ok {
+		return nil, fmt.Errorf("table not found: %s", table)
+	}
+	bound := make([]ast.SetClause, len(sets))
+	for i, set := range sets {
+		col := -1
+		for j, c := range schema.Columns {
+			if c.Name == set.Column {
+				col = j
+			}
+		}
+		if col == -1 {
+			return nil, fmt.Errorf("column not found in SET: %s", set.Column)
+		}
+		for _, prev := range sets[:i] {
+			if prev.Column == set.Column {
+				return nil, fmt.Errorf("column %s assigned more than once in SET", set.Column)
+			}
+		}
+		t, err := p.typeOf(set.Value, b.sc)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.inferParam(set.Value, t, ColumnType(schema.Columns[col])); err != nil {
+			return nil, err
+		}
+		bound[i] = ast.SetClause{Column: set.Column, Value: b.expr(set.Value)}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return bound, nil
+}
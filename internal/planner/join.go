@@ -0,0 +1,319 @@
+package planner
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// JoinMethod is the algorithm the executor uses for a JoinNode.
+type JoinMethod int
+
+const (
+	NestedLoopJoin JoinMethod = iota
+	HashJoin
+	MergeJoin
+)
+
+func (m JoinMethod) String() string {
+	switch m {
+	case HashJoin:
+		return "Hash Join"
+	case MergeJoin:
+		return "Merge Join"
+	}
+	return "Nested Loop"
+}
+
+var (
+	// nestedLoopMaxPairs is the largest estimated number of row pairs that
+	// is still joined by nested loop; below it a hash table is not worth
+	// building.
+	nestedLoopMaxPairs = 1 << 12
+	// hashJoinMaxBuildRows is the largest estimated build (right) side for
+	// a hash join, whose hash table holds the whole build side in memory.
+	// Past it both inputs are sorted and merged instead; the executor
+	// spills sorted runs of inputs that large to disk.
+	hashJoinMaxBuildRows = 1 << 16
+)
+
+// planFrom builds the join tree of the FROM clause and returns it with the
+// scans it reads, in FROM order. Joins nest to the left, and an ON
+// condition sees only the tables joined so far.
+func (p *Planner) planFrom(s *ast.SelectStatement, outer *scope, refs *[]string) (PlanNode, []*ScanNode, error) {
+	first, err := p.scan(s, s.Table, s.Alias)
+	if err != nil {
+		return nil, nil, err
+	}
+	scans := []*ScanNode{first}
+	var node PlanNode = first
+	for _, join := range s.Joins {
+		right, err := p.scan(s, join.Table, join.Alias)
+		if err != nil {
+			return nil, nil, err
+		}
+		leftScope, err := p.tableScope(scans...)
+		if err != nil {
+			return nil, nil, err
+		}
+		scans = append(scans, right)
+		sc, err := p.tableScope(scans...)
+		if err != nil {
+			return nil, nil, err
+		}
+		sc.correlate(outer, refs)
+
+		n := &JoinNode{Left: node, Right: right, Kind: join.Kind}
+		if join.On != nil {
+			if containsAggregate(join.On) {
+				return nil, nil, fmt.Errorf("aggregate functions are not allowed in JOIN conditions")
+			}
+			if err := p.checkCondition("JOIN/ON", join.On, sc); err != nil {
+				return nil, nil, err
+			}
+			b := &binder{p: p, sc: sc}
+			if n.Condition = b.expr(join.On); b.err != nil {
+				return nil, nil, b.err
+			}
+			n.LeftKeys, n.RightKeys = p.equiJoinKeys(n.Condition, leftScope, sc)
+		}
+		n.Method = p.chooseJoinMethod(n)
+		node = n
+	}
+	return node, scans, nil
+}
+
+// equiJoinKeys picks out the conjuncts of cond of the form l = r, where l
+// reads only tables in leftScope and r only the newly joined table (or the
+// other way round), and returns their two sides. Keys whose types would
+// need coercion to compare, like text against numbers, are left to the
+// nested loop, since hashing and sorting compare them differently.
+func (p *Planner) equiJoinKeys(cond ast.Expression, leftScope, sc *scope) (left, right []ast.Expression) {
+	for _, c := range conjuncts(cond) {
+		eq, ok := c.(*ast.InfixExpression)
+		if !ok || eq.Operator != "=" {
+			continue
+		}
+		l, r := eq.Left, eq.Right
+		lSide, lok := joinSide(l, leftScope, sc)
+		rSide, rok := joinSide(r, leftScope, sc)
+		if !lok || !rok || lSide == rSide {
+			continue
+		}
+		if lSide == sideRight {
+			l, r = r, l
+		}
+		lt, _ := p.typeOf(l, sc)
+		rt, _ := p.typeOf(r, sc)
+		if lt == TypeUnknown || rt == TypeUnknown || !hashComparable(lt, rt) {
+			continue
+		}
+		left = append(left, l)
+		right = append(right, r)
+	}
+	return left, right
+}
+
+const (
+	sideLeft = iota
+	sideRight
+)
+
+// joinSide reports which input of a join expr reads. It fails for
+// expressions that read both inputs, neither, or an enclosing query.
+func joinSide(expr ast.Expression, leftScope, sc *scope) (int, bool) {
+	var names []string
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		if id, ok := e.(*ast.Identifier); ok {
+			names = append(names, id.Value)
+		}
+		return true
+	})
+
+	side := -1
+	for _, name := range names {
+		col, found, err := sc.resolveLocal(name)
+		if err != nil || !found {
+			return 0, false
+		}
+		s := sideRight
+		for _, t := range leftScope.tables {
+			if t == col.table {
+				s = sideLeft
+			}
+		}
+		if side != -1 && s != side {
+			return 0, false
+		}
+		side = s
+	}
+	return side, side != -1
+}
+
+// conjuncts splits a condition on its top-level ANDs.
+func conjuncts(expr ast.Expression) []ast.Expression {
+	if and, ok := expr.(*ast.InfixExpression); ok && and.Operator == "AND" {
+		return append(conjuncts(and.Left), conjuncts(and.Right)...)
+	}
+	return []ast.Expression{expr}
+}
+
+// chooseJoinMethod picks a join algorithm from the estimated input sizes.
+// Tables have no indexes yet, so an index nested loop is never an option.
+func (p *Planner) chooseJoinMethod(n *JoinNode) JoinMethod {
+	if len(n.LeftKeys) == 0 {
+		return NestedLoopJoin
+	}
+	left, right := p.estimateRows(n.Left), p.estimateRows(n.Right)
+	switch {
+	case left*right <= nestedLoopMaxPairs:
+		return NestedLoopJoin
+	case right <= hashJoinMaxBuildRows:
+		return HashJoin
+	}
+	return MergeJoin
+}
+
+// estimateRows guesses how many rows a plan node produces, from the row
+// counts of the tables it reads.
+func (p *Planner) estimateRows(node PlanNode) int {
+	switch n := node.(type) {
+	case *ScanNode:
+		if n.CTE != nil {
+			return p.estimateRows(n.CTE.Plan)
+		}
+		return p.catalog.TableRows(n.TableName)
+	case *JoinNode:
+		return p.estimateJoin(n)
+	case *FilterNode:
+		return filterRows(p.estimateRows(n.Child), n.Condition)
+	case *AggregateNode:
+		rows := 1
+		if len(n.GroupBy) > 0 {
+			rows = p.estimateRows(n.Child)
+		}
+		return filterRows(rows, n.Having)
+	case *ProjectNode:
+		return p.estimateRows(n.Child)
+	case *SortNode:
+		return p.estimateRows(n.Child)
+	case *DistinctNode:
+		return p.estimateRows(n.Child)
+	case *WithNode:
+		return p.estimateRows(n.Child)
+	case *LimitNode:
+		// Only constants are known at plan time.
+		rows := p.estimateRows(n.Child)
+		if offset, ok := n.Offset.(*ast.IntegerLiteral); ok {
+			rows = max(rows-int(offset.Value), 0)
+		}
+		if limit, ok := n.Limit.(*ast.IntegerLiteral); ok {
+			rows = min(rows, int(max(limit.Value, 0)))
+		}
+		return rows
+	case *WindowNode:
+		return p.estimateRows(n.Child)
+	case *SetOpNode:
+		return p.estimateRows(n.Left) + p.estimateRows(n.Right)
+	case *InsertNode:
+		if n.Query != nil {
+			return p.estimateRows(n.Query)
+		}
+		return len(n.Rows)
+	case *UpdateNode:
+		if n.From != nil {
+			return p.estimateRows(n.From)
+		}
+		return filterRows(p.catalog.TableRows(n.TableName), whereCondition(n.Where))
+	case *DeleteNode:
+		if n.Using != nil {
+			return p.estimateRows(n.Using)
+		}
+		return filterRows(p.catalog.TableRows(n.TableName), whereCondition(n.Where))
+	}
+	return 0
+}
+
+// estimateJoin guesses the output of a join. An equi-join is taken to
+// join a foreign key to the key it references, so that every row of the
+// larger input finds one match; other conditions are filters over all
+// pairs. Outer joins keep at least the rows of their preserved inputs.
+func (p *Planner) estimateJoin(n *JoinNode) int {
+	left, right := p.estimateRows(n.Left), p.estimateRows(n.Right)
+	rows := left * right
+	if len(n.LeftKeys) > 0 {
+		rows = max(left, right)
+		for _, c := range conjuncts(n.Condition) {
+			if !isJoinKey(c, n) {
+				rows = filterRows(rows, c)
+			}
+		}
+	} else if n.Condition != nil {
+		rows = filterRows(rows, n.Condition)
+	}
+
+	switch n.Kind {
+	case ast.LeftJoin:
+		return max(rows, left)
+	case ast.RightJoin:
+		return max(rows, right)
+	case ast.FullJoin:
+		return max(rows, left, right)
+	case ast.SemiJoin:
+		return min(rows, left)
+	case ast.AntiJoin:
+		// The left rows the semi join would drop, but at least one.
+		return max(left-min(rows, left), min(left, 1))
+	}
+	return rows
+}
+
+// isJoinKey reports whether cond is one of the equalities n joins on.
+func isJoinKey(cond ast.Expression, n *JoinNode) bool {
+	eq, ok := cond.(*ast.InfixExpression)
+	if !ok || eq.Operator != "=" {
+		return false
+	}
+	for i := range n.LeftKeys {
+		if (eq.Left == n.LeftKeys[i] && eq.Right == n.RightKeys[i]) || (eq.Left == n.RightKeys[i] && eq.Right == n.LeftKeys[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// equalitySelectivity and defaultSelectivity are the fractions of rows
+	// an equality and any other condition are guessed to keep, for want of
+	// column statistics.
+	equalitySelectivity = 0.1
+	defaultSelectivity  = 1.0 / 3
+)
+
+// filterRows guesses how many of rows pass cond, applying the selectivity
+// of each of its conjuncts in turn. Some rows of a non-empty input are
+// always taken to pass.
+func filterRows(rows int, cond ast.Expression) int {
+	if cond == nil || rows == 0 {
+		return rows
+	}
+	est := float64(rows)
+	for _, c := range conjuncts(cond) {
+		if eq, ok := c.(*ast.InfixExpression); ok && eq.Operator == "=" {
+			est *= equalitySelectivity
+		} else {
+			est *= defaultSelectivity
+		}
+	}
+	return max(int(math.Round(est)), 1)
+}
+
+// whereCondition returns the condition of a WHERE clause, or nil if there
+// is none.
+func whereCondition(where *ast.WhereClause) ast.Expression {
+	if where == nil {
+		return nil
+	}
+	return where.Condition
+}
@@ -0,0 +1,126 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+	"github.com/Mohammad-y-abbass/moDB/internal/parser"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// fakeCatalog serves fixed schemas and row estimates.
+type fakeCatalog struct {
+	schemas map[string]*storage.Schema
+	rows    map[string]int
+}
+
+func (c *fakeCatalog) TableSchema(name string) (*storage.Schema, bool) {
+	s, ok := c.schemas[name]
+	return s, ok
+}
+
+func (c *fakeCatalog) FunctionType(name string, args []Type) (Type, error) {
+	return TypeUnknown, nil
+}
+
+func (c *fakeCatalog) TableRows(name string) int { return c.rows[name] }
+
+func findJoin(node PlanNode) *JoinNode {
+	switch n := node.(type) {
+	case *JoinNode:
+		return n
+	case *ProjectNode:
+		return findJoin(n.Child)
+	case *FilterNode:
+		return findJoin(n.Child)
+	}
+	return nil
+}
+
+func TestChooseJoinMethod(t *testing.T) {
+	cols := func() []storage.Column {
+		return []storage.Column{
+			{Name: "id", Type: storage.TypeInt32},
+			{Name: "name", Type: storage.TypeFixedText, Size: 32},
+		}
+	}
+	catalog := &fakeCatalog{
+		schemas: map[string]*storage.Schema{
+			"tiny": storage.NewSchema(cols()), "mid": storage.NewSchema(cols()), "huge": storage.NewSchema(cols()),
+		},
+		rows: map[string]int{"tiny": 10, "mid": 10000, "huge": 1000000},
+	}
+
+	tests := []struct {
+		sql    string
+		method JoinMethod
+		keys   int
+	}{
+		{"SELECT * FROM tiny a JOIN tiny b ON a.id = b.id", NestedLoopJoin, 1},
+		{"SELECT * FROM huge JOIN mid ON mid.id = huge.id AND huge.name = mid.name", HashJoin, 2},
+		{"SELECT * FROM mid JOIN huge ON mid.id = huge.id", MergeJoin, 1},
+		{"SELECT * FROM huge JOIN mid ON mid.id < huge.id", NestedLoopJoin, 0},
+		{"SELECT * FROM huge LEFT JOIN mid ON mid.id = huge.id OR mid.name = huge.name", NestedLoopJoin, 0},
+		// Text against a number compares with coercion, so it is not a hash key.
+		{"SELECT * FROM huge JOIN mid ON mid.name = huge.id AND mid.id = huge.id", HashJoin, 1},
+		{"SELECT * FROM huge JOIN mid ON mid.id = mid.id", NestedLoopJoin, 0},
+		{"SELECT * FROM huge CROSS JOIN mid", NestedLoopJoin, 0},
+	}
+	for _, tt := range tests {
+		stmt := parser.New(lexer.New(tt.sql)).ParseProgram().Statements[0].(*ast.SelectStatement)
+		plan, err := New(catalog).GeneratePlan(stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		join := findJoin(plan)
+		if join == nil {
+			t.Fatalf("%s: no join in plan", tt.sql)
+		}
+		if join.Method != tt.method || len(join.LeftKeys) != tt.keys {
+			t.Errorf("%s: expected %s with %d keys, got %s with %d keys", tt.sql, tt.method, tt.keys, join.Method, len(join.LeftKeys))
+		}
+	}
+}
+
+func TestEstimateRows(t *testing.T) {
+	cols := []storage.Column{
+		{Name: "id", Type: storage.TypeInt32},
+		{Name: "name", Type: storage.TypeFixedText, Size: 32},
+	}
+	catalog := &fakeCatalog{
+		schemas: map[string]*storage.Schema{"one": storage.NewSchema(cols), "mid": storage.NewSchema(cols), "big": storage.NewSchema(cols)},
+		rows:    map[string]int{"one": 1, "mid": 300, "big": 3000},
+	}
+
+	tests := []struct {
+		sql  string
+		rows int
+	}{
+		{"SELECT * FROM one", 1},
+		{"SELECT * FROM mid WHERE id > 5", 100},
+		{"SELECT * FROM mid WHERE id = 5", 30},
+		{"SELECT * FROM mid WHERE id = 5 AND name < 'x'", 10},
+		{"SELECT * FROM one WHERE id = 5", 1},
+		{"SELECT * FROM mid LIMIT 20", 20},
+		{"SELECT * FROM mid LIMIT 20 OFFSET 290", 10},
+		{"SELECT * FROM big JOIN mid ON big.id = mid.id", 3000},
+		{"SELECT * FROM big JOIN mid ON big.id = mid.id AND big.name != mid.name", 1000},
+		{"SELECT * FROM mid a JOIN mid b ON a.id < b.id", 30000},
+		{"SELECT * FROM one LEFT JOIN mid ON one.id < mid.id AND mid.id = 0", 10},
+		{"SELECT * FROM mid LEFT JOIN one ON one.id < mid.id AND one.id = 0", 300},
+		{"SELECT COUNT(*) FROM big", 1},
+		{"DELETE FROM big WHERE id = 1", 300},
+	}
+	for _, tt := range tests {
+		stmt := parser.New(lexer.New(tt.sql)).ParseProgram().Statements[0]
+		p := New(catalog)
+		plan, err := p.GeneratePlan(stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := p.estimateRows(plan); got != tt.rows {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, tt.rows, got)
+		}
+	}
+}
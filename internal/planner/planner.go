@@ -1,6 +1,8 @@
 package planner
 
 import (
+	"fmt"
+
 	"github.com/Mohammad-y-abbass/moDB/internal/ast"
 )
 
@@ -10,38 +12,60 @@ type PlanNode interface {
 
 type ScanNode struct {
 	TableName string
+	Alias     string // optional; replaces TableName as the column qualifier
+	CTE       *CTE   // set when TableName names a WITH query, not a table
 }
 
 func (n *ScanNode) PlanNode() {}
 
+// Ref is the name the query uses for the scanned table.
+func (n *ScanNode) Ref() string {
+	if n.Alias != "" {
+		return n.Alias
+	}
+	return n.TableName
+}
+
+// FilterNode keeps the rows of its child for which Condition is TRUE.
 type FilterNode struct {
-	Child PlanNode
-	Left  string
-	Op    string
-	Right string
+	Child     PlanNode
+	Condition ast.Expression
 }
 
 func (n *FilterNode) PlanNode() {}
 
+// ProjectNode evaluates the select list against each row of its child.
+// A *ast.Star entry expands to every child column.
 type ProjectNode struct {
 	Child   PlanNode
-	Columns []string
+	Columns []ast.Expression
 }
 
 func (n *ProjectNode) PlanNode() {}
 
+// InsertNode adds a batch of rows to a table: the VALUES rows, or the
+// result of Query. Columns lists the target columns when they were named.
 type InsertNode struct {
-	TableName string
-	Columns   []string
-	Values    []string
+	TableName  string
+	Columns    []string
+	Rows       [][]string
+	Params     [][]int  // bind parameters among Rows, as in ast.InsertStatement
+	Nulls      [][]bool // NULL keywords among Rows, as in ast.InsertStatement
+	Query      PlanNode
+	OnConflict *OnConflict
+	Returning  []ast.Expression
 }
 
 func (n *InsertNode) PlanNode() {}
 
 type UpdateNode struct {
 	TableName string
-	Sets      map[string]string
+	Sets      []ast.SetClause
 	Where     *ast.WhereClause
+	// From joins the table with the FROM tables on the WHERE condition,
+	// which it then replaces; nil when only the table is read.
+	From      *JoinNode
+	Returning []ast.Expression // evaluated against the updated rows
 }
 
 func (n *UpdateNode) PlanNode() {}
@@ -49,6 +73,10 @@ func (n *UpdateNode) PlanNode() {}
 type DeleteNode struct {
 	TableName string
 	Where     *ast.WhereClause
+	// Using joins the table with the USING tables on the WHERE condition,
+	// which it then replaces; nil when only the table is read.
+	Using     *JoinNode
+	Returning []ast.Expression // evaluated against the deleted rows
 }
 
 func (n *DeleteNode) PlanNode() {}
@@ -74,86 +102,533 @@ func (n *UseDatabaseNode) PlanNode() {}
 
 // JoinNode represents an INNER JOIN between two tables.
 // LeftKey/RightKey are the qualified column references from the ON clause
-// (e.g., "orders.user_id" and "users.id").
+// (e.g., "orders.user_id" and "users.id"). WHERE and the projection are
+// applied by FilterNode and ProjectNode on top.
+// JoinNode joins the rows of Left and Right. For hash and merge joins,
+// LeftKeys and RightKeys are the equi-join keys taken from the condition;
+// the whole condition is still checked on every candidate pair.
 type JoinNode struct {
-	Left     *ScanNode
-	Right    *ScanNode
-	LeftKey  string           // qualified: "left_table.col"
-	RightKey string           // qualified: "right_table.col"
-	Columns  []string         // projection (empty = *)
-	Where    *ast.WhereClause // optional post-join filter
+	Left      PlanNode
+	Right     PlanNode
+	Kind      ast.JoinKind
+	Condition ast.Expression // ON condition; nil for CROSS JOIN
+	Method    JoinMethod
+	LeftKeys  []ast.Expression
+	RightKeys []ast.Expression
 }
 
 func (n *JoinNode) PlanNode() {}
 
-type Planner struct{}
+// AggregateNode groups its child's rows by GroupBy and computes Aggregates
+// for every group. Its output columns are the GROUP BY expressions followed
+// by the aggregates, each named by its SQL text, so that the select list and
+// Having can refer to them by expression. Without GroupBy the whole input is
+// a single group.
+type AggregateNode struct {
+	Child      PlanNode
+	GroupBy    []ast.Expression
+	Aggregates []*ast.FunctionCall
+	Having     ast.Expression // nil when there is no HAVING clause
+}
+
+func (n *AggregateNode) PlanNode() {}
+
+// SortNode orders its child's rows by Keys. NULLs sort last in ascending
+// order and first in descending order.
+type SortNode struct {
+	Child PlanNode
+	Keys  []ast.OrderByItem
+}
+
+func (n *SortNode) PlanNode() {}
+
+// DistinctNode removes duplicate rows from the output of a ProjectNode,
+// keeping the first row of each set of duplicates so a sorted input stays
+// sorted. NULLs compare equal to each other. For DISTINCT ON, the On
+// expressions are appended to the child projection as trailing columns;
+// rows are compared on those columns only, and they are dropped from the
+// output.
+type DistinctNode struct {
+	Child PlanNode
+	On    []ast.Expression
+}
+
+func (n *DistinctNode) PlanNode() {}
+
+// LimitNode skips the first Offset rows of its child and passes on at most
+// Limit of the rest. Either may be nil; both read no columns.
+type LimitNode struct {
+	Child  PlanNode
+	Limit  ast.Expression
+	Offset ast.Expression
+}
+
+func (n *LimitNode) PlanNode() {}
 
-func New() *Planner {
-	return &Planner{}
+// A Planner keeps state while it plans a statement, so it plans one
+// statement at a time; each session uses a planner of its own.
+type Planner struct {
+	catalog Catalog
+	// subqueries caches the plans of the current statement's subqueries,
+	// which are planned while type-checking and reused when binding.
+	subqueries map[*ast.SelectStatement]*plannedQuery
+	// ctes are the WITH queries in scope, innermost last.
+	ctes      []*CTE
+	recursive *recursiveTerm
+	// params are the types of the bind parameters of the statement being
+	// prepared; nil outside PREPARE.
+	params []Type
 }
 
-func (p *Planner) GeneratePlan(stmt ast.Statement) PlanNode {
+func New(catalog Catalog) *Planner {
+	return &Planner{catalog: catalog}
+}
+
+func (p *Planner) GeneratePlan(stmt ast.Statement) (PlanNode, error) {
+	p.subqueries = make(map[*ast.SelectStatement]*plannedQuery)
+	p.ctes, p.recursive = nil, nil
 	switch s := stmt.(type) {
 	case *ast.CreateDatabaseStatement:
 		return &CreateDatabaseNode{
 			DatabaseName: s.DatabaseName,
-		}
+		}, nil
 	case *ast.UseDatabaseStatement:
 		return &UseDatabaseNode{
 			DatabaseName: s.DatabaseName,
-		}
+		}, nil
 	case *ast.CreateTableStatement:
 		return &CreateTableNode{
 			TableName: s.Table,
 			Columns:   s.Columns,
-		}
+		}, nil
 	case *ast.SelectStatement:
-		// JOIN path — produce a JoinNode instead of a ScanNode
-		if s.Join != nil {
-			return &JoinNode{
-				Left:     &ScanNode{TableName: s.Table},
-				Right:    &ScanNode{TableName: s.Join.Table},
-				LeftKey:  s.Join.LeftKey,
-				RightKey: s.Join.RightKey,
-				Columns:  s.Columns,
-				Where:    s.Where,
-			}
+		return p.planSelect(s)
+	case *ast.PrepareStatement:
+		return p.planPrepare(s)
+	case *ast.ExecuteStatement:
+		return p.planExecute(s)
+	case *ast.DeallocateStatement:
+		return &DeallocateNode{Name: s.Name}, nil
+	case *ast.ExplainStatement:
+		return p.planExplain(s)
+	case *ast.ShowStatement:
+		return p.planShow(s)
+	case *ast.CopyStatement:
+		return p.planCopy(s)
+	case *ast.SetOperation:
+		q, err := p.planSetOperation(s)
+		if err != nil {
+			return nil, err
 		}
-
-		// Plain SELECT path
-		var node PlanNode = &ScanNode{TableName: s.Table}
-		if s.Where != nil {
-			node = &FilterNode{
-				Child: node,
-				Left:  s.Where.Left,
-				Op:    s.Where.Op,
-				Right: s.Where.Right,
+		return q.plan, nil
+	case *ast.InsertStatement:
+		return p.planInsert(s)
+	case *ast.UpdateStatement:
+		sc, err := p.tableScope(&ScanNode{TableName: s.Table})
+		if err != nil {
+			return nil, err
+		}
+		var from *JoinNode
+		if s.From != nil {
+			if from, sc, err = p.planTargetJoin(s.Table, s.From, s.Where); err != nil {
+				return nil, err
 			}
 		}
-		if len(s.Columns) > 0 && s.Columns[0] != "*" {
-			node = &ProjectNode{
-				Child:   node,
-				Columns: s.Columns,
+		b := &binder{p: p, sc: sc}
+		sets, err := p.planSets(s.Table, s.Sets, b)
+		if err != nil {
+			return nil, err
+		}
+		var where *ast.WhereClause
+		if from == nil {
+			if err := p.checkWhere(s.Where, sc); err != nil {
+				return nil, err
 			}
+			where = b.where(s.Where)
 		}
-		return node
-	case *ast.InsertStatement:
-		return &InsertNode{
-			TableName: s.Table,
-			Columns:   s.Columns,
-			Values:    s.Values,
+		if b.err != nil {
+			return nil, b.err
+		}
+		var joined *scope
+		if from != nil {
+			joined = sc
+		}
+		returning, err := p.planReturning(s.Table, s.Returning, joined)
+		if err != nil {
+			return nil, err
 		}
-	case *ast.UpdateStatement:
 		return &UpdateNode{
 			TableName: s.Table,
-			Sets:      s.Sets,
-			Where:     s.Where,
-		}
+			Sets:      sets,
+			Where:     where,
+			From:      from,
+			Returning: returning,
+		}, nil
 	case *ast.DeleteStatement:
+		if s.Using != nil {
+			using, sc, err := p.planTargetJoin(s.Table, s.Using, s.Where)
+			if err != nil {
+				return nil, err
+			}
+			returning, err := p.planReturning(s.Table, s.Returning, sc)
+			if err != nil {
+				return nil, err
+			}
+			return &DeleteNode{TableName: s.Table, Using: using, Returning: returning}, nil
+		}
+		sc, err := p.tableScope(&ScanNode{TableName: s.Table})
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkWhere(s.Where, sc); err != nil {
+			return nil, err
+		}
+		b := &binder{p: p, sc: sc}
+		where := b.where(s.Where)
+		if b.err != nil {
+			return nil, b.err
+		}
+		returning, err := p.planReturning(s.Table, s.Returning, nil)
+		if err != nil {
+			return nil, err
+		}
 		return &DeleteNode{
 			TableName: s.Table,
-			Where:     s.Where,
+			Where:     where,
+			Returning: returning,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported statement: %s", stmt.String())
+}
+
+func (p *Planner) planSelect(s *ast.SelectStatement) (PlanNode, error) {
+	q, err := p.planQuery(s, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return q.plan, nil
+}
+
+// planQuery plans a SELECT and returns the names and types of its result
+// columns. For a subquery, outer is the enclosing query's scope and refs
+// collects the outer columns it reads.
+func (p *Planner) planQuery(s *ast.SelectStatement, outer *scope, refs *[]string) (*plannedQuery, error) {
+	if s.With != nil {
+		main := *s
+		main.With = nil
+		return p.planWith(s.With, func() (*plannedQuery, error) { return p.planQuery(&main, outer, refs) })
+	}
+	node, scans, err := p.planFrom(s, outer, refs)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := p.tableScope(scans...)
+	if err != nil {
+		return nil, err
+	}
+	sc.correlate(outer, refs)
+	if err := p.checkWhere(s.Where, sc); err != nil {
+		return nil, err
+	}
+	b := &binder{p: p, sc: sc}
+	if s.Where != nil {
+		if containsAggregate(s.Where.Condition) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in WHERE")
+		}
+		var rest ast.Expression
+		node, rest, err = p.decorrelate(node, s, scans, sc)
+		if err != nil {
+			return nil, err
+		}
+		if rest != nil {
+			node = &FilterNode{
+				Child:     node,
+				Condition: b.expr(rest),
+			}
+		}
+	}
+
+	groupBy := resolveGroupAliases(s.GroupBy, s.Columns, sc)
+	orderBy, err := resolveOrderBy(s.OrderBy, s.Columns)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDistinctOrder(s, orderBy); err != nil {
+		return nil, err
+	}
+
+	exprs := append(append([]ast.Expression{}, s.Columns...), s.Having)
+	for _, item := range orderBy {
+		exprs = append(exprs, item.Expr)
+	}
+	aggregates, err := collectAggregates(exprs...)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkSelectTypes(s, groupBy, orderBy, sc); err != nil {
+		return nil, err
+	}
+
+	grouped := len(aggregates) > 0 || len(groupBy) > 0 || s.Having != nil
+	if grouped {
+		for _, g := range groupBy {
+			if containsAggregate(g) {
+				return nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY: %s", g.String())
+			}
+			if containsWindow(g) {
+				return nil, fmt.Errorf("window functions are not allowed in GROUP BY: %s", g.String())
+			}
+		}
+		for _, col := range s.Columns {
+			if err := checkGrouped(col, groupBy, sc); err != nil {
+				return nil, err
+			}
+		}
+		if err := checkGrouped(s.Having, groupBy, sc); err != nil {
+			return nil, err
+		}
+		for _, item := range append(orderBy, orderByItems(s.DistinctOn)...) {
+			if err := checkGrouped(item.Expr, groupBy, sc); err != nil {
+				return nil, err
+			}
+		}
+		boundAggregates := make([]*ast.FunctionCall, len(aggregates))
+		for i, agg := range aggregates {
+			boundAggregates[i] = b.expr(agg).(*ast.FunctionCall)
+		}
+		node = &AggregateNode{
+			Child:      node,
+			GroupBy:    b.list(groupBy),
+			Aggregates: boundAggregates,
+			Having:     b.expr(s.Having),
+		}
+	}
+
+	windows, err := collectWindows(append(exprs, s.DistinctOn...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(windows) > 0 {
+		boundWindows := make([]*ast.FunctionCall, len(windows))
+		for i, w := range windows {
+			boundWindows[i] = b.expr(w).(*ast.FunctionCall)
+		}
+		node = &WindowNode{Child: node, Windows: boundWindows}
+	}
+
+	if len(orderBy) > 0 {
+		keys := make([]ast.OrderByItem, len(orderBy))
+		for i, item := range orderBy {
+			keys[i] = ast.OrderByItem{Expr: b.expr(item.Expr), Desc: item.Desc}
+		}
+		node = &SortNode{Child: node, Keys: keys}
+	}
+
+	// Scans qualify their columns with the table reference, so even SELECT *
+	// projects to give the result its user-facing headers.
+	columns := sc.expandStar(s.Columns)
+	q := &plannedQuery{names: make([]string, len(columns)), types: make([]Type, len(columns))}
+	for i, col := range columns {
+		q.names[i] = outputName(col)
+		if q.types[i], err = p.typeOf(col, sc); err != nil {
+			return nil, err
+		}
+	}
+	node = &ProjectNode{
+		Child:   node,
+		Columns: b.list(append(columns, s.DistinctOn...)),
+	}
+
+	if s.Distinct {
+		node = &DistinctNode{Child: node, On: b.list(s.DistinctOn)}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	if q.plan, err = p.planLimit(node, s.Limit, s.Offset); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// planLimit puts a LimitNode for LIMIT and OFFSET over node. Both must be
+// integers that can be worked out before the query runs.
+func (p *Planner) planLimit(node PlanNode, limit, offset ast.Expression) (PlanNode, error) {
+	if limit == nil && offset == nil {
+		return node, nil
+	}
+	n := &LimitNode{Child: node}
+	b := &binder{p: p, sc: &scope{}}
+	for _, clause := range []struct {
+		name string
+		expr ast.Expression
+		dst  *ast.Expression
+	}{{"LIMIT", limit, &n.Limit}, {"OFFSET", offset, &n.Offset}} {
+		if clause.expr == nil {
+			continue
+		}
+		if containsAggregate(clause.expr) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in %s", clause.name)
+		}
+		if containsWindow(clause.expr) {
+			return nil, fmt.Errorf("window functions are not allowed in %s", clause.name)
+		}
+		t, err := p.typeOf(clause.expr, b.sc)
+		if err != nil {
+			return nil, fmt.Errorf("argument of %s must not read columns: %v", clause.name, err)
+		}
+		if t != TypeInt && t != TypeUnknown {
+			return nil, fmt.Errorf("argument of %s must be an integer, got %s", clause.name, t)
+		}
+		if _, err := p.inferParam(clause.expr, t, TypeInt); err != nil {
+			return nil, err
+		}
+		*clause.dst = b.expr(clause.expr)
+	}
+	return n, b.err
+}
+
+// checkSelectTypes type-checks every expression of a SELECT against the
+// columns of its FROM tables.
+func (p *Planner) checkSelectTypes(s *ast.SelectStatement, groupBy []ast.Expression, orderBy []ast.OrderByItem, sc *scope) error {
+	var exprs []ast.Expression
+	for _, col := range s.Columns {
+		if _, star := col.(*ast.Star); !star {
+			exprs = append(exprs, col)
+		}
+	}
+	exprs = append(exprs, s.DistinctOn...)
+	exprs = append(exprs, groupBy...)
+	for _, item := range orderBy {
+		exprs = append(exprs, item.Expr)
+	}
+	for _, expr := range exprs {
+		if _, err := p.typeOf(expr, sc); err != nil {
+			return err
+		}
+	}
+	if s.Having != nil {
+		return p.checkCondition("HAVING", s.Having, sc)
+	}
+	return nil
+}
+
+func (p *Planner) checkWhere(where *ast.WhereClause, sc *scope) error {
+	if where == nil {
+		return nil
+	}
+	return p.checkCondition("WHERE", where.Condition, sc)
+}
+
+// resolveOrderBy replaces ORDER BY positions (ORDER BY 2) and output column
+// aliases with the select list expression they refer to.
+func resolveOrderBy(items []ast.OrderByItem, columns []ast.Expression) ([]ast.OrderByItem, error) {
+	resolved := make([]ast.OrderByItem, len(items))
+	for i, item := range items {
+		resolved[i] = item
+		switch key := item.Expr.(type) {
+		case *ast.IntegerLiteral:
+			if key.Value < 1 || int(key.Value) > len(columns) {
+				return nil, fmt.Errorf("ORDER BY position %d is not in select list", key.Value)
+			}
+			col := columns[key.Value-1]
+			if _, star := col.(*ast.Star); star {
+				return nil, fmt.Errorf("ORDER BY position %d refers to *", key.Value)
+			}
+			resolved[i].Expr = ast.Unalias(col)
+		case *ast.Identifier:
+			// An output column name takes precedence over an input column.
+			matches := aliasMatches(columns, key.Value)
+			if len(matches) > 1 {
+				return nil, fmt.Errorf("ORDER BY %s is ambiguous", key.Value)
+			}
+			if len(matches) == 1 {
+				resolved[i].Expr = matches[0]
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// resolveGroupAliases replaces GROUP BY names that refer to select list
+// aliases with the aliased expressions. Unlike ORDER BY, an input column of
+// the same name wins.
+func resolveGroupAliases(groupBy []ast.Expression, columns []ast.Expression, sc *scope) []ast.Expression {
+	resolved := make([]ast.Expression, len(groupBy))
+	for i, g := range groupBy {
+		resolved[i] = g
+		id, ok := g.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		if _, err := sc.lookup(id.Value); err == nil {
+			continue
+		}
+		if matches := aliasMatches(columns, id.Value); len(matches) == 1 {
+			resolved[i] = matches[0]
+		}
+	}
+	return resolved
+}
+
+// aliasMatches returns the expressions of the select list items aliased as name.
+func aliasMatches(columns []ast.Expression, name string) []ast.Expression {
+	var matches []ast.Expression
+	for _, col := range columns {
+		if ae, ok := col.(*ast.AliasExpression); ok && ae.Alias == name {
+			matches = append(matches, ae.Expr)
+		}
+	}
+	return matches
+}
+
+// checkDistinctOrder enforces that a DISTINCT result is sorted only by
+// what it returns: plain DISTINCT may only order by select list items, and
+// DISTINCT ON keys must lead the ORDER BY.
+func checkDistinctOrder(s *ast.SelectStatement, orderBy []ast.OrderByItem) error {
+	if !s.Distinct {
+		return nil
+	}
+	if len(s.DistinctOn) > 0 {
+		for i := 0; i < len(orderBy) && i < len(s.DistinctOn); i++ {
+			if !containsExpr(s.DistinctOn, orderBy[i].Expr) {
+				return fmt.Errorf("SELECT DISTINCT ON expressions must match initial ORDER BY expressions")
+			}
+		}
+		return nil
+	}
+	if isSelectStar(s.Columns) {
+		return nil
+	}
+	for _, item := range orderBy {
+		if !containsExpr(s.Columns, item.Expr) {
+			return fmt.Errorf("for SELECT DISTINCT, ORDER BY expressions must appear in select list: %s", item.Expr.String())
 		}
 	}
 	return nil
 }
+
+func containsExpr(list []ast.Expression, expr ast.Expression) bool {
+	for _, e := range list {
+		if ast.Unalias(e).String() == expr.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func orderByItems(exprs []ast.Expression) []ast.OrderByItem {
+	items := make([]ast.OrderByItem, len(exprs))
+	for i, e := range exprs {
+		items[i] = ast.OrderByItem{Expr: e}
+	}
+	return items
+}
+
+func isSelectStar(columns []ast.Expression) bool {
+	if len(columns) != 1 {
+		return false
+	}
+	_, ok := columns[0].(*ast.Star)
+	return ok
+}
@@ -0,0 +1,93 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// PrepareNode saves the plan of a statement under Name for EXECUTE to run.
+// Params holds the type deduced for each bind parameter from where it is
+// used; a parameter of TypeUnknown takes any value.
+type PrepareNode struct {
+	Name   string
+	Plan   PlanNode
+	Params []Type
+}
+
+func (n *PrepareNode) PlanNode() {}
+
+// ExecuteNode runs a prepared statement with Args bound to its parameters
+// in order. The arguments read no columns.
+type ExecuteNode struct {
+	Name string
+	Args []ast.Expression
+}
+
+func (n *ExecuteNode) PlanNode() {}
+
+// DeallocateNode drops a prepared statement.
+type DeallocateNode struct {
+	Name string
+}
+
+func (n *DeallocateNode) PlanNode() {}
+
+// planPrepare plans the statement of a PREPARE once, leaving its bind
+// parameters in the plan to be filled in by each EXECUTE.
+func (p *Planner) planPrepare(s *ast.PrepareStatement) (PlanNode, error) {
+	p.params = make([]Type, s.Params)
+	defer func() { p.params = nil }()
+	plan, err := p.GeneratePlan(s.Statement)
+	if err != nil {
+		return nil, err
+	}
+	return &PrepareNode{Name: s.Name, Plan: plan, Params: p.params}, nil
+}
+
+func (p *Planner) planExecute(s *ast.ExecuteStatement) (PlanNode, error) {
+	sc := &scope{}
+	for _, arg := range s.Args {
+		if containsAggregate(arg) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in EXECUTE arguments")
+		}
+		if containsWindow(arg) {
+			return nil, fmt.Errorf("window functions are not allowed in EXECUTE arguments")
+		}
+		if _, err := p.typeOf(arg, sc); err != nil {
+			return nil, fmt.Errorf("EXECUTE arguments must not read columns: %v", err)
+		}
+	}
+	b := &binder{p: p, sc: sc}
+	args := b.list(s.Args)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &ExecuteNode{Name: s.Name, Args: args}, nil
+}
+
+// typeOfParam returns the type deduced so far for a bind parameter.
+func (p *Planner) typeOfParam(n *ast.Parameter) (Type, error) {
+	if n.Index > len(p.params) {
+		return TypeUnknown, fmt.Errorf("bind parameter %s is only allowed in PREPARE", n)
+	}
+	return p.params[n.Index-1], nil
+}
+
+// inferParam records that expr, if it is a bind parameter, takes values of
+// type t, and returns the type of expr afterwards: typ, its type so far,
+// unless it is a parameter. A parameter used with two types that do not
+// unify is an error.
+func (p *Planner) inferParam(expr ast.Expression, typ, t Type) (Type, error) {
+	n, ok := expr.(*ast.Parameter)
+	if !ok || n.Index > len(p.params) {
+		return typ, nil
+	}
+	prev := p.params[n.Index-1]
+	u, err := UnifyTypes(prev, t)
+	if err != nil {
+		return TypeUnknown, fmt.Errorf("parameter %s is used as both %s and %s", n, prev, t)
+	}
+	p.params[n.Index-1] = u
+	return u, nil
+}
@@ -0,0 +1,60 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// planReturning checks the RETURNING list of an INSERT, UPDATE or DELETE
+// and returns it bound, with * expanded to the target table's columns. sc
+// is the scope of an UPDATE ... FROM or DELETE ... USING, whose RETURNING
+// can also read the joined tables; nil means the target table alone.
+func (p *Planner) planReturning(table string, returning []ast.Expression, sc *scope) ([]ast.Expression, error) {
+	if len(returning) == 0 {
+		return nil, nil
+	}
+	target, err := p.tableScope(&ScanNode{TableName: table})
+	if err != nil {
+		return nil, err
+	}
+	columns := target.expandStar(returning)
+	if sc == nil {
+		sc = target
+	} else {
+		// * still means the target's columns, qualified so that a joined
+		// table's column of the same name does not make them ambiguous.
+		columns = nil
+		for _, col := range returning {
+			if _, ok := col.(*ast.Star); !ok {
+				columns = append(columns, col)
+				continue
+			}
+			for _, c := range target.expandStar([]ast.Expression{col}) {
+				id := c.(*ast.Identifier)
+				columns = append(columns, &ast.AliasExpression{
+					Token: id.Token,
+					Expr:  &ast.Identifier{Token: id.Token, Value: table + "." + id.Value},
+					Alias: id.Value,
+				})
+			}
+		}
+	}
+	for _, col := range columns {
+		if containsAggregate(col) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in RETURNING")
+		}
+		if containsWindow(col) {
+			return nil, fmt.Errorf("window functions are not allowed in RETURNING")
+		}
+		if _, err := p.typeOf(col, sc); err != nil {
+			return nil, err
+		}
+	}
+	b := &binder{p: p, sc: sc}
+	columns = b.list(columns)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return columns, nil
+}
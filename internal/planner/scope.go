@@ -0,0 +1,400 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// Catalog is the planner's view of the database: table schemas for typing
+// column references, and the executor's function registry.
+type Catalog interface {
+	TableSchema(name string) (*storage.Schema, bool)
+	// FunctionType checks a scalar function call's arguments and returns
+	// its result type.
+	FunctionType(name string, args []Type) (Type, error)
+	// TableRows estimates the number of rows in a table.
+	TableRows(name string) int
+}
+
+// scopeColumn is a column visible to the expressions of one query.
+type scopeColumn struct {
+	table    string
+	name     string
+	typ      Type
+	nullable bool
+}
+
+// scope holds the columns an expression may refer to.
+type scope struct {
+	tables  []string // table references (alias or name), in FROM order
+	columns []scopeColumn
+	// outer is the scope of the enclosing query when this one is a
+	// subquery. Names it resolves are recorded in outerRefs, which all
+	// scopes of one query share.
+	outer     *scope
+	outerRefs *[]string
+}
+
+// tableScope builds the scope of the scanned tables, in FROM order. Each
+// table is referred to by its alias when it has one, so the same table may
+// appear twice under different aliases.
+func (p *Planner) tableScope(scans ...*ScanNode) (*scope, error) {
+	sc := &scope{}
+	for _, scan := range scans {
+		ref := scan.Ref()
+		for _, t := range sc.tables {
+			if t == ref {
+				return nil, fmt.Errorf("table name %s specified more than once", ref)
+			}
+		}
+		sc.tables = append(sc.tables, ref)
+		if scan.CTE != nil {
+			for i, name := range scan.CTE.Columns {
+				sc.columns = append(sc.columns, scopeColumn{table: ref, name: name, typ: scan.CTE.Types[i], nullable: true})
+			}
+			continue
+		}
+		schema, ok := p.catalog.TableSchema(scan.TableName)
+		if !ok {
+			return nil, fmt.Errorf("table not found: %s", scan.TableName)
+		}
+		for _, c := range schema.Columns {
+			sc.columns = append(sc.columns, scopeColumn{table: ref, name: c.Name, typ: ColumnType(c), nullable: c.IsNullable})
+		}
+	}
+	return sc, nil
+}
+
+// expandStar replaces * in a select list with a reference to every column
+// in scope. Columns are qualified only when more than one table is visible.
+func (s *scope) expandStar(columns []ast.Expression) []ast.Expression {
+	var expanded []ast.Expression
+	for _, col := range columns {
+		star, ok := col.(*ast.Star)
+		if !ok {
+			expanded = append(expanded, col)
+			continue
+		}
+		for _, c := range s.columns {
+			name := c.name
+			if len(s.tables) > 1 {
+				name = c.table + "." + c.name
+			}
+			expanded = append(expanded, &ast.Identifier{Token: star.Token, Value: name})
+		}
+	}
+	return expanded
+}
+
+// lookup resolves a possibly qualified column name to its type.
+func (s *scope) lookup(name string) (Type, error) {
+	c, err := s.resolve(name)
+	return c.typ, err
+}
+
+// resolve finds the column a possibly qualified name refers to, looking
+// through enclosing queries when this query has no such column.
+func (s *scope) resolve(name string) (scopeColumn, error) {
+	c, found, err := s.resolveLocal(name)
+	if err != nil {
+		return scopeColumn{}, err
+	}
+	if found {
+		return c, nil
+	}
+	if s.outer != nil {
+		if c, err := s.outer.resolve(name); err == nil {
+			s.addOuterRef(name)
+			return c, nil
+		}
+	}
+	return scopeColumn{}, fmt.Errorf("column not found: %s", name)
+}
+
+// resolveLocal finds name among this query's own columns.
+func (s *scope) resolveLocal(name string) (scopeColumn, bool, error) {
+	table, col := "", name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		table, col = name[:i], name[i+1:]
+	}
+
+	found := -1
+	for i, c := range s.columns {
+		if c.name != col || (table != "" && c.table != table) {
+			continue
+		}
+		if found != -1 {
+			return scopeColumn{}, false, fmt.Errorf("column reference is ambiguous: %s", name)
+		}
+		found = i
+	}
+	if found == -1 {
+		return scopeColumn{}, false, nil
+	}
+	return s.columns[found], true, nil
+}
+
+// addOuterRef records that the query reads name from an enclosing query.
+func (s *scope) addOuterRef(name string) {
+	for _, r := range *s.outerRefs {
+		if r == name {
+			return
+		}
+	}
+	*s.outerRefs = append(*s.outerRefs, name)
+}
+
+// correlate makes s the scope of a subquery inside outer. refs collects
+// the outer column names the subquery reads.
+func (s *scope) correlate(outer *scope, refs *[]string) *scope {
+	s.outer, s.outerRefs = outer, refs
+	return s
+}
+
+// typeOf type-checks expr against sc and returns its type.
+func (p *Planner) typeOf(expr ast.Expression, sc *scope) (Type, error) {
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		return sc.lookup(n.Value)
+	case *ast.IntegerLiteral:
+		return TypeInt, nil
+	case *ast.FloatLiteral:
+		return TypeFloat, nil
+	case *ast.StringLiteral:
+		return TypeText, nil
+	case *ast.NullLiteral:
+		return TypeUnknown, nil
+	case *ast.BooleanLiteral:
+		return TypeBool, nil
+	case *ast.Parameter:
+		return p.typeOfParam(n)
+	case *ast.Star:
+		return TypeUnknown, fmt.Errorf("* is only allowed in the select list or COUNT(*)")
+	case *ast.PrefixExpression:
+		t, err := p.typeOf(n.Right, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		switch n.Operator {
+		case "NOT":
+			if t, err = p.inferParam(n.Right, t, TypeBool); err != nil {
+				return TypeUnknown, err
+			}
+			return TypeBool, expectBool("NOT", t)
+		case "-":
+			if !t.IsNumeric() {
+				return TypeUnknown, fmt.Errorf("operator - requires a numeric operand, got %s: %s", t, n.String())
+			}
+			return t, nil
+		}
+		return TypeUnknown, fmt.Errorf("unsupported operator: %s", n.Operator)
+	case *ast.InfixExpression:
+		return p.typeOfInfix(n, sc)
+	case *ast.AliasExpression:
+		return p.typeOf(n.Expr, sc)
+	case *ast.SubqueryExpression:
+		q, err := p.planSubquery(n.Select, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		if len(q.types) != 1 {
+			return TypeUnknown, fmt.Errorf("subquery must return only one column: %s", n.String())
+		}
+		return q.types[0], nil
+	case *ast.ExistsExpression:
+		_, err := p.planSubquery(n.Select, sc)
+		return TypeBool, err
+	case *ast.InExpression:
+		return p.typeOfIn(n, sc)
+	case *Subquery:
+		return n.Type, nil
+	case *ast.CaseExpression:
+		return p.typeOfCase(n, sc)
+	case *Case:
+		return n.Type, nil
+	case *ast.IsNullExpression:
+		_, err := p.typeOf(n.Expr, sc)
+		return TypeBool, err
+	case *ast.CastExpression:
+		if _, err := p.typeOf(n.Expr, sc); err != nil {
+			return TypeUnknown, err
+		}
+		return ParseTypeName(n.Type)
+	case *ast.FunctionCall:
+		if n.Over != nil {
+			return p.typeOfWindow(n, sc)
+		}
+		if windowFunctions[n.Name] {
+			return TypeUnknown, fmt.Errorf("window function %s requires an OVER clause", n.Name)
+		}
+		if IsAggregate(n.Name) {
+			return p.typeOfAggregate(n, sc)
+		}
+		args := make([]Type, len(n.Args))
+		for i, a := range n.Args {
+			t, err := p.typeOf(a, sc)
+			if err != nil {
+				return TypeUnknown, err
+			}
+			args[i] = t
+		}
+		if n.Distinct {
+			return TypeUnknown, fmt.Errorf("DISTINCT is only allowed in aggregate functions: %s", n.String())
+		}
+		return p.catalog.FunctionType(n.Name, args)
+	}
+	return TypeUnknown, fmt.Errorf("unsupported expression: %s", expr.String())
+}
+
+func (p *Planner) typeOfInfix(n *ast.InfixExpression, sc *scope) (Type, error) {
+	left, err := p.typeOf(n.Left, sc)
+	if err != nil {
+		return TypeUnknown, err
+	}
+	right, err := p.typeOf(n.Right, sc)
+	if err != nil {
+		return TypeUnknown, err
+	}
+
+	// A bind parameter takes the type of the operand it is compared or
+	// combined with.
+	leftWant, rightWant := right, left
+	switch n.Operator {
+	case "AND", "OR":
+		leftWant, rightWant = TypeBool, TypeBool
+	case "||":
+		leftWant, rightWant = TypeUnknown, TypeUnknown
+	}
+	if left, err = p.inferParam(n.Left, left, leftWant); err != nil {
+		return TypeUnknown, err
+	}
+	if right, err = p.inferParam(n.Right, right, rightWant); err != nil {
+		return TypeUnknown, err
+	}
+
+	switch n.Operator {
+	case "AND", "OR":
+		if err := expectBool(n.Operator, left); err != nil {
+			return TypeUnknown, err
+		}
+		return TypeBool, expectBool(n.Operator, right)
+	case "=", "!=", "<", ">", "<=", ">=":
+		// Text compared with a number is coerced at run time, but booleans
+		// only compare with booleans.
+		if (left == TypeBool) != (right == TypeBool) && left != TypeUnknown && right != TypeUnknown {
+			return TypeUnknown, fmt.Errorf("cannot compare %s with %s: %s", left, right, n.String())
+		}
+		return TypeBool, nil
+	case "||":
+		return TypeText, nil
+	case "+", "-", "*", "/", "%":
+		if !left.IsNumeric() || !right.IsNumeric() {
+			return TypeUnknown, fmt.Errorf("operator %s requires numeric operands, got %s and %s: %s", n.Operator, left, right, n.String())
+		}
+		return UnifyTypes(left, right)
+	}
+	return TypeUnknown, fmt.Errorf("unsupported operator: %s", n.Operator)
+}
+
+func (p *Planner) typeOfIn(n *ast.InExpression, sc *scope) (Type, error) {
+	left, err := p.typeOf(n.Left, sc)
+	if err != nil {
+		return TypeUnknown, err
+	}
+	if n.Select != nil {
+		q, err := p.planSubquery(n.Select, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		if len(q.types) != 1 {
+			return TypeUnknown, fmt.Errorf("subquery must return only one column: %s", n.Select.String())
+		}
+		// Subquery results are matched by hashing, which does not coerce.
+		if !hashComparable(left, q.types[0]) {
+			return TypeUnknown, fmt.Errorf("cannot match %s against a subquery of %s: %s", left, q.types[0], n.String())
+		}
+		return TypeBool, nil
+	}
+	for _, item := range n.List {
+		t, err := p.typeOf(item, sc)
+		if err != nil {
+			return TypeUnknown, err
+		}
+		if t, err = p.inferParam(item, t, left); err != nil {
+			return TypeUnknown, err
+		}
+		if left, err = p.inferParam(n.Left, left, t); err != nil {
+			return TypeUnknown, err
+		}
+		if (left == TypeBool) != (t == TypeBool) && left != TypeUnknown && t != TypeUnknown {
+			return TypeUnknown, fmt.Errorf("cannot compare %s with %s: %s", left, t, n.String())
+		}
+	}
+	return TypeBool, nil
+}
+
+// hashComparable reports whether values of types a and b can be matched by
+// hashing or sorting: numbers with numbers, anything else only with its own
+// type. NULL matches nothing, so an unknown type is always fine.
+func hashComparable(a, b Type) bool {
+	if a == TypeUnknown || b == TypeUnknown {
+		return true
+	}
+	if a.IsNumeric() || b.IsNumeric() {
+		return a.IsNumeric() && b.IsNumeric()
+	}
+	return a == b
+}
+
+func (p *Planner) typeOfAggregate(n *ast.FunctionCall, sc *scope) (Type, error) {
+	if n.Name == "COUNT" {
+		if _, star := n.Args[0].(*ast.Star); star {
+			return TypeInt, nil
+		}
+	}
+	arg, err := p.typeOf(n.Args[0], sc)
+	if err != nil {
+		return TypeUnknown, err
+	}
+	switch n.Name {
+	case "COUNT":
+		return TypeInt, nil
+	case "SUM", "AVG":
+		if !arg.IsNumeric() {
+			return TypeUnknown, fmt.Errorf("%s requires a numeric argument, got %s", n.Name, arg)
+		}
+		if n.Name == "AVG" {
+			return TypeFloat, nil
+		}
+		return arg, nil
+	case "STRING_AGG":
+		return TypeText, nil
+	}
+	return arg, nil
+}
+
+func expectBool(op string, t Type) error {
+	if t != TypeBool && t != TypeUnknown {
+		return fmt.Errorf("argument of %s must be boolean, got %s", op, t)
+	}
+	return nil
+}
+
+// checkCondition verifies that a WHERE or HAVING condition is boolean.
+// Conditions are evaluated before window functions, so may not call them.
+func (p *Planner) checkCondition(clause string, expr ast.Expression, sc *scope) error {
+	if containsWindow(expr) {
+		return fmt.Errorf("window functions are not allowed in %s", clause)
+	}
+	t, err := p.typeOf(expr, sc)
+	if err != nil {
+		return err
+	}
+	if t != TypeBool && t != TypeUnknown {
+		return fmt.Errorf("argument of %s must be boolean, got %s", clause, t)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// SetOpNode combines the rows of Left and Right by a UNION, INTERSECT or
+// EXCEPT. Its columns are named after the left query's and have the types
+// both sides unify to.
+type SetOpNode struct {
+	Op      ast.SetOperator
+	All     bool
+	Left    PlanNode
+	Right   PlanNode
+	Columns []string
+	Types   []Type
+}
+
+func (n *SetOpNode) PlanNode() {}
+
+// planAnyQuery plans a SELECT or a set operation.
+func (p *Planner) planAnyQuery(q ast.Query) (*plannedQuery, error) {
+	switch n := q.(type) {
+	case *ast.SelectStatement:
+		return p.planQuery(n, nil, nil)
+	case *ast.SetOperation:
+		return p.planSetOperation(n)
+	}
+	return nil, fmt.Errorf("unsupported query: %s", q.String())
+}
+
+// planSetOperation plans so and the ORDER BY, LIMIT and OFFSET of its
+// combined result. ORDER BY can only refer to result columns, by name or
+// position.
+func (p *Planner) planSetOperation(so *ast.SetOperation) (*plannedQuery, error) {
+	if so.With != nil {
+		main := *so
+		main.With = nil
+		return p.planWith(so.With, func() (*plannedQuery, error) { return p.planSetOperation(&main) })
+	}
+	left, err := p.planAnyQuery(so.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.planAnyQuery(so.Right)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.combine(so, left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &scope{}
+	columns := make([]ast.Expression, len(q.names))
+	for i, name := range q.names {
+		sc.columns = append(sc.columns, scopeColumn{name: name, typ: q.types[i], nullable: true})
+		columns[i] = &ast.Identifier{Token: so.Token, Value: name}
+	}
+	orderBy, err := resolveOrderBy(so.OrderBy, columns)
+	if err != nil {
+		return nil, err
+	}
+	if len(orderBy) > 0 {
+		b := &binder{p: p, sc: sc}
+		keys := make([]ast.OrderByItem, len(orderBy))
+		for i, item := range orderBy {
+			if containsAggregate(item.Expr) {
+				return nil, fmt.Errorf("aggregate functions are not allowed in ORDER BY of %s", so.Op)
+			}
+			if containsWindow(item.Expr) {
+				return nil, fmt.Errorf("window functions are not allowed in ORDER BY of %s", so.Op)
+			}
+			if _, err := p.typeOf(item.Expr, sc); err != nil {
+				return nil, err
+			}
+			keys[i] = ast.OrderByItem{Expr: b.expr(item.Expr), Desc: item.Desc}
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		q.plan = &SortNode{Child: q.plan, Keys: keys}
+	}
+	if q.plan, err = p.planLimit(q.plan, so.Limit, so.Offset); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// combine checks that left and right have matching columns and plans their
+// combination by so's operator.
+func (p *Planner) combine(so *ast.SetOperation, left, right *plannedQuery) (*plannedQuery, error) {
+	if len(left.types) != len(right.types) {
+		return nil, fmt.Errorf("each %s query must have the same number of columns", so.Op)
+	}
+	types := make([]Type, len(left.types))
+	for i := range types {
+		t, err := UnifyTypes(left.types[i], right.types[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s types %s and %s cannot be matched", so.Op, left.types[i], right.types[i])
+		}
+		types[i] = t
+	}
+	node := &SetOpNode{
+		Op:      so.Op,
+		All:     so.All,
+		Left:    left.plan,
+		Right:   right.plan,
+		Columns: left.names,
+		Types:   types,
+	}
+	return &plannedQuery{plan: node, names: left.names, types: types}, nil
+}
@@ -0,0 +1,25 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// ShowNode lists databases, tables or a table's definition. It reads the
+// engine's directory and the loaded schemas, not table rows.
+type ShowNode struct {
+	Kind      ast.ShowKind
+	TableName string // empty unless the statement names a table
+}
+
+func (n *ShowNode) PlanNode() {}
+
+func (p *Planner) planShow(s *ast.ShowStatement) (PlanNode, error) {
+	if s.Table != "" {
+		if _, ok := p.catalog.TableSchema(s.Table); !ok {
+			return nil, fmt.Errorf("table not found: %s", s.Table)
+		}
+	}
+	return &ShowNode{Kind: s.Kind, TableName: s.Table}, nil
+}
@@ -0,0 +1,422 @@
+package planner
+
+import (
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+)
+
+// SubqueryKind is the way a subquery's result is used.
+type SubqueryKind int
+
+const (
+	ScalarSubquery SubqueryKind = iota // (SELECT ...) as a value
+	ExistsSubquery                     // EXISTS (SELECT ...)
+	InSubquery                         // x [NOT] IN (SELECT ...)
+)
+
+// Subquery is a planned subquery. The planner substitutes it for the
+// subquery's AST node in the expressions of plan nodes, so the executor
+// can run Plan when it evaluates the expression.
+type Subquery struct {
+	Kind SubqueryKind
+	Plan PlanNode
+	Left ast.Expression // operand of IN
+	Not  bool           // NOT IN
+	// OuterRefs are the columns of enclosing queries the subquery reads,
+	// as written. An uncorrelated subquery has none and runs only once.
+	OuterRefs []string
+	Type      Type
+	Expr      ast.Expression // the expression the subquery replaces
+}
+
+func (s *Subquery) ExpressionNode()      {}
+func (s *Subquery) TokenLiteral() string { return s.Expr.TokenLiteral() }
+func (s *Subquery) String() string       { return s.Expr.String() }
+
+// plannedQuery is a query's plan with its result columns and, for a
+// subquery, its outer references.
+type plannedQuery struct {
+	plan      PlanNode
+	names     []string
+	types     []Type
+	outerRefs []string
+}
+
+// planSubquery plans a subquery of the query whose scope is outer.
+func (p *Planner) planSubquery(sel *ast.SelectStatement, outer *scope) (*plannedQuery, error) {
+	if q, ok := p.subqueries[sel]; ok {
+		return q, nil
+	}
+	refs := []string{}
+	q, err := p.planQuery(sel, outer, &refs)
+	if err != nil {
+		return nil, err
+	}
+	q.outerRefs = refs
+	p.subqueries[sel] = q
+	return q, nil
+}
+
+// binder replaces the subqueries in expressions with their plans. It keeps
+// the first error, so a sequence of calls can be checked once.
+type binder struct {
+	p   *Planner
+	sc  *scope
+	err error
+}
+
+func (b *binder) expr(expr ast.Expression) ast.Expression {
+	if expr == nil || b.err != nil {
+		return expr
+	}
+	return ast.Rewrite(expr, func(e ast.Expression) ast.Expression {
+		if b.err != nil {
+			return e
+		}
+		switch n := e.(type) {
+		case *ast.SubqueryExpression:
+			return b.subquery(ScalarSubquery, n.Select, n)
+		case *ast.ExistsExpression:
+			return b.subquery(ExistsSubquery, n.Select, n)
+		case *ast.InExpression:
+			if n.Select != nil {
+				sq := b.subquery(InSubquery, n.Select, n)
+				sq.Left, sq.Not = b.expr(n.Left), n.Not
+				return sq
+			}
+		case *ast.CaseExpression:
+			return b.caseExpr(n)
+		}
+		return nil
+	})
+}
+
+func (b *binder) list(exprs []ast.Expression) []ast.Expression {
+	if exprs == nil {
+		return nil
+	}
+	out := make([]ast.Expression, len(exprs))
+	for i, e := range exprs {
+		out[i] = b.expr(e)
+	}
+	return out
+}
+
+func (b *binder) where(where *ast.WhereClause) *ast.WhereClause {
+	if where == nil {
+		return nil
+	}
+	return &ast.WhereClause{Token: where.Token, Condition: b.expr(where.Condition)}
+}
+
+// caseExpr binds the branches of a CASE and records the type they unify to.
+func (b *binder) caseExpr(n *ast.CaseExpression) *Case {
+	t, err := b.p.typeOf(n, b.sc)
+	if err != nil {
+		b.err = err
+	}
+	c := *n
+	c.Operand, c.Else = b.expr(n.Operand), b.expr(n.Else)
+	c.Whens = make([]ast.WhenClause, len(n.Whens))
+	for i, w := range n.Whens {
+		c.Whens[i] = ast.WhenClause{Condition: b.expr(w.Condition), Result: b.expr(w.Result)}
+	}
+	return &Case{Expr: &c, Type: t}
+}
+
+func (b *binder) subquery(kind SubqueryKind, sel *ast.SelectStatement, expr ast.Expression) *Subquery {
+	sq := &Subquery{Kind: kind, Type: TypeBool, Expr: expr}
+	q, err := b.p.planSubquery(sel, b.sc)
+	if err != nil {
+		b.err = err
+		return sq
+	}
+	sq.Plan, sq.OuterRefs = q.plan, q.outerRefs
+	if kind == ScalarSubquery {
+		sq.Type = q.types[0]
+	}
+	return sq
+}
+
+// decorrelate turns the EXISTS, NOT EXISTS, IN and NOT IN subqueries among
+// the top-level conjuncts of s's WHERE into semi and anti joins on node, so
+// the subquery runs once instead of once per row. It returns the new plan
+// and the conjuncts that are left to filter on.
+func (p *Planner) decorrelate(node PlanNode, s *ast.SelectStatement, scans []*ScanNode, sc *scope) (PlanNode, ast.Expression, error) {
+	var rest []ast.Expression
+	for _, c := range conjuncts(s.Where.Condition) {
+		joined, err := p.semiJoin(node, c, s, scans, sc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if joined == nil {
+			rest = append(rest, c)
+			continue
+		}
+		node = joined
+	}
+	return node, andAll(rest), nil
+}
+
+// semiJoin plans cond as a semi or anti join on left, or returns nil when
+// cond is not a subquery test or the subquery is not simple enough: it may
+// only filter and join tables, its tables must not shadow the outer
+// query's, and every column must mean the same thing once both queries'
+// tables are in one scope.
+func (p *Planner) semiJoin(left PlanNode, cond ast.Expression, s *ast.SelectStatement, scans []*ScanNode, sc *scope) (PlanNode, error) {
+	var sub *ast.SelectStatement
+	var in ast.Expression
+	kind := ast.SemiJoin
+	switch n := cond.(type) {
+	case *ast.ExistsExpression:
+		sub = n.Select
+	case *ast.PrefixExpression:
+		exists, ok := n.Right.(*ast.ExistsExpression)
+		if !ok || n.Operator != "NOT" {
+			return nil, nil
+		}
+		sub, kind = exists.Select, ast.AntiJoin
+	case *ast.InExpression:
+		if n.Select == nil {
+			return nil, nil
+		}
+		sub, in = n.Select, n.Left
+		if n.Not {
+			kind = ast.AntiJoin
+		}
+	default:
+		return nil, nil
+	}
+	if !isSimpleSubquery(sub, in != nil) {
+		return nil, nil
+	}
+
+	right, innerScans, err := p.planFrom(sub, nil, nil)
+	if err != nil {
+		return nil, nil // ON conditions reading the outer query
+	}
+	inner, err := p.tableScope(innerScans...)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range inner.tables {
+		for _, outer := range sc.tables {
+			if t == outer {
+				return nil, nil
+			}
+		}
+	}
+	combined, err := p.tableScope(append(append([]*ScanNode{}, scans...), innerScans...)...)
+	if err != nil {
+		return nil, err
+	}
+	combined.correlate(sc.outer, sc.outerRefs)
+	// Resolving in inner, falling back to the outer query, is how the
+	// subquery itself would see its columns.
+	inner.correlate(sc, &[]string{})
+
+	var local, joinConds []ast.Expression
+	if in != nil {
+		// The operand belongs to the outer query and the column to the
+		// subquery, whatever else their names could mean in combined.
+		in = qualify(in, sc)
+		col := qualify(ast.Unalias(sub.Columns[0]), inner, sc)
+		if !sameColumns(in, sc, combined) || !sameColumns(col, inner, combined) {
+			return nil, nil
+		}
+		lt, err := p.typeOf(in, sc)
+		if err != nil {
+			return nil, err
+		}
+		rt, err := p.typeOf(col, inner)
+		if err != nil {
+			return nil, err
+		}
+		if !hashComparable(lt, rt) {
+			return nil, nil // reported when the subquery is planned
+		}
+		// NOT IN is NULL, not TRUE, when either side has a NULL, which an
+		// anti join cannot express.
+		if kind == ast.AntiJoin && (!notNullColumn(in, sc, s) || !notNullColumn(col, inner, sub)) {
+			return nil, nil
+		}
+		joinConds = append(joinConds, &ast.InfixExpression{
+			Token:    lexer.Token{Type: lexer.EQ, Value: "="},
+			Left:     in,
+			Operator: "=",
+			Right:    col,
+		})
+	}
+	for _, col := range sub.Columns {
+		if _, star := col.(*ast.Star); !star {
+			if _, err := p.typeOf(col, inner); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if sub.Where != nil {
+		for _, c := range conjuncts(sub.Where.Condition) {
+			switch {
+			case readsOnly(c, inner):
+				local = append(local, c)
+			case sameColumns(qualify(c, inner, sc), inner, combined):
+				joinConds = append(joinConds, qualify(c, inner, sc))
+			default:
+				return nil, nil
+			}
+		}
+	}
+
+	if len(local) > 0 {
+		where := andAll(local)
+		if err := p.checkCondition("WHERE", where, inner); err != nil {
+			return nil, err
+		}
+		right = &FilterNode{Child: right, Condition: where}
+	}
+	join := &JoinNode{Left: left, Right: right, Kind: kind, Condition: andAll(joinConds)}
+	if join.Condition != nil {
+		if err := p.checkCondition("WHERE", join.Condition, combined); err != nil {
+			return nil, err
+		}
+		join.LeftKeys, join.RightKeys = p.equiJoinKeys(join.Condition, sc, combined)
+	}
+	join.Method = p.chooseJoinMethod(join)
+	return join, nil
+}
+
+// isSimpleSubquery reports whether sub only filters and joins tables: no
+// grouping, aggregates, DISTINCT ON or nested subqueries. An IN subquery
+// must also return a single column.
+func isSimpleSubquery(sub *ast.SelectStatement, in bool) bool {
+	if len(sub.GroupBy) > 0 || sub.Having != nil || len(sub.DistinctOn) > 0 || sub.Limit != nil || sub.Offset != nil {
+		return false
+	}
+	if in && (len(sub.Columns) != 1 || isSelectStar(sub.Columns)) {
+		return false
+	}
+	exprs := append([]ast.Expression{}, sub.Columns...)
+	for _, j := range sub.Joins {
+		exprs = append(exprs, j.On)
+	}
+	if sub.Where != nil {
+		exprs = append(exprs, sub.Where.Condition)
+	}
+	for _, e := range exprs {
+		if containsAggregate(e) || containsWindow(e) || containsSubquery(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsSubquery(expr ast.Expression) bool {
+	found := false
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		switch n := e.(type) {
+		case *ast.SubqueryExpression, *ast.ExistsExpression:
+			found = true
+		case *ast.InExpression:
+			found = found || n.Select != nil
+		}
+		return !found
+	})
+	return found
+}
+
+// columnNames lists the column names expr reads.
+func columnNames(expr ast.Expression) []string {
+	var names []string
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		if id, ok := e.(*ast.Identifier); ok {
+			names = append(names, id.Value)
+		}
+		return true
+	})
+	return names
+}
+
+// readsOnly reports whether every column expr reads belongs to sc itself.
+func readsOnly(expr ast.Expression, sc *scope) bool {
+	for _, name := range columnNames(expr) {
+		if _, found, err := sc.resolveLocal(name); err != nil || !found {
+			return false
+		}
+	}
+	return true
+}
+
+// qualify rewrites each column reference in expr to table.column, taking
+// the table from the first of scopes the name resolves in locally, so that
+// it keeps its meaning once the scopes are combined. Names that resolve in
+// none of them, or ambiguously, are left alone.
+func qualify(expr ast.Expression, scopes ...*scope) ast.Expression {
+	return ast.Rewrite(expr, func(e ast.Expression) ast.Expression {
+		id, ok := e.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		for _, sc := range scopes {
+			c, found, err := sc.resolveLocal(id.Value)
+			if err != nil {
+				return nil
+			}
+			if found {
+				return &ast.Identifier{Token: id.Token, Value: c.table + "." + c.name}
+			}
+		}
+		return nil
+	})
+}
+
+// sameColumns reports whether every column expr reads resolves to the same
+// column in from as in to.
+func sameColumns(expr ast.Expression, from, to *scope) bool {
+	for _, name := range columnNames(expr) {
+		a, err := from.resolve(name)
+		if err != nil {
+			return false
+		}
+		b, err := to.resolve(name)
+		if err != nil || a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// notNullColumn reports whether expr is a NOT NULL column that no outer
+// join of s can pad with NULLs.
+func notNullColumn(expr ast.Expression, sc *scope, s *ast.SelectStatement) bool {
+	id, ok := expr.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	col, found, err := sc.resolveLocal(id.Value)
+	if err != nil || !found || col.nullable {
+		return false
+	}
+	for _, j := range s.Joins {
+		if j.Kind != ast.InnerJoin && j.Kind != ast.CrossJoin {
+			return false
+		}
+	}
+	return true
+}
+
+// andAll joins conditions with AND; it returns nil for none.
+func andAll(exprs []ast.Expression) ast.Expression {
+	if len(exprs) == 0 {
+		return nil
+	}
+	result := exprs[0]
+	for _, e := range exprs[1:] {
+		result = &ast.InfixExpression{
+			Token:    lexer.Token{Type: lexer.AND_TOKEN, Value: "AND"},
+			Left:     result,
+			Operator: "AND",
+			Right:    e,
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+	"github.com/Mohammad-y-abbass/moDB/internal/lexer"
+	"github.com/Mohammad-y-abbass/moDB/internal/parser"
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+func TestDecorrelate(t *testing.T) {
+	catalog := &fakeCatalog{
+		schemas: map[string]*storage.Schema{
+			"emp": storage.NewSchema([]storage.Column{
+				{Name: "id", Type: storage.TypeInt32},
+				{Name: "dept", Type: storage.TypeFixedText, Size: 32, IsNullable: true},
+			}),
+			"dept": storage.NewSchema([]storage.Column{
+				{Name: "name", Type: storage.TypeFixedText, Size: 32},
+				{Name: "head", Type: storage.TypeInt32, IsNullable: true},
+			}),
+		},
+		rows: map[string]int{"emp": 10, "dept": 10},
+	}
+
+	tests := []struct {
+		sql  string
+		kind ast.JoinKind // zero when the subquery stays in the filter
+	}{
+		{"SELECT id FROM emp WHERE dept IN (SELECT name FROM dept)", ast.SemiJoin},
+		{"SELECT id FROM emp WHERE id NOT IN (SELECT head FROM dept)", 0},
+		{"SELECT head FROM dept WHERE name NOT IN (SELECT dept FROM emp)", 0},
+		{"SELECT id FROM emp WHERE dept NOT IN (SELECT name FROM dept)", 0},
+		{"SELECT id FROM emp e WHERE e.id NOT IN (SELECT x.id FROM emp x WHERE x.dept = e.dept)", ast.AntiJoin},
+		// Unqualified names keep the meaning they have in their own query.
+		{"SELECT id FROM emp e WHERE id NOT IN (SELECT id FROM emp x WHERE x.dept = e.dept)", ast.AntiJoin},
+		{"SELECT id FROM emp e WHERE EXISTS (SELECT id FROM emp x WHERE dept = e.dept AND id > e.id)", ast.SemiJoin},
+		{"SELECT id FROM emp e WHERE EXISTS (SELECT name FROM dept d WHERE d.head = e.id)", ast.SemiJoin},
+		{"SELECT id FROM emp e WHERE NOT EXISTS (SELECT name FROM dept d WHERE d.head = e.id) AND id > 1", ast.AntiJoin},
+		{"SELECT id FROM emp e WHERE EXISTS (SELECT name FROM dept d WHERE d.head = e.id) OR id > 1", 0},
+		{"SELECT id FROM emp e WHERE EXISTS (SELECT MAX(head) FROM dept d WHERE d.head = e.id)", 0},
+	}
+	for _, tt := range tests {
+		stmt := parser.New(lexer.New(tt.sql)).ParseProgram().Statements[0].(*ast.SelectStatement)
+		plan, err := New(catalog).GeneratePlan(stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		join := findJoin(plan)
+		switch {
+		case tt.kind == 0 && join != nil:
+			t.Errorf("%s: expected no join, got %s", tt.sql, join.Kind)
+		case tt.kind != 0 && (join == nil || join.Kind != tt.kind):
+			t.Errorf("%s: expected a %s", tt.sql, tt.kind)
+		}
+	}
+}
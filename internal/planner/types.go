@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/storage"
+)
+
+// Type is the static type of an expression, worked out at plan time.
+type Type int
+
+const (
+	TypeUnknown Type = iota // NULL literal; compatible with every type
+	TypeInt
+	TypeFloat
+	TypeText
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeInt:
+		return "INT"
+	case TypeFloat:
+		return "FLOAT"
+	case TypeText:
+		return "TEXT"
+	case TypeBool:
+		return "BOOLEAN"
+	}
+	return "UNKNOWN"
+}
+
+// IsNumeric reports whether values of t can take part in arithmetic.
+func (t Type) IsNumeric() bool {
+	return t == TypeInt || t == TypeFloat || t == TypeUnknown
+}
+
+// ColumnType maps a stored column to its expression type.
+func ColumnType(c storage.Column) Type {
+	if c.Type == storage.TypeFixedText {
+		return TypeText
+	}
+	return TypeInt
+}
+
+// ParseTypeName maps a type name used in CAST to its Type.
+func ParseTypeName(name string) (Type, error) {
+	switch name {
+	case "INT", "INTEGER":
+		return TypeInt, nil
+	case "FLOAT", "REAL", "DOUBLE":
+		return TypeFloat, nil
+	case "TEXT", "VARCHAR":
+		return TypeText, nil
+	case "BOOL", "BOOLEAN":
+		return TypeBool, nil
+	}
+	return TypeUnknown, fmt.Errorf("unknown type: %s", name)
+}
+
+// UnifyTypes returns the type that values of all the given types can be
+// converted to: INT and FLOAT unify to FLOAT, UNKNOWN (NULL) unifies with
+// anything, and any other mix is an error.
+func UnifyTypes(types ...Type) (Type, error) {
+	result := TypeUnknown
+	for _, t := range types {
+		switch {
+		case t == TypeUnknown || t == result:
+		case result == TypeUnknown:
+			result = t
+		case t.IsNumeric() && result.IsNumeric():
+			result = TypeFloat
+		default:
+			return TypeUnknown, fmt.Errorf("types %s and %s cannot be matched", result, t)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,189 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// windowFunctions lists the functions that may only be called with OVER.
+// Aggregates may be called either way.
+var windowFunctions = map[string]bool{
+	"ROW_NUMBER":  true,
+	"RANK":        true,
+	"DENSE_RANK":  true,
+	"LAG":         true,
+	"LEAD":        true,
+	"FIRST_VALUE": true,
+}
+
+// WindowNode computes window function calls over the rows of Child. Every
+// row is kept, in its input order, and gains one column per call named
+// after the call's SQL text, the way AggregateNode names its aggregates.
+type WindowNode struct {
+	Child   PlanNode
+	Windows []*ast.FunctionCall
+}
+
+func (n *WindowNode) PlanNode() {}
+
+// isWindowCall reports whether e is a function call with an OVER clause.
+func isWindowCall(e ast.Expression) bool {
+	fc, ok := e.(*ast.FunctionCall)
+	return ok && fc.Over != nil
+}
+
+// containsWindow reports whether expr calls a window function anywhere.
+func containsWindow(expr ast.Expression) bool {
+	found := false
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		found = found || isWindowCall(e)
+		return !found
+	})
+	return found
+}
+
+// collectWindows returns the distinct window function calls in exprs, in
+// order of first appearance.
+func collectWindows(exprs ...ast.Expression) ([]*ast.FunctionCall, error) {
+	var windows []*ast.FunctionCall
+	seen := make(map[string]bool)
+	var err error
+
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(e ast.Expression) bool {
+			if !isWindowCall(e) || err != nil {
+				return err == nil
+			}
+			fc := e.(*ast.FunctionCall)
+			ast.Inspect(fc, func(inner ast.Expression) bool {
+				if inner != e && isWindowCall(inner) {
+					err = fmt.Errorf("window function calls cannot be nested: %s", fc.String())
+				}
+				return err == nil
+			})
+			if err != nil {
+				return false
+			}
+			if !seen[fc.String()] {
+				seen[fc.String()] = true
+				windows = append(windows, fc)
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return windows, nil
+}
+
+// typeOfWindow type-checks a window function call and its OVER clause.
+func (p *Planner) typeOfWindow(n *ast.FunctionCall, sc *scope) (Type, error) {
+	if err := p.checkWindowSpec(n.Over, sc); err != nil {
+		return TypeUnknown, err
+	}
+	if n.Distinct {
+		return TypeUnknown, fmt.Errorf("DISTINCT is not supported in window functions: %s", n.String())
+	}
+	if IsAggregate(n.Name) {
+		if err := checkAggregateCall(n); err != nil {
+			return TypeUnknown, err
+		}
+		return p.typeOfAggregate(n, sc)
+	}
+
+	switch n.Name {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		if len(n.Args) != 0 {
+			return TypeUnknown, fmt.Errorf("%s expects 0 argument(s), got %d", n.Name, len(n.Args))
+		}
+		return TypeInt, nil
+	case "FIRST_VALUE":
+		if len(n.Args) != 1 {
+			return TypeUnknown, fmt.Errorf("%s expects 1 argument(s), got %d", n.Name, len(n.Args))
+		}
+		return p.typeOf(n.Args[0], sc)
+	case "LAG", "LEAD":
+		if len(n.Args) < 1 || len(n.Args) > 3 {
+			return TypeUnknown, fmt.Errorf("%s expects 1 to 3 arguments, got %d", n.Name, len(n.Args))
+		}
+		types := make([]Type, len(n.Args))
+		for i, a := range n.Args {
+			t, err := p.typeOf(a, sc)
+			if err != nil {
+				return TypeUnknown, err
+			}
+			types[i] = t
+		}
+		if len(types) > 1 && types[1] != TypeInt && types[1] != TypeUnknown {
+			return TypeUnknown, fmt.Errorf("%s offset must be an integer, got %s", n.Name, types[1])
+		}
+		if len(types) > 2 {
+			t, err := UnifyTypes(types[0], types[2])
+			if err != nil {
+				return TypeUnknown, fmt.Errorf("%s default: %v", n.Name, err)
+			}
+			return t, nil
+		}
+		return types[0], nil
+	}
+	return TypeUnknown, fmt.Errorf("%s is not a window function", n.Name)
+}
+
+// checkWindowSpec type-checks the keys and frame of an OVER clause. Frame
+// offsets must be constants: integers for ROWS, and for RANGE numbers
+// measured along the single, numeric ORDER BY key.
+func (p *Planner) checkWindowSpec(w *ast.WindowSpec, sc *scope) error {
+	for _, e := range w.PartitionBy {
+		if _, err := p.typeOf(e, sc); err != nil {
+			return err
+		}
+	}
+	orderTypes := make([]Type, len(w.OrderBy))
+	for i, item := range w.OrderBy {
+		t, err := p.typeOf(item.Expr, sc)
+		if err != nil {
+			return err
+		}
+		orderTypes[i] = t
+	}
+
+	f := w.Frame
+	if f == nil {
+		return nil
+	}
+	switch {
+	case f.Start.Kind == ast.UnboundedFollowing:
+		return fmt.Errorf("frame start cannot be UNBOUNDED FOLLOWING")
+	case f.End.Kind == ast.UnboundedPreceding:
+		return fmt.Errorf("frame end cannot be UNBOUNDED PRECEDING")
+	case f.Start.Kind > f.End.Kind:
+		return fmt.Errorf("frame starting from %s cannot end at %s", f.Start, f.End)
+	}
+	for _, b := range []ast.FrameBound{f.Start, f.End} {
+		if b.Offset == nil {
+			continue
+		}
+		t, err := p.typeOf(b.Offset, &scope{})
+		if err != nil {
+			return fmt.Errorf("frame offset must not read columns: %v", err)
+		}
+		if f.Unit == ast.RowsFrame {
+			if t != TypeInt && t != TypeUnknown {
+				return fmt.Errorf("ROWS frame offset must be an integer, got %s", t)
+			}
+			continue
+		}
+		if len(orderTypes) != 1 {
+			return fmt.Errorf("RANGE with offset PRECEDING/FOLLOWING requires exactly one ORDER BY column")
+		}
+		if !orderTypes[0].IsNumeric() && orderTypes[0] != TypeUnknown {
+			return fmt.Errorf("RANGE with offset PRECEDING/FOLLOWING is not supported for ORDER BY type %s", orderTypes[0])
+		}
+		if !t.IsNumeric() && t != TypeUnknown {
+			return fmt.Errorf("RANGE frame offset must be a number, got %s", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/ast"
+)
+
+// CTE is a planned common table expression. The executor computes its
+// result once per run of the WithNode that defines it, and every scan of
+// the CTE reads that result.
+type CTE struct {
+	Name    string
+	Columns []string
+	Types   []Type
+	Plan    PlanNode // the CTE's query, or the seed of a recursive one
+	// Recursive is the last SELECT of a recursive CTE's UNION, or nil.
+	// It is run until it returns no new rows, each run reading only the
+	// rows the previous one added; UnionAll keeps duplicate rows.
+	Recursive PlanNode
+	UnionAll  bool
+}
+
+// WithNode computes its CTEs, in order, before running Child.
+type WithNode struct {
+	CTEs  []*CTE
+	Child PlanNode
+}
+
+func (n *WithNode) PlanNode() {}
+
+// recursiveTerm is the second UNION term of a WITH RECURSIVE entry being
+// planned. The CTE may be read at most once, in the term's own FROM clause.
+type recursiveTerm struct {
+	cte  *CTE
+	term *ast.SelectStatement
+	refs int
+}
+
+// planWith plans the CTEs of a WITH clause and then, with them in scope as
+// tables, the query they belong to.
+func (p *Planner) planWith(with *ast.WithClause, plan func() (*plannedQuery, error)) (*plannedQuery, error) {
+	defer func(n int) { p.ctes = p.ctes[:n] }(len(p.ctes))
+
+	node := &WithNode{}
+	for i, def := range with.CTEs {
+		for _, prev := range with.CTEs[:i] {
+			if prev.Name == def.Name {
+				return nil, fmt.Errorf("WITH query name %s specified more than once", def.Name)
+			}
+		}
+		cte, err := p.planCTE(def, with.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		node.CTEs = append(node.CTEs, cte)
+	}
+
+	q, err := plan()
+	if err != nil {
+		return nil, err
+	}
+	node.Child = q.plan
+	q.plan = node
+	return q, nil
+}
+
+// planCTE plans one WITH entry and brings it into scope. In a WITH
+// RECURSIVE clause, a CTE whose query is a UNION [ALL] ending in a plain
+// SELECT is in scope for that SELECT, and is recursive if it reads it.
+func (p *Planner) planCTE(def *ast.CommonTableExpression, recursive bool) (*CTE, error) {
+	cte := &CTE{Name: def.Name}
+	union, ok := def.Query.(*ast.SetOperation)
+	var term *ast.SelectStatement
+	if ok && recursive && union.Op == ast.Union && union.With == nil &&
+		len(union.OrderBy) == 0 && union.Limit == nil && union.Offset == nil {
+		term, _ = union.Right.(*ast.SelectStatement)
+	}
+	if term == nil {
+		q, err := p.planAnyQuery(def.Query)
+		if err != nil {
+			return nil, err
+		}
+		if err := cte.setColumns(def, q); err != nil {
+			return nil, err
+		}
+		cte.Plan = q.plan
+		p.ctes = append(p.ctes, cte)
+		return cte, nil
+	}
+
+	anchor, err := p.planAnyQuery(union.Left)
+	if err != nil {
+		return nil, err
+	}
+	if err := cte.setColumns(def, anchor); err != nil {
+		return nil, err
+	}
+	p.ctes = append(p.ctes, cte)
+	p.recursive = &recursiveTerm{cte: cte, term: term}
+	second, err := p.planQuery(term, nil, nil)
+	refs := p.recursive.refs
+	p.recursive = nil
+	if err != nil {
+		return nil, err
+	}
+	combined, err := p.combine(union, anchor, second)
+	if err != nil {
+		return nil, err
+	}
+	cte.Types, cte.UnionAll = combined.types, union.All
+	if refs == 0 {
+		cte.Plan = combined.plan
+	} else {
+		cte.Plan, cte.Recursive = anchor.plan, second.plan
+	}
+	return cte, nil
+}
+
+// setColumns names and types the CTE's columns after its query's, or after
+// its own column list.
+func (c *CTE) setColumns(def *ast.CommonTableExpression, q *plannedQuery) error {
+	c.Columns, c.Types = q.names, q.types
+	if len(def.Columns) == 0 {
+		return nil
+	}
+	if len(def.Columns) != len(q.names) {
+		return fmt.Errorf("WITH query %s has %d columns available but %d columns specified",
+			def.Name, len(q.names), len(def.Columns))
+	}
+	c.Columns = def.Columns
+	return nil
+}
+
+// scan plans a read of the table or CTE name in the FROM clause of s. CTEs
+// shadow tables, and later ones shadow earlier ones.
+func (p *Planner) scan(s *ast.SelectStatement, name, alias string) (*ScanNode, error) {
+	node := &ScanNode{TableName: name, Alias: alias}
+	for i := len(p.ctes) - 1; i >= 0; i-- {
+		if p.ctes[i].Name != name {
+			continue
+		}
+		node.CTE = p.ctes[i]
+		if r := p.recursive; r != nil && r.cte == node.CTE {
+			if r.term != s {
+				return nil, fmt.Errorf("recursive reference to query %s must not appear within a subquery", name)
+			}
+			if r.refs++; r.refs > 1 {
+				return nil, fmt.Errorf("recursive reference to query %s must not appear more than once", name)
+			}
+		}
+		break
+	}
+	return node, nil
+}
+
+// outputName is the name a result column is known by to an enclosing
+// query: its alias, or the column name of a column reference.
+func outputName(col ast.Expression) string {
+	switch c := col.(type) {
+	case *ast.AliasExpression:
+		return c.Alias
+	case *ast.Identifier:
+		return c.Value[strings.LastIndex(c.Value, ".")+1:]
+	}
+	return col.String()
+}
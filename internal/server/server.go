@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 
 	"github.com/Mohammad-y-abbass/moDB/internal/executor"
@@ -13,23 +14,28 @@ import (
 	"github.com/Mohammad-y-abbass/moDB/internal/storage"
 )
 
-var (
-	exec *executor.Executor
-	plan *planner.Planner
-)
+var exec *executor.Executor
 
 func Start() {
 	// Initialize Storage Engine
 	engine := storage.NewEngine("./data")
 
 	exec = executor.New(engine)
+	// COPY FROM reads from MODB_IMPORT_DIR, or ./imports by default, and
+	// COPY TO writes under MODB_EXPORT_DIR, or ./exports by default.
+	exec.ImportDir = os.Getenv("MODB_IMPORT_DIR")
+	if exec.ImportDir == "" {
+		exec.ImportDir = "./imports"
+	}
+	exec.ExportDir = os.Getenv("MODB_EXPORT_DIR")
+	if exec.ExportDir == "" {
+		exec.ExportDir = "./exports"
+	}
 	err := exec.ReloadTables()
 	if err != nil {
 		fmt.Println("Warning: Could not reload tables:", err)
 	}
 
-	plan = planner.New()
-
 	listener, err := net.Listen("tcp", ":3003")
 	if err != nil {
 		fmt.Println("Could not start server: ", err)
@@ -59,6 +65,11 @@ func handleConnection(conn net.Conn) {
 	defer conn.Close()
 	fmt.Printf("--- New connection from %s ---\n", conn.RemoteAddr())
 
+	// Each connection is a session with its own prepared statements, and
+	// its own planner since planning keeps per-statement state.
+	session := exec.NewSession()
+	plan := planner.New(session)
+
 	scanner := bufio.NewScanner(conn)
 	var queryBuffer strings.Builder
 
@@ -90,13 +101,19 @@ func handleConnection(conn net.Conn) {
 			program := p.ParseProgram()
 
 			if len(p.Errors()) > 0 {
-				errorMsg := p.GetErrorMessage()
-				fmt.Printf("%sParsing error:%s %s\n", colorRed, colorReset, errorMsg)
+				errorMsg := p.Diagnostics()
+				fmt.Printf("%s%s%s\n", colorRed, errorMsg, colorReset)
 				conn.Write([]byte(colorRed + errorMsg + colorReset + "\n"))
 			} else {
 				for _, stmt := range program.Statements {
-					pNode := plan.GeneratePlan(stmt)
-					results, err := exec.Execute(pNode)
+					pNode, err := plan.GeneratePlan(stmt)
+					if err != nil {
+						errMsg := "Planning error: " + err.Error()
+						fmt.Printf("%s%s%s\n", colorRed, errMsg, colorReset)
+						conn.Write([]byte(colorRed + errMsg + colorReset + "\n"))
+						continue
+					}
+					results, err := session.Execute(pNode)
 					if err != nil {
 						errMsg := "Execution error: " + err.Error()
 						fmt.Printf("%s%s%s\n", colorRed, errMsg, colorReset)
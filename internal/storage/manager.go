@@ -3,69 +3,96 @@ package storage
 import (
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 )
 
 type Table struct {
 	Pager  *Pager
 	Schema *Schema
+	// rows is the number of live rows, or -1 until RowCount counts them.
+	// InsertMany and Delete keep it current from then on.
+	rows atomic.Int64
 }
 
 func NewTable(pager *Pager, schema *Schema) *Table {
-	return &Table{
+	t := &Table{
 		Pager:  pager,
 		Schema: schema,
 	}
+	t.rows.Store(-1)
+	return t
 }
 
 // Insert handles the end-to-to workflow: Serialize -> Find Page -> Write
 func (t *Table) Insert(values []interface{}) error {
+	return t.InsertMany([][]interface{}{values})
+}
+
+// InsertMany appends a batch of rows, filling the last page and then new
+// ones. Every row is serialized before any page is touched, so a bad value
+// leaves the table unchanged, and each page is written once, when it is
+// full or the batch ends.
+func (t *Table) InsertMany(rows [][]interface{}) (err error) {
 	// 1. Convert Go values to the fixed-length byte format
-	row := Row{Values: values}
-	rowData, err := t.Schema.Serialize(row)
-	if err != nil {
-		return err
+	data := make([][]byte, len(rows))
+	for i, values := range rows {
+		rowData, err := t.Schema.Serialize(Row{Values: values})
+		if err != nil {
+			return err
+		}
+		data[i] = rowData
+	}
+	if len(data) == 0 {
+		return nil
 	}
+	// A failed write may leave part of the batch stored, so the rows are
+	// counted again on the next RowCount.
+	defer func() {
+		if err != nil {
+			t.rows.Store(-1)
+		} else if t.rows.Load() >= 0 {
+			t.rows.Add(int64(len(data)))
+		}
+	}()
 
-	// 2. Find a page with enough space
-	// For now, we'll just try the very last page in the file
+	// 2. Start on the last page of the file, or a fresh first page
 	totalPages := t.Pager.TotalPages()
-	var targetPageID uint32
-
-	if totalPages == 0 {
-		targetPageID = 0
-	} else {
-		targetPageID = totalPages - 1
+	var pageID uint32
+	if totalPages > 0 {
+		pageID = totalPages - 1
 	}
-
-	// 3. Load the page and wrap it in our SlottedPage logic
-	pageData, err := t.Pager.ReadPage(targetPageID)
+	pageData, err := t.Pager.ReadPage(pageID)
 	if err != nil {
 		return err
 	}
 	page := NewSlottedPage(pageData)
-
-	// If it's a brand new page (all zeros), initialize the header
 	if totalPages == 0 {
 		page.InitHeader()
 	}
 
-	// 4. Try to insert into this page
-	_, err = page.Insert(rowData)
-	if err != nil {
-		// If page is full, create a NEW page
-		targetPageID = totalPages
-		newPageData := make([]byte, PAGE_SIZE)
-		page = NewSlottedPage(newPageData)
+	// 3. Fill pages in memory, writing each one out as it fills up
+	dirty := false
+	for _, rowData := range data {
+		if _, err := page.Insert(rowData); err == nil {
+			dirty = true
+			continue
+		}
+		if dirty {
+			if err := t.Pager.WritePage(pageID, page.data); err != nil {
+				return err
+			}
+		}
+		pageID++
+		page = NewSlottedPage(make([]byte, PAGE_SIZE))
 		page.InitHeader()
-
-		_, err = page.Insert(rowData)
-		if err != nil {
+		if _, err := page.Insert(rowData); err != nil {
 			return fmt.Errorf("failed to insert even into new page: %w", err)
 		}
+		dirty = true
 	}
 
-	// 5. Commit the changes back to the physical disk
-	return t.Pager.WritePage(targetPageID, page.data)
+	// 4. Commit the last page back to the physical disk
+	return t.Pager.WritePage(pageID, page.data)
 }
 
 // SelectAll is a "Full Table Scan" - the simplest way to read data
@@ -130,11 +157,44 @@ func (t *Table) Delete(pageID uint32, slotID uint16) error {
 		return err
 	}
 	page := NewSlottedPage(pageData)
+	live := len(page.GetRow(slotID)) > 0
 
 	err = page.Delete(slotID)
 	if err != nil {
 		return err
 	}
 
-	return t.Pager.WritePage(pageID, page.data)
+	if err := t.Pager.WritePage(pageID, page.data); err != nil {
+		return err
+	}
+	if live && t.rows.Load() >= 0 {
+		t.rows.Add(-1)
+	}
+	return nil
+}
+
+// RowCount returns the number of live rows. The first call counts them in
+// the slot directories of every page; later calls answer from the count
+// that inserts and deletes keep.
+func (t *Table) RowCount() (int, error) {
+	if n := t.rows.Load(); n >= 0 {
+		return int(n), nil
+	}
+	var n int64
+	totalPages := t.Pager.TotalPages()
+	for i := uint32(0); i < totalPages; i++ {
+		pageData, err := t.Pager.ReadPage(i)
+		if err != nil {
+			return 0, err
+		}
+		page := NewSlottedPage(pageData)
+		numSlots := binary.LittleEndian.Uint16(page.data[0:2])
+		for slotID := uint16(0); slotID < numSlots; slotID++ {
+			if len(page.GetRow(slotID)) > 0 {
+				n++
+			}
+		}
+	}
+	t.rows.Store(n)
+	return int(n), nil
 }
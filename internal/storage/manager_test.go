@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInsertMany(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create pager: %v", err)
+	}
+	defer pager.Close()
+	table := NewTable(pager, NewSchema([]Column{
+		{Name: "id", Type: TypeInt32},
+		{Name: "name", Type: TypeFixedText, Size: 32, IsNullable: true},
+	}))
+
+	if err := table.Insert([]interface{}{int32(0), "first"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	perPage := (PAGE_SIZE - HeaderSize) / (int(table.Schema.TotalSize) + SlotSize)
+	var batch [][]interface{}
+	for i := 1; i <= 2*perPage; i++ {
+		batch = append(batch, []interface{}{int32(i), nil})
+	}
+	if err := table.InsertMany(batch); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	// The batch topped up the first page and then filled new ones.
+	if got, want := pager.TotalPages(), uint32(3); got != want {
+		t.Errorf("expected %d pages, got %d", want, got)
+	}
+	rows, err := table.SelectAll()
+	if err != nil {
+		t.Fatalf("SelectAll failed: %v", err)
+	}
+	if len(rows) != 2*perPage+1 {
+		t.Fatalf("expected %d rows, got %d", 2*perPage+1, len(rows))
+	}
+	for i, row := range rows {
+		if row.Values[0].(int32) != int32(i) {
+			t.Fatalf("row %d: expected id %d, got %v", i, i, row.Values[0])
+		}
+	}
+
+	// A row that cannot be serialized rejects the whole batch.
+	err = table.InsertMany([][]interface{}{{int32(-1), "ok"}, {"bad", "row"}})
+	if err == nil {
+		t.Fatal("expected an error for a bad row")
+	}
+	if rows, _ := table.SelectAll(); len(rows) != 2*perPage+1 {
+		t.Errorf("expected the table to be unchanged, got %d rows", len(rows))
+	}
+}
+
+func TestRowCount(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create pager: %v", err)
+	}
+	defer pager.Close()
+	schema := NewSchema([]Column{{Name: "id", Type: TypeInt32}})
+	table := NewTable(pager, schema)
+
+	count := func(want int) {
+		t.Helper()
+		if got, err := table.RowCount(); err != nil || got != want {
+			t.Errorf("expected %d rows, got %d (%v)", want, got, err)
+		}
+	}
+	count(0)
+	for i := 0; i < 3; i++ {
+		if err := table.Insert([]interface{}{int32(i)}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	count(3)
+	if err := table.InsertMany([][]interface{}{{int32(3)}, {"bad"}}); err == nil {
+		t.Fatal("expected an error for a bad row")
+	}
+	count(3)
+
+	// Deleting a slot twice removes one row; the count of a reopened
+	// table is read from its pages.
+	for i := 0; i < 2; i++ {
+		if err := table.Delete(0, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	count(2)
+	table = NewTable(pager, schema)
+	count(2)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 )
 
 const (
@@ -11,6 +12,8 @@ const (
 
 type Pager struct {
 	file *os.File
+	// reads counts the pages read so far, for EXPLAIN ANALYZE.
+	reads atomic.Uint64
 }
 
 func NewPager(fileName string) (*Pager, error) {
@@ -24,6 +27,7 @@ func NewPager(fileName string) (*Pager, error) {
 }
 
 func (p *Pager) ReadPage(pageID uint32) ([]byte, error) {
+	p.reads.Add(1)
 	data := make([]byte, PAGE_SIZE)
 	offset := int64(pageID) * int64(PAGE_SIZE)
 
@@ -58,6 +62,11 @@ func (p *Pager) WritePage(pageID uint32, data []byte) error {
 	return nil
 }
 
+// PagesRead returns the number of ReadPage calls made on the pager.
+func (p *Pager) PagesRead() uint64 {
+	return p.reads.Load()
+}
+
 func (p *Pager) TotalPages() uint32 {
 	info, err := p.file.Stat()
 	if err != nil {
@@ -1,7 +1,21 @@
 package main
 
-import "github.com/Mohammad-y-abbass/moDB/internal/server"
+import (
+	"os"
+
+	"github.com/Mohammad-y-abbass/moDB/internal/server"
+)
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(formatCommand(os.Args[2:]))
+		case "dump":
+			os.Exit(dumpCommand(os.Args[2:]))
+		case "restore":
+			os.Exit(restoreCommand(os.Args[2:]))
+		}
+	}
 	server.Start()
 }